@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"github.com/celskeggs/mediator/autocoder/dtype"
 	"github.com/celskeggs/mediator/autocoder/gen"
+	"github.com/celskeggs/mediator/autocoder/predefs"
 	"github.com/celskeggs/mediator/dream/ast"
 	"github.com/celskeggs/mediator/dream/path"
+	"github.com/celskeggs/mediator/dream/tokenizer"
 	"github.com/celskeggs/mediator/platform/types"
 	"github.com/celskeggs/mediator/util"
+	"strconv"
 	"strings"
 )
 
@@ -27,6 +30,29 @@ func ConstantPath(expr ast.Expression) path.TypePath {
 	}
 }
 
+// parses an icon size, which is either a single number for square icons, or a string of the form "32x48"
+func ConstantIconSize(expr ast.Expression) (width uint, height uint, err error) {
+	switch expr.Type {
+	case ast.ExprTypeIntegerLiteral:
+		if expr.Integer < 1 {
+			return 0, 0, fmt.Errorf("invalid icon size %d", expr.Integer)
+		}
+		return uint(expr.Integer), uint(expr.Integer), nil
+	case ast.ExprTypeStringLiteral:
+		parts := strings.Split(expr.Str, "x")
+		if len(parts) == 2 {
+			w, errW := strconv.ParseUint(parts[0], 10, 32)
+			h, errH := strconv.ParseUint(parts[1], 10, 32)
+			if errW == nil && errH == nil && w > 0 && h > 0 {
+				return uint(w), uint(h), nil
+			}
+		}
+		return 0, 0, fmt.Errorf("invalid icon size %q", expr.Str)
+	default:
+		return 0, 0, fmt.Errorf("unimplemented: constant icon size from expr %s", expr.String())
+	}
+}
+
 type CodeGenContext struct {
 	WorldRef string
 	Tree     *gen.DefinedTree
@@ -93,12 +119,22 @@ func (ctx CodeGenContext) ResolveNonLocal(name string) (get string, set func(to
 	return "", nil, dtype.None(), false
 }
 
+// whether the expression refers to the world itself, rather than a variable that happens to be named world
+func isWorldExpr(expr ast.Expression, ctx CodeGenContext) bool {
+	if expr.Type != ast.ExprTypeGetNonLocal || expr.Str != "world" {
+		return false
+	}
+	_, _, _, shadowed := ctx.ResolveNonLocal(expr.Str)
+	return !shadowed
+}
+
 type ResourceType int
 
 const (
 	ResourceTypeNone ResourceType = iota
 	ResourceTypeIcon
 	ResourceTypeAudio
+	ResourceTypeImage
 )
 
 func ResourceTypeByName(name string) ResourceType {
@@ -106,6 +142,8 @@ func ResourceTypeByName(name string) ResourceType {
 		return ResourceTypeAudio
 	} else if strings.HasSuffix(name, ".dmi") {
 		return ResourceTypeIcon
+	} else if strings.HasSuffix(name, ".png") || strings.HasSuffix(name, ".jpg") || strings.HasSuffix(name, ".gif") {
+		return ResourceTypeImage
 	} else {
 		return ResourceTypeNone
 	}
@@ -132,6 +170,9 @@ func ExprToGo(expr ast.Expression, ctx CodeGenContext) (exprString string, etype
 		case ResourceTypeAudio:
 			ctx.Tree.AddImport("github.com/celskeggs/mediator/platform/procs")
 			return fmt.Sprintf("procs.NewSound(%q)", expr.Str), dtype.ConstPath("/sound"), nil
+		case ResourceTypeImage:
+			// plain images are only used by browse_rsc(), which only needs to know their names
+			return fmt.Sprintf("types.String(%q)", expr.Str), dtype.String(), nil
 		default:
 			return "", dtype.None(), fmt.Errorf("cannot interpret resource name %q", expr.Str)
 		}
@@ -139,6 +180,11 @@ func ExprToGo(expr ast.Expression, ctx CodeGenContext) (exprString string, etype
 		return fmt.Sprintf("types.Int(%d)", expr.Integer), dtype.Integer(), nil
 	case ast.ExprTypeStringLiteral:
 		return fmt.Sprintf("types.String(%q)", expr.Str), dtype.String(), nil
+	case ast.ExprTypePathLiteral:
+		if !ctx.Tree.Exists(expr.Path) {
+			return "", dtype.None(), fmt.Errorf("no such type %v at %v", expr.Path, expr.SourceLoc)
+		}
+		return fmt.Sprintf("types.TypePath(%q)", expr.Path), dtype.Any(), nil
 	case ast.ExprTypeStringMacro:
 		innerExpr, _, err := ExprToGo(expr.Children[0], ctx)
 		if err != nil {
@@ -181,11 +227,15 @@ func ExprToGo(expr ast.Expression, ctx CodeGenContext) (exprString string, etype
 			if err != nil {
 				return "", dtype.None(), err
 			}
-			if !dt.IsAnyPath() {
+			if dt.IsList() {
+				invokeSrc = evchild
+				found = predefs.ListProcExists(target.Str)
+			} else if !dt.IsAnyPath() {
 				return "", dtype.None(), fmt.Errorf("calling functions on non-datum type %v at %v", dt, expr.SourceLoc)
+			} else {
+				invokeSrc = evchild
+				_, found = ctx.Tree.ResolveProcedure(dt.Path(), target.Str)
 			}
-			invokeSrc = evchild
-			_, found = ctx.Tree.ResolveProcedure(dt.Path(), target.Str)
 		} else {
 			return "", dtype.None(), fmt.Errorf("calling functions like %v is not yet implemented at %v", target, expr.SourceLoc)
 		}
@@ -227,6 +277,7 @@ func ExprToGo(expr ast.Expression, ctx CodeGenContext) (exprString string, etype
 				kwargStr += fmt.Sprintf("%q: %s", name, arg)
 			}
 			kwargStr += "}"
+			ctx.Tree.AddImport("github.com/celskeggs/mediator/platform/procs")
 			return fmt.Sprintf("procs.KWInvoke(%s, %s, %q, %s%s)", ctx.WorldRef, ctx.UsrRef(), target.Str, kwargStr, strings.Join(convArgs, "")), dtype.Any(), nil
 		} else if super {
 			if ctx.DefIndex > 0 {
@@ -244,6 +295,7 @@ func ExprToGo(expr ast.Expression, ctx CodeGenContext) (exprString string, etype
 			}
 			return fmt.Sprintf("varsrc.SuperInvoke(%s, %q, %q%s)", ctx.UsrRef(), ctx.ChunkName(), ctx.ThisProc, strings.Join(convArgs, "")), dtype.Any(), nil
 		} else if invokeSrc == "" {
+			ctx.Tree.AddImport("github.com/celskeggs/mediator/platform/procs")
 			return fmt.Sprintf("procs.Invoke(%s, %s, %q%s)", ctx.WorldRef, ctx.UsrRef(), target.Str, strings.Join(convArgs, "")), dtype.Any(), nil
 		} else {
 			return fmt.Sprintf("(%s).Invoke(%s, %q%s)", invokeSrc, ctx.UsrRef(), target.Str, strings.Join(convArgs, "")), dtype.Any(), nil
@@ -262,12 +314,29 @@ func ExprToGo(expr ast.Expression, ctx CodeGenContext) (exprString string, etype
 			}
 			argStrs = append(argStrs, ", "+argStr)
 		}
+		if expr.Path.Equals(path.ConstTypePath("/icon")) {
+			// icons are values, rather than datums, so they are not created through the realm
+			ctx.Tree.AddImport("github.com/celskeggs/mediator/platform/procs")
+			return fmt.Sprintf("procs.NewIcon(%s%s)", ctx.WorldRef, strings.Join(argStrs, "")), dtype.Path(expr.Path), nil
+		}
+		if expr.Path.Equals(path.ConstTypePath("/matrix")) {
+			// as are matrices
+			ctx.Tree.AddImport("github.com/celskeggs/mediator/platform/procs")
+			args := strings.TrimPrefix(strings.Join(argStrs, ""), ", ")
+			return fmt.Sprintf("procs.NewMatrix(%s)", args), dtype.Path(expr.Path), nil
+		}
 		return fmt.Sprintf("%s.Realm().New(%q, %s%s)", ctx.WorldRef, expr.Path, ctx.UsrRef(), strings.Join(argStrs, "")), dtype.Path(expr.Path), nil
 	case ast.ExprTypeGetNonLocal:
 		getExpr, _, ftype, ok := ctx.ResolveNonLocal(expr.Str)
 		if ok {
 			return getExpr, ftype, nil
 		}
+		if expr.Str == "null" {
+			return "nil", dtype.Any(), nil
+		}
+		if value, found := predefs.PlatformConstant(expr.Str); found {
+			return fmt.Sprintf("types.Int(%d)", value), dtype.Integer(), nil
+		}
 		return "", dtype.None(), fmt.Errorf("cannot find nonlocal %s at %v", expr.Str, expr.SourceLoc)
 	case ast.ExprTypeGetLocal:
 		if expr.Str == "." {
@@ -282,6 +351,16 @@ func ExprToGo(expr ast.Expression, ctx CodeGenContext) (exprString string, etype
 		}
 		ctx.UseVar(expr.Str)
 		return LocalVariablePrefix + expr.Str, vtype, nil
+	case ast.ExprTypeIndex:
+		exprStr, _, err := ExprToGo(expr.Children[0], ctx)
+		if err != nil {
+			return "", dtype.None(), err
+		}
+		indexStr, _, err := ExprToGo(expr.Children[1], ctx)
+		if err != nil {
+			return "", dtype.None(), err
+		}
+		return fmt.Sprintf("(%s).Invoke(%s, \"[]\", %s)", exprStr, ctx.UsrRef(), indexStr), dtype.Any(), nil
 	case ast.ExprTypeGetField:
 		exprStr, exprType, err := ExprToGo(expr.Children[0], ctx)
 		if err != nil {
@@ -332,16 +411,25 @@ func StatementToGo(statement ast.Statement, ctx CodeGenContext) (lines []string,
 		lines = append(lines, "}")
 		return lines, nil
 	case ast.StatementTypeForList:
-		list, _, err := ExprToGo(statement.From, ctx)
-		if err != nil {
-			return nil, err
-		}
-		ctx.Tree.AddImport("github.com/celskeggs/mediator/platform/datum")
-		lines = append(lines, fmt.Sprintf("for _, %s := range datum.Elements(%s) {", LocalVariablePrefix+statement.Name, list))
-		if !statement.VarType.IsNone() {
-			lines = append(lines, fmt.Sprintf("if !types.IsType(%s, %q) {", LocalVariablePrefix+statement.Name, statement.VarType.Path()))
-			lines = append(lines, "continue")
-			lines = append(lines, "}")
+		if isWorldExpr(statement.From, ctx) {
+			// the realm keeps track of the datums of each type, so only the matching atoms need to be visited
+			atomType := "/atom"
+			if statement.VarType.IsAnyPath() && !statement.VarType.IsPath(path.Root()) {
+				atomType = statement.VarType.Path().String()
+			}
+			lines = append(lines, fmt.Sprintf("for _, %s := range %s.FindAllType(%q) {", LocalVariablePrefix+statement.Name, ctx.WorldRef, atomType))
+		} else {
+			list, _, err := ExprToGo(statement.From, ctx)
+			if err != nil {
+				return nil, err
+			}
+			ctx.Tree.AddImport("github.com/celskeggs/mediator/platform/datum")
+			lines = append(lines, fmt.Sprintf("for _, %s := range datum.Elements(%s) {", LocalVariablePrefix+statement.Name, list))
+			if !statement.VarType.IsNone() {
+				lines = append(lines, fmt.Sprintf("if !types.IsType(%s, %q) {", LocalVariablePrefix+statement.Name, statement.VarType.Path()))
+				lines = append(lines, "continue")
+				lines = append(lines, "}")
+			}
 		}
 		subctx := ctx.WithVar(statement.Name, statement.VarType)
 		for _, bodyStatement := range statement.Body {
@@ -387,27 +475,13 @@ func StatementToGo(statement ast.Statement, ctx CodeGenContext) (lines []string,
 		if err != nil {
 			return nil, err
 		}
-		if statement.To.Type == ast.ExprTypeGetNonLocal {
-			name := statement.To.Str
-			_, setExpr, _, ok := ctx.ResolveNonLocal(name)
-			if ok {
-				util.FIXME("should any typechecking happen here?")
-				return []string{
-					setExpr(value),
-				}, nil
-			}
-			return nil, fmt.Errorf("cannot resolve nonlocal %q at %v", name, statement.SourceLoc)
-		} else if statement.To.Type == ast.ExprTypeGetLocal {
-			assign, _, err := ExprToGo(statement.To, ctx)
-			if err != nil {
-				return nil, err
-			}
-			return []string{
-				fmt.Sprintf("%s = %s", assign, value),
-			}, nil
-		} else {
-			return nil, fmt.Errorf("not sure how to handle assignment to expression %v at %v", statement.To, statement.SourceLoc)
+		return AssignToGo(statement.To, value, ctx, statement.SourceLoc)
+	case ast.StatementTypeRead:
+		source, _, err := ExprToGo(statement.From, ctx)
+		if err != nil {
+			return nil, err
 		}
+		return AssignToGo(statement.To, fmt.Sprintf("(%s).Invoke(%s, \">>\")", source, ctx.UsrRef()), ctx, statement.SourceLoc)
 	case ast.StatementTypeDel:
 		value, _, err := ExprToGo(statement.From, ctx)
 		if err != nil {
@@ -420,6 +494,30 @@ func StatementToGo(statement ast.Statement, ctx CodeGenContext) (lines []string,
 	return nil, fmt.Errorf("cannot convert statement %v to Go at %v", statement, statement.SourceLoc)
 }
 
+func AssignToGo(target ast.Expression, value string, ctx CodeGenContext, loc tokenizer.SourceLocation) ([]string, error) {
+	if target.Type == ast.ExprTypeGetNonLocal {
+		name := target.Str
+		_, setExpr, _, ok := ctx.ResolveNonLocal(name)
+		if ok {
+			util.FIXME("should any typechecking happen here?")
+			return []string{
+				setExpr(value),
+			}, nil
+		}
+		return nil, fmt.Errorf("cannot resolve nonlocal %q at %v", name, loc)
+	} else if target.Type == ast.ExprTypeGetLocal {
+		assign, _, err := ExprToGo(target, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []string{
+			fmt.Sprintf("%s = %s", assign, value),
+		}, nil
+	} else {
+		return nil, fmt.Errorf("not sure how to handle assignment to expression %v at %v", target, loc)
+	}
+}
+
 func DefaultSrcSetting(tree *gen.DefinedTree, typePath path.TypePath) types.SrcSetting {
 	if tree.Extends(typePath, path.ConstTypePath("/mob")) {
 		return types.SrcSetting{
@@ -459,16 +557,19 @@ func ParseSrcSetting(expr ast.Expression, stype ast.StatementType) (types.SrcSet
 				return types.SrcSetting{}, fmt.Errorf("cannot handle keyword arguments in src setting at %v", expr.SourceLoc)
 			}
 		}
-		if expr.Children[0].Type != ast.ExprTypeGetNonLocal || expr.Children[0].Str != "oview" {
-			return types.SrcSetting{}, fmt.Errorf("expected call only to oview, not %q, in src setting at %v", expr.Children[0].Str, expr.Children[0].SourceLoc)
+		if expr.Children[0].Type != ast.ExprTypeGetNonLocal || (expr.Children[0].Str != "view" && expr.Children[0].Str != "oview") {
+			return types.SrcSetting{}, fmt.Errorf("expected call only to view or oview, not %q, in src setting at %v", expr.Children[0].Str, expr.Children[0].SourceLoc)
 		}
 		if len(expr.Children) > 2 {
 			return types.SrcSetting{}, fmt.Errorf("expected call to have 0-1 arguments when in src setting at %v", expr.SourceLoc)
 		}
 		sst = types.SrcSettingTypeOView
+		if expr.Children[0].Str == "view" {
+			sst = types.SrcSettingTypeView
+		}
 		if len(expr.Children) == 2 {
 			if expr.Children[1].Type != ast.ExprTypeIntegerLiteral {
-				return types.SrcSetting{}, fmt.Errorf("expected integer literal in oview parameter at %v", expr.Children[1].SourceLoc)
+				return types.SrcSetting{}, fmt.Errorf("expected integer literal in %s parameter at %v", expr.Children[0].Str, expr.Children[1].SourceLoc)
 			}
 			dist = int(expr.Children[1].Integer)
 			if dist < 0 || int64(dist) != expr.Children[1].Integer {
@@ -41,8 +41,19 @@ func DefineVar(dt *gen.DefinedTree, path path.TypePath, varType path.TypePath, v
 	if defType == nil {
 		panic("expected non-nil type " + path.String())
 	}
+	// var/tmp/x is not saved when its datum is written to a savefile
+	tmp := !varType.IsAbsolute && len(varType.Segments) > 0 && varType.Segments[0] == "tmp"
+	if tmp {
+		varType.Segments = varType.Segments[1:]
+	}
+	fieldType := dtype.Any()
 	if !varType.IsEmpty() {
-		return fmt.Errorf("unimplemented: variable type %v at %v", varType, loc)
+		// var/icon/x declares a var that holds an /icon
+		varType.IsAbsolute = true
+		if !dt.Exists(varType) {
+			return fmt.Errorf("no such type %v for variable %v at %v", varType, variable, loc)
+		}
+		fieldType = dtype.Path(varType)
 	}
 
 	_, found := dt.ResolveField(path, variable)
@@ -52,7 +63,8 @@ func DefineVar(dt *gen.DefinedTree, path path.TypePath, varType path.TypePath, v
 
 	defType.Fields = append(defType.Fields, gen.DefinedField{
 		Name: variable,
-		Type: dtype.Any(),
+		Type: fieldType,
+		Tmp:  tmp,
 	})
 	return nil
 }
@@ -94,6 +106,12 @@ func AssignPath(dt *gen.DefinedTree, path path.TypePath, variable string, expr a
 			if !dt.Exists(dt.WorldMob) {
 				panic("path " + dt.WorldMob.String() + " does not actually exist in the tree")
 			}
+		case "icon_size":
+			width, height, err := ConstantIconSize(expr)
+			if err != nil {
+				return fmt.Errorf("%v at %v", err, loc)
+			}
+			dt.WorldIconWidth, dt.WorldIconHeight = width, height
 		default:
 			return fmt.Errorf("no such path %v for assignment of variable %v", path, variable)
 		}
@@ -13,11 +13,13 @@ import (
 
 func Convert(dmf *ast.File, packageName string, importPath string) (*gen.DefinedTree, error) {
 	dt := &gen.DefinedTree{
-		Package:       packageName,
-		PackageImport: importPath,
-		WorldMob:      path.ConstTypePath("/mob"),
-		WorldName:     "World",
-		Maps:          dmf.Maps,
+		Package:         packageName,
+		PackageImport:   importPath,
+		WorldMob:        path.ConstTypePath("/mob"),
+		WorldName:       "World",
+		WorldIconWidth:  32,
+		WorldIconHeight: 32,
+		Maps:            dmf.Maps,
 	}
 	// define all types
 	for _, def := range dmf.Definitions {
@@ -20,12 +20,21 @@ import (
 type DefinedField struct {
 	Name string
 	Type dtype.DType
+	Tmp  bool
 }
 
 func (d DefinedField) LongName() string {
 	return predefs.ToTitle(d.Name)
 }
 
+// the struct tag that tells the boilerplate generator how to describe this field
+func (d DefinedField) Tag() string {
+	if d.Tmp {
+		return "`mediator:\"tmp\"`"
+	}
+	return ""
+}
+
 type DefinedInit struct {
 	Name      string
 	Value     string
@@ -113,8 +122,11 @@ type DefinedTree struct {
 	Types         []DefinedType
 	WorldName     string
 	WorldMob      path.TypePath
-	Imports       []string
-	Maps          []string
+	// world.icon_size, which may be given either as a single number or as "WIDTHxHEIGHT"
+	WorldIconWidth  uint
+	WorldIconHeight uint
+	Imports         []string
+	Maps            []string
 }
 
 var _ predefs.TypeDefiner = &DefinedTree{}
@@ -325,7 +337,7 @@ import (
 {{- end}}
 type {{.DataStructName}} struct {
 	{{- range .Fields}}
-	Var{{.LongName}} types.Value
+	Var{{.LongName}} types.Value {{.Tag}}
 	{{- end}}
 }
 
@@ -376,6 +388,8 @@ func (*{{$type.DataStructName}}) SettingsForProc{{.Name}}() types.ProcSettings {
 func BeforeMap(world *world.World) []string {
 	world.Name = "{{.WorldName}}"
 	world.Mob = "{{.WorldMob}}"
+	world.IconWidth = {{.WorldIconWidth}}
+	world.IconHeight = {{.WorldIconHeight}}
 	return []string{
 {{range .Maps -}}
 		"{{.}}",
@@ -28,6 +28,12 @@ func IsResourceExtension(ext string) bool {
 		return true
 	case ".wav":
 		return true
+	case ".png":
+		return true
+	case ".jpg":
+		return true
+	case ".gif":
+		return true
 	case ".js":
 		return true
 	case ".html":
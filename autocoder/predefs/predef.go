@@ -64,6 +64,9 @@ var platformDefs = []TypeInfo{
 	{"/mob", "platform", "/atom/movable"},
 	{"/sound", "platform", "/datum"},
 	{"/client", "platform", "/datum"},
+	{"/savefile", "savefile", "/datum"},
+	{"/icon", "platform", "/datum"},
+	{"/matrix", "platform", "/datum"},
 }
 
 var platformFields = []FieldInfo{
@@ -74,9 +77,43 @@ var platformFields = []FieldInfo{
 	{"loc", "/atom", dtype.ConstPath("/atom")},
 	{"density", "/atom", dtype.Integer()},
 	{"opacity", "/atom", dtype.Integer()},
+	{"luminosity", "/atom", dtype.Integer()},
+	{"light_range", "/atom", dtype.Integer()},
+	{"light_power", "/atom", dtype.Integer()},
+	{"light_color", "/atom", dtype.Any()},
+	{"dynamic_lighting", "/area", dtype.Integer()},
 	{"suffix", "/atom", dtype.String()},
 	{"contents", "/atom", dtype.List()},
 	{"dir", "/atom", dtype.Any()},
+	{"pixel_x", "/atom", dtype.Integer()},
+	{"pixel_y", "/atom", dtype.Integer()},
+	{"pixel_w", "/atom", dtype.Integer()},
+	{"pixel_z", "/atom", dtype.Integer()},
+	{"color", "/atom", dtype.Any()},
+	{"alpha", "/atom", dtype.Integer()},
+	{"transform", "/atom", dtype.ConstPath("/matrix")},
+	{"blend_mode", "/atom", dtype.Integer()},
+	{"mouse_opacity", "/atom", dtype.Integer()},
+	{"invisibility", "/atom", dtype.Integer()},
+	{"overlays", "/atom", dtype.List()},
+	{"underlays", "/atom", dtype.List()},
+	{"see_in_dark", "/mob", dtype.Integer()},
+	{"see_invisible", "/mob", dtype.Integer()},
+	{"sight", "/mob", dtype.Integer()},
+	{"key", "/mob", dtype.String()},
+	{"ckey", "/mob", dtype.String()},
+	{"client", "/mob", dtype.ConstPath("/client")},
+	{"key", "/client", dtype.String()},
+	{"ckey", "/client", dtype.String()},
+	{"cd", "/savefile", dtype.String()},
+	{"dir", "/savefile", dtype.List()},
+	{"name", "/savefile", dtype.String()},
+	{"a", "/matrix", dtype.Integer()},
+	{"b", "/matrix", dtype.Integer()},
+	{"c", "/matrix", dtype.Integer()},
+	{"d", "/matrix", dtype.Integer()},
+	{"e", "/matrix", dtype.Integer()},
+	{"f", "/matrix", dtype.Integer()},
 }
 
 var platformProcs = []ProcedureInfo{
@@ -84,6 +121,65 @@ var platformProcs = []ProcedureInfo{
 	{"Bump", path.ConstTypePath("/atom/movable")},
 	{"Move", path.ConstTypePath("/atom/movable")},
 	{"Stat", path.ConstTypePath("/atom")},
+	{"Login", path.ConstTypePath("/mob")},
+	{"Logout", path.ConstTypePath("/mob")},
+	{"Topic", path.ConstTypePath("/datum")},
+	{"Click", path.ConstTypePath("/atom")},
+	{"DblClick", path.ConstTypePath("/atom")},
+	{"MouseDown", path.ConstTypePath("/atom")},
+	{"MouseUp", path.ConstTypePath("/atom")},
+	{"MouseDrop", path.ConstTypePath("/atom")},
+	{"MouseEntered", path.ConstTypePath("/atom")},
+	{"MouseExited", path.ConstTypePath("/atom")},
+	{"Click", path.ConstTypePath("/client")},
+	{"DblClick", path.ConstTypePath("/client")},
+	{"MouseDown", path.ConstTypePath("/client")},
+	{"MouseUp", path.ConstTypePath("/client")},
+	{"MouseDrop", path.ConstTypePath("/client")},
+	{"MouseEntered", path.ConstTypePath("/client")},
+	{"MouseExited", path.ConstTypePath("/client")},
+	{"Read", path.ConstTypePath("/datum")},
+	{"Write", path.ConstTypePath("/datum")},
+	{"ExportText", path.ConstTypePath("/savefile")},
+	{"ImportText", path.ConstTypePath("/savefile")},
+	{"Flush", path.ConstTypePath("/savefile")},
+	{"Blend", path.ConstTypePath("/icon")},
+	{"SwapColor", path.ConstTypePath("/icon")},
+	{"Turn", path.ConstTypePath("/icon")},
+	{"Flip", path.ConstTypePath("/icon")},
+	{"Shift", path.ConstTypePath("/icon")},
+	{"Scale", path.ConstTypePath("/icon")},
+	{"Crop", path.ConstTypePath("/icon")},
+	{"DrawBox", path.ConstTypePath("/icon")},
+	{"GetPixel", path.ConstTypePath("/icon")},
+	{"Insert", path.ConstTypePath("/icon")},
+	{"IconStates", path.ConstTypePath("/icon")},
+	{"Width", path.ConstTypePath("/icon")},
+	{"Height", path.ConstTypePath("/icon")},
+	{"Multiply", path.ConstTypePath("/matrix")},
+	{"Add", path.ConstTypePath("/matrix")},
+	{"Subtract", path.ConstTypePath("/matrix")},
+	{"Invert", path.ConstTypePath("/matrix")},
+	{"Scale", path.ConstTypePath("/matrix")},
+	{"Translate", path.ConstTypePath("/matrix")},
+	{"Turn", path.ConstTypePath("/matrix")},
+}
+
+// the procs that every list has
+var listProcs = []string{
+	"Add",
+	"Remove",
+	"Find",
+	"Cut",
+}
+
+func ListProcExists(name string) bool {
+	for _, proc := range listProcs {
+		if proc == name {
+			return true
+		}
+	}
+	return false
 }
 
 var platformGlobalProcs = []string{
@@ -91,11 +187,63 @@ var platformGlobalProcs = []string{
 	"sound",
 	"oview",
 	"view",
+	"range",
+	"locate",
+	"block",
 	"stat",
 	"statpanel",
 	"walk_to",
 	"get_dir",
 	"flick",
+	"icon",
+	"icon_states",
+	"rgb",
+	"matrix",
+	"list",
+	"params2list",
+	"browse",
+	"browse_rsc",
+	"input",
+	"alert",
+}
+
+var platformConstants = map[string]int{
+	"NORTH":         1,
+	"SOUTH":         2,
+	"EAST":          4,
+	"WEST":          8,
+	"NORTHEAST":     5,
+	"NORTHWEST":     9,
+	"SOUTHEAST":     6,
+	"SOUTHWEST":     10,
+	"ICON_ADD":      0,
+	"ICON_SUBTRACT": 1,
+	"ICON_MULTIPLY": 2,
+	"ICON_OVERLAY":  3,
+	"ICON_AND":      4,
+	"ICON_OR":       5,
+	"ICON_UNDERLAY": 6,
+
+	"BLEND_DEFAULT":       0,
+	"BLEND_OVERLAY":       1,
+	"BLEND_ADD":           2,
+	"BLEND_SUBTRACT":      3,
+	"BLEND_MULTIPLY":      4,
+	"BLEND_INSET_OVERLAY": 5,
+
+	"FLOAT_LAYER": -1,
+
+	"BLIND":     1,
+	"SEE_MOBS":  4,
+	"SEE_OBJS":  8,
+	"SEE_TURFS": 16,
+	"SEE_SELF":  32,
+}
+
+// looks up the value of a built-in constant, like NORTH or ICON_ADD
+func PlatformConstant(name string) (value int, found bool) {
+	value, found = platformConstants[name]
+	return value, found
 }
 
 type platformDefiner struct {
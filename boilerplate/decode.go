@@ -9,6 +9,8 @@ import (
 	"go/parser"
 	"go/token"
 	"path"
+	"reflect"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -61,9 +63,18 @@ func (source *SourceInfo) LoadStruct(fset *token.FileSet, structType *ast.Struct
 	for _, field := range structType.Fields.List {
 		for _, name := range field.Names {
 			if strings.HasPrefix(name.Name, "Var") {
+				var tag reflect.StructTag
+				if field.Tag != nil {
+					unquoted, err := strconv.Unquote(field.Tag.Value)
+					if err != nil {
+						return err
+					}
+					tag = reflect.StructTag(unquoted)
+				}
 				source.Vars = append(source.Vars, VarInfo{
 					FieldName: ToSnakeCase(name.Name[3:]),
 					LongName:  name.Name,
+					Tmp:       tag.Get("mediator") == "tmp",
 					Type:      field.Type,
 					FileSet:   fset,
 
@@ -364,6 +375,16 @@ func (t *TreeInfo) LoadAST(fset *token.FileSet, file *ast.File, importPath strin
 								if err != nil {
 									return err
 								}
+							} else if fun.Name.Name == "OperatorRead" {
+								err := source.LoadProc(fset, ident.Name, fun, ">>")
+								if err != nil {
+									return err
+								}
+							} else if fun.Name.Name == "OperatorIndex" {
+								err := source.LoadProc(fset, ident.Name, fun, "[]")
+								if err != nil {
+									return err
+								}
 							}
 						}
 					}
@@ -19,11 +19,16 @@ func (t *ProcInfo) ParamNums() []int {
 }
 
 func (i *ProcInfo) ProcName() string {
-	name := i.Name
-	if name == "<<" {
+	switch i.Name {
+	case "<<":
 		return "OperatorWrite"
+	case ">>":
+		return "OperatorRead"
+	case "[]":
+		return "OperatorIndex"
+	default:
+		return "Proc" + i.Name
 	}
-	return "Proc" + name
 }
 
 func (t *PreparedVar) ConvertTo() []string {
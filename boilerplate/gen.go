@@ -149,6 +149,19 @@ func (t *{{.Type}}Impl) Var(src *types.Datum, name string) (types.Value, bool) {
 	}
 }
 
+func (t *{{.Type}}Impl) Vars() []types.VarInfo {
+	return []types.VarInfo{
+		{Name: "type", ReadOnly: true},
+		{Name: "parent_type", ReadOnly: true},
+{{- range .Vars}}
+		{Name: "{{.FieldName}}"{{if .Tmp}}, Tmp: true{{end}}},
+{{- end}}
+{{- range .Getters}}
+		{Name: "{{.FieldName}}"{{if not .HasSetter}}, ReadOnly: true{{end}}},
+{{- end}}
+	}
+}
+
 func (t *{{.Type}}Impl) SetVar(src *types.Datum, name string, value types.Value) types.SetResult {
 	switch name {
 	case "type":
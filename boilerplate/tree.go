@@ -16,6 +16,7 @@ type GetterInfo struct {
 type VarInfo struct {
 	FieldName       string
 	LongName        string
+	Tmp             bool
 	Type            ast.Expr
 	FileSet         *token.FileSet
 	DefiningImports []*ast.ImportSpec
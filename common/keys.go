@@ -0,0 +1,18 @@
+package common
+
+import (
+	"strings"
+	"unicode"
+)
+
+// converts a key to its canonical form, which is used to tell whether two keys belong to the same player: letters are
+// lowercased, and everything but letters, digits and @ is removed
+func Ckey(key string) string {
+	var ckey strings.Builder
+	for _, r := range key {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '@' {
+			ckey.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return ckey.String()
+}
@@ -0,0 +1,161 @@
+// Package dmconst reads and writes the pieces of DM constant syntax, like numbers, "strings", 'resources' and
+// /type/paths, that are shared by maps and savefiles.
+package dmconst
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// escapes the contents of a string so that ReadStringBody can read it back
+func EscapeString(str string, quote string) string {
+	return strings.NewReplacer("\\", "\\\\", quote, "\\"+quote, "\n", "\\n", "\t", "\\t").Replace(str)
+}
+
+func QuoteString(str string) string {
+	return "\"" + EscapeString(str, "\"") + "\""
+}
+
+func IsIdentChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// scans DM constant syntax out of a piece of text
+type Scanner struct {
+	Text string
+	Pos  int
+	// where the text came from, like "map definitions", and the line number that the text starts on; both are only
+	// used for error messages
+	Source string
+	Line   int
+}
+
+func (s *Scanner) Errorf(format string, args ...interface{}) error {
+	line := s.Line + strings.Count(s.Text[:s.Pos], "\n")
+	return fmt.Errorf("%s line %d: %s", s.Source, line, fmt.Sprintf(format, args...))
+}
+
+func (s *Scanner) EOF() bool {
+	return s.Pos >= len(s.Text)
+}
+
+func (s *Scanner) Peek() byte {
+	if s.EOF() {
+		return 0
+	}
+	return s.Text[s.Pos]
+}
+
+func (s *Scanner) SkipSpace() {
+	for !s.EOF() && strings.IndexByte(" \t\r\n", s.Text[s.Pos]) != -1 {
+		s.Pos++
+	}
+}
+
+// skips whitespace, and then consumes the token if it's present
+func (s *Scanner) Accept(token string) bool {
+	s.SkipSpace()
+	if strings.HasPrefix(s.Text[s.Pos:], token) {
+		s.Pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (s *Scanner) Expect(token string) error {
+	if !s.Accept(token) {
+		return s.Errorf("expected %q", token)
+	}
+	return nil
+}
+
+func (s *Scanner) ReadWhile(cond func(c byte) bool) string {
+	start := s.Pos
+	for !s.EOF() && cond(s.Text[s.Pos]) {
+		s.Pos++
+	}
+	return s.Text[start:s.Pos]
+}
+
+func (s *Scanner) ReadIdent() (string, error) {
+	s.SkipSpace()
+	ident := s.ReadWhile(IsIdentChar)
+	if ident == "" {
+		return "", s.Errorf("expected identifier")
+	}
+	return ident, nil
+}
+
+func (s *Scanner) ReadPath() (string, error) {
+	s.SkipSpace()
+	path := s.ReadWhile(func(c byte) bool {
+		return c == '/' || IsIdentChar(c)
+	})
+	if !strings.HasPrefix(path, "/") || strings.HasSuffix(path, "/") || strings.Contains(path, "//") {
+		return "", s.Errorf("invalid type path %q", path)
+	}
+	return path, nil
+}
+
+// reads the body of a string after its opening quote, up to and including the closing delimiter
+func (s *Scanner) ReadStringBody(closing string) (string, error) {
+	var out strings.Builder
+	for {
+		if s.EOF() {
+			return "", s.Errorf("unterminated string")
+		}
+		if strings.HasPrefix(s.Text[s.Pos:], closing) {
+			s.Pos += len(closing)
+			return out.String(), nil
+		}
+		c := s.Text[s.Pos]
+		s.Pos++
+		if c == '\\' {
+			if s.EOF() {
+				return "", s.Errorf("unterminated escape sequence")
+			}
+			c = s.Text[s.Pos]
+			s.Pos++
+			switch c {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			default:
+				// covers \" and \\, as well as escaped brackets and the like
+				out.WriteByte(c)
+			}
+		} else {
+			out.WriteByte(c)
+		}
+	}
+}
+
+// reads a "string" or a {"multi-line string"}
+func (s *Scanner) ReadString() (string, error) {
+	if s.Accept("{\"") {
+		return s.ReadStringBody("\"}")
+	}
+	if err := s.Expect("\""); err != nil {
+		return "", err
+	}
+	return s.ReadStringBody("\"")
+}
+
+func (s *Scanner) ReadNumber() (float64, error) {
+	s.SkipSpace()
+	start := s.Pos
+	if s.Peek() == '-' {
+		s.Pos++
+	}
+	s.ReadWhile(func(c byte) bool {
+		return (c >= '0' && c <= '9') || c == '.' || c == 'e' || c == 'E' ||
+			((c == '+' || c == '-') && (s.Text[s.Pos-1] == 'e' || s.Text[s.Pos-1] == 'E'))
+	})
+	number, err := strconv.ParseFloat(s.Text[start:s.Pos], 64)
+	if err != nil {
+		return 0, s.Errorf("invalid number %q", s.Text[start:s.Pos])
+	}
+	return number, nil
+}
@@ -0,0 +1,39 @@
+package dmconst
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReadValues(t *testing.T) {
+	s := &Scanner{Text: `"a \"b\"\n" 'c\'s.dmi' {"multi
+line"} -2.5e3 /obj/door`, Source: "test", Line: 1}
+	str, err := s.ReadString()
+	assert.NoError(t, err)
+	assert.Equal(t, "a \"b\"\n", str)
+	assert.NoError(t, s.Expect("'"))
+	str, err = s.ReadStringBody("'")
+	assert.NoError(t, err)
+	assert.Equal(t, "c's.dmi", str)
+	str, err = s.ReadString()
+	assert.NoError(t, err)
+	assert.Equal(t, "multi\nline", str)
+	number, err := s.ReadNumber()
+	assert.NoError(t, err)
+	assert.Equal(t, -2500.0, number)
+	path, err := s.ReadPath()
+	assert.NoError(t, err)
+	assert.Equal(t, "/obj/door", path)
+	assert.True(t, s.EOF())
+	assert.EqualError(t, s.Expect(","), `test line 2: expected ","`)
+}
+
+func TestEscapeString(t *testing.T) {
+	for _, str := range []string{"", "plain", "quote \" and \\ backslash", "tab\tand\nnewline"} {
+		s := &Scanner{Text: QuoteString(str)}
+		read, err := s.ReadString()
+		assert.NoError(t, err)
+		assert.Equal(t, str, read)
+		assert.True(t, s.EOF())
+	}
+}
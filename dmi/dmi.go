@@ -3,23 +3,75 @@ package dmi
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// a point within a particular frame of an icon state, such as the point that a mouse cursor clicks with
+type Hotspot struct {
+	X int
+	Y int
+	// Frame starts at 1
+	Frame int
+}
+
 type DMIState struct {
 	// Index starts at 0
 	Index      uint
 	Directions int
 	Frames     int
 	Rewind     bool
-	Delay      []int
+	// in ticks; delays can be fractional
+	Delay []float64
+	// the number of times that the animation plays; zero means that it loops forever
+	Loop     int
+	Hotspots []Hotspot
+	// movement states share their names with regular states, and are displayed while an atom is moving
+	Movement bool
+	// keys that this package does not understand, with each of their values in order, preserved so that they can be
+	// written back out
+	Extra map[string][]string
 }
 
 type DMIInfo struct {
 	Width  int
 	Height int
 	States map[string]DMIState
+	// the movement variants of states, which are named the same as the regular states
+	MovementStates map[string]DMIState
+}
+
+// all of the states of a DMI, including movement states, in the order that they are stored
+func (info *DMIInfo) SortedStates() (names []string, states []DMIState) {
+	for name, state := range info.States {
+		names = append(names, name)
+		states = append(states, state)
+	}
+	for name, state := range info.MovementStates {
+		names = append(names, name)
+		states = append(states, state)
+	}
+	sort.Sort(byIndex{names, states})
+	return names, states
+}
+
+type byIndex struct {
+	names  []string
+	states []DMIState
+}
+
+func (b byIndex) Len() int {
+	return len(b.names)
+}
+
+func (b byIndex) Less(i, j int) bool {
+	return b.states[i].Index < b.states[j].Index
+}
+
+func (b byIndex) Swap(i, j int) {
+	b.names[i], b.names[j] = b.names[j], b.names[i]
+	b.states[i], b.states[j] = b.states[j], b.states[i]
 }
 
 func getBodyLines(whole string) ([]string, error) {
@@ -30,8 +82,23 @@ func getBodyLines(whole string) ([]string, error) {
 	return lines[1 : len(lines)-1], nil
 }
 
-func getKVSections(whole string) (sections []map[string]string, err error) {
-	var section map[string]string
+// keys that can only be specified once within a single section; other keys, including ones that this package does
+// not understand, may be repeated
+var uniqueKeys = map[string]bool{
+	"version":  true,
+	"width":    true,
+	"height":   true,
+	"state":    true,
+	"dirs":     true,
+	"frames":   true,
+	"rewind":   true,
+	"delay":    true,
+	"loop":     true,
+	"movement": true,
+}
+
+func getKVSections(whole string) (sections []map[string][]string, err error) {
+	var section map[string][]string
 	lines, err := getBodyLines(whole)
 	if err != nil {
 		return nil, err
@@ -39,7 +106,7 @@ func getKVSections(whole string) (sections []map[string]string, err error) {
 	for _, line := range lines {
 		if line[0] != '\t' {
 			// no tab: start a section
-			section = make(map[string]string)
+			section = make(map[string][]string)
 			sections = append(sections, section)
 		} else {
 			// tab: continue a section
@@ -54,26 +121,34 @@ func getKVSections(whole string) (sections []map[string]string, err error) {
 		}
 		key, value := kv[0], kv[1]
 		_, alreadyExists := section[key]
-		if alreadyExists {
-			return nil, errors.New("duplicate key")
+		if alreadyExists && uniqueKeys[key] {
+			return nil, fmt.Errorf("duplicate key '%s'", key)
 		}
-		section[key] = value
+		section[key] = append(section[key], value)
 	}
 	return sections, nil
 }
 
-func parseHeader(section map[string]string) (width int, height int, err error) {
-	if section["version"] != "4.0" {
+// gets the value of a key that can only be specified once
+func single(section map[string][]string, key string) string {
+	if len(section[key]) == 0 {
+		return ""
+	}
+	return section[key][0]
+}
+
+func parseHeader(section map[string][]string) (width int, height int, err error) {
+	if single(section, "version") != "4.0" {
 		return 0, 0, errors.New("expected version = 4.0 in DMI")
 	}
-	if section["width"] == "" || section["height"] == "" || len(section) != 3 {
+	if single(section, "width") == "" || single(section, "height") == "" || len(section) != 3 {
 		return 0, 0, errors.New("expected exactly three keys in header: version, width, height")
 	}
-	widthU, err := strconv.ParseUint(section["width"], 10, 31)
+	widthU, err := strconv.ParseUint(single(section, "width"), 10, 31)
 	if err != nil {
 		return 0, 0, err
 	}
-	heightU, err := strconv.ParseUint(section["height"], 10, 31)
+	heightU, err := strconv.ParseUint(single(section, "height"), 10, 31)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -84,52 +159,153 @@ func parseString(value string) (string, error) {
 	if !strings.HasPrefix(value, "\"") || !strings.HasSuffix(value, "\"") {
 		return "", errors.New("invalid string format")
 	}
-	// TODO: escaping, maybe?
-	return value[1 : len(value)-1], nil
+	body := value[1 : len(value)-1]
+	var out strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] == '"' {
+			return "", errors.New("unescaped quote in string")
+		}
+		if body[i] == '\\' {
+			i++
+			if i >= len(body) {
+				return "", errors.New("unterminated escape sequence in string")
+			}
+			if body[i] == 'n' {
+				out.WriteByte('\n')
+				continue
+			}
+		}
+		out.WriteByte(body[i])
+	}
+	return out.String(), nil
+}
+
+// the inverse of parseString
+func formatString(value string) string {
+	return "\"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(value) + "\""
+}
+
+func parseInts(value string) (ints []int, err error) {
+	for _, str := range strings.Split(value, ",") {
+		i, err := strconv.ParseInt(strings.TrimSpace(str), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, int(i))
+	}
+	return ints, nil
+}
+
+// delays are measured in ticks, and can be fractional
+func parseDelays(value string) (delays []float64, err error) {
+	for _, str := range strings.Split(value, ",") {
+		delay, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+		if err != nil {
+			return nil, err
+		}
+		if delay < 0 {
+			return nil, fmt.Errorf("negative delay %v", delay)
+		}
+		delays = append(delays, delay)
+	}
+	return delays, nil
+}
+
+func parseFlag(section map[string][]string, key string) (bool, error) {
+	value := single(section, key)
+	if value == "" {
+		return false, nil
+	}
+	if value != "0" && value != "1" {
+		return false, fmt.Errorf("expected '%s' to be 0 or 1", key)
+	}
+	return value == "1", nil
+}
+
+func parseHotspot(value string) (Hotspot, error) {
+	ints, err := parseInts(value)
+	if err != nil {
+		return Hotspot{}, err
+	}
+	if len(ints) != 3 {
+		return Hotspot{}, fmt.Errorf("expected 'hotspot' to be x,y,frame, not %q", value)
+	}
+	return Hotspot{X: ints[0], Y: ints[1], Frame: ints[2]}, nil
 }
 
-func parseState(section map[string]string) (string, DMIState, error) {
+var knownStateKeys = map[string]bool{
+	"state":    true,
+	"dirs":     true,
+	"frames":   true,
+	"rewind":   true,
+	"delay":    true,
+	"loop":     true,
+	"hotspot":  true,
+	"movement": true,
+}
+
+func parseState(section map[string][]string) (string, DMIState, error) {
 	for _, field := range []string{"state", "dirs", "frames"} {
-		if section[field] == "" {
+		if single(section, field) == "" {
 			return "", DMIState{}, fmt.Errorf("no '%s' field specified", field)
 		}
 	}
-	for field := range section {
-		if field != "state" && field != "dirs" && field != "frames" && field != "rewind" && field != "delay" {
-			return "", DMIState{}, fmt.Errorf("unexpected field '%s' found", field)
+	var extra map[string][]string
+	for field, values := range section {
+		if !knownStateKeys[field] {
+			if extra == nil {
+				extra = map[string][]string{}
+			}
+			extra[field] = values
 		}
 	}
-	stateName, err := parseString(section["state"])
+	stateName, err := parseString(single(section, "state"))
 	if err != nil {
 		return "", DMIState{}, err
 	}
 
-	dirs, err := strconv.ParseUint(section["dirs"], 10, 31)
+	dirs, err := strconv.ParseUint(single(section, "dirs"), 10, 31)
 	if err != nil {
 		return "", DMIState{}, err
 	}
-	frames, err := strconv.ParseUint(section["frames"], 10, 31)
+	frames, err := strconv.ParseUint(single(section, "frames"), 10, 31)
 	if err != nil {
 		return "", DMIState{}, err
 	}
 	// TODO: is this the correct default value?
-	rewind := false
-	if section["rewind"] != "" {
-		if section["rewind"] != "0" && section["rewind"] != "1" {
-			return "", DMIState{}, errors.New("expected 'rewind' to be 0 or 1")
+	rewind, err := parseFlag(section, "rewind")
+	if err != nil {
+		return "", DMIState{}, err
+	}
+	movement, err := parseFlag(section, "movement")
+	if err != nil {
+		return "", DMIState{}, err
+	}
+
+	var delay []float64
+	if delayValue := single(section, "delay"); delayValue != "" {
+		delay, err = parseDelays(delayValue)
+		if err != nil {
+			return "", DMIState{}, err
 		}
-		rewind = section["rewind"] != "0"
 	}
 
-	var delay []int
-	if delayValue, found := section["delay"]; found {
-		for _, str := range strings.Split(delayValue, ",") {
-			delayU, err := strconv.ParseUint(str, 10, 31)
-			if err != nil {
-				return "", DMIState{}, err
-			}
-			delay = append(delay, int(delayU))
+	loop := 0
+	if loopValue := single(section, "loop"); loopValue != "" {
+		loopU, err := strconv.ParseUint(loopValue, 10, 31)
+		if err != nil {
+			return "", DMIState{}, err
 		}
+		loop = int(loopU)
+	}
+
+	var hotspots []Hotspot
+	for _, value := range section["hotspot"] {
+		hotspot, err := parseHotspot(value)
+		if err != nil {
+			return "", DMIState{}, err
+		}
+		hotspots = append(hotspots, hotspot)
 	}
 
 	return stateName, DMIState{
@@ -137,6 +313,10 @@ func parseState(section map[string]string) (string, DMIState, error) {
 		Frames:     int(frames),
 		Rewind:     rewind,
 		Delay:      delay,
+		Loop:       loop,
+		Hotspots:   hotspots,
+		Movement:   movement,
+		Extra:      extra,
 	}, nil
 }
 
@@ -145,6 +325,11 @@ func ParseDMI(png []byte) (*DMIInfo, error) {
 	if err != nil {
 		return nil, err
 	}
+	return ParseDescription(description)
+}
+
+// parses the text of the Description chunk of a DMI
+func ParseDescription(description string) (*DMIInfo, error) {
 	sections, err := getKVSections(description)
 	if err != nil {
 		return nil, err
@@ -156,21 +341,26 @@ func ParseDMI(png []byte) (*DMIInfo, error) {
 	if err != nil {
 		return nil, err
 	}
-	states := map[string]DMIState{}
+	info := &DMIInfo{
+		Width:          width,
+		Height:         height,
+		States:         map[string]DMIState{},
+		MovementStates: map[string]DMIState{},
+	}
 	for i := 1; i < len(sections); i++ {
 		key, state, err := parseState(sections[i])
 		if err != nil {
 			return nil, err
 		}
 		state.Index = uint(i - 1)
+		states := info.States
+		if state.Movement {
+			states = info.MovementStates
+		}
 		if _, alreadyExists := states[key]; alreadyExists {
-			return nil, errors.New("duplicate state")
+			return nil, fmt.Errorf("duplicate state %q", key)
 		}
 		states[key] = state
 	}
-	return &DMIInfo{
-		Width:  width,
-		Height: height,
-		States: states,
-	}, nil
+	return info, nil
 }
@@ -0,0 +1,76 @@
+package dmi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const fullDescription = `# BEGIN DMI
+version = 4.0
+	width = 32
+	height = 32
+state = "say \"hi\" \\ wave"
+	dirs = 1
+	frames = 2
+	delay = 1.5,2
+	loop = 3
+	hotspot = 4,5,1
+	hotspot = 6,7,2
+	future = abc
+	future = def
+state = "walk"
+	dirs = 4
+	frames = 1
+state = "walk"
+	dirs = 4
+	frames = 1
+	movement = 1
+# END DMI
+`
+
+func TestParseDescriptionMetadata(t *testing.T) {
+	info, err := ParseDescription(fullDescription)
+	if err != nil {
+		t.Fatal(err)
+	}
+	say, found := info.States[`say "hi" \ wave`]
+	if !found {
+		t.Fatalf("escaped state name not found in %v", info.States)
+	}
+	expected := DMIState{
+		Index:      0,
+		Directions: 1,
+		Frames:     2,
+		Delay:      []float64{1.5, 2},
+		Loop:       3,
+		Hotspots:   []Hotspot{{X: 4, Y: 5, Frame: 1}, {X: 6, Y: 7, Frame: 2}},
+		Extra:      map[string][]string{"future": {"abc", "def"}},
+	}
+	if !reflect.DeepEqual(say, expected) {
+		t.Errorf("expected %+v, got %+v", expected, say)
+	}
+	if walk := info.States["walk"]; walk.Index != 1 || walk.Movement {
+		t.Errorf("unexpected regular walk state: %+v", walk)
+	}
+	if walk := info.MovementStates["walk"]; walk.Index != 2 || !walk.Movement {
+		t.Errorf("unexpected movement walk state: %+v", walk)
+	}
+}
+
+func TestFormatDescriptionRoundTrip(t *testing.T) {
+	info, err := ParseDescription(fullDescription)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := ParseDescription(FormatDescription(info))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info, reparsed) {
+		t.Errorf("round trip changed metadata: %+v became %+v", info, reparsed)
+	}
+	if !strings.Contains(FormatDescription(info), "\tdelay = 1.5,2\n") {
+		t.Errorf("fractional delays not preserved in %q", FormatDescription(info))
+	}
+}
@@ -4,29 +4,373 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/celskeggs/mediator/dmi"
+	"github.com/pkg/errors"
+	"image"
+	"image/png"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
-func main() {
-	if len(os.Args) < 2 {
-		_, _ = fmt.Fprintln(os.Stderr, "usage: dmitool <icon.dmi> [<icon.dmi> ...]")
-		os.Exit(1)
+const manifestName = "manifest.json"
+
+// describes the layout of an extracted DMI; pack reads it back in to rebuild the DMI
+type Manifest struct {
+	Width  int
+	Height int
+	States []ManifestState
+}
+
+type ManifestState struct {
+	Name     string
+	Movement bool                `json:",omitempty"`
+	Rewind   bool                `json:",omitempty"`
+	Loop     int                 `json:",omitempty"`
+	Delay    []float64           `json:",omitempty"`
+	Hotspots []dmi.Hotspot       `json:",omitempty"`
+	Extra    map[string][]string `json:",omitempty"`
+	// filenames of the PNGs for each frame, relative to the manifest, as Images[frame][dir]
+	Images [][]string
+}
+
+func loadDMI(filename string) (*dmi.DMI, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := dmi.DecodeDMI(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while decoding %s", filename)
+	}
+	return decoded, nil
+}
+
+func formatState(state dmi.StateImages) string {
+	desc := stateKey(state) + fmt.Sprintf(" dirs=%d frames=%d", state.Directions(), state.Frames())
+	if len(state.Delay) > 0 {
+		var delays []string
+		for _, delay := range state.Delay {
+			delays = append(delays, fmt.Sprint(delay))
+		}
+		desc += " delay=" + strings.Join(delays, ",")
 	}
-	for _, arg := range os.Args[1:] {
-		png, err := ioutil.ReadFile(arg)
+	if state.Rewind {
+		desc += " rewind"
+	}
+	if state.Loop != 0 {
+		desc += fmt.Sprintf(" loop=%d", state.Loop)
+	}
+	for _, hotspot := range state.Hotspots {
+		desc += fmt.Sprintf(" hotspot=%d,%d,%d", hotspot.X, hotspot.Y, hotspot.Frame)
+	}
+	var keys []string
+	for key := range state.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range state.Extra[key] {
+			desc += fmt.Sprintf(" %s=%s", key, value)
+		}
+	}
+	return desc
+}
+
+// identifies a state; movement states have the same names as regular states, so they are marked separately
+func stateKey(state dmi.StateImages) string {
+	if state.Movement {
+		return fmt.Sprintf("%q (movement)", state.Name)
+	}
+	return fmt.Sprintf("%q", state.Name)
+}
+
+func info(args []string) error {
+	for _, arg := range args {
+		data, err := ioutil.ReadFile(arg)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		dmiInfo, err := dmi.ParseDMI(png)
+		dmiInfo, err := dmi.ParseDMI(data)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		println(arg)
 		result, err := json.MarshalIndent(dmiInfo, "", "  ")
 		if err != nil {
-			panic(err)
+			return err
+		}
+		fmt.Println(arg)
+		fmt.Println(string(result))
+	}
+	return nil
+}
+
+func list(args []string) error {
+	for _, arg := range args {
+		decoded, err := loadDMI(arg)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %dx%d, %d states\n", arg, decoded.Width, decoded.Height, len(decoded.States))
+		for _, state := range decoded.States {
+			fmt.Println("\t" + formatState(state))
+		}
+	}
+	return nil
+}
+
+func writePNG(filename string, img image.Image) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readPNG(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while decoding %s", filename)
+	}
+	return img, nil
+}
+
+// produces a filename for a single frame; state names are escaped, since they can contain any character, and the
+// default state is written as a lone "%", which cannot be produced by escaping any other name
+func frameFilename(state dmi.StateImages, dir int, frame int) string {
+	name := url.PathEscape(state.Name)
+	if name == "" {
+		name = "%"
+	}
+	if state.Movement {
+		name += ".movement"
+	}
+	return fmt.Sprintf("%s.%s.%d.png", name, dmi.DirectionNames[dir], frame+1)
+}
+
+func extract(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: dmitool extract <icon.dmi> <output-dir>")
+	}
+	decoded, err := loadDMI(args[0])
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(args[1], 0755); err != nil {
+		return err
+	}
+	manifest := Manifest{
+		Width:  decoded.Width,
+		Height: decoded.Height,
+	}
+	for _, state := range decoded.States {
+		ms := ManifestState{
+			Name:     state.Name,
+			Movement: state.Movement,
+			Rewind:   state.Rewind,
+			Loop:     state.Loop,
+			Delay:    state.Delay,
+			Hotspots: state.Hotspots,
+			Extra:    state.Extra,
+		}
+		for frame, images := range state.Images {
+			var filenames []string
+			for dir, img := range images {
+				filename := frameFilename(state, dir, frame)
+				if err := writePNG(filepath.Join(args[1], filename), img); err != nil {
+					return err
+				}
+				filenames = append(filenames, filename)
+			}
+			ms.Images = append(ms.Images, filenames)
+		}
+		manifest.States = append(manifest.States, ms)
+	}
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(args[1], manifestName), encoded, 0644)
+}
+
+func pack(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: dmitool pack <input-dir> <icon.dmi>")
+	}
+	content, err := ioutil.ReadFile(filepath.Join(args[0], manifestName))
+	if err != nil {
+		return err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return errors.Wrapf(err, "while parsing %s", manifestName)
+	}
+	result := &dmi.DMI{
+		Width:  manifest.Width,
+		Height: manifest.Height,
+	}
+	for _, ms := range manifest.States {
+		state := dmi.StateImages{
+			Name:     ms.Name,
+			Movement: ms.Movement,
+			Rewind:   ms.Rewind,
+			Loop:     ms.Loop,
+			Delay:    ms.Delay,
+			Hotspots: ms.Hotspots,
+			Extra:    ms.Extra,
+		}
+		for _, filenames := range ms.Images {
+			var images []image.Image
+			for _, filename := range filenames {
+				img, err := readPNG(filepath.Join(args[0], filename))
+				if err != nil {
+					return err
+				}
+				images = append(images, img)
+			}
+			state.Images = append(state.Images, images)
+		}
+		result.States = append(result.States, state)
+	}
+	encoded, err := result.Encode()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(args[1], encoded, 0644)
+}
+
+func sameImage(a image.Image, b image.Image) bool {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Size() != bb.Size() {
+		return false
+	}
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			ar, ag, abl, aa := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			br, bg, bbl, ba := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			// fully transparent pixels are equivalent regardless of their color
+			if aa == 0 && ba == 0 {
+				continue
+			}
+			if ar != br || ag != bg || abl != bbl || aa != ba {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compares two DMIs and describes each of their differences
+func diffDMIs(a *dmi.DMI, b *dmi.DMI) (differences []string) {
+	if a.Width != b.Width || a.Height != b.Height {
+		differences = append(differences, fmt.Sprintf("size: %dx%d -> %dx%d", a.Width, a.Height, b.Width, b.Height))
+	}
+	statesA, statesB := map[string]dmi.StateImages{}, map[string]dmi.StateImages{}
+	for _, state := range a.States {
+		statesA[stateKey(state)] = state
+	}
+	for _, state := range b.States {
+		statesB[stateKey(state)] = state
+	}
+	for i, state := range a.States {
+		other, found := statesB[stateKey(state)]
+		if !found {
+			differences = append(differences, fmt.Sprintf("- %s", formatState(state)))
+			continue
 		}
-		println(string(result))
+		if formatState(state) != formatState(other) {
+			differences = append(differences, fmt.Sprintf("~ %s -> %s", formatState(state), formatState(other)))
+			continue
+		}
+		if i >= len(b.States) || stateKey(b.States[i]) != stateKey(state) {
+			differences = append(differences, fmt.Sprintf("~ %s moved", stateKey(state)))
+		}
+		for frame := range state.Images {
+			for dir := range state.Images[frame] {
+				if !sameImage(state.Images[frame][dir], other.Images[frame][dir]) {
+					differences = append(differences, fmt.Sprintf("~ %s dir=%s frame=%d: pixels differ",
+						stateKey(state), dmi.DirectionNames[dir], frame+1))
+				}
+			}
+		}
+	}
+	for _, state := range b.States {
+		if _, found := statesA[stateKey(state)]; !found {
+			differences = append(differences, fmt.Sprintf("+ %s", formatState(state)))
+		}
+	}
+	return differences
+}
+
+func diff(args []string) (bool, error) {
+	if len(args) != 2 {
+		return false, errors.New("usage: dmitool diff <old.dmi> <new.dmi>")
+	}
+	a, err := loadDMI(args[0])
+	if err != nil {
+		return false, err
+	}
+	b, err := loadDMI(args[1])
+	if err != nil {
+		return false, err
+	}
+	differences := diffDMIs(a, b)
+	for _, difference := range differences {
+		fmt.Println(difference)
+	}
+	return len(differences) > 0, nil
+}
+
+func usage() {
+	_, _ = fmt.Fprintln(os.Stderr, "usage: dmitool info <icon.dmi> [<icon.dmi> ...]")
+	_, _ = fmt.Fprintln(os.Stderr, "       dmitool list <icon.dmi> [<icon.dmi> ...]")
+	_, _ = fmt.Fprintln(os.Stderr, "       dmitool extract <icon.dmi> <output-dir>")
+	_, _ = fmt.Fprintln(os.Stderr, "       dmitool pack <input-dir> <icon.dmi>")
+	_, _ = fmt.Fprintln(os.Stderr, "       dmitool diff <old.dmi> <new.dmi>")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	differs := false
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "info":
+		err = info(args)
+	case "list":
+		err = list(args)
+	case "extract":
+		err = extract(args)
+	case "pack":
+		err = pack(args)
+	case "diff":
+		differs, err = diff(args)
+	default:
+		if !strings.HasSuffix(os.Args[1], ".dmi") {
+			usage()
+		}
+		// for compatibility, a list of icons without a command is treated as 'info'
+		err = info(os.Args[1:])
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "dmitool:", err)
+		os.Exit(1)
+	}
+	if differs {
+		os.Exit(1)
 	}
 }
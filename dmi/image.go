@@ -0,0 +1,268 @@
+package dmi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a single icon state, along with its frames
+type StateImages struct {
+	Name     string
+	Movement bool
+	Rewind   bool
+	Loop     int
+	Delay    []float64
+	Hotspots []Hotspot
+	Extra    map[string][]string
+	// Images[frame][dir], where directions are in the order that DMIs store them: south, north, east, west, and then
+	// southeast, southwest, northeast, northwest
+	Images [][]image.Image
+}
+
+func (s StateImages) Directions() int {
+	if len(s.Images) == 0 {
+		return 0
+	}
+	return len(s.Images[0])
+}
+
+func (s StateImages) Frames() int {
+	return len(s.Images)
+}
+
+// a DMI with its pixel data, split up into individual frames
+type DMI struct {
+	Width  int
+	Height int
+	// in the order that they are stored in the file
+	States []StateImages
+}
+
+// the names of the directions in the order that DMIs store them
+var DirectionNames = []string{"south", "north", "east", "west", "southeast", "southwest", "northeast", "northwest"}
+
+// in DMIs, frames are laid out left-to-right and top-to-bottom in a grid of ceil(sqrt(n)) columns
+func gridColumns(subicons int) int {
+	columns := int(math.Ceil(math.Sqrt(float64(subicons))))
+	if columns < 1 {
+		columns = 1
+	}
+	return columns
+}
+
+// produces the text of the Description chunk for a DMI
+func FormatDescription(info *DMIInfo) string {
+	names, states := info.SortedStates()
+	var out strings.Builder
+	out.WriteString("# BEGIN DMI\n")
+	out.WriteString("version = 4.0\n")
+	out.WriteString(fmt.Sprintf("\twidth = %d\n", info.Width))
+	out.WriteString(fmt.Sprintf("\theight = %d\n", info.Height))
+	for i, name := range names {
+		state := states[i]
+		out.WriteString(fmt.Sprintf("state = %s\n", formatString(name)))
+		out.WriteString(fmt.Sprintf("\tdirs = %d\n", state.Directions))
+		out.WriteString(fmt.Sprintf("\tframes = %d\n", state.Frames))
+		if len(state.Delay) > 0 {
+			var delays []string
+			for _, delay := range state.Delay {
+				delays = append(delays, strconv.FormatFloat(delay, 'g', -1, 64))
+			}
+			out.WriteString(fmt.Sprintf("\tdelay = %s\n", strings.Join(delays, ",")))
+		}
+		if state.Rewind {
+			out.WriteString("\trewind = 1\n")
+		}
+		if state.Loop != 0 {
+			out.WriteString(fmt.Sprintf("\tloop = %d\n", state.Loop))
+		}
+		if state.Movement {
+			out.WriteString("\tmovement = 1\n")
+		}
+		for _, hotspot := range state.Hotspots {
+			out.WriteString(fmt.Sprintf("\thotspot = %d,%d,%d\n", hotspot.X, hotspot.Y, hotspot.Frame))
+		}
+		var extraKeys []string
+		for key := range state.Extra {
+			extraKeys = append(extraKeys, key)
+		}
+		sort.Strings(extraKeys)
+		for _, key := range extraKeys {
+			for _, value := range state.Extra[key] {
+				out.WriteString(fmt.Sprintf("\t%s = %s\n", key, value))
+			}
+		}
+	}
+	out.WriteString("# END DMI\n")
+	return out.String()
+}
+
+// describes the metadata of a DMI, as ParseDMI would report it after the DMI was encoded
+func (d *DMI) Info() *DMIInfo {
+	info := &DMIInfo{
+		Width:          d.Width,
+		Height:         d.Height,
+		States:         map[string]DMIState{},
+		MovementStates: map[string]DMIState{},
+	}
+	for i, state := range d.States {
+		states := info.States
+		if state.Movement {
+			states = info.MovementStates
+		}
+		states[state.Name] = DMIState{
+			Index:      uint(i),
+			Directions: state.Directions(),
+			Frames:     state.Frames(),
+			Rewind:     state.Rewind,
+			Delay:      state.Delay,
+			Loop:       state.Loop,
+			Hotspots:   state.Hotspots,
+			Movement:   state.Movement,
+			Extra:      state.Extra,
+		}
+	}
+	return info
+}
+
+func (d *DMI) validate() error {
+	if d.Width < 1 || d.Height < 1 {
+		return fmt.Errorf("invalid icon size %dx%d", d.Width, d.Height)
+	}
+	names := map[string]bool{}
+	movementNames := map[string]bool{}
+	for _, state := range d.States {
+		seen := names
+		if state.Movement {
+			seen = movementNames
+		}
+		if seen[state.Name] {
+			return fmt.Errorf("duplicate state %q", state.Name)
+		}
+		seen[state.Name] = true
+		for key, values := range state.Extra {
+			if knownStateKeys[key] || key == "" || strings.ContainsAny(key, " \t\n") {
+				return fmt.Errorf("cannot encode key %q of state %q", key, state.Name)
+			}
+			for _, value := range values {
+				if strings.Contains(value, "\n") {
+					return fmt.Errorf("cannot encode key %q of state %q", key, state.Name)
+				}
+			}
+		}
+		if state.Frames() < 1 {
+			return fmt.Errorf("state %q has no frames", state.Name)
+		}
+		dirs := state.Directions()
+		if dirs != 1 && dirs != 4 && dirs != 8 {
+			return fmt.Errorf("state %q has %d directions, not 1, 4 or 8", state.Name, dirs)
+		}
+		if len(state.Delay) != 0 && len(state.Delay) != state.Frames() {
+			return fmt.Errorf("state %q has %d delays for %d frames", state.Name, len(state.Delay), state.Frames())
+		}
+		for frame, images := range state.Images {
+			if len(images) != dirs {
+				return fmt.Errorf("state %q has %d directions in frame %d, but %d in frame 1",
+					state.Name, len(images), frame+1, dirs)
+			}
+			for dir, img := range images {
+				size := img.Bounds().Size()
+				if size.X != d.Width || size.Y != d.Height {
+					return fmt.Errorf("state %q frame %d dir %s is %dx%d, not %dx%d", state.Name, frame+1,
+						DirectionNames[dir], size.X, size.Y, d.Width, d.Height)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// lays out the frames of each state into a single PNG, and attaches the Description chunk
+func (d *DMI) Encode() ([]byte, error) {
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+	var subicons []image.Image
+	for _, state := range d.States {
+		for _, images := range state.Images {
+			subicons = append(subicons, images...)
+		}
+	}
+	columns := gridColumns(len(subicons))
+	rows := (len(subicons) + columns - 1) / columns
+	if rows < 1 {
+		rows = 1
+	}
+	sheet := image.NewNRGBA(image.Rect(0, 0, columns*d.Width, rows*d.Height))
+	for i, subicon := range subicons {
+		origin := image.Pt((i%columns)*d.Width, (i/columns)*d.Height)
+		target := image.Rectangle{Min: origin, Max: origin.Add(image.Pt(d.Width, d.Height))}
+		draw.Draw(sheet, target, subicon, subicon.Bounds().Min, draw.Src)
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, sheet); err != nil {
+		return nil, err
+	}
+	return insertDescription(encoded.Bytes(), FormatDescription(d.Info()))
+}
+
+// decodes both the metadata and the pixel data of a DMI
+func DecodeDMI(data []byte) (*DMI, error) {
+	info, err := ParseDMI(data)
+	if err != nil {
+		return nil, err
+	}
+	sheet, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bounds := sheet.Bounds()
+	columns := bounds.Dx() / info.Width
+	if columns < 1 || bounds.Dy() < info.Height {
+		return nil, fmt.Errorf("DMI image is %dx%d, smaller than its %dx%d icons",
+			bounds.Dx(), bounds.Dy(), info.Width, info.Height)
+	}
+	rows := bounds.Dy() / info.Height
+	names, states := info.SortedStates()
+	result := &DMI{
+		Width:  info.Width,
+		Height: info.Height,
+	}
+	next := 0
+	for i, name := range names {
+		state := states[i]
+		images := StateImages{
+			Name:     name,
+			Movement: state.Movement,
+			Rewind:   state.Rewind,
+			Loop:     state.Loop,
+			Delay:    state.Delay,
+			Hotspots: state.Hotspots,
+			Extra:    state.Extra,
+		}
+		for frame := 0; frame < state.Frames; frame++ {
+			var dirs []image.Image
+			for dir := 0; dir < state.Directions; dir++ {
+				if next >= columns*rows {
+					return nil, errors.New("DMI image does not contain enough frames for its states")
+				}
+				origin := bounds.Min.Add(image.Pt((next%columns)*info.Width, (next/columns)*info.Height))
+				subicon := image.NewNRGBA(image.Rect(0, 0, info.Width, info.Height))
+				draw.Draw(subicon, subicon.Bounds(), sheet, origin, draw.Src)
+				dirs = append(dirs, subicon)
+				next++
+			}
+			images.Images = append(images.Images, dirs)
+		}
+		result.States = append(result.States, images)
+	}
+	return result, nil
+}
@@ -0,0 +1,81 @@
+package dmi
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func solid(width int, height int, c color.NRGBA) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	var walk [][]image.Image
+	for frame := 0; frame < 2; frame++ {
+		var dirs []image.Image
+		for dir := 0; dir < 4; dir++ {
+			dirs = append(dirs, solid(16, 8, color.NRGBA{R: uint8(frame * 100), G: uint8(dir * 50), B: 7, A: 255}))
+		}
+		walk = append(walk, dirs)
+	}
+	original := &DMI{
+		Width:  16,
+		Height: 8,
+		States: []StateImages{
+			{Name: "idle", Images: [][]image.Image{{solid(16, 8, color.NRGBA{R: 1, G: 2, B: 3, A: 128})}}},
+			{Name: "walk", Rewind: true, Delay: []float64{1, 3}, Images: walk},
+		},
+	}
+	encoded, err := original.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ParseDMI(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Width != 16 || info.Height != 8 || len(info.States) != 2 {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	walkInfo := info.States["walk"]
+	if walkInfo.Index != 1 || walkInfo.Directions != 4 || walkInfo.Frames != 2 || !walkInfo.Rewind ||
+		len(walkInfo.Delay) != 2 || walkInfo.Delay[1] != 3 {
+		t.Errorf("unexpected walk state: %+v", walkInfo)
+	}
+	decoded, err := DecodeDMI(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.States) != 2 || decoded.States[0].Name != "idle" || decoded.States[1].Name != "walk" {
+		t.Fatalf("unexpected states: %+v", decoded.States)
+	}
+	for si, state := range original.States {
+		for frame := range state.Images {
+			for dir, img := range state.Images[frame] {
+				expected := img.At(3, 3)
+				actual := decoded.States[si].Images[frame][dir].At(3, 3)
+				if expected != actual {
+					t.Errorf("state %q frame %d dir %d: expected %v, got %v", state.Name, frame, dir, expected, actual)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeRejectsMismatchedSizes(t *testing.T) {
+	bad := &DMI{
+		Width:  32,
+		Height: 32,
+		States: []StateImages{{Name: "x", Images: [][]image.Image{{solid(16, 16, color.NRGBA{A: 255})}}}},
+	}
+	if _, err := bad.Encode(); err == nil {
+		t.Error("expected an error for a 16x16 frame in a 32x32 icon")
+	}
+}
@@ -11,6 +11,9 @@ import (
 
 const pngHeader = "\x89PNG\r\n\x1a\n"
 
+// the keyword of the zTXt chunk that holds DMI metadata, followed by its null separator and compression method
+const descriptionPrefix = "Description\000\000"
+
 func readChunk(png []byte) (ctype string, data []byte, rest []byte, err error) {
 	if len(png) < 4 {
 		return "", nil, nil, errors.New("truncated PNG chunk")
@@ -45,10 +48,11 @@ func parseDescription(png []byte) (string, error) {
 			return "", err
 		}
 		if ctype == "zTXt" {
-			if !bytes.Equal(data[:13], []byte("Description\000\000")) {
+			if !bytes.HasPrefix(data, []byte(descriptionPrefix)) {
+				png = rest
 				continue
 			}
-			text, err := decompress(data[13:])
+			text, err := decompress(data[len(descriptionPrefix):])
 			if err != nil {
 				return "", err
 			}
@@ -58,3 +62,42 @@ func parseDescription(png []byte) (string, error) {
 	}
 	return "", errors.New("did not find any description zTXt chunk")
 }
+
+func writeChunk(out *bytes.Buffer, ctype string, data []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	out.Write(length[:])
+	body := append([]byte(ctype), data...)
+	out.Write(body)
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.ChecksumIEEE(body))
+	out.Write(crc[:])
+}
+
+// inserts a Description zTXt chunk directly after the IHDR chunk of an encoded PNG
+func insertDescription(png []byte, description string) ([]byte, error) {
+	if len(png) < 8 || string(png[:8]) != pngHeader {
+		return nil, errors.New("invalid PNG header")
+	}
+	ctype, _, rest, err := readChunk(png[8:])
+	if err != nil {
+		return nil, err
+	}
+	if ctype != "IHDR" {
+		return nil, errors.New("expected IHDR as first PNG chunk")
+	}
+	var zdata bytes.Buffer
+	zdata.WriteString(descriptionPrefix)
+	w := zlib.NewWriter(&zdata)
+	if _, err := w.Write([]byte(description)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	out.Write(png[:len(png)-len(rest)])
+	writeChunk(&out, "zTXt", zdata.Bytes())
+	out.Write(rest)
+	return out.Bytes(), nil
+}
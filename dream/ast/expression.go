@@ -23,6 +23,7 @@ const (
 	ExprTypeBooleanNot
 	ExprTypeCall
 	ExprTypeNew
+	ExprTypeIndex
 )
 
 func (et ExprType) String() string {
@@ -53,6 +54,8 @@ func (et ExprType) String() string {
 		return "Call"
 	case ExprTypeNew:
 		return "New"
+	case ExprTypeIndex:
+		return "Index"
 	default:
 		panic(fmt.Sprintf("unrecognized expression type: %d", et))
 	}
@@ -148,6 +151,14 @@ func ExprGetField(expr Expression, field string, loc tokenizer.SourceLocation) E
 	}
 }
 
+func ExprIndex(expr Expression, index Expression, loc tokenizer.SourceLocation) Expression {
+	return Expression{
+		Type:      ExprTypeIndex,
+		Children:  []Expression{expr, index},
+		SourceLoc: loc,
+	}
+}
+
 func ExprBooleanNot(expr Expression, loc tokenizer.SourceLocation) Expression {
 	return Expression{
 		Type:      ExprTypeBooleanNot,
@@ -20,6 +20,7 @@ const (
 	StatementTypeAssign
 	StatementTypeDel
 	StatementTypeForList
+	StatementTypeRead
 )
 
 func (et StatementType) String() string {
@@ -44,6 +45,8 @@ func (et StatementType) String() string {
 		return "Del"
 	case StatementTypeForList:
 		return "ForList"
+	case StatementTypeRead:
+		return "Read"
 	default:
 		panic(fmt.Sprintf("unrecognized statement type: %d", et))
 	}
@@ -74,6 +77,15 @@ func StatementWrite(destination Expression, value Expression, loc tokenizer.Sour
 	}
 }
 
+func StatementRead(source Expression, destination Expression, loc tokenizer.SourceLocation) Statement {
+	return Statement{
+		Type:      StatementTypeRead,
+		From:      source,
+		To:        destination,
+		SourceLoc: loc,
+	}
+}
+
 func StatementIf(condition Expression, body []Statement, loc tokenizer.SourceLocation) Statement {
 	return Statement{
 		Type:      StatementTypeIf,
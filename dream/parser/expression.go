@@ -13,15 +13,22 @@ func parseExpression0(i *input, scope *Scope) (ast.Expression, error) {
 	if i.Accept(tokenizer.TokStringStart) {
 		var subexpressions []ast.Expression
 		capitalize := true
+		// set by a text macro like \ref, which applies to the next embedded expression
+		explicitMacro := ""
 		for !i.Accept(tokenizer.TokStringEnd) {
 			partLoc := i.Peek().Loc
-			if i.Accept(tokenizer.TokStringInsertStart) {
+			if tok, ok := i.AcceptParam(tokenizer.TokStringMacro); ok {
+				explicitMacro = tok.Str
+			} else if i.Accept(tokenizer.TokStringInsertStart) {
 				expr, err := parseExpression(i, scope)
 				if err != nil {
 					return ast.ExprNone(), err
 				}
 				macro := "the"
-				if capitalize {
+				if explicitMacro != "" {
+					macro = explicitMacro
+					explicitMacro = ""
+				} else if capitalize {
 					macro = "The"
 				}
 				subexpressions = append(subexpressions, ast.ExprStringMacro(macro, expr, partLoc))
@@ -116,6 +123,39 @@ func parseExpressionArguments(i *input, scope *Scope) (keywords []string, expres
 	return keywords, expressions, nil
 }
 
+// input() can be followed by "as type|type" and "in list", which are passed to it as the "as" and "in" keyword
+// arguments, since neither can be the name of a real argument
+func parseInputSuffix(i *input, scope *Scope, keywords []string, exprs []ast.Expression) ([]string, []ast.Expression, error) {
+	loc := i.Peek().Loc
+	if i.Accept(tokenizer.TokKeywordAs) {
+		var types []string
+		for {
+			tok, err := i.ExpectParam(tokenizer.TokSymbol)
+			if err != nil {
+				return nil, nil, err
+			}
+			if ast.ProcArgumentFromString(tok.Str) == ast.ProcArgumentNone {
+				return nil, nil, fmt.Errorf("invalid input 'as' type: %q at %v", tok.Str, tok.Loc)
+			}
+			types = append(types, tok.Str)
+			if !i.Accept(tokenizer.TokBar) {
+				break
+			}
+		}
+		keywords = append(keywords, "as")
+		exprs = append(exprs, ast.ExprStringLiteral(strings.Join(types, "|"), loc))
+	}
+	if i.Accept(tokenizer.TokKeywordIn) {
+		list, err := parseExpression(i, scope)
+		if err != nil {
+			return nil, nil, err
+		}
+		keywords = append(keywords, "in")
+		exprs = append(exprs, list)
+	}
+	return keywords, exprs, nil
+}
+
 func parseExpression1(i *input, scope *Scope) (ast.Expression, error) {
 	expr, err := parseExpression0(i, scope)
 	if err != nil {
@@ -128,6 +168,12 @@ func parseExpression1(i *input, scope *Scope) (ast.Expression, error) {
 			if err != nil {
 				return ast.ExprNone(), err
 			}
+			if expr.Type == ast.ExprTypeGetNonLocal && expr.Str == "input" {
+				keywords, exprs, err = parseInputSuffix(i, scope, keywords, exprs)
+				if err != nil {
+					return ast.ExprNone(), err
+				}
+			}
 			expr = ast.ExprCall(expr, keywords, exprs, loc)
 		} else if i.Accept(tokenizer.TokDot) {
 			field, err := i.ExpectParam(tokenizer.TokSymbol)
@@ -135,6 +181,15 @@ func parseExpression1(i *input, scope *Scope) (ast.Expression, error) {
 				return ast.ExprNone(), err
 			}
 			expr = ast.ExprGetField(expr, field.Str, field.Loc)
+		} else if i.Accept(tokenizer.TokBracketOpen) {
+			index, err := parseExpression(i, scope)
+			if err != nil {
+				return ast.ExprNone(), err
+			}
+			if err := i.Expect(tokenizer.TokBracketClose); err != nil {
+				return ast.ExprNone(), err
+			}
+			expr = ast.ExprIndex(expr, index, loc)
 		} else {
 			return expr, nil
 		}
@@ -121,6 +121,15 @@ func parseStatement(i *input, scope *Scope) (ast.Statement, error) {
 				return ast.StatementNone(), err
 			}
 			return ast.StatementWrite(leftHand, rightHand, loc2), nil
+		} else if i.Accept(tokenizer.TokRightShift) {
+			rightHand, err := parseExpression(i, scope)
+			if err != nil {
+				return ast.StatementNone(), err
+			}
+			if err := i.Expect(tokenizer.TokNewline); err != nil {
+				return ast.StatementNone(), err
+			}
+			return ast.StatementRead(leftHand, rightHand, loc2), nil
 		} else if i.Accept(tokenizer.TokSetEqual) {
 			rightHand, err := parseExpression(i, scope)
 			if err != nil {
@@ -135,22 +135,37 @@ func (s *scan) Integer(negative bool) (int64, error) {
 	return strconv.ParseInt(prefix+strInt, 10, 64)
 }
 
-func (s *scan) StringChunk(terminator rune) (string, SourceLocation, error) {
+// the text macros that change how the next embedded expression in a string is formatted
+var textMacros = map[string]bool{
+	"the": true,
+	"The": true,
+	"ref": true,
+}
+
+// if the chunk ends at a text macro, such as \ref, the name of that macro is returned as well
+func (s *scan) StringChunk(terminator rune) (string, SourceLocation, string, error) {
 	var runes []rune
 	loc := s.Loc
 	for {
 		ch := s.Take()
 		if ch == terminator || ch == '[' {
 			s.Untake(ch)
-			return string(runes), loc, nil
+			return string(runes), loc, "", nil
 		}
 		if ch == NoChar {
-			return "", loc, fmt.Errorf("unterminated string chunk at %v", loc)
+			return "", loc, "", fmt.Errorf("unterminated string chunk at %v", loc)
 		}
 		if ch == '\\' {
-			if ch != terminator && ch != '\\' {
-				util.FIXME("handle text macros here")
-				panic("unimplemented: text macros")
+			if unicode.IsLetter(s.Peek()) {
+				word := s.AllMatching(unicode.IsLetter)
+				if textMacros[word] {
+					// as in "\the [item]", where the space separates the macro from the expression
+					s.Accept(' ')
+					return string(runes), loc, word, nil
+				}
+				util.FIXME("handle the rest of the text macros here")
+				runes = append(runes, []rune(word)...)
+				continue
 			}
 			ch = s.Take()
 		}
@@ -191,16 +206,18 @@ func tokenizeInternal(s *scan, output chan<- Token, terminator rune) error {
 		case ch == '"':
 			output <- TokStringStart.token(s.Loc)
 			for !s.Accept('"') {
-				chunk, loc, err := s.StringChunk('"')
+				chunk, loc, macro, err := s.StringChunk('"')
 				if err != nil {
 					return err
 				}
 				if chunk != "" {
 					output <- TokStringLiteral.tokenStr(chunk, loc)
 				}
+				if macro != "" {
+					output <- TokStringMacro.tokenStr(macro, s.Loc)
+				}
 				if s.Accept('[') {
 					output <- TokStringInsertStart.token(s.Loc)
-					util.NiceToHave("fix handling of [ and ] within []")
 					err := tokenizeInternal(s, output, ']')
 					if err != nil {
 						return err
@@ -213,10 +230,13 @@ func tokenizeInternal(s *scan, output chan<- Token, terminator rune) error {
 			}
 			output <- TokStringEnd.token(s.Loc)
 		case ch == '\'':
-			chunk, loc, err := s.StringChunk('\'')
+			chunk, loc, macro, err := s.StringChunk('\'')
 			if err != nil {
 				return err
 			}
+			if macro != "" {
+				return fmt.Errorf("text macro \\%s cannot be used in a resource literal at %v", macro, loc)
+			}
 			if !s.Accept('\'') {
 				return fmt.Errorf("expected resource literal to be ended with a single quote at %v", s.Loc)
 			}
@@ -243,12 +263,25 @@ func tokenizeInternal(s *scan, output chan<- Token, terminator rune) error {
 			output <- TokParenOpen.token(s.Loc)
 		case ch == ')':
 			output <- TokParenClose.token(s.Loc)
+		case ch == '[':
+			output <- TokBracketOpen.token(s.Loc)
+			// tokenized separately so that brackets nested within string insertions don't end the insertion early
+			err := tokenizeInternal(s, output, ']')
+			if err != nil {
+				return err
+			}
+			if !s.Accept(']') {
+				panic("should only have gotten here if we hit a ']'")
+			}
+			output <- TokBracketClose.token(s.Loc)
 		case ch == ',':
 			output <- TokComma.token(s.Loc)
 		case ch == ':':
 			output <- TokColon.token(s.Loc)
 		case ch == ';':
 			output <- TokSemicolon.token(s.Loc)
+		case ch == '|':
+			output <- TokBar.token(s.Loc)
 		case ch == '.':
 			loc := s.Loc
 			if s.Accept('.') {
@@ -17,6 +17,8 @@ const (
 	TokSetEqual
 	TokParenOpen
 	TokParenClose
+	TokBracketOpen
+	TokBracketClose
 	TokComma
 	TokDot
 	TokDotDot
@@ -31,6 +33,7 @@ const (
 	TokLeftShift
 	TokRightShift
 	TokNot
+	TokBar
 
 	// keywords
 	TokKeywordIf
@@ -56,6 +59,7 @@ const (
 	TokStringInsertStart
 	TokStringInsertEnd
 	TokStringLiteral
+	TokStringMacro
 
 	// spacing
 	TokNewline
@@ -134,6 +138,10 @@ func (t TokenType) String() string {
 		return "TokParenOpen"
 	case TokParenClose:
 		return "TokParenClose"
+	case TokBracketOpen:
+		return "TokBracketOpen"
+	case TokBracketClose:
+		return "TokBracketClose"
 	case TokComma:
 		return "TokComma"
 	case TokDot:
@@ -162,6 +170,8 @@ func (t TokenType) String() string {
 		return "TokRightShift"
 	case TokNot:
 		return "TokNot"
+	case TokBar:
+		return "TokBar"
 	case TokKeywordIf:
 		return "TokKeywordIf"
 	case TokKeywordReturn:
@@ -204,6 +214,8 @@ func (t TokenType) String() string {
 		return "TokStringInsertEnd"
 	case TokStringLiteral:
 		return "TokStringLiteral"
+	case TokStringMacro:
+		return "TokStringMacro"
 	case TokNewline:
 		return "TokNewline"
 	case TokSpaces:
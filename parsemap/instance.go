@@ -0,0 +1,230 @@
+package parsemap
+
+import (
+	"fmt"
+	"github.com/celskeggs/mediator/dmconst"
+	"strings"
+)
+
+type ValueKind int
+
+const (
+	ValueNull ValueKind = iota
+	ValueNumber
+	ValueString
+	ValueResource
+	ValuePath
+	ValueList
+)
+
+// a constant value as it can appear in an instance override within a map
+type Value struct {
+	Kind   ValueKind
+	Number float64
+	// the contents of a string, the filename of a resource, or a type path
+	Str  string
+	List []ListEntry
+}
+
+type ListEntry struct {
+	// Key is only meaningful if HasKey is set, which is the case for entries of the form `key = value`
+	HasKey bool
+	Key    Value
+	Value  Value
+}
+
+type VarOverride struct {
+	Name  string
+	Value Value
+}
+
+// an atom placed by a map, like /obj/door{name = "Airlock"; dir = 4}
+type Instance struct {
+	Path string
+	// in the order that they were declared
+	Vars []VarOverride
+}
+
+func NewInstance(path string) Instance {
+	return Instance{
+		Path: path,
+	}
+}
+
+// produces the value in DM constant syntax, as it would be written in a map
+func (v Value) String() string {
+	switch v.Kind {
+	case ValueNull:
+		return "null"
+	case ValueNumber:
+		return fmt.Sprintf("%v", v.Number)
+	case ValueString:
+		return dmconst.QuoteString(v.Str)
+	case ValueResource:
+		return "'" + dmconst.EscapeString(v.Str, "'") + "'"
+	case ValuePath:
+		return v.Str
+	case ValueList:
+		var entries []string
+		for _, entry := range v.List {
+			if entry.HasKey {
+				entries = append(entries, entry.Key.String()+" = "+entry.Value.String())
+			} else {
+				entries = append(entries, entry.Value.String())
+			}
+		}
+		return "list(" + strings.Join(entries, ",") + ")"
+	default:
+		panic("unknown value kind")
+	}
+}
+
+// scans the definitions section of a map
+type scanner struct {
+	dmconst.Scanner
+}
+
+func newScanner(text string) *scanner {
+	return &scanner{dmconst.Scanner{Text: text, Source: "map definitions", Line: 1}}
+}
+
+func (s *scanner) readList() ([]ListEntry, error) {
+	entries := []ListEntry{}
+	if s.Accept(")") {
+		return entries, nil
+	}
+	for {
+		var entry ListEntry
+		start := s.Pos
+		s.SkipSpace()
+		if ident := s.ReadWhile(dmconst.IsIdentChar); ident != "" && ident != "null" && !(ident[0] >= '0' && ident[0] <= '9') && s.Accept("=") {
+			// bare identifiers used as keys are treated as strings, as in DM
+			entry.HasKey = true
+			entry.Key = Value{Kind: ValueString, Str: ident}
+		} else {
+			s.Pos = start
+		}
+		value, err := s.readValue()
+		if err != nil {
+			return nil, err
+		}
+		if !entry.HasKey && s.Accept("=") {
+			entry.HasKey = true
+			entry.Key = value
+			value, err = s.readValue()
+			if err != nil {
+				return nil, err
+			}
+		}
+		entry.Value = value
+		entries = append(entries, entry)
+		if s.Accept(")") {
+			return entries, nil
+		}
+		if err := s.Expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *scanner) readValue() (Value, error) {
+	s.SkipSpace()
+	c := s.Peek()
+	switch {
+	case c == '"' || (c == '{' && strings.HasPrefix(s.Text[s.Pos:], "{\"")):
+		str, err := s.ReadString()
+		return Value{Kind: ValueString, Str: str}, err
+	case c == '\'':
+		s.Pos++
+		str, err := s.ReadStringBody("'")
+		return Value{Kind: ValueResource, Str: str}, err
+	case c == '/':
+		path, err := s.ReadPath()
+		return Value{Kind: ValuePath, Str: path}, err
+	case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		number, err := s.ReadNumber()
+		return Value{Kind: ValueNumber, Number: number}, err
+	case dmconst.IsIdentChar(c):
+		ident, err := s.ReadIdent()
+		if err != nil {
+			return Value{}, err
+		}
+		if ident == "null" {
+			return Value{Kind: ValueNull}, nil
+		} else if ident == "list" {
+			if err := s.Expect("("); err != nil {
+				return Value{}, err
+			}
+			entries, err := s.readList()
+			return Value{Kind: ValueList, List: entries}, err
+		}
+		return Value{}, s.Errorf("unsupported value %q", ident)
+	default:
+		return Value{}, s.Errorf("unexpected character %q at start of value", c)
+	}
+}
+
+func (s *scanner) readInstance() (Instance, error) {
+	path, err := s.ReadPath()
+	if err != nil {
+		return Instance{}, err
+	}
+	instance := NewInstance(path)
+	if !s.Accept("{") {
+		return instance, nil
+	}
+	for !s.Accept("}") {
+		name, err := s.ReadIdent()
+		if err != nil {
+			return Instance{}, err
+		}
+		if err := s.Expect("="); err != nil {
+			return Instance{}, err
+		}
+		value, err := s.readValue()
+		if err != nil {
+			return Instance{}, err
+		}
+		for _, existing := range instance.Vars {
+			if existing.Name == name {
+				return Instance{}, s.Errorf("duplicate override of var %s on %s", name, path)
+			}
+		}
+		instance.Vars = append(instance.Vars, VarOverride{Name: name, Value: value})
+		if !s.Accept(";") {
+			if err := s.Expect("}"); err != nil {
+				return Instance{}, err
+			}
+			break
+		}
+	}
+	return instance, nil
+}
+
+// reads `"key" = (/path{var = value; ...},/path,...)`
+func (s *scanner) readPathSet() (string, []Instance, error) {
+	abbreviation, err := s.ReadString()
+	if err != nil {
+		return "", nil, err
+	}
+	if err := s.Expect("="); err != nil {
+		return "", nil, err
+	}
+	if err := s.Expect("("); err != nil {
+		return "", nil, err
+	}
+	var instances []Instance
+	for {
+		instance, err := s.readInstance()
+		if err != nil {
+			return "", nil, err
+		}
+		instances = append(instances, instance)
+		if s.Accept(")") {
+			return abbreviation, instances, nil
+		}
+		if err := s.Expect(","); err != nil {
+			return "", nil, err
+		}
+	}
+}
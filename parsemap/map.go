@@ -3,6 +3,8 @@ package parsemap
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -20,87 +22,239 @@ func NewLocationI(x int, y int, z int) Location {
 	}
 }
 
+type Format int
+
+const (
+	// the classic layout, with one line per definition and one block per z-level
+	FormatDMM Format = iota
+	// the merge-friendly layout, with one line per instance or var, and one block per column
+	FormatTGM
+)
+
+// written by dmm2tgm.py, and used by other tools, to mark maps in the TGM format
+const TGMHeader = "//MAP CONVERTED BY dmm2tgm.py THIS HEADER COMMENT PREVENTS RECONVERSION, DO NOT REMOVE"
+
+// skips the blank lines and comments at the start of a map, which is where the TGM header is found, and returns the
+// lines that follow them
+func detectFormat(lines []string) (Format, []string) {
+	format := FormatDMM
+	for len(lines) > 0 {
+		line := strings.TrimSpace(lines[0])
+		if strings.HasPrefix(line, "//MAP CONVERTED BY dmm2tgm.py") {
+			format = FormatTGM
+		} else if line != "" && !strings.HasPrefix(line, "//") {
+			break
+		}
+		lines = lines[1:]
+	}
+	return format, lines
+}
+
 type RawMap struct {
-	PathSets map[string][]string
+	Format   Format
+	PathSets map[string][]Instance
 	Content  [][][]string
 }
 
 type MapObserver interface {
 	SetSize(size Location)
-	AddAtom(xyz Location, path string) error
+	AddAtom(xyz Location, instance Instance) error
 }
 
-func readPathSets(header string) (map[string][]string, error) {
-	lines := strings.Split(strings.TrimSpace(header), "\n")
-	out := map[string][]string{}
-	for _, line := range lines {
-		if line[0] != '"' {
-			return nil, errors.New("expected \" at start of pathset line")
+func readPathSets(header string) (map[string][]Instance, error) {
+	s := newScanner(header)
+	out := map[string][]Instance{}
+	for s.SkipSpace(); !s.EOF(); s.SkipSpace() {
+		abbreviation, instances, err := s.readPathSet()
+		if err != nil {
+			return nil, err
 		}
-		if line[len(line)-1] != ')' {
-			return nil, errors.New("expected ) at end of pathset line")
-		}
-		parts := strings.Split(line[1:len(line)-1], "\" = (")
-		if len(parts) != 2 {
-			return nil, errors.New("formatting broken: expected two fields")
-		}
-		abbreviation := parts[0]
-		paths := strings.Split(parts[1], ",")
 		_, prev := out[abbreviation]
 		if prev {
 			return nil, fmt.Errorf("unexpected duplicate abbreviation: %s", abbreviation)
 		}
-		out[abbreviation] = paths
+		out[abbreviation] = instances
 	}
 	return out, nil
 }
 
-// row-column to x-y-z
-func convertRCto3D(rc [][]string) [][][]string {
-	xyz := make([][][]string, len(rc[0]))
-	for coli := 0; coli < len(rc[0]); coli++ {
-		xyz[coli] = make([][]string, len(rc))
+// all abbreviations in a map have the same length, which is how the rows of a block are split into cells
+func keyLength(pathSets map[string][]Instance) (int, error) {
+	length := 0
+	for abbreviation := range pathSets {
+		if length == 0 {
+			length = len(abbreviation)
+		} else if length != len(abbreviation) {
+			return 0, fmt.Errorf("inconsistent abbreviation lengths: %d and %d", length, len(abbreviation))
+		}
+	}
+	if length == 0 {
+		return 0, errors.New("no abbreviations defined in map")
 	}
-	for rowi, row := range rc {
-		for coli, col := range row {
-			xyz[coli][len(rc)-rowi-1] = []string{col}
+	return length, nil
+}
+
+// a single (x,y,z) = {"..."} section of a map; Rows are listed from north to south.
+// classic maps have one block per z-level, and TGM maps have one block per column.
+type mapBlock struct {
+	Origin Location
+	Rows   [][]string
+}
+
+func (b mapBlock) Width() int {
+	if len(b.Rows) == 0 {
+		return 0
+	}
+	return len(b.Rows[0])
+}
+
+var blockHeader = regexp.MustCompile(`^\((\d+),(\d+),(\d+)\) = \{"$`)
+
+func parseBlockHeader(line string) (Location, error) {
+	match := blockHeader.FindStringSubmatch(line)
+	if match == nil {
+		return Location{}, fmt.Errorf("could not understand worldmap block header: %q", line)
+	}
+	var coords [3]uint32
+	for i := range coords {
+		value, err := strconv.ParseUint(match[i+1], 10, 32)
+		if err != nil {
+			return Location{}, err
+		}
+		if value < 1 {
+			return Location{}, fmt.Errorf("worldmap block coordinates start at 1, not %d", value)
+		}
+		coords[i] = uint32(value)
+	}
+	return Location{X: coords[0], Y: coords[1], Z: coords[2]}, nil
+}
+
+func splitRow(row string, keyLength int) ([]string, error) {
+	if len(row)%keyLength != 0 {
+		return nil, fmt.Errorf("worldmap row length %d is not a multiple of the key length %d", len(row), keyLength)
+	}
+	cells := make([]string, len(row)/keyLength)
+	for coli := range cells {
+		cells[coli] = row[coli*keyLength : (coli+1)*keyLength]
+	}
+	return cells, nil
+}
+
+func readBlocks(lines []string, keyLength int) (blocks []mapBlock, err error) {
+	for i := 0; i < len(lines); i++ {
+		if lines[i] == "" {
+			continue
+		}
+		origin, err := parseBlockHeader(lines[i])
+		if err != nil {
+			return nil, err
+		}
+		block := mapBlock{Origin: origin}
+		for i++; i < len(lines) && lines[i] != "\"}"; i++ {
+			cells, err := splitRow(lines[i], keyLength)
+			if err != nil {
+				return nil, err
+			}
+			if len(block.Rows) > 0 && len(cells) != block.Width() {
+				return nil, errors.New("inconsistent row lengths in worldmap block")
+			}
+			block.Rows = append(block.Rows, cells)
+		}
+		if i >= len(lines) {
+			return nil, fmt.Errorf("could not find end of worldmap block at %v", origin)
 		}
+		if len(block.Rows) == 0 {
+			return nil, fmt.Errorf("empty worldmap block at %v", origin)
+		}
+		blocks = append(blocks, block)
+	}
+	if len(blocks) == 0 {
+		return nil, errors.New("no worldmap blocks found")
 	}
-	return xyz
+	return blocks, nil
 }
 
-func readContent(body string) ([][][]string, error) {
-	lines := strings.Split(strings.TrimSpace(body), "\n")
-	if lines[0] != "(1,1,1) = {\"" {
-		return nil, fmt.Errorf("could not understand first worldmap line")
+// arranges the row-column contents of each block into x-y-z order
+func convertBlocksTo3D(blocks []mapBlock) ([][][]string, error) {
+	var size Location
+	for _, block := range blocks {
+		if x := block.Origin.X - 1 + uint32(block.Width()); x > size.X {
+			size.X = x
+		}
+		if y := block.Origin.Y - 1 + uint32(len(block.Rows)); y > size.Y {
+			size.Y = y
+		}
+		if block.Origin.Z > size.Z {
+			size.Z = block.Origin.Z
+		}
 	}
-	if lines[len(lines)-1] != "\"}" {
-		return nil, fmt.Errorf("could not understand last worldmap line")
+	xyz := make([][][]string, size.X)
+	for x := range xyz {
+		xyz[x] = make([][]string, size.Y)
+		for y := range xyz[x] {
+			xyz[x][y] = make([]string, size.Z)
+		}
 	}
-	rowColumn := make([][]string, len(lines)-2)
-	for rowi, row := range lines[1 : len(lines)-1] {
-		rowColumn[rowi] = make([]string, len(row))
-		for coli := 0; coli < len(row); coli++ {
-			rowColumn[rowi][coli] = row[coli : coli+1]
+	for _, block := range blocks {
+		for rowi, row := range block.Rows {
+			y := int(block.Origin.Y-1) + len(block.Rows) - rowi - 1
+			for coli, cell := range row {
+				x := int(block.Origin.X-1) + coli
+				z := int(block.Origin.Z - 1)
+				if xyz[x][y][z] != "" {
+					return nil, fmt.Errorf("worldmap blocks overlap at (%d,%d,%d)", x+1, y+1, z+1)
+				}
+				xyz[x][y][z] = cell
+			}
 		}
 	}
-	return convertRCto3D(rowColumn), nil
+	for x := range xyz {
+		for y := range xyz[x] {
+			for z, cell := range xyz[x][y] {
+				if cell == "" {
+					return nil, fmt.Errorf("worldmap blocks do not cover (%d,%d,%d)", x+1, y+1, z+1)
+				}
+			}
+		}
+	}
+	return xyz, nil
+}
+
+func readContent(lines []string, keyLength int, format Format) ([][][]string, error) {
+	blocks, err := readBlocks(lines, keyLength)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatTGM {
+		for _, block := range blocks {
+			if block.Width() != 1 {
+				return nil, fmt.Errorf("expected TGM worldmap block at %v to contain a single column", block.Origin)
+			}
+		}
+	}
+	return convertBlocksTo3D(blocks)
 }
 
 func readRawMap(text string) (*RawMap, error) {
-	parts := strings.Split(strings.TrimSpace(text), "\n\n")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("worldmap format support incomplete: more than one double newline: %v", parts)
+	format, lines := detectFormat(strings.Split(strings.TrimSpace(text), "\n"))
+	split := 0
+	for split < len(lines) && !strings.HasPrefix(lines[split], "(") {
+		split++
+	}
+	pathSets, err := readPathSets(strings.Join(lines[:split], "\n"))
+	if err != nil {
+		return nil, err
 	}
-	pathSets, err := readPathSets(parts[0])
+	keyLength, err := keyLength(pathSets)
 	if err != nil {
 		return nil, err
 	}
-	content, err := readContent(parts[1])
+	content, err := readContent(lines[split:], keyLength, format)
 	if err != nil {
 		return nil, err
 	}
 	return &RawMap{
+		Format:   format,
 		PathSets: pathSets,
 		Content:  content,
 	}, nil
@@ -117,12 +271,12 @@ func ProduceMap(text string, observer MapObserver) error {
 		for y := 0; y < len(raw.Content[x]); y++ {
 			for z := 0; z < len(raw.Content[x][y]); z++ {
 				entry := raw.Content[x][y][z]
-				types, found := raw.PathSets[entry]
+				instances, found := raw.PathSets[entry]
 				if !found {
 					return fmt.Errorf("no such abbreviation: %s", entry)
 				}
-				for _, path := range types {
-					err := observer.AddAtom(NewLocationI(x, y, z), path)
+				for _, instance := range instances {
+					err := observer.AddAtom(NewLocationI(x, y, z), instance)
 					if err != nil {
 						return err
 					}
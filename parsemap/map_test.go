@@ -0,0 +1,221 @@
+package parsemap
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type atomRecord struct {
+	Location Location
+	Path     string
+}
+
+type recordingObserver struct {
+	Size  Location
+	Atoms []atomRecord
+}
+
+func (r *recordingObserver) SetSize(size Location) {
+	r.Size = size
+}
+
+func (r *recordingObserver) AddAtom(xyz Location, instance Instance) error {
+	r.Atoms = append(r.Atoms, atomRecord{Location: xyz, Path: instance.Path})
+	return nil
+}
+
+func (r *recordingObserver) PathsAt(x, y, z int) (paths []string) {
+	for _, atom := range r.Atoms {
+		if atom.Location == NewLocationI(x, y, z) {
+			paths = append(paths, atom.Path)
+		}
+	}
+	return paths
+}
+
+const multiZMap = `"aa" = (/turf/floor,/area)
+"ab" = (/obj/table,/turf/wall,/area)
+
+(1,1,1) = {"
+aaab
+abaa
+"}
+
+(1,1,2) = {"
+abab
+aaaa
+"}
+`
+
+func TestProduceMapMultiZ(t *testing.T) {
+	var observer recordingObserver
+	assert.NoError(t, ProduceMap(multiZMap, &observer))
+	assert.Equal(t, NewLocationI(2, 2, 2), observer.Size)
+	// rows are listed from north to south, so the last row is y=1
+	assert.Equal(t, []string{"/obj/table", "/turf/wall", "/area"}, observer.PathsAt(0, 0, 0))
+	assert.Equal(t, []string{"/turf/floor", "/area"}, observer.PathsAt(1, 0, 0))
+	assert.Equal(t, []string{"/obj/table", "/turf/wall", "/area"}, observer.PathsAt(1, 1, 0))
+	assert.Equal(t, []string{"/turf/floor", "/area"}, observer.PathsAt(0, 0, 1))
+	assert.Equal(t, []string{"/obj/table", "/turf/wall", "/area"}, observer.PathsAt(0, 1, 1))
+}
+
+func TestProduceMapWindowsNewlines(t *testing.T) {
+	var observer recordingObserver
+	assert.NoError(t, ProduceMap("\"a\" = (/turf)\r\n\r\n(1,1,1) = {\"\r\naa\r\n\"}\r\n", &observer))
+	assert.Equal(t, NewLocationI(2, 1, 1), observer.Size)
+	assert.Len(t, observer.Atoms, 2)
+}
+
+func TestProduceMapUncoveredLevel(t *testing.T) {
+	var observer recordingObserver
+	err := ProduceMap("\"a\" = (/turf)\n\n(1,1,1) = {\"\na\n\"}\n\n(1,1,3) = {\"\na\n\"}\n", &observer)
+	assert.Error(t, err)
+}
+
+const overrideMap = `"a" = (/obj/door{name = "Airlock, \"east\" {side}"; dir = 4},/obj/sign{pixel_x = -32; tags = list("a","b" = 2.5,c = null); desc = null},/turf/floor{icon = 'floors.dmi'},/area)
+
+(1,1,1) = {"
+a
+"}
+`
+
+func TestReadPathSetsOverrides(t *testing.T) {
+	raw, err := readRawMap(overrideMap)
+	assert.NoError(t, err)
+	instances := raw.PathSets["a"]
+	if !assert.Len(t, instances, 4) {
+		return
+	}
+	assert.Equal(t, Instance{
+		Path: "/obj/door",
+		Vars: []VarOverride{
+			{Name: "name", Value: Value{Kind: ValueString, Str: "Airlock, \"east\" {side}"}},
+			{Name: "dir", Value: Value{Kind: ValueNumber, Number: 4}},
+		},
+	}, instances[0])
+	assert.Equal(t, Instance{
+		Path: "/obj/sign",
+		Vars: []VarOverride{
+			{Name: "pixel_x", Value: Value{Kind: ValueNumber, Number: -32}},
+			{Name: "tags", Value: Value{Kind: ValueList, List: []ListEntry{
+				{Value: Value{Kind: ValueString, Str: "a"}},
+				{HasKey: true, Key: Value{Kind: ValueString, Str: "b"}, Value: Value{Kind: ValueNumber, Number: 2.5}},
+				{HasKey: true, Key: Value{Kind: ValueString, Str: "c"}, Value: Value{Kind: ValueNull}},
+			}}},
+			{Name: "desc", Value: Value{Kind: ValueNull}},
+		},
+	}, instances[1])
+	assert.Equal(t, Instance{
+		Path: "/turf/floor",
+		Vars: []VarOverride{
+			{Name: "icon", Value: Value{Kind: ValueResource, Str: "floors.dmi"}},
+		},
+	}, instances[2])
+	assert.Equal(t, NewInstance("/area"), instances[3])
+}
+
+func TestReadPathSetsErrors(t *testing.T) {
+	for _, header := range []string{
+		`"a" = (/obj{name = "unterminated})`,
+		`"a" = (/obj{name = "x"; name = "y"})`,
+		`"a" = (/obj{dir = NORTH})`,
+		`"a" = (/obj/)`,
+	} {
+		_, err := readPathSets(header)
+		assert.Error(t, err, header)
+	}
+}
+
+const tgmMap = TGMHeader + `
+"aa" = (
+/obj/door{
+	dir = 4;
+	name = "Airlock"
+	},
+/turf/floor,
+/area)
+"ab" = (
+/turf/wall,
+/area)
+
+(1,1,1) = {"
+ab
+aa
+aa
+"}
+(2,1,1) = {"
+ab
+ab
+aa
+"}
+`
+
+func TestProduceMapTGM(t *testing.T) {
+	raw, err := readRawMap(tgmMap)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatTGM, raw.Format)
+	assert.Equal(t, []VarOverride{
+		{Name: "dir", Value: Value{Kind: ValueNumber, Number: 4}},
+		{Name: "name", Value: Value{Kind: ValueString, Str: "Airlock"}},
+	}, raw.PathSets["aa"][0].Vars)
+
+	var observer recordingObserver
+	assert.NoError(t, ProduceMap(tgmMap, &observer))
+	assert.Equal(t, NewLocationI(2, 3, 1), observer.Size)
+	assert.Equal(t, []string{"/turf/wall", "/area"}, observer.PathsAt(0, 2, 0))
+	assert.Equal(t, []string{"/obj/door", "/turf/floor", "/area"}, observer.PathsAt(0, 1, 0))
+	assert.Equal(t, []string{"/turf/wall", "/area"}, observer.PathsAt(1, 1, 0))
+	assert.Equal(t, []string{"/obj/door", "/turf/floor", "/area"}, observer.PathsAt(1, 0, 0))
+}
+
+func TestDetectFormatSkipsComments(t *testing.T) {
+	raw, err := readRawMap("// the first floor\n\n" + multiZMap)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatDMM, raw.Format)
+	assert.Len(t, raw.PathSets, 2)
+
+	// the TGM header is still recognized after other comments
+	raw, err = readRawMap("// the airlock\n\n" + tgmMap)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatTGM, raw.Format)
+	var observer recordingObserver
+	assert.NoError(t, ProduceMap("// the airlock\n"+tgmMap, &observer))
+	assert.Equal(t, NewLocationI(2, 3, 1), observer.Size)
+}
+
+func TestWriteMapRoundTrip(t *testing.T) {
+	door := Instance{
+		Path: "/obj/door",
+		Vars: []VarOverride{
+			{Name: "name", Value: Value{Kind: ValueString, Str: "Airlock \"east\"\\\n"}},
+			{Name: "icon", Value: Value{Kind: ValueResource, Str: "doors.dmi"}},
+			{Name: "tags", Value: Value{Kind: ValueList, List: []ListEntry{
+				{Value: Value{Kind: ValueNumber, Number: -2.5}},
+				{HasKey: true, Key: Value{Kind: ValueString, Str: "kind"}, Value: Value{Kind: ValuePath, Str: "/obj/key"}},
+			}}},
+		},
+	}
+	floor := []Instance{NewInstance("/turf/floor"), NewInstance("/area")}
+	cells := [][][][]Instance{
+		{{floor, floor}, {append([]Instance{door}, floor...), floor}},
+		{{floor, floor}, {floor, floor}},
+		{{floor, floor}, {floor, {NewInstance("/turf/wall"), NewInstance("/area")}}},
+	}
+	for _, format := range []Format{FormatDMM, FormatTGM} {
+		raw := NewRawMap(format, cells)
+		assert.Len(t, raw.PathSets, 3)
+		text := WriteMap(raw)
+		reread, err := readRawMap(text)
+		if !assert.NoError(t, err, text) {
+			continue
+		}
+		assert.Equal(t, raw, reread)
+		assert.Equal(t, text, WriteMap(reread))
+	}
+}
+
+func TestWriteMapCanonicalDMM(t *testing.T) {
+	raw, err := readRawMap(multiZMap)
+	assert.NoError(t, err)
+	assert.Equal(t, multiZMap, WriteMap(raw))
+}
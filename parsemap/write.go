@@ -0,0 +1,135 @@
+package parsemap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// the characters used for abbreviations, in the order that DreamMaker assigns them
+const keyAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+func makeKey(index int, length int) string {
+	key := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		key[i] = keyAlphabet[index%len(keyAlphabet)]
+		index /= len(keyAlphabet)
+	}
+	return string(key)
+}
+
+func encodeInstance(instance Instance, separator string, indent string) string {
+	if len(instance.Vars) == 0 {
+		return instance.Path
+	}
+	var vars []string
+	for _, override := range instance.Vars {
+		vars = append(vars, indent+override.Name+" = "+override.Value.String())
+	}
+	if indent == "" {
+		return instance.Path + "{" + strings.Join(vars, separator) + "}"
+	}
+	return instance.Path + "{\n" + strings.Join(vars, separator) + "\n" + indent + "}"
+}
+
+func encodeInstances(instances []Instance, format Format) string {
+	var parts []string
+	for _, instance := range instances {
+		if format == FormatTGM {
+			parts = append(parts, encodeInstance(instance, ";\n", "\t"))
+		} else {
+			parts = append(parts, encodeInstance(instance, "; ", ""))
+		}
+	}
+	if format == FormatTGM {
+		return "(\n" + strings.Join(parts, ",\n") + ")"
+	}
+	return "(" + strings.Join(parts, ",") + ")"
+}
+
+// builds a map from the instances at each location, indexed as [x][y][z], sharing abbreviations between cells with
+// identical contents
+func NewRawMap(format Format, cells [][][][]Instance) *RawMap {
+	var order []string
+	unique := map[string][]Instance{}
+	for x := range cells {
+		for y := range cells[x] {
+			for _, instances := range cells[x][y] {
+				encoded := encodeInstances(instances, FormatDMM)
+				if _, found := unique[encoded]; !found {
+					unique[encoded] = instances
+					order = append(order, encoded)
+				}
+			}
+		}
+	}
+	length := 1
+	for capacity := len(keyAlphabet); capacity < len(order); capacity *= len(keyAlphabet) {
+		length++
+	}
+	keys := map[string]string{}
+	raw := &RawMap{
+		Format:   format,
+		PathSets: map[string][]Instance{},
+		Content:  make([][][]string, len(cells)),
+	}
+	for i, encoded := range order {
+		keys[encoded] = makeKey(i, length)
+		raw.PathSets[keys[encoded]] = unique[encoded]
+	}
+	for x := range cells {
+		raw.Content[x] = make([][]string, len(cells[x]))
+		for y := range cells[x] {
+			raw.Content[x][y] = make([]string, len(cells[x][y]))
+			for z, instances := range cells[x][y] {
+				raw.Content[x][y][z] = keys[encodeInstances(instances, FormatDMM)]
+			}
+		}
+	}
+	return raw
+}
+
+// serializes a map in the canonical layout for its format, which ProduceMap can read back in
+func WriteMap(raw *RawMap) string {
+	var out strings.Builder
+	if raw.Format == FormatTGM {
+		out.WriteString(TGMHeader + "\n")
+	}
+	var keys []string
+	for key := range raw.PathSets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		out.WriteString(fmt.Sprintf("\"%s\" = %s\n", key, encodeInstances(raw.PathSets[key], raw.Format)))
+	}
+	if len(raw.Content) == 0 || len(raw.Content[0]) == 0 {
+		return out.String()
+	}
+	sizeX, sizeY, sizeZ := len(raw.Content), len(raw.Content[0]), len(raw.Content[0][0])
+	if raw.Format == FormatTGM {
+		out.WriteString("\n")
+		for z := 0; z < sizeZ; z++ {
+			for x := 0; x < sizeX; x++ {
+				out.WriteString(fmt.Sprintf("(%d,1,%d) = {\"\n", x+1, z+1))
+				for y := sizeY - 1; y >= 0; y-- {
+					out.WriteString(raw.Content[x][y][z] + "\n")
+				}
+				out.WriteString("\"}\n")
+			}
+		}
+	} else {
+		for z := 0; z < sizeZ; z++ {
+			out.WriteString(fmt.Sprintf("\n(1,1,%d) = {\"\n", z+1))
+			// rows are listed from north to south
+			for y := sizeY - 1; y >= 0; y-- {
+				for x := 0; x < sizeX; x++ {
+					out.WriteString(raw.Content[x][y][z])
+				}
+				out.WriteString("\n")
+			}
+			out.WriteString("\"}\n")
+		}
+	}
+	return out.String()
+}
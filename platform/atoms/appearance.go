@@ -1,7 +1,9 @@
 package atoms
 
 import (
+	"fmt"
 	"github.com/celskeggs/mediator/common"
+	"github.com/celskeggs/mediator/platform/datum"
 	"github.com/celskeggs/mediator/platform/icon"
 	"github.com/celskeggs/mediator/platform/types"
 	"github.com/celskeggs/mediator/util"
@@ -15,6 +17,19 @@ const (
 	MobLayer  = 4
 )
 
+// overlays and underlays on this layer are drawn directly above or below the appearance that they are attached to
+const FloatLayer = -1
+
+// values of blend_mode
+const (
+	BlendDefault      = 0
+	BlendOverlay      = 1
+	BlendAdd          = 2
+	BlendSubtract     = 3
+	BlendMultiply     = 4
+	BlendInsetOverlay = 5
+)
+
 type Appearance struct {
 	Icon      *icon.Icon
 	IconState string
@@ -22,30 +37,252 @@ type Appearance struct {
 	Name      string
 	Desc      string
 	Suffix    string
+	// pixel_x and pixel_w both shift the icon horizontally, and pixel_y and pixel_z both shift it vertically; they are
+	// only distinct for isometric maps, which are not supported
+	PixelX, PixelY int
+	PixelW, PixelZ int
+	// at most one of Color and ColorMatrix is set; ColorMatrix always has all 20 entries
+	Color       string
+	ColorMatrix []float64
+	// from 0 (transparent) to 255 (opaque)
+	Alpha        int
+	Transform    Matrix
+	BlendMode    int
+	MouseOpacity int
+	Invisibility int
+	// drawn below and above this appearance, in order. these slices are shared between copies of an appearance, so
+	// they are replaced rather than modified.
+	Underlays []Appearance
+	Overlays  []Appearance
+}
+
+func NewAppearance(name string) Appearance {
+	return Appearance{
+		Name:         name,
+		Alpha:        255,
+		Transform:    *IdentityMatrix(),
+		MouseOpacity: 1,
+	}
+}
+
+func colorComponents(c string) ([]float64, bool, error) {
+	parsed, err := icon.ParseColor(types.String(c))
+	if err != nil {
+		return nil, false, err
+	}
+	hasAlpha := len(c) == 5 || len(c) == 9
+	return []float64{float64(parsed.R) / 255, float64(parsed.G) / 255, float64(parsed.B) / 255, float64(parsed.A) / 255},
+		hasAlpha, nil
+}
+
+// converts a list of numbers or colors, in any of the forms that DM accepts, into a full 20-entry color matrix, which
+// has rows for red, green, blue, alpha and a constant. each output channel is the sum of the input channels weighted
+// by their rows, plus the constant row.
+func ParseColorMatrix(elements []types.Value) ([]float64, error) {
+	matrix := []float64{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0}
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("empty color matrix")
+	}
+	if _, isString := elements[0].(types.String); isString {
+		if len(elements) < 3 || len(elements) > 5 {
+			return nil, fmt.Errorf("color matrix has %d colors, not 3 to 5", len(elements))
+		}
+		for row, element := range elements {
+			s, ok := element.(types.String)
+			if !ok {
+				return nil, fmt.Errorf("color matrix mixes colors and numbers")
+			}
+			components, hasAlpha, err := colorComponents(string(s))
+			if err != nil {
+				return nil, err
+			}
+			// colors without alpha only affect the alpha channel when they are the alpha row
+			if !hasAlpha && row != 3 {
+				components[3] = 0
+			}
+			copy(matrix[row*4:row*4+4], components)
+		}
+		return matrix, nil
+	}
+	numbers := make([]float64, len(elements))
+	for i, element := range elements {
+		switch n := element.(type) {
+		case types.Int, types.Float:
+			numbers[i] = types.Unnumber(n)
+		default:
+			return nil, fmt.Errorf("color matrix mixes colors and numbers")
+		}
+	}
+	switch len(numbers) {
+	case 9, 12:
+		// three channels, optionally followed by the constants; alpha passes through unchanged
+		for row := 0; row < 3; row++ {
+			copy(matrix[row*4:row*4+3], numbers[row*3:row*3+3])
+		}
+		if len(numbers) == 12 {
+			copy(matrix[16:19], numbers[9:12])
+		}
+	case 16, 20:
+		copy(matrix, numbers)
+	default:
+		return nil, fmt.Errorf("color matrix has %d entries, not 9, 12, 16 or 20", len(numbers))
+	}
+	return matrix, nil
+}
+
+// the value of the color var, which is either null, a color or a list
+func (a Appearance) ColorValue() types.Value {
+	if a.ColorMatrix != nil {
+		entries := make([]types.Value, len(a.ColorMatrix))
+		for i, entry := range a.ColorMatrix {
+			entries[i] = types.Number(entry)
+		}
+		return datum.NewList(entries...)
+	}
+	if a.Color != "" {
+		return types.String(a.Color)
+	}
+	return nil
+}
+
+// sets the color var from null, a color or a list
+func (a *Appearance) SetColorValue(value types.Value) {
+	a.Color, a.ColorMatrix = "", nil
+	switch v := value.(type) {
+	case nil:
+	case types.String:
+		c, err := icon.ParseColor(v)
+		if err != nil {
+			panic(err.Error())
+		}
+		a.Color = icon.FormatColor(c)
+	case datum.List:
+		matrix, err := ParseColorMatrix(datum.Elements(v))
+		if err != nil {
+			panic(err.Error())
+		}
+		a.ColorMatrix = matrix
+	default:
+		panic("cannot set color to " + v.String())
+	}
 }
 
 var _ types.Value = Appearance{}
 
-func (a Appearance) ToSprite(x, y uint, dir common.Direction) (bool, int, sprite.GameSprite) {
-	util.FIXME("implement correct sizing")
+// x and y are the pixel position of the bottom-left corner of the atom's tile; icons larger than a tile extend up
+// and to the right over the neighboring tiles
+func (a Appearance) ToSprite(x, y int, dir common.Direction) (bool, int, sprite.GameSprite) {
 	if a.Icon == nil {
 		return false, 0, sprite.GameSprite{}
 	}
-	iconName, frames, sourceWidth, sourceHeight := a.Icon.Render(a.IconState, dir)
-	return true, a.Layer, sprite.GameSprite{
-		Icon:         iconName,
-		Frames:       frames,
-		SourceWidth:  sourceWidth,
-		SourceHeight: sourceHeight,
-		X:            x,
-		Y:            y,
-		Width:        32,
-		Height:       32,
+	util.NiceToHave("display movement states while atoms glide between turfs")
+	iconName, frames, sourceWidth, sourceHeight := a.Icon.Render(a.IconState, false, dir)
+	s := sprite.GameSprite{
+		SpriteLook: sprite.SpriteLook{
+			Icon:         iconName,
+			Frames:       frames,
+			Animation:    a.Icon.Animation(a.IconState, false),
+			SourceWidth:  sourceWidth,
+			SourceHeight: sourceHeight,
+			Width:        sourceWidth,
+			Height:       sourceHeight,
+			Color:        a.Color,
+			ColorMatrix:  a.ColorMatrix,
+			Alpha:        a.Alpha,
+			BlendMode:    a.BlendMode,
+			MouseOpacity: a.MouseOpacity,
+		},
+		X: x + a.PixelX + a.PixelW,
+		Y: y + a.PixelY + a.PixelZ,
+	}
+	if !a.Transform.IsIdentity() {
+		s.Transform = a.Transform.Entries()
+	}
+	return true, a.Layer, s
+}
+
+// a sprite, along with the layer that it is drawn on
+type LayeredSprite struct {
+	Layer  int
+	Sprite sprite.GameSprite
+}
+
+// applies the visual effects of the appearance that an overlay or underlay is attached to
+func (a Appearance) attachedTo(parent Appearance) Appearance {
+	a.PixelX += parent.PixelX
+	a.PixelY += parent.PixelY
+	a.PixelW += parent.PixelW
+	a.PixelZ += parent.PixelZ
+	a.Alpha = a.Alpha * parent.Alpha / 255
+	if a.Color == "" && a.ColorMatrix == nil {
+		a.Color, a.ColorMatrix = parent.Color, parent.ColorMatrix
+	}
+	a.Transform.multiply(&parent.Transform)
+	if a.Layer == FloatLayer {
+		a.Layer = parent.Layer
+	}
+	return a
+}
+
+// nextPart numbers the sprites of underlays and overlays; the sprite of the appearance at the root is part zero
+func (a Appearance) collectSprites(x, y int, dir common.Direction, root bool, nextPart *uint, out []LayeredSprite) []LayeredSprite {
+	for _, underlay := range a.Underlays {
+		out = underlay.attachedTo(a).collectSprites(x, y, dir, false, nextPart, out)
+	}
+	if found, layer, s := a.ToSprite(x, y, dir); found {
+		if !root {
+			s.Part = *nextPart
+			*nextPart++
+		}
+		out = append(out, LayeredSprite{Layer: layer, Sprite: s})
+	}
+	for _, overlay := range a.Overlays {
+		out = overlay.attachedTo(a).collectSprites(x, y, dir, false, nextPart, out)
+	}
+	return out
+}
+
+// produces the sprites for this appearance and each of its underlays and overlays, in the order that they are drawn
+// within each layer
+func (a Appearance) ToSprites(x, y int, dir common.Direction) []LayeredSprite {
+	nextPart := uint(1)
+	return a.collectSprites(x, y, dir, true, &nextPart, nil)
+}
+
+func appearancesEqual(a []Appearance, b []Appearance) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a Appearance) Equal(other types.Value) bool {
+	o, ok := other.(Appearance)
+	if !ok {
+		return false
+	}
+	if len(a.ColorMatrix) != len(o.ColorMatrix) {
+		return false
+	}
+	for i, entry := range a.ColorMatrix {
+		if o.ColorMatrix[i] != entry {
+			return false
+		}
 	}
+	return a.Icon == o.Icon && a.IconState == o.IconState && a.Layer == o.Layer && a.Name == o.Name &&
+		a.Desc == o.Desc && a.Suffix == o.Suffix && a.PixelX == o.PixelX && a.PixelY == o.PixelY &&
+		a.PixelW == o.PixelW && a.PixelZ == o.PixelZ && a.Color == o.Color && a.Alpha == o.Alpha &&
+		a.Transform == o.Transform && a.BlendMode == o.BlendMode && a.MouseOpacity == o.MouseOpacity &&
+		a.Invisibility == o.Invisibility &&
+		appearancesEqual(a.Underlays, o.Underlays) && appearancesEqual(a.Overlays, o.Overlays)
 }
 
 func (a Appearance) Var(name string) types.Value {
-	util.FIXME("support opacity (and other variables)")
 	switch name {
 	case "desc":
 		return types.String(a.Desc)
@@ -59,6 +296,30 @@ func (a Appearance) Var(name string) types.Value {
 		return types.String(a.Name)
 	case "suffix":
 		return types.String(a.Suffix)
+	case "pixel_x":
+		return types.Int(a.PixelX)
+	case "pixel_y":
+		return types.Int(a.PixelY)
+	case "pixel_w":
+		return types.Int(a.PixelW)
+	case "pixel_z":
+		return types.Int(a.PixelZ)
+	case "color":
+		return a.ColorValue()
+	case "alpha":
+		return types.Int(a.Alpha)
+	case "transform":
+		return a.Transform.Copy()
+	case "blend_mode":
+		return types.Int(a.BlendMode)
+	case "mouse_opacity":
+		return types.Int(a.MouseOpacity)
+	case "invisibility":
+		return types.Int(a.Invisibility)
+	case "underlays":
+		return appearanceList(a.Underlays)
+	case "overlays":
+		return appearanceList(a.Overlays)
 	default:
 		panic("no such field " + name + " on appearance")
 	}
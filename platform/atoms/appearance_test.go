@@ -0,0 +1,97 @@
+package atoms
+
+import (
+	"github.com/celskeggs/mediator/common"
+	"github.com/celskeggs/mediator/dmi"
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/icon"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/resourcepack"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"testing"
+)
+
+func newTestAppearance(t *testing.T) Appearance {
+	cache, err := icon.NewIconCache(&resourcepack.ResourcePack{Resources: map[string]resourcepack.Resource{}})
+	assert.NoError(t, err)
+	blank, err := cache.Register(&dmi.DMI{
+		Width:  32,
+		Height: 32,
+		States: []dmi.StateImages{{Images: [][]image.Image{{image.NewNRGBA(image.Rect(0, 0, 32, 32))}}}},
+	})
+	assert.NoError(t, err)
+	a := NewAppearance("test")
+	a.Icon = blank
+	return a
+}
+
+func TestToSpriteVisuals(t *testing.T) {
+	a := newTestAppearance(t)
+	found, _, s := a.ToSprite(64, 32, common.South)
+	assert.True(t, found)
+	assert.Equal(t, 64, s.X)
+	assert.Equal(t, 32, s.Y)
+	assert.Equal(t, 255, s.Alpha)
+	assert.Equal(t, "", s.Color)
+	assert.Nil(t, s.ColorMatrix)
+	assert.Nil(t, s.Transform)
+
+	// pixel_x and pixel_w both shift the sprite right, and pixel_y and pixel_z both shift it up
+	a.PixelX, a.PixelW, a.PixelY, a.PixelZ = 5, -2, 7, 1
+	a.Alpha = 128
+	a.SetColorValue(types.String("#f00"))
+	a.Transform.Invoke(nil, "Scale", types.Int(2))
+	_, _, s = a.ToSprite(64, 32, common.South)
+	assert.Equal(t, 67, s.X)
+	assert.Equal(t, 40, s.Y)
+	assert.Equal(t, 128, s.Alpha)
+	assert.Equal(t, "#ff0000", s.Color)
+	assert.Equal(t, []float64{2, 0, 0, 0, 2, 0}, s.Transform)
+
+	a.SetColorValue(numbers(0.5, 0, 0, 0, 1, 0, 0, 0, 1))
+	_, _, s = a.ToSprite(0, 0, common.South)
+	assert.Equal(t, "", s.Color)
+	assert.Equal(t, []float64{0.5, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0}, s.ColorMatrix)
+}
+
+func TestColorValueReadsBack(t *testing.T) {
+	a := NewAppearance("test")
+	assert.Nil(t, a.ColorValue())
+	a.SetColorValue(types.String("#00ff0080"))
+	assert.Equal(t, types.String("#00ff0080"), a.ColorValue())
+	// fractional entries read back exactly, rather than being rounded to whole numbers
+	a.SetColorValue(numbers(0.25, 0, 0, 0, 1, 0, 0, 0, 1, 0.5, 0, 0))
+	entries := datum.Elements(a.ColorValue())
+	if assert.Len(t, entries, 20) {
+		assert.Equal(t, types.Float(0.25), entries[0])
+		assert.Equal(t, types.Int(1), entries[5])
+		assert.Equal(t, types.Float(0.5), entries[16])
+	}
+	assert.Panics(t, func() {
+		a.SetColorValue(numbers(1, 2, 3))
+	})
+}
+
+func TestOverlayInheritsVisuals(t *testing.T) {
+	parent := newTestAppearance(t)
+	parent.PixelX, parent.Alpha = 4, 128
+	parent.SetColorValue(types.String("#0000ff"))
+	parent.Transform.Invoke(nil, "Translate", types.Int(3), types.Int(0))
+	overlay := newTestAppearance(t)
+	overlay.PixelY, overlay.Alpha = 2, 128
+	overlay.Transform.Invoke(nil, "Scale", types.Int(2))
+	parent.Overlays = []Appearance{overlay}
+
+	sprites := parent.ToSprites(0, 0, common.South)
+	if assert.Len(t, sprites, 2) {
+		s := sprites[1].Sprite
+		assert.Equal(t, uint(1), s.Part)
+		assert.Equal(t, 4, s.X)
+		assert.Equal(t, 2, s.Y)
+		assert.Equal(t, 64, s.Alpha)
+		assert.Equal(t, "#0000ff", s.Color)
+		// the overlay's own transform applies first, and then its parent's
+		assert.Equal(t, []float64{2, 0, 3, 0, 2, 0}, s.Transform)
+	}
+}
@@ -7,12 +7,17 @@ import (
 )
 
 //mediator:declare AreaData /area /atom !singleton
-type AreaData struct{}
+type AreaData struct {
+	// when set, the turfs in this area are only lit by light sources, rather than being fully lit
+	VarDynamicLighting int
+}
 
 func NewAreaData(src *types.Datum, _ *AreaData, _ ...types.Value) {
 	util.FIXME("handle area.X, .Y, .Z correctly")
 	src.SetVar("name", types.String("area"))
 	src.SetVar("layer", types.Int(AreaLayer))
+	// areas are lit by default, so that everything in them can be seen
+	src.SetVar("luminosity", types.Int(1))
 }
 
 func TurfsInArea(area types.Value) (turfs []types.Value) {
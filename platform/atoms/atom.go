@@ -6,24 +6,28 @@ import (
 	"github.com/celskeggs/mediator/platform/icon"
 	"github.com/celskeggs/mediator/platform/types"
 	"github.com/celskeggs/mediator/util"
+	"math"
 )
 
 //mediator:declare AtomData /atom /datum
 type AtomData struct {
-	VarAppearance Appearance
+	VarAppearance Appearance `mediator:"tmp"`
 	VarDensity    int
-	VarOpacity    int
-	VarVerbs      []Verb
+	VarVerbs      []Verb `mediator:"tmp"`
 	direction     common.Direction
 	location      *types.Ref
 	contents      []*types.Ref
+	opacity       int
+	luminosity    int
+	lightRange    int
+	lightPower    float64
+	lightColor    types.Value
 }
 
 func NewAtomData(src *types.Datum, data *AtomData, args ...types.Value) {
 	data.direction = common.South
-	data.VarAppearance = Appearance{
-		Name: "atom",
-	}
+	data.VarAppearance = NewAppearance("atom")
+	data.lightPower = 1
 	if len(args) >= 1 {
 		data.SetLoc(src, args[0])
 	}
@@ -38,7 +42,13 @@ func (d *AtomData) SetDir(src *types.Datum, value types.Value) {
 	// in at least some cases (like setting dir=100), the variable does take on the higher integer value.
 	util.FIXME("figure out the right way to handle edge case values")
 
-	dir := value.(common.Direction)
+	var dir common.Direction
+	if i, ok := value.(types.Int); ok {
+		// numeric directions, as specified by maps or arithmetic
+		dir = common.Direction(i)
+	} else {
+		dir = value.(common.Direction)
+	}
 	if dir.IsValid() {
 		d.direction = dir
 	}
@@ -57,7 +67,8 @@ func (d *AtomData) GetIcon(src *types.Datum) types.Value {
 }
 
 func (d *AtomData) SetIcon(src *types.Datum, value types.Value) {
-	d.VarAppearance.Icon = value.(*icon.Icon)
+	// modifiable icons are copied, so that later changes to them do not affect this atom
+	d.VarAppearance.Icon = value.(*icon.Icon).Resource()
 }
 
 func (d *AtomData) GetIconState(src *types.Datum) types.Value {
@@ -92,6 +103,111 @@ func (d *AtomData) SetSuffix(src *types.Datum, value types.Value) {
 	d.VarAppearance.Suffix = types.Unstring(value)
 }
 
+// sprites are drawn at whole pixel offsets, so fractional offsets are rounded
+func wholePixels(value types.Value) int {
+	return int(math.Round(types.Unnumber(value)))
+}
+
+func (d *AtomData) GetPixelX(src *types.Datum) types.Value {
+	return types.Int(d.VarAppearance.PixelX)
+}
+
+func (d *AtomData) SetPixelX(src *types.Datum, value types.Value) {
+	d.VarAppearance.PixelX = wholePixels(value)
+}
+
+func (d *AtomData) GetPixelY(src *types.Datum) types.Value {
+	return types.Int(d.VarAppearance.PixelY)
+}
+
+func (d *AtomData) SetPixelY(src *types.Datum, value types.Value) {
+	d.VarAppearance.PixelY = wholePixels(value)
+}
+
+func (d *AtomData) GetPixelW(src *types.Datum) types.Value {
+	return types.Int(d.VarAppearance.PixelW)
+}
+
+func (d *AtomData) SetPixelW(src *types.Datum, value types.Value) {
+	d.VarAppearance.PixelW = wholePixels(value)
+}
+
+func (d *AtomData) GetPixelZ(src *types.Datum) types.Value {
+	return types.Int(d.VarAppearance.PixelZ)
+}
+
+func (d *AtomData) SetPixelZ(src *types.Datum, value types.Value) {
+	d.VarAppearance.PixelZ = wholePixels(value)
+}
+
+func (d *AtomData) GetColor(src *types.Datum) types.Value {
+	return d.VarAppearance.ColorValue()
+}
+
+func (d *AtomData) SetColor(src *types.Datum, value types.Value) {
+	d.VarAppearance.SetColorValue(value)
+}
+
+func clamp(value types.Value, min, max int) int {
+	i := int(math.Round(types.Unnumber(value)))
+	if i < min {
+		return min
+	} else if i > max {
+		return max
+	}
+	return i
+}
+
+func (d *AtomData) GetAlpha(src *types.Datum) types.Value {
+	return types.Int(d.VarAppearance.Alpha)
+}
+
+func (d *AtomData) SetAlpha(src *types.Datum, value types.Value) {
+	d.VarAppearance.Alpha = clamp(value, 0, 255)
+}
+
+func (d *AtomData) GetTransform(src *types.Datum) types.Value {
+	// matrices are modified in place, so a copy is returned to keep the appearance from changing behind our back
+	return d.VarAppearance.Transform.Copy()
+}
+
+func (d *AtomData) SetTransform(src *types.Datum, value types.Value) {
+	if value == nil {
+		d.VarAppearance.Transform = *IdentityMatrix()
+		return
+	}
+	matrix, ok := ToMatrix(value)
+	if !ok {
+		panic("cannot set transform to " + value.String())
+	}
+	d.VarAppearance.Transform = *matrix
+}
+
+func (d *AtomData) GetBlendMode(src *types.Datum) types.Value {
+	return types.Int(d.VarAppearance.BlendMode)
+}
+
+func (d *AtomData) SetBlendMode(src *types.Datum, value types.Value) {
+	d.VarAppearance.BlendMode = clamp(value, BlendDefault, BlendInsetOverlay)
+}
+
+func (d *AtomData) GetMouseOpacity(src *types.Datum) types.Value {
+	return types.Int(d.VarAppearance.MouseOpacity)
+}
+
+func (d *AtomData) SetMouseOpacity(src *types.Datum, value types.Value) {
+	d.VarAppearance.MouseOpacity = clamp(value, 0, 2)
+}
+
+func (d *AtomData) GetInvisibility(src *types.Datum) types.Value {
+	return types.Int(d.VarAppearance.Invisibility)
+}
+
+func (d *AtomData) SetInvisibility(src *types.Datum, value types.Value) {
+	// see_invisible only goes up to 100, so an invisibility of 101 hides an atom from everyone
+	d.VarAppearance.Invisibility = clamp(value, 0, 101)
+}
+
 func (d *AtomData) GetContents(src *types.Datum) types.Value {
 	util.FIXME("should this really be a copy?")
 	var contents []*types.Ref
@@ -131,6 +247,9 @@ func removeFromContents(contents []*types.Ref, remove *types.Datum) []*types.Ref
 }
 
 func (d *AtomData) SetLoc(src *types.Datum, location types.Value) {
+	// once for the tile being left, and once for the tile being entered
+	lightingChanged(src)
+	defer lightingChanged(src)
 	if d.location != nil {
 		oldloc, ok := AtomDataChunk(d.location.Dereference())
 		if !ok {
@@ -145,11 +264,9 @@ func (d *AtomData) SetLoc(src *types.Datum, location types.Value) {
 			panic("attempt to move atom to non-atom location: " + location.String())
 		}
 		d.location = types.Reference(location)
-		for _, elem := range newloc.contents {
-			if elem.Dereference() == src {
-				panic("should not have found self in new location's contents")
-			}
-		}
+		// src was just removed from its old location, so it cannot already be in the new location's contents. this is
+		// not checked, because scanning the contents takes time proportional to their length, and areas can contain
+		// every turf on the map.
 		newloc.contents = append(newloc.contents, types.Reference(src))
 	}
 }
@@ -203,3 +320,54 @@ func (d *AtomData) ProcStat(src *types.Datum, usr *types.Datum) types.Value {
 	// nothing to do
 	return nil
 }
+
+// the mouse procs are called by the /client procs of the same names, which are given the atom that the mouse was over;
+// none of them do anything by default
+func (d *AtomData) ProcClick(src *types.Datum, usr *types.Datum, location types.Value, control types.Value, params types.Value) types.Value {
+	return nil
+}
+
+func (d *AtomData) ProcDblClick(src *types.Datum, usr *types.Datum, location types.Value, control types.Value, params types.Value) types.Value {
+	return nil
+}
+
+func (d *AtomData) ProcMouseDown(src *types.Datum, usr *types.Datum, location types.Value, control types.Value, params types.Value) types.Value {
+	return nil
+}
+
+func (d *AtomData) ProcMouseUp(src *types.Datum, usr *types.Datum, location types.Value, control types.Value, params types.Value) types.Value {
+	return nil
+}
+
+func (d *AtomData) ProcMouseEntered(src *types.Datum, usr *types.Datum, location types.Value, control types.Value, params types.Value) types.Value {
+	return nil
+}
+
+func (d *AtomData) ProcMouseExited(src *types.Datum, usr *types.Datum, location types.Value, control types.Value, params types.Value) types.Value {
+	return nil
+}
+
+// called on the atom that was dragged, when it is dropped onto over
+func (d *AtomData) ProcMouseDrop(src *types.Datum, usr *types.Datum, over types.Value, srcLocation types.Value, overLocation types.Value, srcControl types.Value, overControl types.Value, params types.Value) types.Value {
+	return nil
+}
+
+// contents are saved along with the atom, so that a player's inventory comes back with them
+func (d *AtomData) ProcWrite(src *types.Datum, usr *types.Datum, savefile types.Value) types.Value {
+	src.SuperInvoke(usr, "github.com/celskeggs/mediator/platform/atoms.AtomData", "Write", savefile)
+	if len(d.contents) > 0 {
+		savefile.Invoke(usr, "[]", types.String("contents")).Invoke(usr, "<<", d.GetContents(src))
+	}
+	return nil
+}
+
+func (d *AtomData) ProcRead(src *types.Datum, usr *types.Datum, savefile types.Value) types.Value {
+	src.SuperInvoke(usr, "github.com/celskeggs/mediator/platform/atoms.AtomData", "Read", savefile)
+	contents := savefile.Invoke(usr, "[]", types.String("contents")).Invoke(usr, ">>")
+	if contents != nil {
+		for _, item := range datum.Elements(contents) {
+			item.SetVar("loc", src)
+		}
+	}
+	return nil
+}
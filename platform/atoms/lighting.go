@@ -0,0 +1,72 @@
+package atoms
+
+import (
+	"github.com/celskeggs/mediator/platform/icon"
+	"github.com/celskeggs/mediator/platform/types"
+	"math"
+)
+
+// lets the world know that the light cast by an atom, or whether its tile is opaque, may change. this is called both
+// before and after each change, so that the world knows about both the old and the new state. areas do not cast light;
+// their luminosity only determines whether their turfs are lit.
+func lightingChanged(src *types.Datum) {
+	if !types.IsType(src, "/area") {
+		WorldOf(src).UpdateLighting(src)
+	}
+}
+
+// light reaches whole tiles, so fractional ranges are rounded
+func wholeTiles(value types.Value) int {
+	return int(math.Round(types.Unnumber(value)))
+}
+
+func (d *AtomData) GetOpacity(src *types.Datum) types.Value {
+	return types.Int(d.opacity)
+}
+
+func (d *AtomData) SetOpacity(src *types.Datum, value types.Value) {
+	lightingChanged(src)
+	d.opacity = types.Unint(value)
+	lightingChanged(src)
+}
+
+func (d *AtomData) GetLuminosity(src *types.Datum) types.Value {
+	return types.Int(d.luminosity)
+}
+
+func (d *AtomData) SetLuminosity(src *types.Datum, value types.Value) {
+	d.luminosity = wholeTiles(value)
+	lightingChanged(src)
+}
+
+func (d *AtomData) GetLightRange(src *types.Datum) types.Value {
+	return types.Int(d.lightRange)
+}
+
+func (d *AtomData) SetLightRange(src *types.Datum, value types.Value) {
+	d.lightRange = wholeTiles(value)
+	lightingChanged(src)
+}
+
+func (d *AtomData) GetLightPower(src *types.Datum) types.Value {
+	return types.Number(d.lightPower)
+}
+
+func (d *AtomData) SetLightPower(src *types.Datum, value types.Value) {
+	d.lightPower = types.Unnumber(value)
+	lightingChanged(src)
+}
+
+func (d *AtomData) GetLightColor(src *types.Datum) types.Value {
+	return d.lightColor
+}
+
+func (d *AtomData) SetLightColor(src *types.Datum, value types.Value) {
+	if value != nil {
+		if _, err := icon.ParseColor(value); err != nil {
+			panic("invalid light_color: " + err.Error())
+		}
+	}
+	d.lightColor = value
+	lightingChanged(src)
+}
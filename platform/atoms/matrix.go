@@ -0,0 +1,196 @@
+package atoms
+
+import (
+	"fmt"
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/types"
+	"math"
+)
+
+// a /matrix, which describes a 2D affine transformation as in DM, where x' = a*x + b*y + c and y' = d*x + e*y + f.
+// like in DM, matrix procs modify the matrix in place, so matrices are copied when they are stored in an appearance
+type Matrix struct {
+	A, B, C float64
+	D, E, F float64
+}
+
+var _ types.Value = &Matrix{}
+
+func IdentityMatrix() *Matrix {
+	return &Matrix{A: 1, E: 1}
+}
+
+// converts a matrix, or a list of six numbers, into a matrix
+func ToMatrix(value types.Value) (*Matrix, bool) {
+	switch v := value.(type) {
+	case *Matrix:
+		return v, v != nil
+	case datum.List:
+		elements := datum.Elements(v)
+		if len(elements) != 6 {
+			return nil, false
+		}
+		var entries [6]float64
+		for i, element := range elements {
+			switch n := element.(type) {
+			case types.Int, types.Float:
+				entries[i] = types.Unnumber(n)
+			default:
+				return nil, false
+			}
+		}
+		return &Matrix{
+			A: entries[0], B: entries[1], C: entries[2],
+			D: entries[3], E: entries[4], F: entries[5],
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+func (m *Matrix) Copy() *Matrix {
+	result := *m
+	return &result
+}
+
+func (m *Matrix) IsIdentity() bool {
+	return *m == Matrix{A: 1, E: 1}
+}
+
+// the six entries of the matrix, in the order a, b, c, d, e, f
+func (m *Matrix) Entries() []float64 {
+	return []float64{m.A, m.B, m.C, m.D, m.E, m.F}
+}
+
+// applies o after m, and stores the result in m
+func (m *Matrix) multiply(o *Matrix) {
+	*m = Matrix{
+		A: o.A*m.A + o.B*m.D,
+		B: o.A*m.B + o.B*m.E,
+		C: o.A*m.C + o.B*m.F + o.C,
+		D: o.D*m.A + o.E*m.D,
+		E: o.D*m.B + o.E*m.E,
+		F: o.D*m.C + o.E*m.F + o.F,
+	}
+}
+
+func (m *Matrix) invert() {
+	det := m.A*m.E - m.B*m.D
+	if det == 0 {
+		panic("cannot invert a singular matrix")
+	}
+	*m = Matrix{
+		A: m.E / det,
+		B: -m.B / det,
+		C: (m.B*m.F - m.E*m.C) / det,
+		D: -m.D / det,
+		E: m.A / det,
+		F: (m.D*m.C - m.A*m.F) / det,
+	}
+}
+
+func number(value types.Value) float64 {
+	return types.Unnumber(value)
+}
+
+func (m *Matrix) Equal(o types.Value) bool {
+	om, ok := o.(*Matrix)
+	return ok && om != nil && *m == *om
+}
+
+func (m *Matrix) Var(name string) types.Value {
+	var entry float64
+	switch name {
+	case "a":
+		entry = m.A
+	case "b":
+		entry = m.B
+	case "c":
+		entry = m.C
+	case "d":
+		entry = m.D
+	case "e":
+		entry = m.E
+	case "f":
+		entry = m.F
+	default:
+		panic("no such var " + name + " on matrix")
+	}
+	return types.Number(entry)
+}
+
+func (m *Matrix) SetVar(name string, value types.Value) {
+	switch name {
+	case "a":
+		m.A = number(value)
+	case "b":
+		m.B = number(value)
+	case "c":
+		m.C = number(value)
+	case "d":
+		m.D = number(value)
+	case "e":
+		m.E = number(value)
+	case "f":
+		m.F = number(value)
+	default:
+		panic("no such var " + name + " on matrix")
+	}
+}
+
+func (m *Matrix) Invoke(usr *types.Datum, name string, parameters ...types.Value) types.Value {
+	switch name {
+	case "Multiply":
+		switch n := types.Param(parameters, 0).(type) {
+		case types.Int, types.Float:
+			// scaling about the origin also scales the translation, so every entry is multiplied
+			scale := types.Unnumber(n)
+			m.multiply(&Matrix{A: scale, E: scale})
+		default:
+			o, ok := ToMatrix(n)
+			if !ok {
+				panic("Multiply() requires a matrix or a number")
+			}
+			m.multiply(o)
+		}
+	case "Add", "Subtract":
+		o, ok := ToMatrix(types.Param(parameters, 0))
+		if !ok {
+			panic(name + "() requires a matrix")
+		}
+		sign := 1.0
+		if name == "Subtract" {
+			sign = -1
+		}
+		m.A, m.B, m.C = m.A+sign*o.A, m.B+sign*o.B, m.C+sign*o.C
+		m.D, m.E, m.F = m.D+sign*o.D, m.E+sign*o.E, m.F+sign*o.F
+	case "Invert":
+		m.invert()
+	case "Scale":
+		x := number(types.Param(parameters, 0))
+		y := x
+		if len(parameters) >= 2 {
+			y = number(parameters[1])
+		}
+		m.multiply(&Matrix{A: x, E: y})
+	case "Translate":
+		x := number(types.Param(parameters, 0))
+		y := x
+		if len(parameters) >= 2 {
+			y = number(parameters[1])
+		}
+		m.multiply(&Matrix{A: 1, C: x, E: 1, F: y})
+	case "Turn":
+		// clockwise, in degrees
+		radians := number(types.Param(parameters, 0)) * math.Pi / 180
+		sin, cos := math.Sincos(radians)
+		m.multiply(&Matrix{A: cos, B: sin, D: -sin, E: cos})
+	default:
+		panic("no such proc " + name + " on matrix")
+	}
+	return m
+}
+
+func (m *Matrix) String() string {
+	return fmt.Sprintf("[matrix: %g %g %g %g %g %g]", m.A, m.B, m.C, m.D, m.E, m.F)
+}
@@ -0,0 +1,110 @@
+package atoms
+
+import (
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func matrixOf(entries ...float64) *Matrix {
+	return &Matrix{A: entries[0], B: entries[1], C: entries[2], D: entries[3], E: entries[4], F: entries[5]}
+}
+
+func numbers(entries ...float64) types.Value {
+	values := make([]types.Value, len(entries))
+	for i, entry := range entries {
+		values[i] = types.Number(entry)
+	}
+	return datum.NewList(values...)
+}
+
+// where a point ends up after being transformed by a matrix
+func apply(m *Matrix, x, y float64) (float64, float64) {
+	return m.A*x + m.B*y + m.C, m.D*x + m.E*y + m.F
+}
+
+func TestMatrixProcs(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		start    *Matrix
+		proc     string
+		params   []types.Value
+		expected *Matrix
+	}{
+		// Turn is clockwise, so the point to the right of the origin moves below it
+		{"turn", IdentityMatrix(), "Turn", []types.Value{types.Int(90)}, matrixOf(0, 1, 0, -1, 0, 0)},
+		{"turn back", matrixOf(0, 1, 0, -1, 0, 0), "Turn", []types.Value{types.Int(-90)}, IdentityMatrix()},
+		{"scale", matrixOf(1, 0, 3, 0, 1, 4), "Scale", []types.Value{types.Int(2), types.Int(3)}, matrixOf(2, 0, 6, 0, 3, 12)},
+		{"uniform scale", IdentityMatrix(), "Scale", []types.Value{types.Float(0.5)}, matrixOf(0.5, 0, 0, 0, 0.5, 0)},
+		{"translate", matrixOf(2, 0, 0, 0, 2, 0), "Translate", []types.Value{types.Int(5), types.Int(-1)}, matrixOf(2, 0, 5, 0, 2, -1)},
+		// multiplying by a number scales every entry, including the translation
+		{"multiply number", matrixOf(1, 2, 3, 4, 5, 6), "Multiply", []types.Value{types.Float(1.5)}, matrixOf(1.5, 3, 4.5, 6, 7.5, 9)},
+		// m.Multiply(o) applies m first and then o: here, a translation followed by a scale, which scales the offset
+		{"multiply order", matrixOf(1, 0, 1, 0, 1, 0), "Multiply", []types.Value{matrixOf(2, 0, 0, 0, 2, 0)}, matrixOf(2, 0, 2, 0, 2, 0)},
+		{"multiply list", matrixOf(2, 0, 0, 0, 2, 0), "Multiply", []types.Value{numbers(1, 0, 1, 0, 1, 0)}, matrixOf(2, 0, 1, 0, 2, 0)},
+		{"add", matrixOf(1, 2, 3, 4, 5, 6), "Add", []types.Value{numbers(1, 1, 1, 1, 1, 1)}, matrixOf(2, 3, 4, 5, 6, 7)},
+		{"subtract", matrixOf(1, 2, 3, 4, 5, 6), "Subtract", []types.Value{matrixOf(1, 2, 3, 4, 5, 6)}, matrixOf(0, 0, 0, 0, 0, 0)},
+		{"invert", matrixOf(2, 0, 4, 0, 4, -8), "Invert", nil, matrixOf(0.5, 0, -2, 0, 0.25, 2)},
+	} {
+		m := test.start.Copy()
+		// matrix procs modify the matrix in place and return it
+		assert.True(t, m == m.Invoke(nil, test.proc, test.params...), test.name)
+		for i, entry := range test.expected.Entries() {
+			assert.InDelta(t, entry, m.Entries()[i], 1e-9, test.name)
+		}
+	}
+}
+
+func TestMatrixTurnIsClockwise(t *testing.T) {
+	m := IdentityMatrix()
+	m.Invoke(nil, "Turn", types.Int(90))
+	x, y := apply(m, 1, 0)
+	assert.InDelta(t, 0, x, 1e-9)
+	assert.InDelta(t, -1, y, 1e-9)
+}
+
+func TestMatrixInvertUndoes(t *testing.T) {
+	m := matrixOf(1, 2, 3, -1, 0.5, 7)
+	inverse := m.Copy()
+	inverse.Invoke(nil, "Invert")
+	m.Invoke(nil, "Multiply", inverse)
+	for i, entry := range IdentityMatrix().Entries() {
+		assert.InDelta(t, entry, m.Entries()[i], 1e-9)
+	}
+	assert.Panics(t, func() {
+		matrixOf(1, 2, 0, 2, 4, 0).Invoke(nil, "Invert")
+	})
+}
+
+func TestToMatrix(t *testing.T) {
+	m, ok := ToMatrix(numbers(1, 0.5, 3, 0, 1, -2))
+	assert.True(t, ok)
+	assert.Equal(t, matrixOf(1, 0.5, 3, 0, 1, -2), m)
+	original := matrixOf(1, 2, 3, 4, 5, 6)
+	m, ok = ToMatrix(original)
+	assert.True(t, ok)
+	assert.True(t, m == original)
+	for _, value := range []types.Value{
+		numbers(1, 2, 3, 4, 5),
+		datum.NewList(types.Int(1), types.Int(0), types.Int(0), types.Int(0), types.Int(1), types.String("0")),
+		types.Int(1),
+		nil,
+		(*Matrix)(nil),
+	} {
+		_, ok := ToMatrix(value)
+		assert.False(t, ok, "%v", value)
+	}
+}
+
+func TestMatrixVars(t *testing.T) {
+	m := matrixOf(1, 0.25, 0, 0, 1, 0)
+	// whole entries read back as Ints and fractional ones as Floats, so neither loses precision
+	assert.Equal(t, types.Int(1), m.Var("a"))
+	assert.Equal(t, types.Float(0.25), m.Var("b"))
+	m.SetVar("c", types.Float(-1.5))
+	m.SetVar("f", types.Int(3))
+	assert.Equal(t, matrixOf(1, 0.25, -1.5, 0, 1, 3), m)
+	assert.True(t, m.Equal(matrixOf(1, 0.25, -1.5, 0, 1, 3)))
+	assert.False(t, m.Equal(IdentityMatrix()))
+}
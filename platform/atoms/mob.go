@@ -7,17 +7,30 @@ import (
 	"github.com/celskeggs/mediator/webclient/sprite"
 )
 
+// flags for mob.sight
+const (
+	SightBlind    = 1
+	SightSeeMobs  = 4
+	SightSeeObjs  = 8
+	SightSeeTurfs = 16
+	SightSeeSelf  = 32
+)
+
 //mediator:declare MobData /mob /atom/movable
 type MobData struct {
-	key    string
-	client types.Value // not a ref to avoid refcounting cycle
-	stat   *StatContext
+	VarSeeInDark    int
+	VarSeeInvisible int
+	VarSight        int
+	key             string
+	client          types.Value // not a ref to avoid refcounting cycle
+	stat            *StatContext
 }
 
 func NewMobData(src *types.Datum, _ *MobData, _ ...types.Value) {
 	src.SetVar("name", types.String("mob"))
 	src.SetVar("layer", types.Int(MobLayer))
 	src.SetVar("density", types.Int(1))
+	src.SetVar("see_in_dark", types.Int(2))
 }
 
 func (m *MobData) OperatorWrite(src *types.Datum, usr *types.Datum, output types.Value) types.Value {
@@ -57,7 +70,8 @@ func MobSetClient(mobV types.Value, client types.Value) {
 	if !ismob {
 		panic("attempt to set client on not-a-mob: " + mobV.String())
 	}
-	if mob.client != nil {
+	// a client that has since been deleted doesn't count
+	if mob.GetClient(mobV.(*types.Datum)) != nil {
 		panic("client already set!")
 	}
 	if client != nil {
@@ -70,6 +84,10 @@ func (m *MobData) GetKey(src *types.Datum) types.Value {
 	return types.String(m.key)
 }
 
+func (m *MobData) GetCkey(src *types.Datum) types.Value {
+	return types.String(common.Ckey(m.key))
+}
+
 func (m *MobData) moveToInitialLocation(src *types.Datum, usr *types.Datum) {
 	// algorithm:
 	// start at (1,1,1), scan across horizontally, then vertically, then in Z direction
@@ -99,6 +117,12 @@ func (m *MobData) ProcLogin(src *types.Datum, usr *types.Datum) types.Value {
 	return nil
 }
 
+// Logout is called while the client is being deleted, so the mob is still attached to it, but the player has already
+// disconnected and cannot be sent anything
+func (m *MobData) ProcLogout(src *types.Datum, usr *types.Datum) types.Value {
+	return nil
+}
+
 func (m *MobData) StatContext() *StatContext {
 	return m.stat
 }
@@ -0,0 +1,148 @@
+package atoms
+
+import (
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/icon"
+	"github.com/celskeggs/mediator/platform/types"
+)
+
+// a plain list holding a copy of some appearances
+func appearanceList(appearances []Appearance) types.Value {
+	values := make([]types.Value, len(appearances))
+	for i, appearance := range appearances {
+		values[i] = appearance
+	}
+	return datum.NewList(values...)
+}
+
+// converts anything that can be added to overlays or underlays into the appearance that is displayed: an icon, an
+// icon state of the atom's own icon, a type of atom, another atom, or an appearance
+func toOverlay(src *types.Datum, base Appearance, value types.Value) Appearance {
+	switch v := value.(type) {
+	case Appearance:
+		return v
+	case *icon.Icon:
+		overlay := NewAppearance("")
+		overlay.Icon = v.Resource()
+		overlay.Layer = FloatLayer
+		return overlay
+	case types.String:
+		overlay := NewAppearance("")
+		overlay.Icon = base.Icon
+		overlay.IconState = string(v)
+		overlay.Layer = FloatLayer
+		return overlay
+	case types.TypePath:
+		if !src.Realm().IsSubType(v, "/atom") {
+			panic("cannot use non-atom type " + v.String() + " as an overlay")
+		}
+		return src.Realm().NewPlain(v).Var("appearance").(Appearance)
+	case *types.Datum:
+		if !types.IsType(v, "/atom") {
+			panic("cannot use non-atom " + v.String() + " as an overlay")
+		}
+		return v.Var("appearance").(Appearance)
+	case nil:
+		panic("cannot use null as an overlay")
+	default:
+		panic("cannot use " + v.String() + " as an overlay")
+	}
+}
+
+// the overlays or underlays list of an atom, which converts each element to an appearance as it is added
+type overlayList struct {
+	src       *types.Datum
+	data      *AtomData
+	underlays bool
+}
+
+var _ datum.NormalizingListProvider = overlayList{}
+
+func (o overlayList) appearances() []Appearance {
+	if o.underlays {
+		return o.data.VarAppearance.Underlays
+	}
+	return o.data.VarAppearance.Overlays
+}
+
+// the slices may be shared with copies of the appearance, so every change produces a new slice
+func (o overlayList) replace(appearances []Appearance) {
+	if len(appearances) == 0 {
+		appearances = nil
+	}
+	if o.underlays {
+		o.data.VarAppearance.Underlays = appearances
+	} else {
+		o.data.VarAppearance.Overlays = appearances
+	}
+}
+
+func (o overlayList) Normalize(v types.Value) types.Value {
+	if v == nil {
+		return nil
+	}
+	return toOverlay(o.src, o.data.VarAppearance, v)
+}
+
+func (o overlayList) Length() int {
+	return len(o.appearances())
+}
+
+func (o overlayList) Get(i int) *types.Ref {
+	return types.Reference(o.appearances()[i])
+}
+
+func (o overlayList) Set(i int, v *types.Ref) {
+	appearances := append([]Appearance{}, o.appearances()...)
+	appearances[i] = toOverlay(o.src, o.data.VarAppearance, v.Dereference())
+	o.replace(appearances)
+}
+
+func (o overlayList) Append(v *types.Ref) {
+	value := v.Dereference()
+	if value == nil {
+		// as in DM, adding null to overlays does nothing
+		return
+	}
+	overlay := toOverlay(o.src, o.data.VarAppearance, value)
+	o.replace(append(append([]Appearance{}, o.appearances()...), overlay))
+}
+
+func (o overlayList) RemoveLast() {
+	o.RemoveIndex(o.Length() - 1)
+}
+
+func (o overlayList) RemoveIndex(i int) {
+	old := o.appearances()
+	appearances := append(append([]Appearance{}, old[:i]...), old[i+1:]...)
+	o.replace(appearances)
+}
+
+func (d *AtomData) GetOverlays(src *types.Datum) types.Value {
+	return datum.List{ListProvider: overlayList{src: src, data: d}}
+}
+
+func (d *AtomData) SetOverlays(src *types.Datum, value types.Value) {
+	d.setOverlays(src, value, false)
+}
+
+func (d *AtomData) GetUnderlays(src *types.Datum) types.Value {
+	return datum.List{ListProvider: overlayList{src: src, data: d, underlays: true}}
+}
+
+func (d *AtomData) SetUnderlays(src *types.Datum, value types.Value) {
+	d.setOverlays(src, value, true)
+}
+
+// replaces the overlays or underlays with the elements of a list, or clears them when set to null
+func (d *AtomData) setOverlays(src *types.Datum, value types.Value, underlays bool) {
+	list := overlayList{src: src, data: d, underlays: underlays}
+	var elements []types.Value
+	if value != nil {
+		elements = datum.Elements(value)
+	}
+	list.replace(nil)
+	for _, element := range elements {
+		list.Append(types.Reference(element))
+	}
+}
@@ -33,15 +33,21 @@ func (t *TurfData) GetZ(src *types.Datum) types.Value {
 
 func (t *TurfData) SetX(src *types.Datum, x types.Value) {
 	util.NiceToHave("see if these can be made private, and if so, if they should be")
+	oldX := t.X
 	t.X = uint(types.Unint(x))
+	WorldOf(src).TurfMoved(src, oldX, t.Y, t.Z)
 }
 
 func (t *TurfData) SetY(src *types.Datum, y types.Value) {
+	oldY := t.Y
 	t.Y = uint(types.Unint(y))
+	WorldOf(src).TurfMoved(src, t.X, oldY, t.Z)
 }
 
 func (t *TurfData) SetZ(src *types.Datum, z types.Value) {
+	oldZ := t.Z
 	t.Z = uint(types.Unint(z))
+	WorldOf(src).TurfMoved(src, t.X, t.Y, oldZ)
 }
 
 func (t *TurfData) ProcExit(src *types.Datum, usr *types.Datum, atom types.Value, newloc types.Value) types.Value {
@@ -38,6 +38,10 @@ func (v Verb) Matches(name string, src *types.Datum, usr *types.Datum, args []st
 	if name != v.VisibleName {
 		return false
 	}
+	// usr can always use its own verbs, but not those of atoms that are too invisible for it to see
+	if src != usr && types.IsType(usr, "/mob") && types.Unint(src.Var("invisibility")) > types.Unint(usr.Var("see_invisible")) {
+		return false
+	}
 	settings, ok := types.UnpackDatum(src).ProcSettings(v.VisibleName)
 	if !ok {
 		util.FIXME("make sure this never actually happens")
@@ -68,25 +72,25 @@ func (v Verb) Matches(name string, src *types.Datum, usr *types.Datum, args []st
 			// src = usr
 			return src == usr
 		}
-	case types.SrcSettingTypeOView:
+	case types.SrcSettingTypeView, types.SrcSettingTypeOView:
 		util.FIXME("come up with a more efficient way to do this")
-		if settings.Src.In {
-			// src in oview(N)
-			var objects []types.Value
-			if settings.Src.Dist == types.SrcDistUnspecified {
-				objects = WorldOf(src).View1(usr, ViewExclusive)
-			} else {
-				objects = WorldOf(src).View(uint(settings.Src.Dist), usr, ViewExclusive)
-			}
-			for _, obj := range objects {
-				if obj == src {
-					return true
-				}
-			}
-			return false
+		// both 'src in view(N)' and 'src = view(N)' require src to be visible; they only differ in whether src is named
+		mode := ViewInclusive
+		if settings.Src.Type == types.SrcSettingTypeOView {
+			mode = ViewExclusive
+		}
+		var objects []types.Value
+		if settings.Src.Dist == types.SrcDistUnspecified {
+			objects = WorldOf(src).View1(usr, mode)
 		} else {
-			panic("support not implemented for proc src setting 'src = oview(...)'")
+			objects = WorldOf(src).View(uint(settings.Src.Dist), usr, mode)
+		}
+		for _, obj := range objects {
+			if obj == src {
+				return true
+			}
 		}
+		return false
 	default:
 		panic("support not implemented for proc src setting " + settings.Src.Type.String())
 	}
@@ -18,16 +18,22 @@ type World interface {
 	MaxXYZ() (uint, uint, uint)
 	SetMaxXYZ(x, y, z uint)
 	LocateXYZ(x, y, z uint) (turf types.Value)
+	TurfMoved(turf *types.Datum, oldX, oldY, oldZ uint)
+	Block(x1, y1, z1, x2, y2, z2 uint) []types.Value
 	Realm() *types.Realm
 	Icon(name string) *icon.Icon
+	LoadIcon(name string) (*icon.Icon, bool)
+	RegisterIcon(data []byte) (*icon.Icon, error)
 	FindAll(predicate func(*types.Datum) bool) []types.Value
 	FindAllType(tp types.TypePath) []types.Value
 	FindOne(predicate func(*types.Datum) bool) types.Value
 	FindOneType(tp types.TypePath) types.Value
 	View(distance uint, centerD *types.Datum, mode ViewMode) []types.Value
 	View1(centerD *types.Datum, mode ViewMode) []types.Value
+	Range(distance uint, center *types.Datum) []types.Value
 	ListVerbsOnAtom(client types.Value, atom *types.Datum) (verbs []string)
 	Flick(icon *icon.Icon, icon_state string, target types.Value)
+	UpdateLighting(atom *types.Datum)
 }
 
 func WorldOf(t *types.Datum) World {
@@ -16,6 +16,13 @@ type ListProvider interface {
 	RemoveIndex(i int)
 }
 
+// implemented by lists that convert their elements as they are added, like overlays, so that elements can be found
+// by the same values that were added
+type NormalizingListProvider interface {
+	ListProvider
+	Normalize(v types.Value) types.Value
+}
+
 type List struct {
 	ListProvider
 }
@@ -60,6 +67,60 @@ func (l List) Invoke(usr *types.Datum, name string, parameters ...types.Value) t
 			refsA := ElementsAsRefs(l)
 			return NewListFromRefs(append(refsA, types.Reference(value))...)
 		}
+	case "[]":
+		key := types.Param(parameters, 0)
+		if _, isIndex := key.(types.Int); !isIndex {
+			assoc, ok := l.ListProvider.(AssociativeListProvider)
+			if !ok {
+				panic(fmt.Sprintf("cannot look up %v in a list that is not associative", key))
+			}
+			return assoc.Association(key)
+		}
+		index := types.Unint(key)
+		if index < 1 || index > l.Length() {
+			panic(fmt.Sprintf("list index %d out of bounds for list of length %d", index, l.Length()))
+		}
+		return l.Get(index - 1).Dereference()
+	case "Add":
+		for _, value := range parameters {
+			if otherList, ok := value.(List); ok {
+				for _, element := range ElementsAsRefs(otherList) {
+					l.Append(element)
+				}
+			} else {
+				l.Append(types.Reference(value))
+			}
+		}
+		return nil
+	case "Remove":
+		removed := false
+		for _, value := range parameters {
+			// removing a list removes each of its elements
+			values := []types.Value{value}
+			if otherList, ok := value.(List); ok {
+				values = Elements(otherList)
+			}
+			for _, v := range values {
+				if index := l.findLast(v); index >= 0 {
+					l.RemoveIndex(index)
+					removed = true
+				}
+			}
+		}
+		return types.FromBool(removed)
+	case "Find":
+		var rest []types.Value
+		if len(parameters) > 1 {
+			rest = parameters[1:]
+		}
+		start, end := l.bounds("Find", rest)
+		return types.Int(l.findFirst(types.Param(parameters, 0), start, end) + 1)
+	case "Cut":
+		start, end := l.bounds("Cut", parameters)
+		for i := end - 1; i >= start; i-- {
+			l.RemoveIndex(i)
+		}
+		return nil
 	case "<<":
 		for _, element := range Elements(l) {
 			if types.IsType(element, "/mob") {
@@ -72,6 +133,55 @@ func (l List) Invoke(usr *types.Datum, name string, parameters ...types.Value) t
 	}
 }
 
+// converts the optional Start and End arguments of procs like Find and Cut into a range of zero-based indices, where
+// an End of 0 means the end of the list
+func (l List) bounds(proc string, parameters []types.Value) (start int, end int) {
+	start, end = 1, 0
+	if len(parameters) >= 1 && parameters[0] != nil {
+		start = types.Unint(parameters[0])
+	}
+	if len(parameters) >= 2 && parameters[1] != nil {
+		end = types.Unint(parameters[1])
+	}
+	if end <= 0 {
+		end += l.Length() + 1
+	}
+	if start < 1 || end > l.Length()+1 || start > end {
+		panic(fmt.Sprintf("%s() range %d to %d out of bounds for list of length %d", proc, start, end, l.Length()))
+	}
+	return start - 1, end - 1
+}
+
+func (l List) normalize(value types.Value) types.Value {
+	if normalizing, ok := l.ListProvider.(NormalizingListProvider); ok {
+		return normalizing.Normalize(value)
+	}
+	return value
+}
+
+// finds the first index of a value within the range of indices, or -1 if it is not present
+func (l List) findFirst(value types.Value, start int, end int) int {
+	value = l.normalize(value)
+	for i := start; i < end; i++ {
+		if equalValues(l.Get(i).Dereference(), value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// finds the last index of a value in the list, or -1 if it is not present. as in DM, Remove removes the last
+// matching element.
+func (l List) findLast(value types.Value) int {
+	value = l.normalize(value)
+	for i := l.Length() - 1; i >= 0; i-- {
+		if equalValues(l.Get(i).Dereference(), value) {
+			return i
+		}
+	}
+	return -1
+}
+
 func (l List) String() string {
 	return fmt.Sprintf("[list of length %d]", l.Length())
 }
@@ -0,0 +1,50 @@
+package datum
+
+import (
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func ints(values ...int) types.Value {
+	var elements []types.Value
+	for _, v := range values {
+		elements = append(elements, types.Int(v))
+	}
+	return NewList(elements...)
+}
+
+func TestListFind(t *testing.T) {
+	list := ints(1, 2, 3, 2, 1)
+	assert.Equal(t, types.Int(2), list.Invoke(nil, "Find", types.Int(2)))
+	assert.Equal(t, types.Int(4), list.Invoke(nil, "Find", types.Int(2), types.Int(3)))
+	assert.Equal(t, types.Int(0), list.Invoke(nil, "Find", types.Int(2), types.Int(3), types.Int(4)))
+	assert.Equal(t, types.Int(0), list.Invoke(nil, "Find", types.Int(7)))
+	assert.Panics(t, func() {
+		list.Invoke(nil, "Find", types.Int(2), types.Int(7))
+	})
+}
+
+func TestListRemove(t *testing.T) {
+	list := ints(1, 2, 3, 2, 1)
+	assert.Equal(t, types.Int(1), list.Invoke(nil, "Remove", types.Int(2)))
+	assert.Equal(t, Elements(ints(1, 2, 3, 1)), Elements(list))
+	assert.Equal(t, types.Int(1), list.Invoke(nil, "Remove", ints(1, 3, 9)))
+	assert.Equal(t, Elements(ints(1, 2)), Elements(list))
+	assert.Equal(t, types.Int(0), list.Invoke(nil, "Remove", types.Int(9)))
+}
+
+func TestListCut(t *testing.T) {
+	list := ints(1, 2, 3, 4, 5)
+	list.Invoke(nil, "Cut", types.Int(2), types.Int(4))
+	assert.Equal(t, Elements(ints(1, 4, 5)), Elements(list))
+	list.Invoke(nil, "Cut", types.Int(3))
+	assert.Equal(t, Elements(ints(1, 4)), Elements(list))
+	for _, bounds := range [][]types.Value{{types.Int(0)}, {types.Int(1), types.Int(4)}, {types.Int(3), types.Int(2)}} {
+		assert.Panics(t, func() {
+			list.Invoke(nil, "Cut", bounds...)
+		})
+	}
+	list.Invoke(nil, "Cut")
+	assert.Equal(t, 0, list.(List).Length())
+}
@@ -0,0 +1,92 @@
+package datum
+
+import (
+	"github.com/celskeggs/mediator/platform/types"
+	"net/url"
+	"strings"
+)
+
+// implemented by lists whose elements can have values associated with them, so that list["key"] finds the value
+type AssociativeListProvider interface {
+	ListProvider
+	Association(key types.Value) types.Value
+}
+
+// a list whose elements can each have a value associated with them. keys are usually strings, but can be any value
+// other than a number, because numbers index the list by position.
+type AssocList struct {
+	ConcreteList
+	Values map[types.Value]types.Value
+}
+
+var _ AssociativeListProvider = &AssocList{}
+
+func (a *AssocList) Association(key types.Value) types.Value {
+	// elements that have been removed from the list no longer have associations
+	for _, element := range a.Contents {
+		if element.Dereference() == key {
+			return a.Values[key]
+		}
+	}
+	return nil
+}
+
+// adds a key to the list if it is not already present, and associates a value with it
+func (a *AssocList) Associate(key types.Value, value types.Value) {
+	if _, found := a.Values[key]; !found {
+		a.Append(types.Reference(key))
+	}
+	a.Values[key] = value
+}
+
+func NewAssocList() *AssocList {
+	return &AssocList{Values: map[types.Value]types.Value{}}
+}
+
+type Param struct {
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+// parses parameters of the form "a=1;b=2" or "a=1&b=2", as in links, params2list and the options to browse()
+func ParseParams(params string) (parsed []Param) {
+	for _, param := range strings.FieldsFunc(params, func(r rune) bool {
+		return r == ';' || r == '&'
+	}) {
+		rawKey, rawValue, hasValue := param, "", false
+		if eq := strings.IndexByte(param, '='); eq >= 0 {
+			rawKey, rawValue, hasValue = param[:eq], param[eq+1:], true
+		}
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			value = rawValue
+		}
+		parsed = append(parsed, Param{Key: key, Value: value, HasValue: hasValue})
+	}
+	return parsed
+}
+
+// a parameter that is given more than once is associated with a list of all of its values
+func ParamsToList(params string) types.Value {
+	list := NewAssocList()
+	for _, param := range ParseParams(params) {
+		key := types.String(param.Key)
+		if !param.HasValue {
+			list.Associate(key, list.Values[key])
+		} else if existing, found := list.Values[key]; found && existing != nil {
+			if values, isList := existing.(List); isList {
+				values.Append(types.Reference(types.String(param.Value)))
+			} else {
+				list.Values[key] = NewList(existing, types.String(param.Value))
+			}
+		} else {
+			list.Associate(key, types.String(param.Value))
+		}
+	}
+	return List{list}
+}
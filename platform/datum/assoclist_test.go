@@ -0,0 +1,36 @@
+package datum
+
+import (
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParamsToList(t *testing.T) {
+	list := ParamsToList("src=[0x3e8];action=buy+now&item=a%26b;item=c;flag")
+	assert.Equal(t, []types.Value{
+		types.String("src"), types.String("action"), types.String("item"), types.String("flag"),
+	}, Elements(list))
+	assert.Equal(t, types.String("[0x3e8]"), list.Invoke(nil, "[]", types.String("src")))
+	assert.Equal(t, types.String("buy now"), list.Invoke(nil, "[]", types.String("action")))
+	assert.Equal(t, []types.Value{types.String("a&b"), types.String("c")}, Elements(list.Invoke(nil, "[]", types.String("item"))))
+	assert.Nil(t, list.Invoke(nil, "[]", types.String("flag")))
+	assert.Nil(t, list.Invoke(nil, "[]", types.String("missing")))
+	assert.Equal(t, types.String("src"), list.Invoke(nil, "[]", types.Int(1)))
+
+	list.Invoke(nil, "Remove", types.String("action"))
+	assert.Nil(t, list.Invoke(nil, "[]", types.String("action")))
+}
+
+func TestAssocListKeys(t *testing.T) {
+	assoc := NewAssocList()
+	assoc.Associate(types.String("a"), types.Int(1))
+	assoc.Associate(types.TypePath("/obj"), types.Float(2.5))
+	assoc.Associate(types.String("b"), nil)
+	list := List{assoc}
+	assert.Equal(t, []types.Value{types.String("a"), types.TypePath("/obj"), types.String("b")}, Elements(list))
+	assert.Equal(t, types.Int(1), list.Invoke(nil, "[]", types.String("a")))
+	assert.Equal(t, types.Float(2.5), list.Invoke(nil, "[]", types.TypePath("/obj")))
+	assert.Equal(t, types.TypePath("/obj"), list.Invoke(nil, "[]", types.Int(2)))
+	assert.Nil(t, list.Invoke(nil, "[]", types.String("b")))
+}
@@ -13,7 +13,87 @@ func NewDatumData(src *types.Datum, _ *DatumData, _ ...types.Value) {
 
 func (d *DatumData) ProcNew(src *types.Datum, usr *types.Datum) types.Value {
 	util.FIXME("support tag and vars on /datum")
-	util.FIXME("support for Del, Read, Topic, Write")
+	util.FIXME("support for Del")
 	// nothing to do for plain /datum
 	return nil
 }
+
+// called when a player follows a link to this datum, with the link's parameters in hrefList
+func (d *DatumData) ProcTopic(src *types.Datum, usr *types.Datum, href types.Value, hrefList types.Value) types.Value {
+	// nothing to do for plain /datum
+	return nil
+}
+
+// vars that describe where a datum is, rather than what it is; whoever reads a datum back decides where it goes
+var positionalVars = map[string]bool{
+	"loc": true,
+	"x":   true,
+	"y":   true,
+	"z":   true,
+}
+
+// vars that hold appearances, which savefiles cannot represent yet
+var appearanceVars = map[string]bool{
+	"overlays":  true,
+	"underlays": true,
+}
+
+func sameValue(a types.Value, b types.Value) bool {
+	listA, isListA := a.(List)
+	listB, isListB := b.(List)
+	if isListA || isListB {
+		if !isListA || !isListB {
+			return false
+		}
+		elementsA, elementsB := Elements(listA), Elements(listB)
+		if len(elementsA) != len(elementsB) {
+			return false
+		}
+		for i := range elementsA {
+			if !sameValue(elementsA[i], elementsB[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return equalValues(a, b)
+}
+
+// values like matrices and appearances are compared by their contents, rather than by identity
+func equalValues(a types.Value, b types.Value) bool {
+	if comparable, ok := a.(interface{ Equal(types.Value) bool }); ok && a != nil && b != nil {
+		return comparable.Equal(b)
+	}
+	return a == b
+}
+
+// stores each var that differs from its initial value into the current directory of the savefile, as in DM
+func (d *DatumData) ProcWrite(src *types.Datum, usr *types.Datum, savefile types.Value) types.Value {
+	prototype := src.Realm().NewPlain(src.Type())
+	for _, info := range types.UnpackDatum(src).Vars() {
+		if info.ReadOnly || info.Tmp || positionalVars[info.Name] || appearanceVars[info.Name] {
+			continue
+		}
+		value := src.Var(info.Name)
+		// singletons have no separate prototype to compare against, so every var is written
+		if prototype != src && sameValue(value, prototype.Var(info.Name)) {
+			continue
+		}
+		savefile.Invoke(usr, "[]", types.String(info.Name)).Invoke(usr, "<<", value)
+	}
+	return nil
+}
+
+// loads each var stored in the current directory of the savefile, as in DM
+func (d *DatumData) ProcRead(src *types.Datum, usr *types.Datum, savefile types.Value) types.Value {
+	writable := map[string]bool{}
+	for _, info := range types.UnpackDatum(src).Vars() {
+		writable[info.Name] = !info.ReadOnly && !info.Tmp && !positionalVars[info.Name] && !appearanceVars[info.Name]
+	}
+	for _, name := range Elements(savefile.Var("dir")) {
+		if writable[types.Unstring(name)] {
+			src.SetVar(types.Unstring(name), savefile.Invoke(usr, "[]", name).Invoke(usr, ">>"))
+		}
+	}
+	return nil
+}
@@ -17,6 +17,12 @@ func FormatMacro(macro string, atom types.Value) string {
 	util.FIXME("support more types of non-atoms")
 	if atom == nil {
 		return ""
+	} else if macro == "ref" {
+		util.FIXME("support \\ref for values other than datums")
+		if d, ok := atom.(*types.Datum); ok {
+			return types.RefString(d)
+		}
+		return ""
 	} else if s, ok := atom.(types.String); ok {
 		return types.Unstring(s)
 	} else if macro == "the" || macro == "The" {
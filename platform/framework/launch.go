@@ -23,12 +23,10 @@ func BuildWorld(tree types.TypeTree, setup SetupFunc) (*world.World, *resourcepa
 	}
 	gameworld := world.NewWorld(types.NewRealm(tree), cache)
 	maps := setup(gameworld)
-	if len(maps) > 1 {
-		panic("unimplemented: more than one map")
-	}
 
 	if len(maps) > 0 {
-		err = worldmap.LoadMapFromPack(gameworld, pack, maps[0])
+		// each map is stacked on top of the previous ones as additional z-levels
+		err = worldmap.LoadMapsFromPack(gameworld, pack, maps...)
 		if err != nil {
 			panic("cannot load world: " + err.Error())
 		}
@@ -41,7 +39,7 @@ func BuildWorld(tree types.TypeTree, setup SetupFunc) (*world.World, *resourcepa
 func Launch(tree types.TypeTree, setup SetupFunc) {
 	gameworld, pack := BuildWorld(tree, setup)
 
-	err := websession.LaunchServer(gameworld.ServerAPI(), pack)
+	err := websession.LaunchServer(gameworld.ServerAPI(), pack, websession.AuthenticatorFromFlags())
 	if err != nil {
 		panic("error in server: " + err.Error())
 	}
@@ -1,23 +1,17 @@
 package icon
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/celskeggs/mediator/dmi"
 	"github.com/celskeggs/mediator/resourcepack"
-	"math"
-	"sort"
+	"image/png"
+	"strings"
+	"time"
 )
 
-func getStatesSorted(info *dmi.DMIInfo) (out []string) {
-	for state := range info.States {
-		out = append(out, state)
-	}
-	sort.Slice(out, func(i, j int) bool {
-		return info.States[out[i]].Index < info.States[out[j]].Index
-	})
-	return out
-}
-
 func validate(state dmi.DMIState) error {
 	if state.Directions != 1 && state.Directions != 4 && state.Directions != 8 {
 		return fmt.Errorf("unexpected number of directions: %d", state.Directions)
@@ -28,34 +22,72 @@ func validate(state dmi.DMIState) error {
 	return nil
 }
 
-func precomputeStateIndexes(info *dmi.DMIInfo) (map[string]uint, uint, error) {
-	indexes := map[string]uint{}
-	sorted := getStatesSorted(info)
+// returns the index of the first subicon of each state, and then of each movement state
+func precomputeStateIndexes(info *dmi.DMIInfo) (map[string]uint, map[string]uint, error) {
+	indexes, movementIndexes := map[string]uint{}, map[string]uint{}
+	names, states := info.SortedStates()
 	nextIndex := 0
-	for _, iconState := range sorted {
-		state := info.States[iconState]
+	for i, iconState := range names {
+		state := states[i]
 		if err := validate(state); err != nil {
-			return nil, 0, err
+			return nil, nil, err
+		}
+		if state.Movement {
+			movementIndexes[iconState] = uint(nextIndex)
+		} else {
+			indexes[iconState] = uint(nextIndex)
 		}
-		indexes[iconState] = uint(nextIndex)
 		nextIndex += state.Directions * state.Frames
 	}
-	// assuming that DMIs are stored in approximate squares, ceil(sqrt(num_subicons)) will tell us the width of each row
-	stride := uint(math.Ceil(math.Sqrt(float64(nextIndex))))
-	return indexes, stride, nil
+	return indexes, movementIndexes, nil
+}
+
+// determines the number of subicons in each row of the DMI's image, based on the actual width of the PNG
+func computeStride(data []byte, info *dmi.DMIInfo) (uint, error) {
+	if info.Width < 1 || info.Height < 1 {
+		return 0, fmt.Errorf("invalid icon size %dx%d", info.Width, info.Height)
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	if config.Width < info.Width || config.Height < info.Height {
+		return 0, fmt.Errorf("DMI image is %dx%d, smaller than its %dx%d icons",
+			config.Width, config.Height, info.Width, info.Height)
+	}
+	return uint(config.Width / info.Width), nil
+}
+
+// the directory that icons created at runtime are named within
+const DynamicPrefix = "dynamic/"
+
+// dynamic icons are evicted once they have not been displayed for between one and two of these intervals. clients
+// download icons right after they are first displayed, so this only needs to be long enough for that.
+const DynamicIconLifetime = time.Minute
+
+type dynamicIcon struct {
+	icon     *Icon
+	resource resourcepack.Resource
+	// the sweep during which the icon was last registered or displayed
+	lastUsed uint
 }
 
 type IconCache struct {
 	icons map[string]*Icon
+	// icons created at runtime, which are served alongside the resource pack
+	dynamic   map[string]*dynamicIcon
+	sweep     uint
+	lastSweep time.Time
 }
 
 func NewIconCache(pack *resourcepack.ResourcePack) (*IconCache, error) {
 	ic := &IconCache{
-		icons: map[string]*Icon{},
+		icons:   map[string]*Icon{},
+		dynamic: map[string]*dynamicIcon{},
 	}
 	for _, resource := range pack.Resources {
 		if resource.IsIcon() {
-			icon, err := loadInternal(resource)
+			icon, err := ic.loadInternal(resource)
 			if err != nil {
 				return nil, err
 			}
@@ -65,24 +97,114 @@ func NewIconCache(pack *resourcepack.ResourcePack) (*IconCache, error) {
 	return ic, nil
 }
 
-func loadInternal(resource resourcepack.Resource) (*Icon, error) {
+func (i *IconCache) loadInternal(resource resourcepack.Resource) (*Icon, error) {
 	info, err := dmi.ParseDMI(resource.Data)
 	if err != nil {
 		return nil, err
 	}
-	indexes, stride, err := precomputeStateIndexes(info)
+	indexes, movementIndexes, err := precomputeStateIndexes(info)
+	if err != nil {
+		return nil, err
+	}
+	stride, err := computeStride(resource.Data, info)
 	if err != nil {
 		return nil, err
 	}
 	return &Icon{
-		dmiPath:      resource.Name,
-		dmiInfo:      info,
-		stateIndexes: indexes,
-		stride:       stride,
+		dmiPath:         resource.Name,
+		dmiInfo:         info,
+		stateIndexes:    indexes,
+		movementIndexes: movementIndexes,
+		stride:          stride,
+		data:            resource.Data,
+		cache:           i,
 	}, nil
 }
 
+// adds an icon created at runtime to the cache. icons are named by their contents, so registering identical
+// pixels twice returns the same icon.
+func (i *IconCache) Register(pixels *dmi.DMI) (*Icon, error) {
+	data, err := pixels.Encode()
+	if err != nil {
+		return nil, err
+	}
+	return i.RegisterData(data)
+}
+
+// adds an already-encoded .dmi file to the cache as an icon created at runtime, as for Register
+func (i *IconCache) RegisterData(data []byte) (*Icon, error) {
+	hash := sha256.Sum256(data)
+	name := DynamicPrefix + hex.EncodeToString(hash[:16]) + ".dmi"
+	if entry, found := i.dynamic[name]; found {
+		entry.lastUsed = i.sweep
+		return entry.icon, nil
+	}
+	resource := resourcepack.Resource{
+		Name:     name,
+		Data:     data,
+		Modified: time.Now(),
+	}
+	icon, err := i.loadInternal(resource)
+	if err != nil {
+		return nil, err
+	}
+	i.dynamic[name] = &dynamicIcon{
+		icon:     icon,
+		resource: resource,
+		lastUsed: i.sweep,
+	}
+	return icon, nil
+}
+
+// notes that a dynamic icon is being displayed. if it was evicted, but something like a var still refers to it, it is
+// registered again, so that clients can download it.
+func (i *IconCache) touch(icon *Icon) {
+	if !strings.HasPrefix(icon.dmiPath, DynamicPrefix) {
+		return
+	}
+	entry, found := i.dynamic[icon.dmiPath]
+	if !found {
+		entry = &dynamicIcon{
+			icon: icon,
+			resource: resourcepack.Resource{
+				Name:     icon.dmiPath,
+				Data:     icon.data,
+				Modified: time.Now(),
+			},
+		}
+		i.dynamic[icon.dmiPath] = entry
+	}
+	entry.lastUsed = i.sweep
+}
+
+// forgets the dynamic icons that have not been displayed since the previous sweep. sweeps happen at most once per
+// DynamicIconLifetime, no matter how often this is called.
+func (i *IconCache) EvictUnused(now time.Time) {
+	if now.Sub(i.lastSweep) < DynamicIconLifetime {
+		return
+	}
+	i.lastSweep = now
+	for name, entry := range i.dynamic {
+		if entry.lastUsed < i.sweep {
+			delete(i.dynamic, name)
+		}
+	}
+	i.sweep++
+}
+
+// looks up an icon that was registered at runtime, so that it can be served to clients
+func (i *IconCache) DynamicResource(name string) (resourcepack.Resource, bool) {
+	entry, found := i.dynamic[name]
+	if !found {
+		return resourcepack.Resource{}, false
+	}
+	return entry.resource, true
+}
+
 func (i *IconCache) Load(name string) (*Icon, bool) {
+	if entry, found := i.dynamic[name]; found {
+		return entry.icon, true
+	}
 	icon, found := i.icons[name]
 	return icon, found
 }
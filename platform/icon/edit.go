@@ -0,0 +1,618 @@
+package icon
+
+import (
+	"fmt"
+	"github.com/celskeggs/mediator/common"
+	"github.com/celskeggs/mediator/dmi"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/util"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// the blend modes accepted by Blend, numbered as in DM
+const (
+	BlendAdd      = 0
+	BlendSubtract = 1
+	BlendMultiply = 2
+	BlendOverlay  = 3
+	BlendAnd      = 4
+	BlendOr       = 5
+	BlendUnderlay = 6
+)
+
+// parses a DM color string, like "#f00", "#ff0000" or "#ff000080"
+func ParseColor(value types.Value) (color.NRGBA, error) {
+	str, ok := value.(types.String)
+	if !ok {
+		return color.NRGBA{}, fmt.Errorf("expected a color string, not %v", value)
+	}
+	hex := strings.TrimPrefix(string(str), "#")
+	if len(hex) == 3 || len(hex) == 4 {
+		var expanded strings.Builder
+		for _, c := range hex {
+			expanded.WriteRune(c)
+			expanded.WriteRune(c)
+		}
+		hex = expanded.String()
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 || !strings.HasPrefix(string(str), "#") {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", string(str))
+	}
+	rgba, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", string(str))
+	}
+	return color.NRGBA{R: uint8(rgba >> 24), G: uint8(rgba >> 16), B: uint8(rgba >> 8), A: uint8(rgba)}, nil
+}
+
+func parseColorOrPanic(value types.Value) color.NRGBA {
+	c, err := ParseColor(value)
+	if err != nil {
+		panic(err.Error())
+	}
+	return c
+}
+
+// the inverse of ParseColor; the alpha channel is only included when the color is not opaque
+func FormatColor(c color.NRGBA) string {
+	if c.A == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+func toDirection(value types.Value) common.Direction {
+	switch v := value.(type) {
+	case common.Direction:
+		return v
+	case types.Int:
+		return common.Direction(v)
+	default:
+		panic(fmt.Sprintf("expected a direction, not %v", value))
+	}
+}
+
+// optional integer parameters are null when they are not specified
+func intParam(params []types.Value, i int, def int) int {
+	if v := types.Param(params, i); v != nil {
+		return types.Unint(v)
+	}
+	return def
+}
+
+func cloneImage(img image.Image) *image.NRGBA {
+	out := image.NewNRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Src)
+	return out
+}
+
+func cloneDMI(d *dmi.DMI) *dmi.DMI {
+	out := &dmi.DMI{
+		Width:  d.Width,
+		Height: d.Height,
+	}
+	for _, state := range d.States {
+		copied := state
+		// delays can be changed in place by Insert, so they cannot be shared with the original
+		copied.Delay = append([]float64(nil), state.Delay...)
+		copied.Images = nil
+		for _, images := range state.Images {
+			var frame []image.Image
+			for _, img := range images {
+				frame = append(frame, cloneImage(img))
+			}
+			copied.Images = append(copied.Images, frame)
+		}
+		out.States = append(out.States, copied)
+	}
+	return out
+}
+
+// applies an operation to every frame of every direction of every state
+func (icon *Icon) eachImage(op func(img *image.NRGBA) *image.NRGBA) {
+	for _, state := range icon.editable().States {
+		for _, images := range state.Images {
+			for dir, img := range images {
+				images[dir] = op(img.(*image.NRGBA))
+			}
+		}
+	}
+}
+
+// converts DM's pixel coordinates, which start at 1 in the bottom-left corner, to image coordinates
+func toPoint(img *image.NRGBA, x, y int) image.Point {
+	return image.Pt(x-1, img.Bounds().Dy()-y)
+}
+
+func clampByte(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// composites top over bottom
+func over(top, bottom color.NRGBA) color.NRGBA {
+	ta, ba := float64(top.A)/255, float64(bottom.A)/255
+	outA := ta + ba*(1-ta)
+	if outA == 0 {
+		return color.NRGBA{}
+	}
+	channel := func(t, b uint8) uint8 {
+		return clampByte(int(math.Round((float64(t)*ta + float64(b)*ba*(1-ta)) / outA)))
+	}
+	return color.NRGBA{
+		R: channel(top.R, bottom.R),
+		G: channel(top.G, bottom.G),
+		B: channel(top.B, bottom.B),
+		A: clampByte(int(math.Round(outA * 255))),
+	}
+}
+
+func blendPixel(base, other color.NRGBA, mode int) color.NRGBA {
+	add := func(a, b uint8) uint8 { return clampByte(int(a) + int(b)) }
+	switch mode {
+	case BlendAdd, BlendAnd:
+		return color.NRGBA{R: add(base.R, other.R), G: add(base.G, other.G), B: add(base.B, other.B),
+			A: minByte(base.A, other.A)}
+	case BlendSubtract:
+		sub := func(a, b uint8) uint8 { return clampByte(int(a) - int(b)) }
+		return color.NRGBA{R: sub(base.R, other.R), G: sub(base.G, other.G), B: sub(base.B, other.B),
+			A: minByte(base.A, other.A)}
+	case BlendMultiply:
+		mul := func(a, b uint8) uint8 { return uint8((int(a)*int(b) + 127) / 255) }
+		return color.NRGBA{R: mul(base.R, other.R), G: mul(base.G, other.G), B: mul(base.B, other.B),
+			A: mul(base.A, other.A)}
+	case BlendOverlay:
+		return over(other, base)
+	case BlendUnderlay:
+		return over(base, other)
+	case BlendOr:
+		if base.A == 0 {
+			return other
+		}
+		if other.A == 0 {
+			return base
+		}
+		return color.NRGBA{R: add(base.R, other.R), G: add(base.G, other.G), B: add(base.B, other.B),
+			A: maxByte(base.A, other.A)}
+	default:
+		panic(fmt.Sprintf("unknown blend mode %d", mode))
+	}
+}
+
+func minByte(a, b uint8) uint8 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxByte(a, b uint8) uint8 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// picks the image for a direction and frame, falling back to the first direction and the last frame when the state
+// does not have an exact match
+func pickImage(state dmi.StateImages, dir int, frame int) image.Image {
+	if frame >= state.Frames() {
+		frame = state.Frames() - 1
+	}
+	if dir >= state.Directions() {
+		dir = 0
+	}
+	return state.Images[frame][dir]
+}
+
+// finds the image of another icon that corresponds to a particular frame, falling back to the default state and
+// then the first state when the other icon does not have a state with the same name
+func (icon *Icon) correspondingImage(state dmi.StateImages, dir int, frame int) image.Image {
+	pixels := icon.pixels()
+	if len(pixels.States) == 0 {
+		return nil
+	}
+	match := pixels.States[0]
+	for _, candidate := range pixels.States {
+		if candidate.Name == state.Name && candidate.Movement == state.Movement {
+			match = candidate
+			break
+		} else if candidate.Name == "" && !candidate.Movement && match.Name != "" {
+			match = candidate
+		}
+	}
+	return pickImage(match, dir, frame)
+}
+
+func (icon *Icon) blend(with types.Value, mode int, x int, y int) {
+	if other, ok := with.(*Icon); ok && other != nil {
+		for _, state := range icon.editable().States {
+			for frame, images := range state.Images {
+				for dir, img := range images {
+					base := img.(*image.NRGBA)
+					top := other.correspondingImage(state, dir, frame)
+					if top == nil {
+						continue
+					}
+					// the bottom-left corner of the other icon is placed at (x, y)
+					offset := toPoint(base, x, y).Sub(image.Pt(0, top.Bounds().Dy()-1))
+					for py := 0; py < base.Bounds().Dy(); py++ {
+						for px := 0; px < base.Bounds().Dx(); px++ {
+							source := image.Pt(px, py).Sub(offset).Add(top.Bounds().Min)
+							// pixels outside of the other icon are left alone
+							if !source.In(top.Bounds()) {
+								continue
+							}
+							otherColor := color.NRGBAModel.Convert(top.At(source.X, source.Y)).(color.NRGBA)
+							base.SetNRGBA(px, py, blendPixel(base.NRGBAAt(px, py), otherColor, mode))
+						}
+					}
+				}
+			}
+		}
+		return
+	}
+	c := parseColorOrPanic(with)
+	icon.eachImage(func(img *image.NRGBA) *image.NRGBA {
+		for py := 0; py < img.Bounds().Dy(); py++ {
+			for px := 0; px < img.Bounds().Dx(); px++ {
+				img.SetNRGBA(px, py, blendPixel(img.NRGBAAt(px, py), c, mode))
+			}
+		}
+		return img
+	})
+}
+
+func (icon *Icon) swapColor(from color.NRGBA, to color.NRGBA, matchAlpha bool) {
+	icon.eachImage(func(img *image.NRGBA) *image.NRGBA {
+		for py := 0; py < img.Bounds().Dy(); py++ {
+			for px := 0; px < img.Bounds().Dx(); px++ {
+				c := img.NRGBAAt(px, py)
+				if c.R == from.R && c.G == from.G && c.B == from.B && (!matchAlpha || c.A == from.A) && c.A != 0 {
+					if !matchAlpha {
+						// when the colors were given without alpha, the existing transparency is kept
+						to.A = c.A
+					}
+					img.SetNRGBA(px, py, to)
+				}
+			}
+		}
+		return img
+	})
+}
+
+// rotates each image clockwise around its center, keeping its size
+func (icon *Icon) turn(angle float64) {
+	util.NiceToHave("rotate the directions of directional icons along with their pixels")
+	radians := angle * math.Pi / 180
+	sin, cos := math.Sin(radians), math.Cos(radians)
+	icon.eachImage(func(img *image.NRGBA) *image.NRGBA {
+		width, height := img.Bounds().Dx(), img.Bounds().Dy()
+		cx, cy := float64(width-1)/2, float64(height-1)/2
+		out := image.NewNRGBA(img.Bounds())
+		for py := 0; py < height; py++ {
+			for px := 0; px < width; px++ {
+				dx, dy := float64(px)-cx, float64(py)-cy
+				sx := int(math.Round(cos*dx + sin*dy + cx))
+				sy := int(math.Round(-sin*dx + cos*dy + cy))
+				if image.Pt(sx, sy).In(img.Bounds()) {
+					out.SetNRGBA(px, py, img.NRGBAAt(sx, sy))
+				}
+			}
+		}
+		return out
+	})
+}
+
+// mirrors each image; north and south flip it vertically, east and west horizontally, and diagonals flip square
+// icons across that diagonal
+func (icon *Icon) flip(dir common.Direction) {
+	util.NiceToHave("swap the directions of directional icons along with their pixels")
+	icon.eachImage(func(img *image.NRGBA) *image.NRGBA {
+		width, height := img.Bounds().Dx(), img.Bounds().Dy()
+		out := image.NewNRGBA(img.Bounds())
+		for py := 0; py < height; py++ {
+			for px := 0; px < width; px++ {
+				var sx, sy int
+				switch dir {
+				case common.North, common.South:
+					sx, sy = px, height-1-py
+				case common.East, common.West:
+					sx, sy = width-1-px, py
+				case common.Northwest, common.Southeast:
+					sx, sy = py, px
+				case common.Northeast, common.Southwest:
+					sx, sy = width-1-py, height-1-px
+				default:
+					panic(fmt.Sprintf("cannot flip icon in direction %v", dir))
+				}
+				if image.Pt(sx, sy).In(img.Bounds()) {
+					out.SetNRGBA(px, py, img.NRGBAAt(sx, sy))
+				}
+			}
+		}
+		return out
+	})
+}
+
+func (icon *Icon) shift(dir common.Direction, offset int, wrap bool) {
+	dx, dy := dir.XY()
+	// north is up, which is towards lower image coordinates
+	dx, dy = dx*offset, -dy*offset
+	icon.eachImage(func(img *image.NRGBA) *image.NRGBA {
+		width, height := img.Bounds().Dx(), img.Bounds().Dy()
+		out := image.NewNRGBA(img.Bounds())
+		for py := 0; py < height; py++ {
+			for px := 0; px < width; px++ {
+				sx, sy := px-dx, py-dy
+				if wrap {
+					sx, sy = ((sx%width)+width)%width, ((sy%height)+height)%height
+				}
+				if image.Pt(sx, sy).In(img.Bounds()) {
+					out.SetNRGBA(px, py, img.NRGBAAt(sx, sy))
+				}
+			}
+		}
+		return out
+	})
+}
+
+func scaleImage(img image.Image, width int, height int) *image.NRGBA {
+	bounds := img.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for py := 0; py < height; py++ {
+		for px := 0; px < width; px++ {
+			sx := bounds.Min.X + px*bounds.Dx()/width
+			sy := bounds.Min.Y + py*bounds.Dy()/height
+			out.Set(px, py, img.At(sx, sy))
+		}
+	}
+	return out
+}
+
+func (icon *Icon) scale(width int, height int) {
+	if width < 1 || height < 1 {
+		panic(fmt.Sprintf("cannot scale icon to %dx%d", width, height))
+	}
+	icon.eachImage(func(img *image.NRGBA) *image.NRGBA {
+		return scaleImage(img, width, height)
+	})
+	icon.editable().Width, icon.editable().Height = width, height
+}
+
+func (icon *Icon) crop(x1, y1, x2, y2 int) {
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	if y1 > y2 {
+		y1, y2 = y2, y1
+	}
+	width, height := x2-x1+1, y2-y1+1
+	icon.eachImage(func(img *image.NRGBA) *image.NRGBA {
+		out := image.NewNRGBA(image.Rect(0, 0, width, height))
+		// the top-left corner of the cropped region
+		origin := toPoint(img, x1, y2)
+		for py := 0; py < height; py++ {
+			for px := 0; px < width; px++ {
+				source := origin.Add(image.Pt(px, py))
+				if source.In(img.Bounds()) {
+					out.SetNRGBA(px, py, img.NRGBAAt(source.X, source.Y))
+				}
+			}
+		}
+		return out
+	})
+	icon.editable().Width, icon.editable().Height = width, height
+}
+
+func (icon *Icon) drawBox(c color.NRGBA, x1, y1, x2, y2 int) {
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	if y1 > y2 {
+		y1, y2 = y2, y1
+	}
+	icon.eachImage(func(img *image.NRGBA) *image.NRGBA {
+		box := image.Rectangle{Min: toPoint(img, x1, y2), Max: toPoint(img, x2, y1).Add(image.Pt(1, 1))}
+		draw.Draw(img, box.Intersect(img.Bounds()), image.NewUniform(c), image.Point{}, draw.Src)
+		return img
+	})
+}
+
+// finds a state by name, preferring the movement variant only when moving is set
+func findState(pixels *dmi.DMI, name string, moving bool) (int, bool) {
+	fallback := -1
+	for i, state := range pixels.States {
+		if state.Name == name {
+			if state.Movement == moving {
+				return i, true
+			}
+			fallback = i
+		}
+	}
+	return fallback, fallback >= 0
+}
+
+func dirIndex(dir common.Direction, dirs int) int {
+	if dirs == 1 {
+		return 0
+	}
+	return int(directionToIndex(dir, dirs))
+}
+
+func (icon *Icon) getPixel(x, y int, state string, dir common.Direction, frame int, moving bool) types.Value {
+	pixels := icon.pixels()
+	index, found := findState(pixels, state, moving)
+	if !found {
+		return nil
+	}
+	images := pixels.States[index]
+	if frame < 1 || frame > images.Frames() {
+		return nil
+	}
+	img := images.Images[frame-1][dirIndex(dir, images.Directions())]
+	point := image.Pt(x-1, img.Bounds().Dy()-y).Add(img.Bounds().Min)
+	if !point.In(img.Bounds()) {
+		return nil
+	}
+	c := color.NRGBAModel.Convert(img.At(point.X, point.Y)).(color.NRGBA)
+	if c.A == 0 {
+		return nil
+	}
+	return types.String(FormatColor(c))
+}
+
+// the number of directions a state needs to hold an image for a particular direction
+func directionsFor(dir common.Direction) int {
+	if dir == common.North || dir == common.South || dir == common.East || dir == common.West {
+		return 4
+	}
+	return 8
+}
+
+// expands a state to hold more directions or frames, filling new images with transparency or with copies of its
+// first direction
+func resizeState(state *dmi.StateImages, dirs int, frames int, width int, height int) {
+	for len(state.Images) < frames {
+		var images []image.Image
+		for i := 0; i < state.Directions(); i++ {
+			images = append(images, image.NewNRGBA(image.Rect(0, 0, width, height)))
+		}
+		state.Images = append(state.Images, images)
+		if len(state.Delay) > 0 {
+			state.Delay = append(state.Delay, 1)
+		}
+	}
+	for frame, images := range state.Images {
+		for len(images) < dirs {
+			images = append(images, cloneImage(images[0]))
+		}
+		state.Images[frame] = images
+	}
+}
+
+// adds the images of another icon to this one, either as a whole new state or as particular directions or frames
+func (icon *Icon) insert(other *Icon, stateName string, dir common.Direction, frame int, moving bool, delay float64) {
+	pixels := icon.editable()
+	source := other.pixels()
+	if len(source.States) == 0 {
+		panic("cannot insert an icon with no states")
+	}
+	sourceState := source.States[0]
+	if index, found := findState(source, stateName, moving); found {
+		sourceState = source.States[index]
+	}
+	fit := func(img image.Image) image.Image {
+		if img.Bounds().Dx() != pixels.Width || img.Bounds().Dy() != pixels.Height {
+			return scaleImage(img, pixels.Width, pixels.Height)
+		}
+		return cloneImage(img)
+	}
+	index, found := findState(pixels, stateName, moving)
+	if found && pixels.States[index].Movement != moving {
+		found = false
+	}
+	if !found {
+		created := dmi.StateImages{Name: stateName, Movement: moving}
+		if dir == common.None && frame == 0 {
+			// the whole state is copied
+			for _, images := range sourceState.Images {
+				var copied []image.Image
+				for _, img := range images {
+					copied = append(copied, fit(img))
+				}
+				created.Images = append(created.Images, copied)
+			}
+			created.Delay = append([]float64{}, sourceState.Delay...)
+			pixels.States = append(pixels.States, created)
+			return
+		}
+		created.Images = [][]image.Image{{image.NewNRGBA(image.Rect(0, 0, pixels.Width, pixels.Height))}}
+		pixels.States = append(pixels.States, created)
+		index = len(pixels.States) - 1
+	}
+	state := &pixels.States[index]
+	dirs, frames := state.Directions(), state.Frames()
+	if dir != common.None && directionsFor(dir) > dirs {
+		dirs = directionsFor(dir)
+	}
+	if frame > frames {
+		frames = frame
+	}
+	resizeState(state, dirs, frames, pixels.Width, pixels.Height)
+	for f := range state.Images {
+		if frame != 0 && f != frame-1 {
+			continue
+		}
+		for d := range state.Images[f] {
+			if dir != common.None && d != dirIndex(dir, dirs) {
+				continue
+			}
+			state.Images[f][d] = fit(pickImage(sourceState, d, f))
+		}
+	}
+	if delay > 0 && frame != 0 {
+		if len(state.Delay) == 0 {
+			for range state.Images {
+				state.Delay = append(state.Delay, 1)
+			}
+		}
+		state.Delay[frame-1] = delay
+	}
+}
+
+// copies the parts of an icon selected by new/icon(), where an empty state, a zero direction and a zero frame
+// select everything
+func (icon *Icon) extract(state types.Value, dir common.Direction, frame int, moving types.Value) *dmi.DMI {
+	out := cloneDMI(icon.pixels())
+	if state != nil {
+		var kept []dmi.StateImages
+		for _, images := range out.States {
+			if images.Name == types.Unstring(state) && (moving == nil || images.Movement == types.AsBool(moving)) {
+				kept = append(kept, images)
+			}
+		}
+		out.States = kept
+	}
+	for i := range out.States {
+		images := &out.States[i]
+		if frame != 0 {
+			if frame > images.Frames() {
+				images.Images = nil
+			} else {
+				images.Images = images.Images[frame-1 : frame]
+			}
+			if len(images.Delay) >= frame {
+				images.Delay = []float64{images.Delay[frame-1]}
+			} else {
+				images.Delay = nil
+			}
+		}
+		if dir != common.None {
+			for f, frameImages := range images.Images {
+				images.Images[f] = []image.Image{frameImages[dirIndex(dir, len(frameImages))]}
+			}
+		}
+	}
+	var kept []dmi.StateImages
+	for _, images := range out.States {
+		if images.Frames() > 0 {
+			kept = append(kept, images)
+		}
+	}
+	out.States = kept
+	return out
+}
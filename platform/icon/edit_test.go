@@ -0,0 +1,121 @@
+package icon
+
+import (
+	"github.com/celskeggs/mediator/common"
+	"github.com/celskeggs/mediator/dmi"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func newTestIcon(t *testing.T) *Icon {
+	// a 4x2 icon, with a red pixel in its bottom-left corner and a blue one in its top-right corner
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	img.SetNRGBA(0, 1, color.NRGBA{R: 255, A: 255})
+	img.SetNRGBA(3, 0, color.NRGBA{B: 255, A: 255})
+	cache := &IconCache{icons: map[string]*Icon{}, dynamic: map[string]*dynamicIcon{}}
+	source, err := cache.Register(&dmi.DMI{
+		Width:  4,
+		Height: 2,
+		States: []dmi.StateImages{{Name: "", Images: [][]image.Image{{img}}}},
+	})
+	assert.NoError(t, err)
+	return source.Copy(nil, common.None, 0, nil)
+}
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor(types.String("#f008"))
+	assert.NoError(t, err)
+	assert.Equal(t, color.NRGBA{R: 255, A: 0x88}, c)
+	assert.Equal(t, "#ff000088", FormatColor(c))
+	_, err = ParseColor(types.String("red"))
+	assert.Error(t, err)
+}
+
+func TestIconProcs(t *testing.T) {
+	i := newTestIcon(t)
+	assert.Equal(t, types.String("#ff0000"), i.Invoke(nil, "GetPixel", types.Int(1), types.Int(1)))
+	assert.Equal(t, types.String("#0000ff"), i.Invoke(nil, "GetPixel", types.Int(4), types.Int(2)))
+	assert.Nil(t, i.Invoke(nil, "GetPixel", types.Int(2), types.Int(1)))
+
+	i.Invoke(nil, "Blend", types.String("#00ff00"), types.Int(BlendAdd))
+	assert.Equal(t, types.String("#ffff00"), i.Invoke(nil, "GetPixel", types.Int(1), types.Int(1)))
+
+	i.Invoke(nil, "SwapColor", types.String("#ffff00"), types.String("#123456"))
+	assert.Equal(t, types.String("#123456"), i.Invoke(nil, "GetPixel", types.Int(1), types.Int(1)))
+
+	i.Invoke(nil, "Flip", common.East)
+	assert.Equal(t, types.String("#123456"), i.Invoke(nil, "GetPixel", types.Int(4), types.Int(1)))
+
+	i.Invoke(nil, "Shift", common.West, types.Int(1), types.Int(1))
+	assert.Equal(t, types.String("#123456"), i.Invoke(nil, "GetPixel", types.Int(3), types.Int(1)))
+
+	i.Invoke(nil, "DrawBox", types.String("#ffffff"), types.Int(1), types.Int(2), types.Int(2), types.Int(2))
+	i.Invoke(nil, "Crop", types.Int(2), types.Int(1), types.Int(3), types.Int(2))
+	assert.Equal(t, types.Int(2), i.Invoke(nil, "Width"))
+	assert.Equal(t, types.String("#ffffff"), i.Invoke(nil, "GetPixel", types.Int(1), types.Int(2)))
+	assert.Equal(t, types.String("#123456"), i.Invoke(nil, "GetPixel", types.Int(2), types.Int(1)))
+
+	i.Invoke(nil, "Scale", types.Int(4), types.Int(4))
+	assert.Equal(t, types.String("#123456"), i.Invoke(nil, "GetPixel", types.Int(4), types.Int(1)))
+}
+
+func TestTurnFractionalAngle(t *testing.T) {
+	i := newTestIcon(t)
+	i.Invoke(nil, "Turn", types.Float(180.4))
+	assert.Equal(t, types.String("#ff0000"), i.Invoke(nil, "GetPixel", types.Int(4), types.Int(2)))
+	assert.Equal(t, types.String("#0000ff"), i.Invoke(nil, "GetPixel", types.Int(1), types.Int(1)))
+}
+
+func TestIconInsertAndStates(t *testing.T) {
+	i := newTestIcon(t)
+	i.Invoke(nil, "Insert", newTestIcon(t), types.String("walk"), common.East, types.Int(2))
+	states := i.IconStates()
+	assert.Equal(t, []types.Value{types.String(""), types.String("walk")}, states)
+	assert.Equal(t, types.String("#ff0000"),
+		i.Invoke(nil, "GetPixel", types.Int(1), types.Int(1), types.String("walk"), common.East, types.Int(2)))
+	assert.Nil(t, i.Invoke(nil, "GetPixel", types.Int(1), types.Int(1), types.String("walk"), common.North, types.Int(2)))
+}
+
+func TestResourceRegistersSnapshot(t *testing.T) {
+	i := newTestIcon(t)
+	first := i.Resource()
+	assert.Equal(t, first, i.Resource())
+	i.Invoke(nil, "Turn", types.Int(180))
+	second := i.Resource()
+	assert.NotEqual(t, first.Name(), second.Name())
+	_, found := i.cache.DynamicResource(second.Name())
+	assert.True(t, found)
+	assert.Equal(t, types.String("#ff0000"), second.Invoke(nil, "GetPixel", types.Int(4), types.Int(2)))
+	assert.Panics(t, func() {
+		second.Invoke(nil, "Turn", types.Int(90))
+	})
+}
+
+func TestEvictUnusedDynamicIcons(t *testing.T) {
+	i := newTestIcon(t)
+	registered := i.Resource()
+	start := time.Now()
+	i.cache.EvictUnused(start)
+	_, found := i.cache.DynamicResource(registered.Name())
+	assert.True(t, found)
+	// the next sweep comes too soon, and the one after it finds that the icon has not been displayed since
+	i.cache.EvictUnused(start.Add(DynamicIconLifetime / 2))
+	i.cache.EvictUnused(start.Add(DynamicIconLifetime))
+	_, found = i.cache.DynamicResource(registered.Name())
+	assert.False(t, found)
+	// displaying the icon again makes it available again
+	registered.Render("", false, common.South)
+	_, found = i.cache.DynamicResource(registered.Name())
+	assert.True(t, found)
+	i.cache.EvictUnused(start.Add(2 * DynamicIconLifetime))
+	_, found = i.cache.DynamicResource(registered.Name())
+	assert.True(t, found)
+	// the registration is reused until the icon is modified
+	assert.True(t, registered == i.Resource())
+	i.Invoke(nil, "Flip", common.North)
+	assert.False(t, registered == i.Resource())
+}
@@ -1,17 +1,30 @@
 package icon
 
 import (
+	"fmt"
 	"github.com/celskeggs/mediator/common"
 	"github.com/celskeggs/mediator/dmi"
+	"github.com/celskeggs/mediator/platform/datum"
 	"github.com/celskeggs/mediator/platform/types"
 	"github.com/celskeggs/mediator/util"
+	"image/color"
 )
 
 type Icon struct {
-	dmiPath      string
-	dmiInfo      *dmi.DMIInfo
-	stateIndexes map[string]uint
-	stride       uint
+	dmiPath         string
+	dmiInfo         *dmi.DMIInfo
+	stateIndexes    map[string]uint
+	movementIndexes map[string]uint
+	stride          uint
+	// the encoded DMI, which is only decoded into pixels when an icon proc needs them
+	data    []byte
+	decoded *dmi.DMI
+	cache   *IconCache
+	// the pixels of an icon created by new/icon(), which icon procs modify in place. such icons are registered as
+	// resources in the IconCache when they are displayed; icons loaded from resources are never modified.
+	modifiable *dmi.DMI
+	// the resource that the modifiable pixels were last registered as, until they are next modified
+	registered *Icon
 }
 
 func directionToIndex(direction common.Direction, dirs int) uint {
@@ -26,21 +39,50 @@ func directionToIndex(direction common.Direction, dirs int) uint {
 	}
 }
 
-func (icon *Icon) countFrames(state string) int {
-	return icon.dmiInfo.States[state].Frames
+// finds the state to display, and the index of its first subicon. moving atoms use the movement variant of their
+// state when there is one, and any atom falls back to the movement variant when there is no regular state.
+func (icon *Icon) lookupState(state string, moving bool) (dmi.DMIState, uint) {
+	_, hasRegular := icon.dmiInfo.States[state]
+	if movementState, found := icon.dmiInfo.MovementStates[state]; found && (moving || !hasRegular) {
+		return movementState, icon.movementIndexes[state]
+	}
+	return icon.dmiInfo.States[state], icon.stateIndexes[state]
 }
 
-func (icon *Icon) lookupIndex(state string, direction common.Direction, frame int) (index uint) {
-	dmiState := icon.dmiInfo.States[state]
+func (icon *Icon) lookupIndex(state string, moving bool, direction common.Direction, frame int) (index uint) {
+	dmiState, start := icon.lookupState(state, moving)
 	if frame < 0 || frame >= dmiState.Frames {
 		util.FIXME("don't panic here")
 		panic("invalid frame number")
 	}
-	return icon.stateIndexes[state] +
+	return start +
 		uint(frame*dmiState.Directions) +
 		directionToIndex(direction, dmiState.Directions)
 }
 
+// describes how the frames of an icon state are played back
+type Animation struct {
+	// how long each frame is displayed, in ticks, which may be fractional; frames without a delay are displayed for
+	// one tick
+	Delays []float64 `json:"delays,omitempty"`
+	// if set, the frames are played forwards and then backwards
+	Rewind bool `json:"rewind,omitempty"`
+	// the number of times that the animation plays before stopping; zero means that it plays forever
+	Loop uint `json:"loop,omitempty"`
+}
+
+func (a Animation) Equal(o Animation) bool {
+	if a.Rewind != o.Rewind || a.Loop != o.Loop || len(a.Delays) != len(o.Delays) {
+		return false
+	}
+	for i, delay := range a.Delays {
+		if o.Delays[i] != delay {
+			return false
+		}
+	}
+	return true
+}
+
 type SourceXY struct {
 	X uint `json:"x"`
 	Y uint `json:"y"`
@@ -59,17 +101,110 @@ func (icon *Icon) indexToPosition(index, width, height uint) SourceXY {
 	}
 }
 
-func (icon *Icon) Render(state string, dir common.Direction) (iconname string, frames []SourceXY, sourceWidth, sourceHeight uint) {
-	util.FIXME("implement icon sizes correctly")
-	frames = make([]SourceXY, icon.countFrames(state))
+// the name of the .dmi resource that this icon was loaded from
+func (icon *Icon) Name() string {
+	return icon.Resource().dmiPath
+}
+
+// the contents of the .dmi resource that this icon was loaded from
+func (icon *Icon) Data() []byte {
+	return icon.Resource().data
+}
+
+// the pixel data of this icon, which must not be modified unless this icon is modifiable
+func (icon *Icon) pixels() *dmi.DMI {
+	if icon.modifiable != nil {
+		return icon.modifiable
+	}
+	if icon.decoded == nil {
+		decoded, err := dmi.DecodeDMI(icon.data)
+		if err != nil {
+			panic(fmt.Sprintf("cannot decode pixels of icon %q: %v", icon.dmiPath, err))
+		}
+		icon.decoded = decoded
+	}
+	return icon.decoded
+}
+
+func (icon *Icon) editable() *dmi.DMI {
+	if icon.modifiable == nil {
+		panic(fmt.Sprintf("cannot modify icon resource %q; use new/icon() to make a copy first", icon.dmiPath))
+	}
+	icon.registered = nil
+	return icon.modifiable
+}
+
+// creates a modifiable copy of part of this icon, as in new/icon(icon, icon_state, dir, frame, moving)
+func (icon *Icon) Copy(state types.Value, dir common.Direction, frame int, moving types.Value) *Icon {
+	return &Icon{
+		cache:      icon.cache,
+		modifiable: icon.extract(state, dir, frame, moving),
+	}
+}
+
+// returns an icon resource with the current contents of this icon, which can be displayed. icon resources are
+// returned as-is, and modifiable icons are registered in the IconCache, so that later changes are not displayed.
+func (icon *Icon) Resource() *Icon {
+	if icon == nil || icon.modifiable == nil {
+		return icon
+	}
+	if icon.registered == nil {
+		registered, err := icon.cache.Register(icon.modifiable)
+		if err != nil {
+			panic(fmt.Sprintf("cannot register modified icon: %v", err))
+		}
+		icon.registered = registered
+	}
+	return icon.registered
+}
+
+// lists the names of the states of an icon; a name is only listed once, even if it has a movement state
+func (icon *Icon) IconStates() (names []types.Value) {
+	seen := map[string]bool{}
+	for _, state := range icon.pixels().States {
+		if !seen[state.Name] {
+			seen[state.Name] = true
+			names = append(names, types.String(state.Name))
+		}
+	}
+	return names
+}
+
+// finds the regions of the icon's image to display for each frame; icons can be of any size, not just the world's
+// tile size, so the size of each region is returned as well
+func (icon *Icon) Render(state string, moving bool, dir common.Direction) (iconname string, frames []SourceXY, sourceWidth, sourceHeight uint) {
+	icon.cache.touch(icon)
+	sourceWidth, sourceHeight = uint(icon.dmiInfo.Width), uint(icon.dmiInfo.Height)
+	dmiState, _ := icon.lookupState(state, moving)
+	frames = make([]SourceXY, dmiState.Frames)
 	if len(frames) == 0 {
 		panic("should never be NO frames to render")
 	}
 	for i := 0; i < len(frames); i++ {
-		index := icon.lookupIndex(state, dir, i)
-		frames[i] = icon.indexToPosition(index, 32, 32)
+		index := icon.lookupIndex(state, moving, dir, i)
+		frames[i] = icon.indexToPosition(index, sourceWidth, sourceHeight)
 	}
-	return icon.dmiPath, frames, 32, 32
+	return icon.dmiPath, frames, sourceWidth, sourceHeight
+}
+
+// describes the timing of the frames returned by Render
+func (icon *Icon) Animation(state string, moving bool) Animation {
+	dmiState, _ := icon.lookupState(state, moving)
+	var animation Animation
+	if dmiState.Frames > 1 {
+		for _, delay := range dmiState.Delay {
+			// delays are kept fractional, because clients animate on real time rather than whole ticks
+			if delay <= 0 {
+				delay = 1
+			}
+			animation.Delays = append(animation.Delays, delay)
+		}
+		animation.Rewind = dmiState.Rewind
+		if dmiState.Loop > 0 {
+			animation.Loop = uint(dmiState.Loop)
+		}
+	}
+	return animation
 }
 
 var _ types.Value = &Icon{}
@@ -83,8 +218,77 @@ func (icon *Icon) SetVar(name string, value types.Value) {
 }
 
 func (icon *Icon) Invoke(usr *types.Datum, name string, parameters ...types.Value) types.Value {
-	util.FIXME("implement Icon procedures")
-	panic("no such proc " + name + " on icon")
+	switch name {
+	case "Blend":
+		icon.blend(types.Param(parameters, 0), intParam(parameters, 1, BlendAdd),
+			intParam(parameters, 2, 1), intParam(parameters, 3, 1))
+	case "SwapColor":
+		from, to := parseColorOrPanic(types.Param(parameters, 0)), color.NRGBA{}
+		if types.Param(parameters, 1) != nil {
+			to = parseColorOrPanic(types.Param(parameters, 1))
+		}
+		// colors given with an alpha channel only match pixels with the same transparency
+		matchAlpha := len(types.Unstring(types.Param(parameters, 0))) == 9
+		icon.swapColor(from, to, matchAlpha)
+	case "Turn":
+		// angles do not have to be whole numbers of degrees
+		icon.turn(types.Unnumber(types.Param(parameters, 0)))
+	case "Flip":
+		icon.flip(toDirection(types.Param(parameters, 0)))
+	case "Shift":
+		icon.shift(toDirection(types.Param(parameters, 0)), types.Unint(types.Param(parameters, 1)),
+			types.AsBool(types.Param(parameters, 2)))
+	case "Scale":
+		icon.scale(types.Unint(types.Param(parameters, 0)), types.Unint(types.Param(parameters, 1)))
+	case "Crop":
+		icon.crop(types.Unint(types.Param(parameters, 0)), types.Unint(types.Param(parameters, 1)),
+			types.Unint(types.Param(parameters, 2)), types.Unint(types.Param(parameters, 3)))
+	case "DrawBox":
+		var c color.NRGBA
+		if types.Param(parameters, 0) != nil {
+			c = parseColorOrPanic(types.Param(parameters, 0))
+		}
+		x1, y1 := types.Unint(types.Param(parameters, 1)), types.Unint(types.Param(parameters, 2))
+		icon.drawBox(c, x1, y1, intParam(parameters, 3, x1), intParam(parameters, 4, y1))
+	case "GetPixel":
+		state := ""
+		if types.Param(parameters, 2) != nil {
+			state = types.Unstring(types.Param(parameters, 2))
+		}
+		dir := common.South
+		if types.Param(parameters, 3) != nil {
+			dir = toDirection(types.Param(parameters, 3))
+		}
+		return icon.getPixel(types.Unint(types.Param(parameters, 0)), types.Unint(types.Param(parameters, 1)),
+			state, dir, intParam(parameters, 4, 1), types.AsBool(types.Param(parameters, 5)))
+	case "Insert":
+		other, ok := types.Param(parameters, 0).(*Icon)
+		if !ok || other == nil {
+			panic("Insert requires an icon to insert")
+		}
+		state := ""
+		if types.Param(parameters, 1) != nil {
+			state = types.Unstring(types.Param(parameters, 1))
+		}
+		dir := common.None
+		if types.Param(parameters, 2) != nil {
+			dir = toDirection(types.Param(parameters, 2))
+		}
+		var delay float64
+		if types.Param(parameters, 5) != nil {
+			delay = types.Unnumber(parameters[5])
+		}
+		icon.insert(other, state, dir, intParam(parameters, 3, 0), types.AsBool(types.Param(parameters, 4)), delay)
+	case "IconStates":
+		return datum.NewList(icon.IconStates()...)
+	case "Width":
+		return types.Int(icon.pixels().Width)
+	case "Height":
+		return types.Int(icon.pixels().Height)
+	default:
+		panic("no such proc " + name + " on icon")
+	}
+	return nil
 }
 
 func (icon *Icon) String() string {
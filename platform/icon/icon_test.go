@@ -0,0 +1,104 @@
+package icon
+
+import (
+	"bytes"
+	"github.com/celskeggs/mediator/common"
+	"github.com/celskeggs/mediator/dmi"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestRenderAnimation(t *testing.T) {
+	var frames [][]image.Image
+	for i := 0; i < 3; i++ {
+		frames = append(frames, []image.Image{image.NewNRGBA(image.Rect(0, 0, 16, 24))})
+	}
+	cache := &IconCache{icons: map[string]*Icon{}, dynamic: map[string]*dynamicIcon{}}
+	i, err := cache.Register(&dmi.DMI{
+		Width:  16,
+		Height: 24,
+		States: []dmi.StateImages{
+			{Name: "still", Images: frames[:1]},
+			{Name: "anim", Rewind: true, Loop: 2, Delay: []float64{0.5, 3, 1.25}, Images: frames},
+		},
+	})
+	assert.NoError(t, err)
+
+	// four subicons are laid out in two rows of two
+	name, positions, sw, sh := i.Render("anim", false, common.South)
+	assert.Equal(t, i.dmiPath, name)
+	assert.Equal(t, []SourceXY{{X: 16, Y: 0}, {X: 0, Y: 24}, {X: 16, Y: 24}}, positions)
+	assert.Equal(t, uint(16), sw)
+	assert.Equal(t, uint(24), sh)
+
+	// fractional delays are sent as they are, rather than rounded to whole ticks
+	assert.Equal(t, Animation{Delays: []float64{0.5, 3, 1.25}, Rewind: true, Loop: 2}, i.Animation("anim", false))
+	assert.Equal(t, Animation{}, i.Animation("still", false))
+
+	// frames without a delay are displayed for one tick
+	instant, err := cache.Register(&dmi.DMI{
+		Width:  16,
+		Height: 24,
+		States: []dmi.StateImages{{Name: "", Delay: []float64{0, 2}, Images: frames[:2]}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, Animation{Delays: []float64{1, 2}}, instant.Animation("", false))
+}
+
+func TestRenderLargeIcons(t *testing.T) {
+	cache := &IconCache{icons: map[string]*Icon{}, dynamic: map[string]*dynamicIcon{}}
+	for _, size := range []image.Point{{X: 64, Y: 64}, {X: 32, Y: 48}} {
+		var dirs []image.Image
+		for i := 0; i < 4; i++ {
+			dirs = append(dirs, image.NewNRGBA(image.Rect(0, 0, size.X, size.Y)))
+		}
+		i, err := cache.Register(&dmi.DMI{
+			Width:  size.X,
+			Height: size.Y,
+			States: []dmi.StateImages{
+				{Name: "", Images: [][]image.Image{dirs[:1]}},
+				{Name: "facing", Images: [][]image.Image{dirs}},
+			},
+		})
+		assert.NoError(t, err)
+		w, h := uint(size.X), uint(size.Y)
+		// five subicons are laid out in two rows of three
+		assert.Equal(t, uint(3), i.stride)
+		// the subicons of each direction are stored in the order south, north, east, west
+		for dir, expected := range map[common.Direction]SourceXY{
+			common.South: {X: w, Y: 0},
+			common.North: {X: 2 * w, Y: 0},
+			common.East:  {X: 0, Y: h},
+			common.West:  {X: w, Y: h},
+		} {
+			_, positions, sw, sh := i.Render("facing", false, dir)
+			assert.Equal(t, []SourceXY{expected}, positions, "%v at %dx%d", dir, w, h)
+			assert.Equal(t, w, sw)
+			assert.Equal(t, h, sh)
+		}
+		_, positions, _, _ := i.Render("", false, common.East)
+		assert.Equal(t, []SourceXY{{X: 0, Y: 0}}, positions)
+	}
+}
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	var out bytes.Buffer
+	assert.NoError(t, png.Encode(&out, image.NewNRGBA(image.Rect(0, 0, width, height))))
+	return out.Bytes()
+}
+
+func TestComputeStride(t *testing.T) {
+	stride, err := computeStride(encodePNG(t, 192, 96), &dmi.DMIInfo{Width: 64, Height: 48})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), stride)
+	// any leftover columns at the right edge are not part of a subicon
+	stride, err = computeStride(encodePNG(t, 100, 48), &dmi.DMIInfo{Width: 32, Height: 48})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), stride)
+	_, err = computeStride(encodePNG(t, 32, 32), &dmi.DMIInfo{Width: 64, Height: 64})
+	assert.Error(t, err)
+	_, err = computeStride(encodePNG(t, 32, 32), &dmi.DMIInfo{Width: 0, Height: 32})
+	assert.Error(t, err)
+}
@@ -4,5 +4,6 @@ package impl
 import (
 	_ "github.com/celskeggs/mediator/platform/atoms"
 	_ "github.com/celskeggs/mediator/platform/datum"
+	_ "github.com/celskeggs/mediator/platform/savefile"
 	_ "github.com/celskeggs/mediator/platform/world"
 )
@@ -0,0 +1,81 @@
+package procs
+
+import (
+	"fmt"
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/icon"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/util"
+	"github.com/celskeggs/mediator/webclient/sprite"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// browse(body, options) shows the HTML in a popup window, or closes the window if the body is null
+func NewBrowse(body types.Value, options types.Value) sprite.Browse {
+	var browse sprite.Browse
+	if options != nil {
+		for _, param := range datum.ParseParams(types.Unstring(options)) {
+			switch param.Key {
+			case "window":
+				browse.Window = param.Value
+			case "size":
+				// like an unknown option, a badly formed size is ignored rather than stopping the proc
+				if width, height, err := parseSize(param.Value); err != nil {
+					log.Printf("ignoring browse() size: %v\n", err)
+				} else {
+					browse.Width, browse.Height = width, height
+				}
+			default:
+				util.NiceToHave("support more browse() options")
+			}
+		}
+	}
+	if body == nil {
+		browse.Close = true
+	} else if html, ok := body.(types.String); ok {
+		browse.HTML = types.Unstring(html)
+	} else {
+		util.NiceToHave("support browse() of files")
+		panic(fmt.Sprintf("browse() requires HTML text, not %v", body))
+	}
+	return browse
+}
+
+func parseSize(size string) (width uint, height uint, err error) {
+	parts := strings.Split(size, "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid size %q", size)
+	}
+	w, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid size %q", size)
+	}
+	h, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid size %q", size)
+	}
+	return uint(w), uint(h), nil
+}
+
+// browse_rsc(file, filename) lets HTML in popup windows refer to a resource by the filename
+func NewBrowseResource(file types.Value, filename types.Value) sprite.Browse {
+	var resource string
+	if s, ok := file.(types.String); ok {
+		resource = types.Unstring(s)
+	} else if i, ok := file.(*icon.Icon); ok {
+		resource = i.Name()
+	} else {
+		panic(fmt.Sprintf("browse_rsc() requires a resource file, not %v", file))
+	}
+	name := path.Base(resource)
+	if filename != nil {
+		name = types.Unstring(filename)
+	}
+	return sprite.Browse{
+		Name:     name,
+		Resource: resource,
+	}
+}
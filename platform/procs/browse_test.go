@@ -0,0 +1,21 @@
+package procs_test
+
+import (
+	"github.com/celskeggs/mediator/platform/procs"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/webclient/sprite"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBrowseOptions(t *testing.T) {
+	body := types.String("<p>hello</p>")
+	assert.Equal(t, sprite.Browse{Window: "help", HTML: "<p>hello</p>", Width: 400, Height: 300},
+		procs.NewBrowse(body, types.String("window=help;size=400x300;can_resize=0")))
+	// a badly formed size is ignored, just like an unknown option
+	for _, size := range []string{"400 x 300", "400", "x300", "400x300x2", "-1x300"} {
+		assert.Equal(t, sprite.Browse{Window: "help", HTML: "<p>hello</p>"},
+			procs.NewBrowse(body, types.String("window=help;size="+size)), size)
+	}
+	assert.Equal(t, sprite.Browse{Window: "help", Close: true}, procs.NewBrowse(nil, types.String("window=help")))
+}
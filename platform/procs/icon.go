@@ -0,0 +1,60 @@
+package procs
+
+import (
+	"fmt"
+	"github.com/celskeggs/mediator/common"
+	"github.com/celskeggs/mediator/platform/atoms"
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/icon"
+	"github.com/celskeggs/mediator/platform/types"
+)
+
+// implements both new/icon(icon, icon_state, dir, frame, moving) and icon(...), which create a modifiable copy of
+// part of an icon
+func NewIcon(w atoms.World, args ...types.Value) *icon.Icon {
+	var source *icon.Icon
+	switch file := types.Param(args, 0).(type) {
+	case *icon.Icon:
+		source = file
+	case types.String:
+		source = w.Icon(types.Unstring(file))
+	}
+	if source == nil {
+		panic("new/icon() requires an icon file")
+	}
+	dir := common.None
+	switch d := types.Param(args, 2).(type) {
+	case common.Direction:
+		dir = d
+	case types.Int:
+		dir = common.Direction(d)
+	}
+	frame := 0
+	if f := types.Param(args, 3); f != nil {
+		frame = types.Unint(f)
+	}
+	return source.Copy(types.Param(args, 1), dir, frame, types.Param(args, 4))
+}
+
+// implements both new/matrix(...) and matrix(...), which accept either nothing, another matrix to copy, or the six
+// entries a-f
+func NewMatrix(args ...types.Value) *atoms.Matrix {
+	switch len(args) {
+	case 0:
+		return atoms.IdentityMatrix()
+	case 1:
+		m, ok := atoms.ToMatrix(args[0])
+		if !ok {
+			panic("matrix() requires a matrix to copy")
+		}
+		return m.Copy()
+	case 6:
+		m, ok := atoms.ToMatrix(datum.NewList(args...))
+		if !ok {
+			panic("matrix() requires six numbers")
+		}
+		return m
+	default:
+		panic(fmt.Sprintf("matrix() does not accept %d arguments", len(args)))
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/celskeggs/mediator/platform/types"
 	"github.com/celskeggs/mediator/platform/world"
 	"github.com/celskeggs/mediator/util"
+	"image/color"
 )
 
 func KWInvoke(w atoms.World, usr *types.Datum, name string, kwargs map[string]types.Value, args ...types.Value) types.Value {
@@ -22,6 +23,14 @@ func KWInvoke(w atoms.World, usr *types.Datum, name string, kwargs map[string]ty
 			types.KWParam(args, 3, kwargs, "channel"),
 			types.KWParam(args, 4, kwargs, "volume"),
 		)
+	case "browse":
+		return NewBrowse(types.KWParam(args, 0, kwargs, "Body"), types.KWParam(args, 1, kwargs, "Options"))
+	case "browse_rsc":
+		return NewBrowseResource(types.KWParam(args, 0, kwargs, "File"), types.KWParam(args, 1, kwargs, "FileName"))
+	case "input":
+		return NewInput(w.(*world.World), usr, kwargs, args)
+	case "alert":
+		return NewAlert(w.(*world.World), usr, kwargs, args)
 	case "oview":
 		if usr == nil {
 			panic("usr was nil when calling oview")
@@ -47,6 +56,50 @@ func KWInvoke(w atoms.World, usr *types.Datum, name string, kwargs map[string]ty
 			}
 			return datum.NewList(w.View1(usr, atoms.ViewInclusive)...)
 		}
+	case "range":
+		// the distance and the center can be given in either order
+		dist, center := w.(*world.World).ViewDist, usr
+		for _, arg := range args {
+			if d, ok := arg.(*types.Datum); ok {
+				center = d
+			} else if arg != nil {
+				dist = types.Unuint(arg)
+			}
+		}
+		if center == nil {
+			panic("usr was nil when calling range")
+		}
+		return datum.NewList(w.Range(dist, center)...)
+	case "locate":
+		if len(args) == 1 {
+			if path, ok := args[0].(types.TypePath); ok {
+				util.NiceToHave("support locate(Type) in Container")
+				return w.FindOneType(path)
+			}
+			if ref, ok := args[0].(types.String); ok {
+				util.NiceToHave("support locate(Tag)")
+				return w.Realm().LookupRef(types.Unstring(ref))
+			}
+		}
+		if len(args) != 3 {
+			util.NiceToHave("support locate(Tag)")
+			panic("only locate(Type) and locate(x, y, z) are supported")
+		}
+		x, y, z := types.Unint(args[0]), types.Unint(args[1]), types.Unint(args[2])
+		if x < 1 || y < 1 || z < 1 {
+			return nil
+		}
+		return w.LocateXYZ(uint(x), uint(y), uint(z))
+	case "block":
+		start, end := types.Param(args, 0), types.Param(args, 1)
+		if !types.IsType(start, "/turf") || !types.IsType(end, "/turf") {
+			panic("block() requires two turfs")
+		}
+		sx, sy, sz := world.XYZ(start)
+		ex, ey, ez := world.XYZ(end)
+		util.NiceToHave("support block(x1, y1, z1, x2, y2, z2)")
+		return datum.NewList(w.Block(world.MinUint(sx, ex), world.MinUint(sy, ey), world.MinUint(sz, ez),
+			world.MaxUint(sx, ex), world.MaxUint(sy, ey), world.MaxUint(sz, ez))...)
 	case "stat":
 		if usr == nil {
 			panic("usr is nil during attempt to use stat()")
@@ -122,6 +175,32 @@ func KWInvoke(w atoms.World, usr *types.Datum, name string, kwargs map[string]ty
 			panic("attempt to flick to something that's not an icon state nor an icon")
 		}
 		return nil
+	case "icon":
+		return NewIcon(w, args...)
+	case "matrix":
+		return NewMatrix(args...)
+	case "list":
+		util.NiceToHave("support associative list() arguments")
+		return datum.NewList(args...)
+	case "params2list":
+		return datum.ParamsToList(types.Unstring(types.Param(args, 0)))
+	case "icon_states":
+		i, ok := types.Param(args, 0).(*icon.Icon)
+		if !ok || i == nil {
+			panic("icon_states() requires an icon")
+		}
+		return datum.NewList(i.IconStates()...)
+	case "rgb":
+		c := color.NRGBA{
+			R: uint8(types.Unint(types.Param(args, 0))),
+			G: uint8(types.Unint(types.Param(args, 1))),
+			B: uint8(types.Unint(types.Param(args, 2))),
+			A: 255,
+		}
+		if alpha := types.Param(args, 3); alpha != nil {
+			c.A = uint8(types.Unint(alpha))
+		}
+		return types.String(icon.FormatColor(c))
 	default:
 		panic(fmt.Sprintf("unimplemented global function %q", name))
 	}
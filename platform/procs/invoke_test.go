@@ -0,0 +1,64 @@
+package procs_test
+
+import (
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/procs"
+	"github.com/celskeggs/mediator/platform/testworld"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const testMap = `"a" = (/turf,/area)
+"b" = (/mob{name = "player"},/turf,/area)
+
+(1,1,1) = {"
+aaa
+aba
+aaa
+"}
+`
+
+func TestLocate(t *testing.T) {
+	w := testworld.NewWorld(t, testMap)
+	assert.Equal(t, w.LocateXYZ(1, 3, 1), procs.Invoke(w, nil, "locate", types.Int(1), types.Int(3), types.Int(1)))
+	assert.Nil(t, procs.Invoke(w, nil, "locate", types.Int(0), types.Int(1), types.Int(1)))
+	assert.Nil(t, procs.Invoke(w, nil, "locate", types.Int(-1), types.Int(1), types.Int(1)))
+	assert.Nil(t, procs.Invoke(w, nil, "locate", types.Int(1), types.Int(1), types.Int(2)))
+	player := w.FindOneType("/mob")
+	assert.Equal(t, player, procs.Invoke(w, nil, "locate", types.TypePath("/mob")))
+	assert.Equal(t, player, procs.Invoke(w, nil, "locate", types.String(types.RefString(player.(*types.Datum)))))
+	assert.Nil(t, procs.Invoke(w, nil, "locate", types.String("[0xffff]")))
+	assert.Panics(t, func() {
+		procs.Invoke(w, nil, "locate", types.Int(1), types.Int(1))
+	})
+}
+
+func TestBlockProc(t *testing.T) {
+	w := testworld.NewWorld(t, testMap)
+	// the corners can be given in any order
+	turfs := datum.Elements(procs.Invoke(w, nil, "block", w.LocateXYZ(3, 2, 1), w.LocateXYZ(2, 1, 1)))
+	assert.Equal(t, []types.Value{
+		w.LocateXYZ(2, 1, 1), w.LocateXYZ(3, 1, 1), w.LocateXYZ(2, 2, 1), w.LocateXYZ(3, 2, 1),
+	}, turfs)
+	assert.Panics(t, func() {
+		procs.Invoke(w, nil, "block", w.LocateXYZ(1, 1, 1), w.FindOneType("/mob"))
+	})
+}
+
+func TestRangeProc(t *testing.T) {
+	w := testworld.NewWorld(t, testMap)
+	player := w.FindOneType("/mob").(*types.Datum)
+	corner := w.LocateXYZ(1, 1, 1).(*types.Datum)
+	// range(Center, Dist) is the same as range(Dist, Center)
+	assert.Equal(t, datum.Elements(procs.Invoke(w, nil, "range", types.Int(1), corner)),
+		datum.Elements(procs.Invoke(w, nil, "range", corner, types.Int(1))))
+	assert.Len(t, datum.Elements(procs.Invoke(w, nil, "range", types.Int(1), corner)), 5)
+	// the distance and the center default to world.view and usr
+	assert.Len(t, datum.Elements(procs.Invoke(w, player, "range")), 10)
+	assert.Equal(t, []types.Value{w.LocateXYZ(2, 2, 1), player},
+		datum.Elements(procs.Invoke(w, player, "range", types.Int(0))))
+	assert.Panics(t, func() {
+		procs.Invoke(w, nil, "range", types.Int(1))
+	})
+}
@@ -0,0 +1,125 @@
+package procs
+
+import (
+	"fmt"
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/platform/world"
+	"github.com/celskeggs/mediator/util"
+	"github.com/celskeggs/mediator/webclient/sprite"
+	"strings"
+)
+
+// input() and alert() take the player to ask as an optional first argument, which defaults to usr
+func promptTarget(usr *types.Datum, kwargs map[string]types.Value, args []types.Value) (client types.Value, rest []types.Value) {
+	target, rest := types.Value(usr), args
+	if explicit, found := kwargs["Usr"]; found {
+		target = explicit
+	} else if first := types.Param(args, 0); types.IsType(first, "/mob") || types.IsType(first, "/client") {
+		target, rest = first, args[1:]
+	}
+	if types.IsType(target, "/mob") {
+		target = target.Var("client")
+	}
+	if target == nil || !types.IsType(target, "/client") {
+		// there is nobody to ask
+		return nil, rest
+	}
+	return target, rest
+}
+
+// the text shown for a choice in a list
+func choiceName(choice types.Value) string {
+	if choice == nil {
+		return ""
+	} else if s, ok := choice.(types.String); ok {
+		return types.Unstring(s)
+	} else if _, ok := choice.(*types.Datum); ok {
+		return types.Unstring(choice.Var("name"))
+	} else {
+		return choice.String()
+	}
+}
+
+func optionalText(v types.Value) string {
+	if v == nil {
+		return ""
+	} else if s, ok := v.(types.String); ok {
+		return types.Unstring(s)
+	} else {
+		return v.String()
+	}
+}
+
+// input(Usr=usr, Message, Title, Default) as Type in List, where the parser passes the type and the list as the
+// "as" and "in" keyword arguments
+func NewInput(w *world.World, usr *types.Datum, kwargs map[string]types.Value, args []types.Value) types.Value {
+	client, args := promptTarget(usr, kwargs, args)
+	defaultValue := types.KWParam(args, 2, kwargs, "Default")
+	prompt := sprite.Prompt{
+		Message: optionalText(types.KWParam(args, 0, kwargs, "Message")),
+		Title:   optionalText(types.KWParam(args, 1, kwargs, "Title")),
+		Default: optionalText(defaultValue),
+	}
+	if as, found := kwargs["as"]; found {
+		for _, asType := range strings.Split(types.Unstring(as), "|") {
+			switch asType {
+			case "text":
+				prompt.Kind = sprite.PromptText
+			case "message":
+				prompt.Kind = sprite.PromptMessage
+			case "num":
+				prompt.Kind = sprite.PromptNum
+			case "null":
+				prompt.Cancel = true
+			case "anything":
+				prompt.Kind = sprite.PromptList
+			default:
+				util.NiceToHave("support input() of types other than text, message, num and anything")
+				panic(fmt.Sprintf("unsupported input() type %q", asType))
+			}
+		}
+	}
+	var choices []types.Value
+	if list, found := kwargs["in"]; found {
+		prompt.Kind = sprite.PromptList
+		choices = datum.Elements(list)
+		for _, choice := range choices {
+			prompt.Choices = append(prompt.Choices, choiceName(choice))
+		}
+		prompt.Default = choiceName(defaultValue)
+	} else if prompt.Kind == sprite.PromptList {
+		panic("input() as anything requires a list to choose from")
+	} else if prompt.Kind == "" {
+		prompt.Kind = sprite.PromptText
+	}
+	if client == nil {
+		return nil
+	}
+	return w.Prompt(client, prompt, choices)
+}
+
+// alert(Usr=usr, Message, Title, Button1="Ok", Button2, Button3) returns the text of the button that was pressed
+func NewAlert(w *world.World, usr *types.Datum, kwargs map[string]types.Value, args []types.Value) types.Value {
+	client, args := promptTarget(usr, kwargs, args)
+	prompt := sprite.Prompt{
+		Kind:    sprite.PromptAlert,
+		Message: optionalText(types.KWParam(args, 0, kwargs, "Message")),
+		Title:   optionalText(types.KWParam(args, 1, kwargs, "Title")),
+	}
+	var choices []types.Value
+	for i, name := range []string{"Button1", "Button2", "Button3"} {
+		if button := types.KWParam(args, 2+i, kwargs, name); button != nil {
+			choices = append(choices, button)
+			prompt.Choices = append(prompt.Choices, optionalText(button))
+		}
+	}
+	if len(choices) == 0 {
+		choices = []types.Value{types.String("Ok")}
+		prompt.Choices = []string{"Ok"}
+	}
+	if client == nil {
+		return nil
+	}
+	return w.Prompt(client, prompt, choices)
+}
@@ -0,0 +1,371 @@
+// Package savefile implements /savefile: a tree of directories, each of which can hold a value, stored in a file.
+//
+// On disk, a savefile is kept in the same text form that ExportText produces. Each line is one directory, indented
+// with one tab for each level that it is nested below the top of the file:
+//
+//	name = "Bob"
+//	level = 3
+//	stats
+//		strength = 12
+//	mob = object(".0")
+//		.0
+//			type = /mob/player
+//			name = "Bob"
+//
+// A line holds the name of the directory, followed by " = " and a value if the directory has one. Names are written
+// bare if they only contain letters, digits, underscores and dots, and are quoted otherwise.
+//
+// Values are DM constants: null, numbers, "strings", 'resources', /type/paths and list(...). Infinite numbers are
+// written as inf and -inf, and numbers that are not a number as nan. Entries of associative lists are written as
+// key = value, as in list("a" = 1, "b"), where "b" has no associated value. Icons created at runtime only exist in
+// memory, so they are written with their contents, as filedata("name", "base64 data"). A datum is written as
+// object("path"), and its type and saved vars are stored in the directory named by the path. The first reference to a
+// datum in a single write stores the datum in a ".0", ".1", ... directory beneath the entry, and uses a relative path;
+// later references to the same datum use its absolute path, so that shared references survive a round trip.
+//
+// Empty lines, and lines that start with //, are ignored.
+package savefile
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/celskeggs/mediator/dmconst"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Kind int
+
+const (
+	KindNull Kind = iota
+	KindNumber
+	KindString
+	KindResource
+	KindPath
+	KindList
+	KindObject
+	KindFile
+)
+
+// a value as it is stored in a savefile
+type Value struct {
+	Kind   Kind
+	Number float64
+	// the contents of a string, the name of a resource or file, a type path, or the directory of an object
+	Str  string
+	List []ListEntry
+	// the contents of a file
+	Data []byte
+}
+
+type ListEntry struct {
+	// Key is only meaningful if HasKey is set, which is the case for entries of the form `key = value`
+	HasKey bool
+	Key    Value
+	Value  Value
+}
+
+func (v Value) String() string {
+	switch v.Kind {
+	case KindNull:
+		return "null"
+	case KindNumber:
+		return formatNumber(v.Number)
+	case KindString:
+		return dmconst.QuoteString(v.Str)
+	case KindResource:
+		return "'" + dmconst.EscapeString(v.Str, "'") + "'"
+	case KindPath:
+		return v.Str
+	case KindList:
+		var entries []string
+		for _, entry := range v.List {
+			if entry.HasKey {
+				entries = append(entries, entry.Key.String()+" = "+entry.Value.String())
+			} else {
+				entries = append(entries, entry.Value.String())
+			}
+		}
+		return "list(" + strings.Join(entries, ",") + ")"
+	case KindObject:
+		return "object(" + dmconst.QuoteString(v.Str) + ")"
+	case KindFile:
+		return "filedata(" + dmconst.QuoteString(v.Str) + "," + dmconst.QuoteString(base64.StdEncoding.EncodeToString(v.Data)) + ")"
+	default:
+		panic("unknown savefile value kind")
+	}
+}
+
+// numbers are written so that readValue can read them back exactly, including those that have no numeric literal
+func formatNumber(number float64) string {
+	switch {
+	case math.IsInf(number, 1):
+		return "inf"
+	case math.IsInf(number, -1):
+		return "-inf"
+	case math.IsNaN(number):
+		return "nan"
+	default:
+		return strconv.FormatFloat(number, 'g', -1, 64)
+	}
+}
+
+type node struct {
+	name     string
+	hasValue bool
+	value    Value
+	children []*node
+}
+
+func (n *node) child(name string) *node {
+	for _, child := range n.children {
+		if child.name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+func (n *node) makeChild(name string) *node {
+	child := n.child(name)
+	if child == nil {
+		child = &node{name: name}
+		n.children = append(n.children, child)
+	}
+	return child
+}
+
+// object directories are named .0, .1, and so on; other names that start with a dot belong to the user
+func isObjectDir(name string) bool {
+	if len(name) < 2 || name[0] != '.' {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if name[i] < '0' || name[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *node) objectCount() (count int) {
+	for _, child := range n.children {
+		if isObjectDir(child.name) {
+			count++
+		}
+	}
+	return count
+}
+
+// drops the objects stored beneath a directory, which is done whenever its value is replaced
+func (n *node) removeObjects() {
+	var kept []*node
+	for _, child := range n.children {
+		if !isObjectDir(child.name) {
+			kept = append(kept, child)
+		}
+	}
+	n.children = kept
+}
+
+func isBareName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !dmconst.IsIdentChar(name[i]) && name[i] != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func encodeName(name string) string {
+	if isBareName(name) {
+		return name
+	}
+	return dmconst.QuoteString(name)
+}
+
+func encodeChildren(n *node, depth int, out *strings.Builder) {
+	for _, child := range n.children {
+		out.WriteString(strings.Repeat("\t", depth))
+		out.WriteString(encodeName(child.name))
+		if child.hasValue {
+			out.WriteString(" = ")
+			out.WriteString(child.value.String())
+		}
+		out.WriteString("\n")
+		encodeChildren(child, depth+1, out)
+	}
+}
+
+// produces the text form of everything beneath a directory
+func encodeTree(n *node) string {
+	var out strings.Builder
+	encodeChildren(n, 0, &out)
+	return out.String()
+}
+
+// parses the text form of a directory tree, returning a directory that contains everything in the text
+func decodeTree(text string) (*node, error) {
+	root := &node{}
+	stack := []*node{root}
+	for linei, line := range strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n") {
+		body := strings.TrimLeft(line, "\t")
+		if strings.TrimSpace(body) == "" || strings.HasPrefix(body, "//") {
+			continue
+		}
+		depth := len(line) - len(body)
+		if depth >= len(stack) {
+			return nil, fmt.Errorf("savefile line %d: indented too far", linei+1)
+		}
+		s := &scanner{dmconst.Scanner{Text: body, Source: "savefile", Line: linei + 1}}
+		name, err := s.readName()
+		if err != nil {
+			return nil, err
+		}
+		parent := stack[depth]
+		if parent.child(name) != nil {
+			return nil, s.Errorf("duplicate entry %q", name)
+		}
+		entry := parent.makeChild(name)
+		if s.Accept("=") {
+			entry.value, err = s.readValue()
+			if err != nil {
+				return nil, err
+			}
+			entry.hasValue = true
+		}
+		if s.SkipSpace(); !s.EOF() {
+			return nil, s.Errorf("unexpected text %q", s.Text[s.Pos:])
+		}
+		stack = append(stack[:depth+1], entry)
+	}
+	return root, nil
+}
+
+// scans a single line of a savefile
+type scanner struct {
+	dmconst.Scanner
+}
+
+func (s *scanner) readName() (string, error) {
+	s.SkipSpace()
+	if s.Peek() == '"' {
+		return s.ReadString()
+	}
+	name := s.ReadWhile(func(c byte) bool {
+		return dmconst.IsIdentChar(c) || c == '.'
+	})
+	if name == "" {
+		return "", s.Errorf("expected entry name")
+	}
+	return name, nil
+}
+
+func (s *scanner) readList() ([]ListEntry, error) {
+	entries := []ListEntry{}
+	if s.Accept(")") {
+		return entries, nil
+	}
+	for {
+		var entry ListEntry
+		value, err := s.readValue()
+		if err != nil {
+			return nil, err
+		}
+		if s.Accept("=") {
+			entry.HasKey, entry.Key = true, value
+			value, err = s.readValue()
+			if err != nil {
+				return nil, err
+			}
+		}
+		entry.Value = value
+		entries = append(entries, entry)
+		if s.Accept(")") {
+			return entries, nil
+		}
+		if err := s.Expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// reads the rest of filedata("name", "base64 data")
+func (s *scanner) readFileData() (Value, error) {
+	if err := s.Expect("("); err != nil {
+		return Value{}, err
+	}
+	name, err := s.ReadString()
+	if err != nil {
+		return Value{}, err
+	}
+	if err := s.Expect(","); err != nil {
+		return Value{}, err
+	}
+	encoded, err := s.ReadString()
+	if err != nil {
+		return Value{}, err
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return Value{}, s.Errorf("invalid file data: %v", err)
+	}
+	return Value{Kind: KindFile, Str: name, Data: data}, s.Expect(")")
+}
+
+func (s *scanner) readValue() (Value, error) {
+	s.SkipSpace()
+	c := s.Peek()
+	switch {
+	case c == '"':
+		str, err := s.ReadString()
+		return Value{Kind: KindString, Str: str}, err
+	case c == '\'':
+		s.Pos++
+		str, err := s.ReadStringBody("'")
+		return Value{Kind: KindResource, Str: str}, err
+	case c == '/':
+		path, err := s.ReadPath()
+		return Value{Kind: KindPath, Str: path}, err
+	case s.Accept("-inf"):
+		return Value{Kind: KindNumber, Number: math.Inf(-1)}, nil
+	case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		number, err := s.ReadNumber()
+		return Value{Kind: KindNumber, Number: number}, err
+	case dmconst.IsIdentChar(c):
+		ident := s.ReadWhile(dmconst.IsIdentChar)
+		switch ident {
+		case "null":
+			return Value{Kind: KindNull}, nil
+		case "inf":
+			return Value{Kind: KindNumber, Number: math.Inf(1)}, nil
+		case "nan":
+			return Value{Kind: KindNumber, Number: math.NaN()}, nil
+		case "object":
+			if err := s.Expect("("); err != nil {
+				return Value{}, err
+			}
+			path, err := s.ReadString()
+			if err != nil {
+				return Value{}, err
+			}
+			return Value{Kind: KindObject, Str: path}, s.Expect(")")
+		case "filedata":
+			return s.readFileData()
+		case "list":
+			if err := s.Expect("("); err != nil {
+				return Value{}, err
+			}
+			entries, err := s.readList()
+			return Value{Kind: KindList, List: entries}, err
+		default:
+			return Value{}, s.Errorf("unsupported value %q", ident)
+		}
+	default:
+		return Value{}, s.Errorf("unexpected character %q at start of value", c)
+	}
+}
@@ -0,0 +1,90 @@
+package savefile
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+const exampleSavefile = `name = "Bob \"the\" Builder\n"
+level = 3
+"with spaces"
+	icon = 'player.dmi'
+	kind = /mob/player
+mob = object(".0")
+	.0
+		type = /mob/player
+		inventory = list(object(".0"),-2.5,null)
+		stats = list("strength" = 12,"lucky")
+			.0
+				type = /obj/sword
+		self = object("/mob/.0")
+`
+
+func TestEncodeDecodeTree(t *testing.T) {
+	root, err := decodeTree(exampleSavefile)
+	assert.NoError(t, err)
+	assert.Equal(t, exampleSavefile, encodeTree(root))
+
+	name := root.child("name")
+	assert.Equal(t, Value{Kind: KindString, Str: "Bob \"the\" Builder\n"}, name.value)
+	group := root.child("with spaces")
+	assert.False(t, group.hasValue)
+	assert.Equal(t, Value{Kind: KindResource, Str: "player.dmi"}, group.child("icon").value)
+	inventory := root.child("mob").child(".0").child("inventory")
+	assert.Equal(t, []ListEntry{
+		{Value: Value{Kind: KindObject, Str: ".0"}},
+		{Value: Value{Kind: KindNumber, Number: -2.5}},
+		{Value: Value{Kind: KindNull}},
+	}, inventory.value.List)
+	stats := root.child("mob").child(".0").child("stats")
+	assert.Equal(t, []ListEntry{
+		{HasKey: true, Key: Value{Kind: KindString, Str: "strength"}, Value: Value{Kind: KindNumber, Number: 12}},
+		{Value: Value{Kind: KindString, Str: "lucky"}},
+	}, stats.value.List)
+}
+
+func TestNonFiniteNumbers(t *testing.T) {
+	const text = "up = inf\ndown = list(-inf,-1)\nunknown = nan\n"
+	root, err := decodeTree(text)
+	assert.NoError(t, err)
+	assert.Equal(t, text, encodeTree(root))
+	assert.True(t, math.IsInf(root.child("up").value.Number, 1))
+	assert.True(t, math.IsInf(root.child("down").value.List[0].Value.Number, -1))
+	assert.Equal(t, -1.0, root.child("down").value.List[1].Value.Number)
+	assert.True(t, math.IsNaN(root.child("unknown").value.Number))
+	assert.Equal(t, "nan", Value{Kind: KindNumber, Number: math.NaN()}.String())
+}
+
+func TestDecodeTreeErrors(t *testing.T) {
+	for _, text := range []string{
+		"a = 1\n\t\tb = 2\n",
+		"a = 1\na = 2\n",
+		"a = \"unterminated\n",
+		"a = NORTH\n",
+		"a = infinity\n",
+		"a = list(1,2\n",
+		"a = 1 2\n",
+		"a = list(\"b\" = )\n",
+		"a = filedata(\"b.dmi\",\"not base64!\")\n",
+	} {
+		_, err := decodeTree(text)
+		assert.Error(t, err, text)
+	}
+}
+
+func TestIsObjectDir(t *testing.T) {
+	assert.True(t, isObjectDir(".0"))
+	assert.True(t, isObjectDir(".12"))
+	assert.False(t, isObjectDir("."))
+	assert.False(t, isObjectDir(".hidden"))
+	assert.False(t, isObjectDir(".1a"))
+	assert.False(t, isObjectDir("0"))
+}
+
+func TestResolvePath(t *testing.T) {
+	assert.Equal(t, []string{"players", "bob"}, resolvePath([]string{"players"}, "bob"))
+	assert.Equal(t, []string{"other"}, resolvePath([]string{"players"}, "../other"))
+	assert.Equal(t, []string{"a", "b"}, resolvePath([]string{"players"}, "/a/b/"))
+	assert.Equal(t, []string{"players", ".0"}, resolvePath([]string{"players"}, "./.0"))
+}
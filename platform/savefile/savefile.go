@@ -0,0 +1,440 @@
+package savefile
+
+import (
+	"fmt"
+	"github.com/celskeggs/mediator/common"
+	"github.com/celskeggs/mediator/platform/atoms"
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/icon"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/util"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//mediator:declare SavefileData /savefile /datum
+type SavefileData struct {
+	filename string
+	root     *node
+	cwd      []string
+	// the number of << and >> operations in progress; nested operations happen while datums write and read their vars
+	depth int
+	// the datums written or read by the outermost operation, so that shared references are preserved
+	written map[*types.Datum]string
+	read    map[string]*types.Datum
+}
+
+func NewSavefileData(src *types.Datum, data *SavefileData, args ...types.Value) {
+	data.root = &node{}
+	if len(args) >= 1 && args[0] != nil {
+		data.filename = types.Unstring(args[0])
+		content, err := ioutil.ReadFile(data.filename)
+		if err == nil {
+			data.root, err = decodeTree(string(content))
+			if err != nil {
+				panic(fmt.Sprintf("cannot load savefile %q: %v", data.filename, err))
+			}
+		} else if !os.IsNotExist(err) {
+			panic(fmt.Sprintf("cannot load savefile %q: %v", data.filename, err))
+		}
+	}
+}
+
+func SavefileDataChunk(v types.Value) (*SavefileData, bool) {
+	impl, ok := types.Unpack(v)
+	if !ok {
+		return nil, false
+	}
+	chunk := impl.Chunk("github.com/celskeggs/mediator/platform/savefile.SavefileData")
+	if chunk == nil {
+		return nil, false
+	}
+	return chunk.(*SavefileData), true
+}
+
+// interprets a path like "/players/bob", "stats" or "../other" relative to the directory cwd
+func resolvePath(cwd []string, path string) []string {
+	var out []string
+	if !strings.HasPrefix(path, "/") {
+		out = append(out, cwd...)
+	}
+	for _, part := range strings.Split(path, "/") {
+		if part == "" || part == "." {
+			continue
+		} else if part == ".." {
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		} else {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func formatPath(path []string) string {
+	return "/" + strings.Join(path, "/")
+}
+
+func (s *SavefileData) lookup(path []string, create bool) *node {
+	n := s.root
+	for _, part := range path {
+		next := n.child(part)
+		if next == nil {
+			if !create {
+				return nil
+			}
+			next = n.makeChild(part)
+		}
+		n = next
+	}
+	return n
+}
+
+func (s *SavefileData) begin() {
+	if s.depth == 0 {
+		s.written = map[*types.Datum]string{}
+		s.read = map[string]*types.Datum{}
+	}
+	s.depth++
+}
+
+func (s *SavefileData) end(modified bool) {
+	s.depth--
+	if s.depth == 0 {
+		s.written = nil
+		s.read = nil
+		if modified {
+			s.flush()
+		}
+	}
+}
+
+// writes the savefile to disk; this happens after every change, so a crash cannot lose a completed write
+func (s *SavefileData) flush() {
+	if s.filename == "" {
+		return
+	}
+	err := writeFileAtomic(s.filename, []byte(encodeTree(s.root)))
+	if err != nil {
+		panic(fmt.Sprintf("cannot save savefile %q: %v", s.filename, err))
+	}
+}
+
+func writeFileAtomic(filename string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	temp := filename + ".tmp"
+	if err := ioutil.WriteFile(temp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, filename)
+}
+
+func (s *SavefileData) encode(src *types.Datum, usr *types.Datum, path []string, n *node, value types.Value) Value {
+	switch v := value.(type) {
+	case nil:
+		return Value{Kind: KindNull}
+	case types.Int:
+		return Value{Kind: KindNumber, Number: float64(v)}
+	case types.Float:
+		return Value{Kind: KindNumber, Number: float64(v)}
+	case common.Direction:
+		return Value{Kind: KindNumber, Number: float64(v)}
+	case types.String:
+		return Value{Kind: KindString, Str: string(v)}
+	case types.TypePath:
+		return Value{Kind: KindPath, Str: string(v)}
+	case *icon.Icon:
+		if v == nil {
+			return Value{Kind: KindNull}
+		}
+		if strings.HasPrefix(v.Name(), icon.DynamicPrefix) {
+			// the icon is only kept in memory for as long as it is used, so it has to be saved along with its contents
+			return Value{Kind: KindFile, Str: v.Name(), Data: v.Data()}
+		}
+		return Value{Kind: KindResource, Str: v.Name()}
+	case *atoms.Matrix:
+		// read back as a list of six numbers, which can be assigned to a transform just like a matrix
+		list := Value{Kind: KindList, List: []ListEntry{}}
+		for _, entry := range v.Entries() {
+			list.List = append(list.List, ListEntry{Value: Value{Kind: KindNumber, Number: entry}})
+		}
+		return list
+	case datum.List:
+		list := Value{Kind: KindList, List: []ListEntry{}}
+		assoc, isAssoc := v.ListProvider.(datum.AssociativeListProvider)
+		for _, element := range datum.Elements(v) {
+			entry := ListEntry{Value: s.encode(src, usr, path, n, element)}
+			if isAssoc {
+				// an element without an associated value is the same as one associated with null
+				if associated := assoc.Association(element); associated != nil {
+					entry.HasKey, entry.Key = true, entry.Value
+					entry.Value = s.encode(src, usr, path, n, associated)
+				}
+			}
+			list.List = append(list.List, entry)
+		}
+		return list
+	case *types.Datum:
+		if existing, found := s.written[v]; found {
+			return Value{Kind: KindObject, Str: existing}
+		}
+		name := fmt.Sprintf(".%d", n.objectCount())
+		objectPath := append(append([]string{}, path...), name)
+		s.written[v] = formatPath(objectPath)
+		object := n.makeChild(name)
+		typeEntry := object.makeChild("type")
+		typeEntry.value = Value{Kind: KindPath, Str: string(v.Type())}
+		typeEntry.hasValue = true
+		oldCwd := s.cwd
+		s.cwd = objectPath
+		v.Invoke(usr, "Write", src)
+		s.cwd = oldCwd
+		return Value{Kind: KindObject, Str: name}
+	default:
+		panic(fmt.Sprintf("cannot write %v to a savefile", value))
+	}
+}
+
+func (s *SavefileData) decode(src *types.Datum, usr *types.Datum, path []string, value Value) (types.Value, error) {
+	switch value.Kind {
+	case KindNull:
+		return nil, nil
+	case KindNumber:
+		return types.Number(value.Number), nil
+	case KindString:
+		return types.String(value.Str), nil
+	case KindPath:
+		return types.TypePath(value.Str), nil
+	case KindResource:
+		if !strings.HasSuffix(value.Str, ".dmi") {
+			util.FIXME("support non-icon resources in savefiles")
+			return nil, fmt.Errorf("resource %q not supported: only icons can be read from savefiles", value.Str)
+		}
+		loaded, found := atoms.WorldOf(src).LoadIcon(value.Str)
+		if !found {
+			return nil, fmt.Errorf("no such icon %q found in resource pack", value.Str)
+		}
+		return loaded, nil
+	case KindFile:
+		if !strings.HasSuffix(value.Str, ".dmi") {
+			return nil, fmt.Errorf("file %q not supported: only icons can be read from savefiles", value.Str)
+		}
+		return atoms.WorldOf(src).RegisterIcon(value.Data)
+	case KindList:
+		return s.decodeList(src, usr, path, value.List)
+	case KindObject:
+		objectPath := resolvePath(path, value.Str)
+		if existing, found := s.read[formatPath(objectPath)]; found {
+			return existing, nil
+		}
+		object := s.lookup(objectPath, false)
+		if object == nil || object.child("type") == nil || object.child("type").value.Kind != KindPath {
+			return nil, fmt.Errorf("savefile object at %s has no type", formatPath(objectPath))
+		}
+		d := src.Realm().New(types.TypePath(object.child("type").value.Str), usr)
+		s.read[formatPath(objectPath)] = d
+		oldCwd := s.cwd
+		s.cwd = objectPath
+		d.Invoke(usr, "Read", src)
+		s.cwd = oldCwd
+		return d, nil
+	default:
+		panic("unknown savefile value kind")
+	}
+}
+
+func (s *SavefileData) decodeList(src *types.Datum, usr *types.Datum, path []string, entries []ListEntry) (types.Value, error) {
+	elements := make([]types.Value, len(entries))
+	keyed := false
+	for i, entry := range entries {
+		element, err := s.decode(src, usr, path, entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		elements[i] = element
+		keyed = keyed || entry.HasKey
+	}
+	if !keyed {
+		return datum.NewList(elements...), nil
+	}
+	list := datum.NewAssocList()
+	for i, entry := range entries {
+		if !entry.HasKey {
+			list.Associate(elements[i], nil)
+			continue
+		}
+		key, err := s.decode(src, usr, path, entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		if _, isNumber := key.(types.Int); isNumber || key == nil {
+			return nil, fmt.Errorf("%v cannot be used as a key in an associative list", entry.Key)
+		}
+		list.Associate(key, elements[i])
+	}
+	return datum.List{ListProvider: list}, nil
+}
+
+func (s *SavefileData) writeAt(src *types.Datum, usr *types.Datum, path []string, value types.Value) {
+	s.begin()
+	defer s.end(true)
+	n := s.lookup(path, true)
+	n.removeObjects()
+	n.value = s.encode(src, usr, path, n, value)
+	n.hasValue = true
+}
+
+func (s *SavefileData) readAt(src *types.Datum, usr *types.Datum, path []string) types.Value {
+	n := s.lookup(path, false)
+	if n == nil || !n.hasValue {
+		return nil
+	}
+	s.begin()
+	defer s.end(false)
+	value, err := s.decode(src, usr, path, n.value)
+	if err != nil {
+		// only this entry is lost; the rest of the savefile can still be read
+		log.Printf("cannot read savefile entry %s from %q: %v\n", formatPath(path), s.filename, err)
+		return nil
+	}
+	return value
+}
+
+func (s *SavefileData) GetCd(src *types.Datum) types.Value {
+	return types.String(formatPath(s.cwd))
+}
+
+// changing to a directory that does not exist creates it, as in DM
+func (s *SavefileData) SetCd(src *types.Datum, value types.Value) {
+	s.cwd = resolvePath(s.cwd, types.Unstring(value))
+	s.lookup(s.cwd, true)
+}
+
+func (s *SavefileData) GetDir(src *types.Datum) types.Value {
+	var names []types.Value
+	if n := s.lookup(s.cwd, false); n != nil {
+		for _, child := range n.children {
+			if !isObjectDir(child.name) {
+				names = append(names, types.String(child.name))
+			}
+		}
+	}
+	return datum.NewList(names...)
+}
+
+func (s *SavefileData) GetName(src *types.Datum) types.Value {
+	return types.String(s.filename)
+}
+
+// implements S << value, which stores a value in the current directory
+func (s *SavefileData) OperatorWrite(src *types.Datum, usr *types.Datum, value types.Value) types.Value {
+	s.writeAt(src, usr, s.cwd, value)
+	return nil
+}
+
+// implements S >> var, which loads the value of the current directory
+func (s *SavefileData) OperatorRead(src *types.Datum, usr *types.Datum) types.Value {
+	return s.readAt(src, usr, s.cwd)
+}
+
+// implements S["key"], which refers to a directory relative to the current directory
+func (s *SavefileData) OperatorIndex(src *types.Datum, usr *types.Datum, key types.Value) types.Value {
+	return Entry{
+		savefile: src,
+		path:     resolvePath(s.cwd, types.Unstring(key)),
+	}
+}
+
+func (s *SavefileData) ProcExportText(src *types.Datum, usr *types.Datum, path types.Value, file types.Value) types.Value {
+	dir := s.cwd
+	if path != nil {
+		dir = resolvePath(s.cwd, types.Unstring(path))
+	}
+	var text string
+	if n := s.lookup(dir, false); n != nil {
+		text = encodeTree(n)
+	}
+	if file == nil {
+		return types.String(text)
+	}
+	err := writeFileAtomic(types.Unstring(file), []byte(text))
+	if err != nil {
+		panic(fmt.Sprintf("cannot export savefile to %q: %v", types.Unstring(file), err))
+	}
+	return nil
+}
+
+// merges the entries described by the text into the directory, replacing any existing entries with the same names
+func (s *SavefileData) ProcImportText(src *types.Datum, usr *types.Datum, path types.Value, source types.Value) types.Value {
+	dir := s.cwd
+	if path != nil {
+		dir = resolvePath(s.cwd, types.Unstring(path))
+	}
+	imported, err := decodeTree(types.Unstring(source))
+	if err != nil {
+		panic(errors.Wrap(err, "while importing savefile text").Error())
+	}
+	n := s.lookup(dir, true)
+	for _, child := range imported.children {
+		if existing := n.child(child.name); existing != nil {
+			*existing = *child
+		} else {
+			n.children = append(n.children, child)
+		}
+	}
+	s.flush()
+	return nil
+}
+
+func (s *SavefileData) ProcFlush(src *types.Datum, usr *types.Datum) types.Value {
+	s.flush()
+	return nil
+}
+
+// the result of indexing a savefile, as in S["key"], which can be written with << or read with >>
+type Entry struct {
+	savefile *types.Datum
+	path     []string
+}
+
+var _ types.Value = Entry{}
+
+func (e Entry) Var(name string) types.Value {
+	panic("no such var " + name + " on savefile entry")
+}
+
+func (e Entry) SetVar(name string, value types.Value) {
+	panic("no such var " + name + " on savefile entry")
+}
+
+func (e Entry) Invoke(usr *types.Datum, name string, parameters ...types.Value) types.Value {
+	s, ok := SavefileDataChunk(e.savefile)
+	if !ok {
+		panic("savefile entry does not refer to a savefile")
+	}
+	switch name {
+	case "<<":
+		s.writeAt(e.savefile, usr, e.path, types.Param(parameters, 0))
+		return nil
+	case ">>":
+		return s.readAt(e.savefile, usr, e.path)
+	case "[]":
+		return Entry{
+			savefile: e.savefile,
+			path:     resolvePath(e.path, types.Unstring(types.Param(parameters, 0))),
+		}
+	default:
+		panic(fmt.Sprintf("unimplemented: savefile entry proc %q", name))
+	}
+}
+
+func (e Entry) String() string {
+	return "[savefile entry " + formatPath(e.path) + "]"
+}
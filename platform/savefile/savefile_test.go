@@ -0,0 +1,159 @@
+package savefile_test
+
+import (
+	"github.com/celskeggs/mediator/common"
+	"github.com/celskeggs/mediator/dmi"
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/icon"
+	"github.com/celskeggs/mediator/platform/testworld"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/platform/world"
+	"github.com/celskeggs/mediator/resourcepack"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/color"
+	"testing"
+)
+
+const testMap = `"a" = (/turf,/area)
+
+(1,1,1) = {"
+aa
+"}
+`
+
+// a world with a single 1x1 icon, box.dmi, which is one white pixel
+func newTestWorld(t *testing.T) *world.World {
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	data, err := (&dmi.DMI{
+		Width:  1,
+		Height: 1,
+		States: []dmi.StateImages{{Name: "", Images: [][]image.Image{{img}}}},
+	}).Encode()
+	assert.NoError(t, err)
+	return testworld.NewWorldWithResources(t, []resourcepack.Resource{{Name: "box.dmi", Data: data}}, testMap)
+}
+
+func newSavefile(w *world.World) *types.Datum {
+	return w.Realm().New("/savefile", nil)
+}
+
+func write(s *types.Datum, key string, value types.Value) {
+	s.Invoke(nil, "[]", types.String(key)).Invoke(nil, "<<", value)
+}
+
+func read(s *types.Datum, key string) types.Value {
+	return s.Invoke(nil, "[]", types.String(key)).Invoke(nil, ">>")
+}
+
+func exportText(s *types.Datum) string {
+	return types.Unstring(s.Invoke(nil, "ExportText"))
+}
+
+func TestWriteObjectVars(t *testing.T) {
+	w := newTestWorld(t)
+	sword := w.Realm().New("/obj", nil, w.LocateXYZ(2, 1, 1))
+	sword.SetVar("name", types.String("sword"))
+	sword.SetVar("density", types.Int(1))
+	s := newSavefile(w)
+	write(s, "sword", sword)
+	// tmp vars like appearance and verbs, vars that still have their initial values, and loc, x, y and z are left out
+	assert.Equal(t, `sword = object(".0")
+	.0
+		type = /obj
+		density = 1
+		name = "sword"
+`, exportText(s))
+}
+
+func TestSharedObjectWrittenOnce(t *testing.T) {
+	w := newTestWorld(t)
+	sword := w.Realm().New("/obj", nil)
+	sword.SetVar("name", types.String("sword"))
+	s := newSavefile(w)
+	write(s, "pair", datum.NewList(sword, sword))
+	assert.Equal(t, `pair = list(object(".0"),object("/pair/.0"))
+	.0
+		type = /obj
+		name = "sword"
+`, exportText(s))
+
+	pair := datum.Elements(read(s, "pair"))
+	if assert.Len(t, pair, 2) {
+		assert.Equal(t, types.String("sword"), pair[0].Var("name"))
+		assert.True(t, pair[0] == pair[1])
+		assert.False(t, pair[0] == sword)
+	}
+}
+
+func TestDottedKeysAreNotObjects(t *testing.T) {
+	w := newTestWorld(t)
+	s := newSavefile(w)
+	write(s, "sword/.hidden", types.Int(1))
+	sword := w.Realm().New("/obj", nil)
+	sword.SetVar("name", types.String("sword"))
+	// replacing the value of a directory only drops the objects stored beneath it, not the user's own entries
+	write(s, "sword", sword)
+	write(s, "sword", sword)
+	assert.Equal(t, `sword = object(".0")
+	.hidden = 1
+	.0
+		type = /obj
+		name = "sword"
+`, exportText(s))
+	s.SetVar("cd", types.String("sword"))
+	assert.Equal(t, []types.Value{types.String(".hidden")}, datum.Elements(s.Var("dir")))
+}
+
+func TestReadObjectVars(t *testing.T) {
+	w := newTestWorld(t)
+	s := newSavefile(w)
+	// a savefile edited by hand to include tmp and positional vars, which are not restored
+	s.Invoke(nil, "ImportText", nil, types.String(`sword = object(".0")
+	.0
+		type = /obj
+		name = "sword"
+		appearance = "not an appearance"
+		loc = object(".0")
+			.0
+				type = /obj
+				name = "bag"
+`))
+	sword := read(s, "sword")
+	if assert.NotNil(t, sword) {
+		assert.Equal(t, types.String("sword"), sword.Var("name"))
+		assert.Nil(t, sword.Var("loc"))
+		assert.Equal(t, types.Int(0), sword.Var("x"))
+	}
+}
+
+func TestDynamicIconSurvivesRestart(t *testing.T) {
+	w := newTestWorld(t)
+	tinted := w.Icon("box.dmi").Copy(nil, common.None, 0, nil)
+	tinted.Invoke(nil, "Blend", types.String("#ff0000"), types.Int(icon.BlendMultiply))
+	sword := w.Realm().New("/obj", nil)
+	sword.SetVar("icon", tinted)
+	s := newSavefile(w)
+	write(s, "sword", sword)
+
+	// a new world has never seen the tinted icon, so it has to come from the savefile itself
+	restarted := newTestWorld(t)
+	s2 := newSavefile(restarted)
+	s2.Invoke(nil, "ImportText", nil, types.String(exportText(s)))
+	loaded := read(s2, "sword")
+	if assert.NotNil(t, loaded) {
+		i, ok := loaded.Var("icon").(*icon.Icon)
+		if assert.True(t, ok) {
+			assert.Equal(t, types.String("#ff0000"), i.Invoke(nil, "GetPixel", types.Int(1), types.Int(1)))
+		}
+	}
+}
+
+func TestUnknownIconIsNotLoaded(t *testing.T) {
+	w := newTestWorld(t)
+	s := newSavefile(w)
+	s.Invoke(nil, "ImportText", nil, types.String("skin = 'missing.dmi'\nface = 'box.dmi'\n"))
+	assert.Nil(t, read(s, "skin"))
+	assert.Equal(t, w.Icon("box.dmi"), read(s, "face"))
+}
@@ -0,0 +1,70 @@
+// Code generated by mediator boilerplate; DO NOT EDIT.
+package testworld
+
+import (
+	"github.com/celskeggs/mediator/platform/types"
+)
+
+type tree struct{}
+
+type treeSingletons struct {
+	Area *types.Datum
+}
+
+var Tree types.TypeTree = tree{}
+
+func (tree) PopulateRealm(realm *types.Realm) {
+	realm.TreePrivateState = &treeSingletons{
+		Area: NewArea(realm),
+	}
+}
+
+func (tree) Parent(path types.TypePath) types.TypePath {
+	switch path {
+	case "/area":
+		return "/atom"
+	case "/atom":
+		return "/datum"
+	case "/atom/movable":
+		return "/atom"
+	case "/client":
+		return "/datum"
+	case "/datum":
+		return ""
+	case "/mob":
+		return "/atom/movable"
+	case "/obj":
+		return "/atom/movable"
+	case "/savefile":
+		return "/datum"
+	case "/turf":
+		return "/atom"
+	default:
+		panic("unknown type " + path.String())
+	}
+}
+
+func (tree) New(realm *types.Realm, path types.TypePath, params ...types.Value) *types.Datum {
+	switch path {
+	case "/area":
+		return realm.TreePrivateState.(*treeSingletons).Area
+	case "/atom":
+		return NewAtom(realm, params...)
+	case "/atom/movable":
+		return NewAtomMovable(realm, params...)
+	case "/client":
+		return NewClient(realm, params...)
+	case "/datum":
+		return NewDatum(realm, params...)
+	case "/mob":
+		return NewMob(realm, params...)
+	case "/obj":
+		return NewObj(realm, params...)
+	case "/savefile":
+		return NewSavefile(realm, params...)
+	case "/turf":
+		return NewTurf(realm, params...)
+	default:
+		panic("unknown type " + path.String())
+	}
+}
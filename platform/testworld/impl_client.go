@@ -0,0 +1,208 @@
+// Code generated by mediator boilerplate; DO NOT EDIT.
+package testworld
+
+import (
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/platform/world"
+)
+
+type ClientImpl struct {
+	world.ClientData
+	datum.DatumData
+}
+
+func NewClient(realm *types.Realm, params ...types.Value) *types.Datum {
+	i := &ClientImpl{}
+	d := realm.NewDatum(i)
+	datum.NewDatumData(d, &i.DatumData, params...)
+	world.NewClientData(d, &i.ClientData, params...)
+	return d
+}
+
+func (t *ClientImpl) Type() types.TypePath {
+	return "/client"
+}
+
+func (t *ClientImpl) Var(src *types.Datum, name string) (types.Value, bool) {
+	switch name {
+	case "type":
+		return types.TypePath("/client"), true
+	case "parent_type":
+		return types.TypePath("/datum"), true
+	case "key":
+		return types.String(t.ClientData.VarKey), true
+	case "statobj":
+		return t.ClientData.VarStatobj.Dereference(), true
+	case "view":
+		return types.Int(t.ClientData.VarView), true
+	case "ckey":
+		return t.ClientData.GetCkey(src), true
+	case "eye":
+		return t.ClientData.GetEye(src), true
+	case "mob":
+		return t.ClientData.GetMob(src), true
+	case "virtual_eye":
+		return t.ClientData.GetVirtualEye(src), true
+	default:
+		return nil, false
+	}
+}
+
+func (t *ClientImpl) Vars() []types.VarInfo {
+	return []types.VarInfo{
+		{Name: "type", ReadOnly: true},
+		{Name: "parent_type", ReadOnly: true},
+		{Name: "key"},
+		{Name: "statobj"},
+		{Name: "view"},
+		{Name: "ckey", ReadOnly: true},
+		{Name: "eye"},
+		{Name: "mob"},
+		{Name: "virtual_eye", ReadOnly: true},
+	}
+}
+
+func (t *ClientImpl) SetVar(src *types.Datum, name string, value types.Value) types.SetResult {
+	switch name {
+	case "type":
+		return types.SetResultReadOnly
+	case "parent_type":
+		return types.SetResultReadOnly
+	case "key":
+		t.ClientData.VarKey = types.Unstring(value)
+		return types.SetResultOk
+	case "statobj":
+		t.ClientData.VarStatobj = types.Reference(value)
+		return types.SetResultOk
+	case "view":
+		t.ClientData.VarView = types.Unint(value)
+		return types.SetResultOk
+	case "ckey":
+		return types.SetResultReadOnly
+	case "eye":
+		t.ClientData.SetEye(src, value)
+		return types.SetResultOk
+	case "mob":
+		t.ClientData.SetMob(src, value)
+		return types.SetResultOk
+	case "virtual_eye":
+		return types.SetResultReadOnly
+	default:
+		return types.SetResultNonexistent
+	}
+}
+
+func (t *ClientImpl) Proc(src *types.Datum, usr *types.Datum, name string, params ...types.Value) (types.Value, bool) {
+	switch name {
+	case "<<":
+		return t.ClientData.OperatorWrite(src, usr, types.Param(params, 0)), true
+	case "Click":
+		return t.ClientData.ProcClick(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2), types.Param(params, 3)), true
+	case "DblClick":
+		return t.ClientData.ProcDblClick(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2), types.Param(params, 3)), true
+	case "Del":
+		return t.ClientData.ProcDel(src, usr), true
+	case "East":
+		return t.ClientData.ProcEast(src, usr), true
+	case "MouseDown":
+		return t.ClientData.ProcMouseDown(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2), types.Param(params, 3)), true
+	case "MouseDrop":
+		return t.ClientData.ProcMouseDrop(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2), types.Param(params, 3), types.Param(params, 4), types.Param(params, 5), types.Param(params, 6)), true
+	case "MouseEntered":
+		return t.ClientData.ProcMouseEntered(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2), types.Param(params, 3)), true
+	case "MouseExited":
+		return t.ClientData.ProcMouseExited(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2), types.Param(params, 3)), true
+	case "MouseUp":
+		return t.ClientData.ProcMouseUp(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2), types.Param(params, 3)), true
+	case "Move":
+		return t.ClientData.ProcMove(src, usr, types.Param(params, 0), types.Param(params, 1)), true
+	case "New":
+		return t.ClientData.ProcNew(src, usr, types.Param(params, 0)), true
+	case "North":
+		return t.ClientData.ProcNorth(src, usr), true
+	case "Read":
+		return t.DatumData.ProcRead(src, usr, types.Param(params, 0)), true
+	case "South":
+		return t.ClientData.ProcSouth(src, usr), true
+	case "Stat":
+		return t.ClientData.ProcStat(src, usr), true
+	case "Topic":
+		return t.ClientData.ProcTopic(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2)), true
+	case "West":
+		return t.ClientData.ProcWest(src, usr), true
+	case "Write":
+		return t.DatumData.ProcWrite(src, usr, types.Param(params, 0)), true
+	default:
+		return nil, false
+	}
+}
+
+func (t *ClientImpl) SuperProc(src *types.Datum, usr *types.Datum, chunk string, name string, params ...types.Value) (types.Value, bool) {
+	switch chunk {
+	case "github.com/celskeggs/mediator/platform/world.ClientData":
+		switch name {
+		case "New":
+			return t.DatumData.ProcNew(src, usr), true
+		case "Topic":
+			return t.DatumData.ProcTopic(src, usr, types.Param(params, 0), types.Param(params, 1)), true
+		}
+	}
+	return nil, false
+}
+
+func (t *ClientImpl) ProcSettings(name string) (types.ProcSettings, bool) {
+	switch name {
+	case "<<":
+		return types.ProcSettings{}, true
+	case "Click":
+		return types.ProcSettings{}, true
+	case "DblClick":
+		return types.ProcSettings{}, true
+	case "Del":
+		return types.ProcSettings{}, true
+	case "East":
+		return types.ProcSettings{}, true
+	case "MouseDown":
+		return types.ProcSettings{}, true
+	case "MouseDrop":
+		return types.ProcSettings{}, true
+	case "MouseEntered":
+		return types.ProcSettings{}, true
+	case "MouseExited":
+		return types.ProcSettings{}, true
+	case "MouseUp":
+		return types.ProcSettings{}, true
+	case "Move":
+		return types.ProcSettings{}, true
+	case "New":
+		return types.ProcSettings{}, true
+	case "North":
+		return types.ProcSettings{}, true
+	case "Read":
+		return types.ProcSettings{}, true
+	case "South":
+		return types.ProcSettings{}, true
+	case "Stat":
+		return types.ProcSettings{}, true
+	case "Topic":
+		return types.ProcSettings{}, true
+	case "West":
+		return types.ProcSettings{}, true
+	case "Write":
+		return types.ProcSettings{}, true
+	default:
+		return types.ProcSettings{}, false
+	}
+}
+
+func (t *ClientImpl) Chunk(ref string) interface{} {
+	switch ref {
+	case "github.com/celskeggs/mediator/platform/world.ClientData":
+		return &t.ClientData
+	case "github.com/celskeggs/mediator/platform/datum.DatumData":
+		return &t.DatumData
+	default:
+		return nil
+	}
+}
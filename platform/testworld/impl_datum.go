@@ -0,0 +1,96 @@
+// Code generated by mediator boilerplate; DO NOT EDIT.
+package testworld
+
+import (
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/types"
+)
+
+type DatumImpl struct {
+	datum.DatumData
+}
+
+func NewDatum(realm *types.Realm, params ...types.Value) *types.Datum {
+	i := &DatumImpl{}
+	d := realm.NewDatum(i)
+	datum.NewDatumData(d, &i.DatumData, params...)
+	return d
+}
+
+func (t *DatumImpl) Type() types.TypePath {
+	return "/datum"
+}
+
+func (t *DatumImpl) Var(src *types.Datum, name string) (types.Value, bool) {
+	switch name {
+	case "type":
+		return types.TypePath("/datum"), true
+	case "parent_type":
+		return nil, true
+	default:
+		return nil, false
+	}
+}
+
+func (t *DatumImpl) Vars() []types.VarInfo {
+	return []types.VarInfo{
+		{Name: "type", ReadOnly: true},
+		{Name: "parent_type", ReadOnly: true},
+	}
+}
+
+func (t *DatumImpl) SetVar(src *types.Datum, name string, value types.Value) types.SetResult {
+	switch name {
+	case "type":
+		return types.SetResultReadOnly
+	case "parent_type":
+		return types.SetResultReadOnly
+	default:
+		return types.SetResultNonexistent
+	}
+}
+
+func (t *DatumImpl) Proc(src *types.Datum, usr *types.Datum, name string, params ...types.Value) (types.Value, bool) {
+	switch name {
+	case "New":
+		return t.DatumData.ProcNew(src, usr), true
+	case "Read":
+		return t.DatumData.ProcRead(src, usr, types.Param(params, 0)), true
+	case "Topic":
+		return t.DatumData.ProcTopic(src, usr, types.Param(params, 0), types.Param(params, 1)), true
+	case "Write":
+		return t.DatumData.ProcWrite(src, usr, types.Param(params, 0)), true
+	default:
+		return nil, false
+	}
+}
+
+func (t *DatumImpl) SuperProc(src *types.Datum, usr *types.Datum, chunk string, name string, params ...types.Value) (types.Value, bool) {
+	switch chunk {
+	}
+	return nil, false
+}
+
+func (t *DatumImpl) ProcSettings(name string) (types.ProcSettings, bool) {
+	switch name {
+	case "New":
+		return types.ProcSettings{}, true
+	case "Read":
+		return types.ProcSettings{}, true
+	case "Topic":
+		return types.ProcSettings{}, true
+	case "Write":
+		return types.ProcSettings{}, true
+	default:
+		return types.ProcSettings{}, false
+	}
+}
+
+func (t *DatumImpl) Chunk(ref string) interface{} {
+	switch ref {
+	case "github.com/celskeggs/mediator/platform/datum.DatumData":
+		return &t.DatumData
+	default:
+		return nil
+	}
+}
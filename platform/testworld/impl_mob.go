@@ -0,0 +1,405 @@
+// Code generated by mediator boilerplate; DO NOT EDIT.
+package testworld
+
+import (
+	"github.com/celskeggs/mediator/platform/atoms"
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/types"
+)
+
+type MobImpl struct {
+	atoms.MobData
+	atoms.AtomMovableData
+	atoms.AtomData
+	datum.DatumData
+}
+
+func NewMob(realm *types.Realm, params ...types.Value) *types.Datum {
+	i := &MobImpl{}
+	d := realm.NewDatum(i)
+	datum.NewDatumData(d, &i.DatumData, params...)
+	atoms.NewAtomData(d, &i.AtomData, params...)
+	atoms.NewAtomMovableData(d, &i.AtomMovableData, params...)
+	atoms.NewMobData(d, &i.MobData, params...)
+	return d
+}
+
+func (t *MobImpl) Type() types.TypePath {
+	return "/mob"
+}
+
+func (t *MobImpl) Var(src *types.Datum, name string) (types.Value, bool) {
+	switch name {
+	case "type":
+		return types.TypePath("/mob"), true
+	case "parent_type":
+		return types.TypePath("/atom/movable"), true
+	case "appearance":
+		return t.AtomData.VarAppearance, true
+	case "density":
+		return types.Int(t.AtomData.VarDensity), true
+	case "see_in_dark":
+		return types.Int(t.MobData.VarSeeInDark), true
+	case "see_invisible":
+		return types.Int(t.MobData.VarSeeInvisible), true
+	case "sight":
+		return types.Int(t.MobData.VarSight), true
+	case "verbs":
+		return datum.NewListFromSlice(t.AtomData.VarVerbs), true
+	case "alpha":
+		return t.AtomData.GetAlpha(src), true
+	case "blend_mode":
+		return t.AtomData.GetBlendMode(src), true
+	case "ckey":
+		return t.MobData.GetCkey(src), true
+	case "client":
+		return t.MobData.GetClient(src), true
+	case "color":
+		return t.AtomData.GetColor(src), true
+	case "contents":
+		return t.AtomData.GetContents(src), true
+	case "desc":
+		return t.AtomData.GetDesc(src), true
+	case "dir":
+		return t.AtomData.GetDir(src), true
+	case "icon":
+		return t.AtomData.GetIcon(src), true
+	case "icon_state":
+		return t.AtomData.GetIconState(src), true
+	case "invisibility":
+		return t.AtomData.GetInvisibility(src), true
+	case "key":
+		return t.MobData.GetKey(src), true
+	case "layer":
+		return t.AtomData.GetLayer(src), true
+	case "light_color":
+		return t.AtomData.GetLightColor(src), true
+	case "light_power":
+		return t.AtomData.GetLightPower(src), true
+	case "light_range":
+		return t.AtomData.GetLightRange(src), true
+	case "loc":
+		return t.AtomData.GetLoc(src), true
+	case "luminosity":
+		return t.AtomData.GetLuminosity(src), true
+	case "mouse_opacity":
+		return t.AtomData.GetMouseOpacity(src), true
+	case "name":
+		return t.AtomData.GetName(src), true
+	case "opacity":
+		return t.AtomData.GetOpacity(src), true
+	case "overlays":
+		return t.AtomData.GetOverlays(src), true
+	case "pixel_w":
+		return t.AtomData.GetPixelW(src), true
+	case "pixel_x":
+		return t.AtomData.GetPixelX(src), true
+	case "pixel_y":
+		return t.AtomData.GetPixelY(src), true
+	case "pixel_z":
+		return t.AtomData.GetPixelZ(src), true
+	case "suffix":
+		return t.AtomData.GetSuffix(src), true
+	case "transform":
+		return t.AtomData.GetTransform(src), true
+	case "underlays":
+		return t.AtomData.GetUnderlays(src), true
+	case "x":
+		return t.AtomData.GetX(src), true
+	case "y":
+		return t.AtomData.GetY(src), true
+	case "z":
+		return t.AtomData.GetZ(src), true
+	default:
+		return nil, false
+	}
+}
+
+func (t *MobImpl) Vars() []types.VarInfo {
+	return []types.VarInfo{
+		{Name: "type", ReadOnly: true},
+		{Name: "parent_type", ReadOnly: true},
+		{Name: "appearance", Tmp: true},
+		{Name: "density"},
+		{Name: "see_in_dark"},
+		{Name: "see_invisible"},
+		{Name: "sight"},
+		{Name: "verbs", Tmp: true},
+		{Name: "alpha"},
+		{Name: "blend_mode"},
+		{Name: "ckey", ReadOnly: true},
+		{Name: "client", ReadOnly: true},
+		{Name: "color"},
+		{Name: "contents", ReadOnly: true},
+		{Name: "desc"},
+		{Name: "dir"},
+		{Name: "icon"},
+		{Name: "icon_state"},
+		{Name: "invisibility"},
+		{Name: "key", ReadOnly: true},
+		{Name: "layer"},
+		{Name: "light_color"},
+		{Name: "light_power"},
+		{Name: "light_range"},
+		{Name: "loc"},
+		{Name: "luminosity"},
+		{Name: "mouse_opacity"},
+		{Name: "name"},
+		{Name: "opacity"},
+		{Name: "overlays"},
+		{Name: "pixel_w"},
+		{Name: "pixel_x"},
+		{Name: "pixel_y"},
+		{Name: "pixel_z"},
+		{Name: "suffix"},
+		{Name: "transform"},
+		{Name: "underlays"},
+		{Name: "x", ReadOnly: true},
+		{Name: "y", ReadOnly: true},
+		{Name: "z", ReadOnly: true},
+	}
+}
+
+func (t *MobImpl) SetVar(src *types.Datum, name string, value types.Value) types.SetResult {
+	switch name {
+	case "type":
+		return types.SetResultReadOnly
+	case "parent_type":
+		return types.SetResultReadOnly
+	case "appearance":
+		t.AtomData.VarAppearance = value.(atoms.Appearance)
+		return types.SetResultOk
+	case "density":
+		t.AtomData.VarDensity = types.Unint(value)
+		return types.SetResultOk
+	case "see_in_dark":
+		t.MobData.VarSeeInDark = types.Unint(value)
+		return types.SetResultOk
+	case "see_invisible":
+		t.MobData.VarSeeInvisible = types.Unint(value)
+		return types.SetResultOk
+	case "sight":
+		t.MobData.VarSight = types.Unint(value)
+		return types.SetResultOk
+	case "verbs":
+		t.AtomData.VarVerbs = datum.ElementsAsType([]atoms.Verb{}, value).([]atoms.Verb)
+		return types.SetResultOk
+	case "alpha":
+		t.AtomData.SetAlpha(src, value)
+		return types.SetResultOk
+	case "blend_mode":
+		t.AtomData.SetBlendMode(src, value)
+		return types.SetResultOk
+	case "ckey":
+		return types.SetResultReadOnly
+	case "client":
+		return types.SetResultReadOnly
+	case "color":
+		t.AtomData.SetColor(src, value)
+		return types.SetResultOk
+	case "contents":
+		return types.SetResultReadOnly
+	case "desc":
+		t.AtomData.SetDesc(src, value)
+		return types.SetResultOk
+	case "dir":
+		t.AtomData.SetDir(src, value)
+		return types.SetResultOk
+	case "icon":
+		t.AtomData.SetIcon(src, value)
+		return types.SetResultOk
+	case "icon_state":
+		t.AtomData.SetIconState(src, value)
+		return types.SetResultOk
+	case "invisibility":
+		t.AtomData.SetInvisibility(src, value)
+		return types.SetResultOk
+	case "key":
+		return types.SetResultReadOnly
+	case "layer":
+		t.AtomData.SetLayer(src, value)
+		return types.SetResultOk
+	case "light_color":
+		t.AtomData.SetLightColor(src, value)
+		return types.SetResultOk
+	case "light_power":
+		t.AtomData.SetLightPower(src, value)
+		return types.SetResultOk
+	case "light_range":
+		t.AtomData.SetLightRange(src, value)
+		return types.SetResultOk
+	case "loc":
+		t.AtomData.SetLoc(src, value)
+		return types.SetResultOk
+	case "luminosity":
+		t.AtomData.SetLuminosity(src, value)
+		return types.SetResultOk
+	case "mouse_opacity":
+		t.AtomData.SetMouseOpacity(src, value)
+		return types.SetResultOk
+	case "name":
+		t.AtomData.SetName(src, value)
+		return types.SetResultOk
+	case "opacity":
+		t.AtomData.SetOpacity(src, value)
+		return types.SetResultOk
+	case "overlays":
+		t.AtomData.SetOverlays(src, value)
+		return types.SetResultOk
+	case "pixel_w":
+		t.AtomData.SetPixelW(src, value)
+		return types.SetResultOk
+	case "pixel_x":
+		t.AtomData.SetPixelX(src, value)
+		return types.SetResultOk
+	case "pixel_y":
+		t.AtomData.SetPixelY(src, value)
+		return types.SetResultOk
+	case "pixel_z":
+		t.AtomData.SetPixelZ(src, value)
+		return types.SetResultOk
+	case "suffix":
+		t.AtomData.SetSuffix(src, value)
+		return types.SetResultOk
+	case "transform":
+		t.AtomData.SetTransform(src, value)
+		return types.SetResultOk
+	case "underlays":
+		t.AtomData.SetUnderlays(src, value)
+		return types.SetResultOk
+	case "x":
+		return types.SetResultReadOnly
+	case "y":
+		return types.SetResultReadOnly
+	case "z":
+		return types.SetResultReadOnly
+	default:
+		return types.SetResultNonexistent
+	}
+}
+
+func (t *MobImpl) Proc(src *types.Datum, usr *types.Datum, name string, params ...types.Value) (types.Value, bool) {
+	switch name {
+	case "<<":
+		return t.MobData.OperatorWrite(src, usr, types.Param(params, 0)), true
+	case "Bump":
+		return t.AtomData.ProcBump(src, usr, types.Param(params, 0)), true
+	case "Click":
+		return t.AtomData.ProcClick(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2)), true
+	case "DblClick":
+		return t.AtomData.ProcDblClick(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2)), true
+	case "Enter":
+		return t.AtomData.ProcEnter(src, usr, types.Param(params, 0), types.Param(params, 1)), true
+	case "Entered":
+		return t.AtomData.ProcEntered(src, usr, types.Param(params, 0), types.Param(params, 1)), true
+	case "Exit":
+		return t.AtomData.ProcExit(src, usr, types.Param(params, 0), types.Param(params, 1)), true
+	case "Exited":
+		return t.AtomData.ProcExited(src, usr, types.Param(params, 0), types.Param(params, 1)), true
+	case "Login":
+		return t.MobData.ProcLogin(src, usr), true
+	case "Logout":
+		return t.MobData.ProcLogout(src, usr), true
+	case "MouseDown":
+		return t.AtomData.ProcMouseDown(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2)), true
+	case "MouseDrop":
+		return t.AtomData.ProcMouseDrop(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2), types.Param(params, 3), types.Param(params, 4), types.Param(params, 5)), true
+	case "MouseEntered":
+		return t.AtomData.ProcMouseEntered(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2)), true
+	case "MouseExited":
+		return t.AtomData.ProcMouseExited(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2)), true
+	case "MouseUp":
+		return t.AtomData.ProcMouseUp(src, usr, types.Param(params, 0), types.Param(params, 1), types.Param(params, 2)), true
+	case "Move":
+		return t.AtomData.ProcMove(src, usr, types.Param(params, 0), types.Param(params, 1)), true
+	case "New":
+		return t.DatumData.ProcNew(src, usr), true
+	case "Read":
+		return t.AtomData.ProcRead(src, usr, types.Param(params, 0)), true
+	case "Stat":
+		return t.AtomData.ProcStat(src, usr), true
+	case "Topic":
+		return t.DatumData.ProcTopic(src, usr, types.Param(params, 0), types.Param(params, 1)), true
+	case "Write":
+		return t.AtomData.ProcWrite(src, usr, types.Param(params, 0)), true
+	default:
+		return nil, false
+	}
+}
+
+func (t *MobImpl) SuperProc(src *types.Datum, usr *types.Datum, chunk string, name string, params ...types.Value) (types.Value, bool) {
+	switch chunk {
+	case "github.com/celskeggs/mediator/platform/atoms.AtomData":
+		switch name {
+		case "Write":
+			return t.DatumData.ProcWrite(src, usr, types.Param(params, 0)), true
+		case "Read":
+			return t.DatumData.ProcRead(src, usr, types.Param(params, 0)), true
+		}
+	}
+	return nil, false
+}
+
+func (t *MobImpl) ProcSettings(name string) (types.ProcSettings, bool) {
+	switch name {
+	case "<<":
+		return types.ProcSettings{}, true
+	case "Bump":
+		return types.ProcSettings{}, true
+	case "Click":
+		return types.ProcSettings{}, true
+	case "DblClick":
+		return types.ProcSettings{}, true
+	case "Enter":
+		return types.ProcSettings{}, true
+	case "Entered":
+		return types.ProcSettings{}, true
+	case "Exit":
+		return types.ProcSettings{}, true
+	case "Exited":
+		return types.ProcSettings{}, true
+	case "Login":
+		return types.ProcSettings{}, true
+	case "Logout":
+		return types.ProcSettings{}, true
+	case "MouseDown":
+		return types.ProcSettings{}, true
+	case "MouseDrop":
+		return types.ProcSettings{}, true
+	case "MouseEntered":
+		return types.ProcSettings{}, true
+	case "MouseExited":
+		return types.ProcSettings{}, true
+	case "MouseUp":
+		return types.ProcSettings{}, true
+	case "Move":
+		return types.ProcSettings{}, true
+	case "New":
+		return types.ProcSettings{}, true
+	case "Read":
+		return types.ProcSettings{}, true
+	case "Stat":
+		return types.ProcSettings{}, true
+	case "Topic":
+		return types.ProcSettings{}, true
+	case "Write":
+		return types.ProcSettings{}, true
+	default:
+		return types.ProcSettings{}, false
+	}
+}
+
+func (t *MobImpl) Chunk(ref string) interface{} {
+	switch ref {
+	case "github.com/celskeggs/mediator/platform/atoms.MobData":
+		return &t.MobData
+	case "github.com/celskeggs/mediator/platform/atoms.AtomMovableData":
+		return &t.AtomMovableData
+	case "github.com/celskeggs/mediator/platform/atoms.AtomData":
+		return &t.AtomData
+	case "github.com/celskeggs/mediator/platform/datum.DatumData":
+		return &t.DatumData
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,141 @@
+// Code generated by mediator boilerplate; DO NOT EDIT.
+package testworld
+
+import (
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/savefile"
+	"github.com/celskeggs/mediator/platform/types"
+)
+
+type SavefileImpl struct {
+	savefile.SavefileData
+	datum.DatumData
+}
+
+func NewSavefile(realm *types.Realm, params ...types.Value) *types.Datum {
+	i := &SavefileImpl{}
+	d := realm.NewDatum(i)
+	datum.NewDatumData(d, &i.DatumData, params...)
+	savefile.NewSavefileData(d, &i.SavefileData, params...)
+	return d
+}
+
+func (t *SavefileImpl) Type() types.TypePath {
+	return "/savefile"
+}
+
+func (t *SavefileImpl) Var(src *types.Datum, name string) (types.Value, bool) {
+	switch name {
+	case "type":
+		return types.TypePath("/savefile"), true
+	case "parent_type":
+		return types.TypePath("/datum"), true
+	case "cd":
+		return t.SavefileData.GetCd(src), true
+	case "dir":
+		return t.SavefileData.GetDir(src), true
+	case "name":
+		return t.SavefileData.GetName(src), true
+	default:
+		return nil, false
+	}
+}
+
+func (t *SavefileImpl) Vars() []types.VarInfo {
+	return []types.VarInfo{
+		{Name: "type", ReadOnly: true},
+		{Name: "parent_type", ReadOnly: true},
+		{Name: "cd"},
+		{Name: "dir", ReadOnly: true},
+		{Name: "name", ReadOnly: true},
+	}
+}
+
+func (t *SavefileImpl) SetVar(src *types.Datum, name string, value types.Value) types.SetResult {
+	switch name {
+	case "type":
+		return types.SetResultReadOnly
+	case "parent_type":
+		return types.SetResultReadOnly
+	case "cd":
+		t.SavefileData.SetCd(src, value)
+		return types.SetResultOk
+	case "dir":
+		return types.SetResultReadOnly
+	case "name":
+		return types.SetResultReadOnly
+	default:
+		return types.SetResultNonexistent
+	}
+}
+
+func (t *SavefileImpl) Proc(src *types.Datum, usr *types.Datum, name string, params ...types.Value) (types.Value, bool) {
+	switch name {
+	case "<<":
+		return t.SavefileData.OperatorWrite(src, usr, types.Param(params, 0)), true
+	case ">>":
+		return t.SavefileData.OperatorRead(src, usr), true
+	case "ExportText":
+		return t.SavefileData.ProcExportText(src, usr, types.Param(params, 0), types.Param(params, 1)), true
+	case "Flush":
+		return t.SavefileData.ProcFlush(src, usr), true
+	case "ImportText":
+		return t.SavefileData.ProcImportText(src, usr, types.Param(params, 0), types.Param(params, 1)), true
+	case "New":
+		return t.DatumData.ProcNew(src, usr), true
+	case "Read":
+		return t.DatumData.ProcRead(src, usr, types.Param(params, 0)), true
+	case "Topic":
+		return t.DatumData.ProcTopic(src, usr, types.Param(params, 0), types.Param(params, 1)), true
+	case "Write":
+		return t.DatumData.ProcWrite(src, usr, types.Param(params, 0)), true
+	case "[]":
+		return t.SavefileData.OperatorIndex(src, usr, types.Param(params, 0)), true
+	default:
+		return nil, false
+	}
+}
+
+func (t *SavefileImpl) SuperProc(src *types.Datum, usr *types.Datum, chunk string, name string, params ...types.Value) (types.Value, bool) {
+	switch chunk {
+	}
+	return nil, false
+}
+
+func (t *SavefileImpl) ProcSettings(name string) (types.ProcSettings, bool) {
+	switch name {
+	case "<<":
+		return types.ProcSettings{}, true
+	case ">>":
+		return types.ProcSettings{}, true
+	case "ExportText":
+		return types.ProcSettings{}, true
+	case "Flush":
+		return types.ProcSettings{}, true
+	case "ImportText":
+		return types.ProcSettings{}, true
+	case "New":
+		return types.ProcSettings{}, true
+	case "Read":
+		return types.ProcSettings{}, true
+	case "Topic":
+		return types.ProcSettings{}, true
+	case "Write":
+		return types.ProcSettings{}, true
+	case "[]":
+		return types.ProcSettings{}, true
+	default:
+		return types.ProcSettings{}, false
+	}
+}
+
+func (t *SavefileImpl) Chunk(ref string) interface{} {
+	switch ref {
+	case "github.com/celskeggs/mediator/platform/savefile.SavefileData":
+		return &t.SavefileData
+	case "github.com/celskeggs/mediator/platform/datum.DatumData":
+		return &t.DatumData
+	default:
+		return nil
+	}
+}
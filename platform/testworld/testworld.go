@@ -0,0 +1,33 @@
+// Package testworld builds worlds for the tests of the platform packages.
+//
+// Unlike platform/impl, the type tree generated here is checked in, so that tests pass on a clean checkout. Run
+// go generate in this directory after changing any of the declared types.
+package testworld
+
+import (
+	"github.com/celskeggs/mediator/platform/icon"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/platform/world"
+	"github.com/celskeggs/mediator/platform/worldmap"
+	"github.com/celskeggs/mediator/resourcepack"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// creates a world on the standard type tree, without any resources, and loads the maps into it
+func NewWorld(t *testing.T, maps ...string) *world.World {
+	return NewWorldWithResources(t, nil, maps...)
+}
+
+// like NewWorld, but with icons that can be loaded from the resources
+func NewWorldWithResources(t *testing.T, resources []resourcepack.Resource, maps ...string) *world.World {
+	pack := &resourcepack.ResourcePack{Resources: map[string]resourcepack.Resource{}}
+	for _, resource := range resources {
+		pack.Resources[resource.Name] = resource
+	}
+	cache, err := icon.NewIconCache(pack)
+	assert.NoError(t, err)
+	w := world.NewWorld(types.NewRealm(Tree), cache)
+	assert.NoError(t, worldmap.LoadMaps(w, maps...))
+	return w
+}
@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"math"
 	"strings"
 )
 
@@ -34,6 +35,9 @@ type Int int
 var _ Value = Int(0)
 
 func Unint(i Value) int {
+	if f, isFloat := i.(Float); isFloat {
+		panic(fmt.Sprintf("non-integer number %v used where a whole number is required", float64(f)))
+	}
 	return int(i.(Int))
 }
 
@@ -61,6 +65,47 @@ func (i Int) String() string {
 	return fmt.Sprintf("[int: %d]", int(i))
 }
 
+// a number with a fractional part. whole numbers are always represented as Int, so that code which only handles whole
+// numbers keeps working; use Number to convert a float64 into whichever one fits.
+type Float float64
+
+var _ Value = Float(0)
+
+func Number(f float64) Value {
+	if f == math.Trunc(f) && f >= math.MinInt32 && f <= math.MaxInt32 {
+		return Int(f)
+	}
+	return Float(f)
+}
+
+// converts an Int or a Float into a float64
+func Unnumber(v Value) float64 {
+	switch n := v.(type) {
+	case Int:
+		return float64(n)
+	case Float:
+		return float64(n)
+	default:
+		panic(fmt.Sprintf("%v is not a number", v))
+	}
+}
+
+func (f Float) Var(name string) Value {
+	panic("no variable " + name + " on float")
+}
+
+func (f Float) SetVar(name string, value Value) {
+	panic("no variable " + name + " on float")
+}
+
+func (f Float) Invoke(usr *Datum, name string, parameters ...Value) Value {
+	panic("no proc " + name + " on float")
+}
+
+func (f Float) String() string {
+	return fmt.Sprintf("[float: %v]", float64(f))
+}
+
 type TypePath string
 
 var _ Value = TypePath("")
@@ -100,6 +145,8 @@ func AsBool(v Value) bool {
 		return false
 	} else if i, ok := v.(Int); ok {
 		return int(i) != 0
+	} else if f, ok := v.(Float); ok {
+		return float64(f) != 0
 	} else if s, ok := v.(String); ok {
 		return string(s) != ""
 	} else {
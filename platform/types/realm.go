@@ -1,16 +1,24 @@
 package types
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 )
 
 var TRACE = false
 
 type Realm struct {
-	busylock         sync.Mutex
-	busy             bool
-	datums           map[*Datum]struct{}
-	deferredRemovals []*Datum
+	busylock sync.Mutex
+	busy     bool
+	// the type of each datum in the realm, as of when it was added, since deleted datums no longer know their types
+	datums map[*Datum]TypePath
+	// the datums of each type, including the datums of every subtype
+	byType map[TypePath]map[*Datum]struct{}
+	// the number of datums of each type, not counting subtypes
+	typeCounts       map[TypePath]int
+	deferredRemovals map[*Datum]struct{}
 
 	datumsByUID map[uint64]*Datum // locked under busy
 	nextUID     uint64            // locked under uidlock
@@ -26,8 +34,10 @@ func NewRealm(tree TypeTree) *Realm {
 		datumsByUID: map[uint64]*Datum{},
 		nextUID:     1000,
 
-		datums:   map[*Datum]struct{}{},
-		typeTree: tree,
+		datums:     map[*Datum]TypePath{},
+		byType:     map[TypePath]map[*Datum]struct{}{},
+		typeCounts: map[TypePath]int{},
+		typeTree:   tree,
 	}
 	tree.PopulateRealm(realm)
 	return realm
@@ -45,15 +55,8 @@ func (r *Realm) setBusy(busy bool) {
 	}
 	r.busy = busy
 	if !busy && r.deferredRemovals != nil {
-		for _, dr := range r.deferredRemovals {
-			if _, found := r.datums[dr]; !found {
-				panic("deferred datum removal: datum not found in realm")
-			}
-			delete(r.datums, dr)
-			if _, found := r.datumsByUID[dr.uid]; !found {
-				panic("deferred datum removal: UID not found in realm")
-			}
-			delete(r.datumsByUID, dr.uid)
+		for dr := range r.deferredRemovals {
+			r.unindex(dr, "deferred datum removal: ")
 		}
 		r.deferredRemovals = nil
 	}
@@ -62,15 +65,13 @@ func (r *Realm) setBusy(busy bool) {
 func (r *Realm) add(d *Datum) {
 	r.busylock.Lock()
 	defer r.busylock.Unlock()
-	if _, found := r.datums[d]; found {
-		panic("datum already found in realm")
-	}
-	if _, found := r.datumsByUID[d.uid]; found {
-		panic("UID already found in realm")
-	}
 	// no busy check here; it's only really for garbage collection, which means remove
-	r.datums[d] = struct{}{}
-	r.datumsByUID[d.uid] = d
+	if _, pending := r.deferredRemovals[d]; pending {
+		// the datum was removed and then added back during the same iteration, so it never needs to leave the realm
+		delete(r.deferredRemovals, d)
+	} else {
+		r.index(d)
+	}
 	if TRACE {
 		println("added datum", d, "of type", d.Type(), "to realm")
 	}
@@ -80,19 +81,60 @@ func (r *Realm) remove(d *Datum) {
 	r.busylock.Lock()
 	defer r.busylock.Unlock()
 	if r.busy {
-		r.deferredRemovals = append(r.deferredRemovals)
-	} else {
 		if _, found := r.datums[d]; !found {
 			panic("datum not found in realm")
 		}
-		delete(r.datums, d)
-		if _, found := r.datumsByUID[d.uid]; !found {
-			panic("UID not found in realm")
+		if r.deferredRemovals == nil {
+			r.deferredRemovals = map[*Datum]struct{}{}
 		}
-		delete(r.datumsByUID, d.uid)
+		r.deferredRemovals[d] = struct{}{}
+	} else {
+		r.unindex(d, "")
 	}
 	if TRACE {
-		println("removed datum", d, "of type", d.Type(), "from realm")
+		println("removed datum", d, "from realm")
+	}
+}
+
+func (r *Realm) index(d *Datum) {
+	if _, found := r.datums[d]; found {
+		panic("datum already found in realm")
+	}
+	if _, found := r.datumsByUID[d.uid]; found {
+		panic("UID already found in realm")
+	}
+	path := d.Type()
+	r.datums[d] = path
+	r.datumsByUID[d.uid] = d
+	r.typeCounts[path]++
+	for ; path != ""; path = r.typeTree.Parent(path) {
+		members := r.byType[path]
+		if members == nil {
+			members = map[*Datum]struct{}{}
+			r.byType[path] = members
+		}
+		members[d] = struct{}{}
+	}
+}
+
+func (r *Realm) unindex(d *Datum, context string) {
+	path, found := r.datums[d]
+	if !found {
+		panic(context + "datum not found in realm")
+	}
+	delete(r.datums, d)
+	if _, found := r.datumsByUID[d.uid]; !found {
+		panic(context + "UID not found in realm")
+	}
+	delete(r.datumsByUID, d.uid)
+	if r.typeCounts[path]--; r.typeCounts[path] == 0 {
+		delete(r.typeCounts, path)
+	}
+	for ; path != ""; path = r.typeTree.Parent(path) {
+		delete(r.byType[path], d)
+		if len(r.byType[path]) == 0 {
+			delete(r.byType, path)
+		}
 	}
 }
 
@@ -119,13 +161,68 @@ func (r *Realm) FindOne(predicate func(*Datum) bool) Value {
 	return nil
 }
 
+// finds every datum of the specified type or any of its subtypes that matches the predicate, which may be nil. this
+// only visits datums of the right type, so it takes time proportional to the number of them rather than the size of
+// the realm.
+func (r *Realm) FindAllType(path TypePath, predicate func(*Datum) bool) (out []Value) {
+	r.setBusy(true)
+	defer r.setBusy(false)
+	for datum := range r.byType[path] {
+		// datums deleted during the search stay indexed until it finishes
+		if datum.impl != nil && (predicate == nil || predicate(datum)) {
+			out = append(out, datum)
+		}
+	}
+	return out
+}
+
+// returns nil if not found
+func (r *Realm) FindOneType(path TypePath, predicate func(*Datum) bool) Value {
+	r.setBusy(true)
+	defer r.setBusy(false)
+	for datum := range r.byType[path] {
+		if datum.impl != nil && (predicate == nil || predicate(datum)) {
+			return datum
+		}
+	}
+	return nil
+}
+
+// lists the types that at least one datum in the realm has, without their supertypes, in no particular order
+func (r *Realm) Types() (types []TypePath) {
+	r.busylock.Lock()
+	defer r.busylock.Unlock()
+	for path := range r.typeCounts {
+		types = append(types, path)
+	}
+	return types
+}
+
+// returns nil if the datum has been deleted
 func (r *Realm) Lookup(uid uint64) Value {
-	if d, ok := r.datumsByUID[uid]; ok {
+	if d, ok := r.datumsByUID[uid]; ok && d.impl != nil {
 		return d
 	}
 	return nil
 }
 
+// the text that \ref produces, which LookupRef can turn back into the datum
+func RefString(d *Datum) string {
+	return fmt.Sprintf("[0x%x]", d.uid)
+}
+
+// returns nil if the ref is malformed, or if its datum has been deleted
+func (r *Realm) LookupRef(ref string) Value {
+	if !strings.HasPrefix(ref, "[0x") || !strings.HasSuffix(ref, "]") {
+		return nil
+	}
+	uid, err := strconv.ParseUint(ref[3:len(ref)-1], 16, 64)
+	if err != nil {
+		return nil
+	}
+	return r.Lookup(uid)
+}
+
 func (realm *Realm) SetWorldRef(worldRef interface{}) {
 	if worldRef == nil {
 		panic("worldref cannot be nil")
@@ -0,0 +1,121 @@
+package types
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type testTree map[TypePath]TypePath
+
+func (t testTree) Parent(path TypePath) TypePath {
+	return t[path]
+}
+
+func (t testTree) New(realm *Realm, path TypePath, params ...Value) *Datum {
+	return realm.NewDatum(testImpl(path))
+}
+
+func (t testTree) PopulateRealm(realm *Realm) {
+}
+
+// a datum implementation that only knows its type
+type testImpl TypePath
+
+func (t testImpl) Type() TypePath {
+	return TypePath(t)
+}
+
+func (t testImpl) Var(src *Datum, name string) (Value, bool) {
+	return nil, false
+}
+
+func (t testImpl) Vars() []VarInfo {
+	return nil
+}
+
+func (t testImpl) SetVar(src *Datum, name string, value Value) SetResult {
+	return SetResultNonexistent
+}
+
+func (t testImpl) Proc(src *Datum, usr *Datum, name string, params ...Value) (Value, bool) {
+	return nil, false
+}
+
+func (t testImpl) SuperProc(src *Datum, usr *Datum, chunk string, name string, params ...Value) (Value, bool) {
+	return nil, false
+}
+
+func (t testImpl) ProcSettings(name string) (ProcSettings, bool) {
+	return ProcSettings{}, false
+}
+
+func (t testImpl) Chunk(ref string) interface{} {
+	return nil
+}
+
+func newTestRealm() *Realm {
+	return NewRealm(testTree{
+		"/datum":        "",
+		"/atom":         "/datum",
+		"/atom/movable": "/atom",
+		"/obj":          "/atom/movable",
+		"/mob":          "/atom/movable",
+		"/turf":         "/atom",
+	})
+}
+
+// creates a datum and adds it to the realm, as the first reference to it would
+func addTestDatum(r *Realm, path TypePath) *Datum {
+	d := r.NewPlain(path)
+	r.add(d)
+	return d
+}
+
+func TestRealmTypeIndex(t *testing.T) {
+	r := newTestRealm()
+	obj := addTestDatum(r, "/obj")
+	mob := addTestDatum(r, "/mob")
+	turf := addTestDatum(r, "/turf")
+	assert.ElementsMatch(t, []TypePath{"/obj", "/mob", "/turf"}, r.Types())
+	// searches cover every subtype
+	assert.ElementsMatch(t, []Value{obj, mob, turf}, r.FindAllType("/datum", nil))
+	assert.ElementsMatch(t, []Value{obj, mob}, r.FindAllType("/atom/movable", nil))
+	assert.Equal(t, []Value{turf}, r.FindAllType("/turf", nil))
+	assert.Empty(t, r.FindAllType("/area", nil))
+	assert.Equal(t, mob, r.FindOneType("/atom/movable", func(d *Datum) bool {
+		return d.Type() == "/mob"
+	}))
+	r.remove(obj)
+	assert.Equal(t, []Value{mob}, r.FindAllType("/atom/movable", nil))
+	assert.Nil(t, r.Lookup(obj.UID()))
+	r.remove(mob)
+	assert.Empty(t, r.FindAllType("/atom/movable", nil))
+	assert.Empty(t, r.byType["/atom/movable"])
+	assert.Equal(t, []Value{turf}, r.FindAllType("/datum", nil))
+	assert.Equal(t, []TypePath{"/turf"}, r.Types())
+}
+
+func TestRealmRemoveWhileBusy(t *testing.T) {
+	r := newTestRealm()
+	obj := addTestDatum(r, "/obj")
+	mob := addTestDatum(r, "/mob")
+	r.FindAllType("/datum", func(d *Datum) bool {
+		if d == obj {
+			// datums that are dropped during a search leave the realm once it finishes
+			r.remove(obj)
+		} else {
+			// a datum that is dropped and then referenced again during a search never leaves
+			r.remove(mob)
+			r.add(mob)
+		}
+		assert.Len(t, r.datums, 2)
+		return false
+	})
+	assert.Nil(t, r.Lookup(obj.UID()))
+	assert.Equal(t, mob, r.Lookup(mob.UID()))
+	assert.Equal(t, []Value{mob}, r.FindAllType("/atom", nil))
+	assert.Equal(t, []Value{mob}, r.FindAll(func(*Datum) bool { return true }))
+	// a datum can come back after it has left
+	r.add(obj)
+	assert.ElementsMatch(t, []Value{obj, mob}, r.FindAllType("/atom/movable", nil))
+}
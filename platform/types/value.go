@@ -49,11 +49,19 @@ func (r *Ref) Dereference() Value {
 	return r.v
 }
 
+// implemented by a realm's world ref if it needs to see datums just before they are deleted
+type DeleteObserver interface {
+	BeforeDelete(datum *Datum)
+}
+
 func Del(v Value) {
 	datum, ok := v.(*Datum)
 	if !ok {
 		panic("cannot delete non-datum value " + v.String())
 	}
+	if observer, ok := datum.realm.worldRef.(DeleteObserver); ok && datum.impl != nil {
+		observer.BeforeDelete(datum)
+	}
 	datum.delete()
 }
 
@@ -95,9 +103,19 @@ const (
 	SetResultReadOnly
 )
 
+// describes one of the variables that a datum defines
+type VarInfo struct {
+	Name     string
+	ReadOnly bool
+	// tmp vars are skipped when the datum is written to a savefile
+	Tmp bool
+}
+
 type DatumImpl interface {
 	Type() TypePath
 	Var(src *Datum, name string) (Value, bool)
+	// lists every variable that Var understands, in a stable order
+	Vars() []VarInfo
 	SetVar(src *Datum, name string, value Value) SetResult
 	Proc(src *Datum, usr *Datum, name string, params ...Value) (Value, bool)
 	SuperProc(src *Datum, usr *Datum, chunk string, name string, params ...Value) (Value, bool)
@@ -23,12 +23,24 @@ type ClientData struct {
 	textBuffer  []string
 	soundBuffer []sprite.Sound
 	flicks      []sprite.Flick
+	browse      []sprite.Browse
+	prompts     []sprite.Prompt
+	// the prompts from input() and alert() that the player has not yet answered, by ID
+	pending     map[uint64]*pendingPrompt
 	statDisplay sprite.StatDisplay
+	// the UIDs of the atoms drawn in the last view rendered for the player, which are all that they can click on
+	rendered map[uint64]bool
+	// the atom that the mouse last entered, which is the only one that it can exit
+	hovered *types.Ref
 }
 
 func NewClientData(_ *types.Datum, _ *ClientData, _ ...types.Value) {
 }
 
+func (d *ClientData) GetCkey(src *types.Datum) types.Value {
+	return types.String(common.Ckey(d.VarKey))
+}
+
 func (d *ClientData) GetMob(src *types.Datum) types.Value {
 	return d.mob.Dereference()
 }
@@ -145,6 +157,8 @@ func (d *ClientData) OperatorWrite(src *types.Datum, usr *types.Datum, output ty
 	} else if sound, ok := output.(sprite.Sound); ok {
 		sound = sound.FixMID()
 		d.soundBuffer = append(d.soundBuffer, sound)
+	} else if browse, ok := output.(sprite.Browse); ok {
+		d.browse = append(d.browse, browse)
 	} else {
 		panic("not sure how to send output " + output.String() + " to client")
 	}
@@ -176,22 +190,35 @@ func FlickClient(client *types.Datum, flick sprite.Flick) {
 	d.flicks = append(d.flicks, flick)
 }
 
-func PullClientRequests(client *types.Datum) (textDisplay []string, sounds []sprite.Sound, flicks []sprite.Flick) {
+// everything that has been sent to the client since the last call, as a ViewUpdate without a delta
+func PullClientRequests(client *types.Datum) sprite.ViewUpdate {
 	_, d := ClientDataChunk(client)
-	textDisplay, sounds, flicks = d.textBuffer, d.soundBuffer, d.flicks
+	update := sprite.ViewUpdate{
+		TextLines: d.textBuffer,
+		Sounds:    d.soundBuffer,
+		Flicks:    d.flicks,
+		Browse:    d.browse,
+		Prompts:   d.prompts,
+	}
 	d.textBuffer = nil
 	d.soundBuffer = nil
 	d.flicks = nil
-	return textDisplay, sounds, flicks
+	d.browse = nil
+	d.prompts = nil
+	return update
 }
 
 func (w *World) RenderClientView(client types.Value) (center types.Value, viewAtoms []types.Value, stat sprite.StatDisplay, verbs []string, verbsOn map[*types.Datum][]string) {
 	cdatum, cc := ClientDataChunk(client)
 	util.FIXME("actually do this correctly")
-	eye := client.Var("eye").(*types.Datum)
-	veye := client.Var("virtual_eye").(*types.Datum)
+	eye, _ := client.Var("eye").(*types.Datum)
+	veye, _ := client.Var("virtual_eye").(*types.Datum)
 	view := types.Unuint(client.Var("view"))
 	verbs, verbsOn = cc.ListVerbs(cdatum)
+	if eye == nil || veye == nil {
+		// without an eye, the player sees nothing
+		return nil, nil, cc.statDisplay, verbs, verbsOn
+	}
 	return veye, w.ViewX(view, veye, eye, atoms.ViewVisual), cc.statDisplay, verbs, verbsOn
 }
 
@@ -212,8 +239,8 @@ func (w *World) findExistingMob(key string) types.Value {
 	if key == "" {
 		return nil
 	}
-	return w.FindOne(func(atom *types.Datum) bool {
-		return types.IsType(atom, "/mob") && types.Unstring(atom.Var("key")) == key
+	return w.Realm().FindOneType("/mob", func(mob *types.Datum) bool {
+		return types.Unstring(mob.Var("key")) == key
 	})
 }
 
@@ -223,15 +250,41 @@ func (d *ClientData) ProcNew(src *types.Datum, _ *types.Datum, usr types.Value)
 	if mob == nil {
 		mob = atoms.WorldOf(src).(*World).constructNewMob(d.VarKey)
 	}
-	util.NiceToHave("add support for Topics")
 	d.SetMob(src, mob)
 	mob.Invoke(mob.(*types.Datum), "Login")
 	return mob
 }
 
+// hsrc is the datum that the link's src parameter refers to, if any, which gets to handle the link by default
+func (d *ClientData) ProcTopic(src *types.Datum, usr *types.Datum, href types.Value, hrefList types.Value, hsrc types.Value) types.Value {
+	if hsrc != nil {
+		hsrc.Invoke(usr, "Topic", href, hrefList)
+	}
+	return nil
+}
+
+// handles a link that the player followed, where href is the query string of a link like byond://?src=[0x1];a=b
+func InvokeTopic(client types.Value, href string) {
+	clientDatum, clientData := ClientDataChunk(client)
+	var usr *types.Datum
+	if mob := clientData.GetMob(clientDatum); mob != nil {
+		usr = mob.(*types.Datum)
+	}
+	hrefList := datum.ParamsToList(href)
+	var hsrc types.Value
+	if ref, ok := hrefList.Invoke(usr, "[]", types.String("src")).(types.String); ok {
+		hsrc = clientDatum.Realm().LookupRef(string(ref))
+	}
+	client.Invoke(usr, "Topic", types.String(href), hrefList, hsrc)
+}
+
 func (d *ClientData) ProcDel(src *types.Datum, usr *types.Datum) types.Value {
-	util.FIXME("call Logout() on mob")
 	util.FIXME("should killing the connection go here, maybe in addition to other places?")
+	mob := d.GetMob(src)
+	if mob != nil {
+		mob.Invoke(mob.(*types.Datum), "Logout")
+	}
+	atoms.WorldOf(src).(*World).cancelPrompts(d)
 	return nil
 }
 
@@ -244,7 +297,7 @@ func (d *ClientData) ResolveVerb(src *types.Datum, verbName string, args []strin
 		return
 	}
 	verbUsr := mob.(*types.Datum)
-	for _, verbSrcVal := range atoms.WorldOf(src).FindAllType("/atom") {
+	for _, verbSrcVal := range d.verbCandidates(src, verbUsr) {
 		verbSrc := verbSrcVal.(*types.Datum)
 		for _, verbVal := range datum.Elements(verbSrc.Var("verbs")) {
 			verb := verbVal.(atoms.Verb)
@@ -264,6 +317,59 @@ func (d *ClientData) ResolveVerb(src *types.Datum, verbName string, args []strin
 	src.Invoke(verbUsr, "<<", types.String(fmt.Sprintf("Not a known verb: %q", verbName)))
 }
 
+// the farthest distance N given by a 'src in view(N)' or 'src in oview(N)' setting on any verb of an atom. this
+// only depends on the atom's type, as long as verbs are not added to individual atoms.
+func verbReach(atom *types.Datum) (reach uint) {
+	for _, verbVal := range datum.Elements(atom.Var("verbs")) {
+		settings, ok := types.UnpackDatum(atom).ProcSettings(verbVal.(atoms.Verb).VisibleName)
+		if !ok || settings.Src.Dist == types.SrcDistUnspecified {
+			continue
+		}
+		if settings.Src.Type == types.SrcSettingTypeView || settings.Src.Type == types.SrcSettingTypeOView {
+			reach = MaxUint(reach, uint(settings.Src.Dist))
+		}
+	}
+	return reach
+}
+
+// the farthest that any atom in the world might be from usr while still having a verb available to it
+func (w *World) maxVerbReach() (reach uint) {
+	for _, path := range w.realm.Types() {
+		typeReach, found := w.verbReaches[path]
+		if !found {
+			if !w.realm.IsSubType(path, "/atom") {
+				continue
+			}
+			example := w.realm.FindOneType(path, func(d *types.Datum) bool {
+				return d.Type() == path
+			})
+			if example == nil {
+				continue
+			}
+			typeReach = verbReach(example.(*types.Datum))
+			w.verbReaches[path] = typeReach
+		}
+		reach = MaxUint(reach, typeReach)
+	}
+	return reach
+}
+
+// the atoms that might have verbs available to usr: usr itself, whatever it is carrying, and anything nearby. nearby
+// means within the view distance, or farther if some verb is declared with a larger view(N) or oview(N).
+func (d *ClientData) verbCandidates(src *types.Datum, usr *types.Datum) (candidates []types.Value) {
+	w := atoms.WorldOf(src).(*World)
+	distance := MaxUint(MaxUint(w.ViewDist, types.Unuint(src.Var("view"))), w.maxVerbReach())
+	seen := map[types.Value]bool{}
+	nearby := append([]types.Value{usr}, datum.Elements(usr.Var("contents"))...)
+	for _, atom := range append(nearby, w.Range(distance, usr)...) {
+		if !seen[atom] {
+			seen[atom] = true
+			candidates = append(candidates, atom)
+		}
+	}
+	return candidates
+}
+
 func (d *ClientData) listVerbsOnAtomInternal(src *types.Datum, usr *types.Datum, atom *types.Datum) (verbs []string) {
 	for _, verbVal := range datum.Elements(atom.Var("verbs")) {
 		verb := verbVal.(atoms.Verb)
@@ -295,7 +401,7 @@ func (d *ClientData) ListVerbs(src *types.Datum) (verbs []string, available map[
 	verbUsr := mob.(*types.Datum)
 	allVerbs := map[string]struct{}{}
 	available = map[*types.Datum][]string{}
-	for _, verbSrc := range atoms.WorldOf(src).FindAllType("/atom") {
+	for _, verbSrc := range d.verbCandidates(src, verbUsr) {
 		verbsOnAtom := d.listVerbsOnAtomInternal(src, verbUsr, verbSrc.(*types.Datum))
 		for _, verb := range verbsOnAtom {
 			allVerbs[verb] = struct{}{}
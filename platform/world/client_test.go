@@ -0,0 +1,128 @@
+package world
+
+import (
+	"github.com/celskeggs/mediator/platform/atoms"
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"testing"
+)
+
+type verbTestTree map[types.TypePath]types.TypePath
+
+func (t verbTestTree) Parent(path types.TypePath) types.TypePath {
+	return t[path]
+}
+
+func (t verbTestTree) New(realm *types.Realm, path types.TypePath, params ...types.Value) *types.Datum {
+	return realm.NewDatum(verbTestImpl{path: path, settings: verbTestSettings[path], vars: map[string]types.Value{}})
+}
+
+func (t verbTestTree) PopulateRealm(realm *types.Realm) {
+}
+
+// the setting of the single verb, "use", of each type
+var verbTestSettings = map[types.TypePath]types.SrcSetting{
+	"/obj/sign":      {Type: types.SrcSettingTypeView, Dist: 12, In: true},
+	"/obj/lever":     {Type: types.SrcSettingTypeOView, Dist: 7, In: true},
+	"/obj/button":    {Type: types.SrcSettingTypeView, Dist: types.SrcDistUnspecified, In: true},
+	"/obj/backpack":  {Type: types.SrcSettingTypeUsr, In: true},
+	"/datum/faraway": {Type: types.SrcSettingTypeView, Dist: 50, In: true},
+}
+
+// a datum with a single verb, no procs, and whatever vars are set on it
+type verbTestImpl struct {
+	path     types.TypePath
+	settings types.SrcSetting
+	vars     map[string]types.Value
+}
+
+func (t verbTestImpl) Type() types.TypePath {
+	return t.path
+}
+
+func (t verbTestImpl) Var(src *types.Datum, name string) (types.Value, bool) {
+	if name == "verbs" {
+		return datum.NewList(atoms.NewVerb("use", string(t.path), "use")), true
+	}
+	value, found := t.vars[name]
+	return value, found
+}
+
+func (t verbTestImpl) Vars() []types.VarInfo {
+	return nil
+}
+
+func (t verbTestImpl) SetVar(src *types.Datum, name string, value types.Value) types.SetResult {
+	t.vars[name] = value
+	return types.SetResultOk
+}
+
+func (t verbTestImpl) Proc(src *types.Datum, usr *types.Datum, name string, params ...types.Value) (types.Value, bool) {
+	return nil, false
+}
+
+func (t verbTestImpl) SuperProc(src *types.Datum, usr *types.Datum, chunk string, name string, params ...types.Value) (types.Value, bool) {
+	return nil, false
+}
+
+func (t verbTestImpl) ProcSettings(name string) (types.ProcSettings, bool) {
+	return types.ProcSettings{Src: t.settings}, name == "use"
+}
+
+func (t verbTestImpl) Chunk(ref string) interface{} {
+	return nil
+}
+
+func newVerbTestWorld() *World {
+	return NewWorld(types.NewRealm(verbTestTree{
+		"/datum":         "",
+		"/datum/faraway": "/datum",
+		"/atom":          "/datum",
+		"/atom/movable":  "/atom",
+		"/obj":           "/atom/movable",
+		"/obj/sign":      "/obj",
+		"/obj/lever":     "/obj",
+		"/obj/button":    "/obj",
+		"/obj/backpack":  "/obj",
+		"/mob":           "/atom/movable",
+	}), nil)
+}
+
+func TestVerbOnInvisibleAtom(t *testing.T) {
+	w := newVerbTestWorld()
+	usr := w.realm.NewPlain("/mob")
+	usr.SetVar("see_invisible", types.Int(0))
+	usr.SetVar("invisibility", types.Int(0))
+	backpack := w.realm.NewPlain("/obj/backpack")
+	backpack.SetVar("loc", usr)
+	backpack.SetVar("invisibility", types.Int(0))
+	verb := atoms.NewVerb("use", "/obj/backpack", "use")
+	assert.True(t, verb.Matches("use", backpack, usr, nil))
+	backpack.SetVar("invisibility", types.Int(1))
+	assert.False(t, verb.Matches("use", backpack, usr, nil))
+	usr.SetVar("see_invisible", types.Int(1))
+	assert.True(t, verb.Matches("use", backpack, usr, nil))
+}
+
+func TestMaxVerbReach(t *testing.T) {
+	w := newVerbTestWorld()
+	realm := w.realm
+	var refs []*types.Ref
+	add := func(path types.TypePath) {
+		refs = append(refs, types.Reference(realm.NewPlain(path)))
+	}
+	assert.Equal(t, uint(0), w.maxVerbReach())
+	// verbs that are not limited to a view distance, and datums that are not atoms, do not count
+	add("/obj/button")
+	add("/obj/backpack")
+	add("/datum/faraway")
+	assert.Equal(t, uint(0), w.maxVerbReach())
+	add("/obj/lever")
+	assert.Equal(t, uint(7), w.maxVerbReach())
+	add("/obj/sign")
+	assert.Equal(t, uint(12), w.maxVerbReach())
+	// the datums only stay in the realm while they are referenced
+	runtime.KeepAlive(refs)
+}
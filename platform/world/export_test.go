@@ -0,0 +1,22 @@
+package world
+
+import (
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/webclient"
+)
+
+func (w *World) MouseTarget(client types.Value, uid uint64) types.Value {
+	return w.mouseTarget(client, uid)
+}
+
+func (w *World) EventTarget(client types.Value, event webclient.MouseEvent) types.Value {
+	return w.eventTarget(client, event)
+}
+
+func (w *World) LightingDirty(atom *types.Datum) bool {
+	return w.lighting.dirtyAtoms[atom.UID()]
+}
+
+func (w *World) CarriedLightSources(atom *types.Datum) int {
+	return w.lighting.carried[atom.UID()]
+}
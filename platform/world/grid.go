@@ -0,0 +1,95 @@
+package world
+
+import (
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/types"
+)
+
+// an index from each position on the map to the turf there, so that turfs can be found without searching the whole
+// realm. the turfs themselves keep track of what is on them, so anything on the map can be found through this index.
+type turfGrid struct {
+	turfs map[tilePos]*types.Ref
+}
+
+func newTurfGrid() *turfGrid {
+	return &turfGrid{
+		turfs: map[tilePos]*types.Ref{},
+	}
+}
+
+// called whenever a turf's x, y or z changes; turfs are only indexed once all three coordinates are set
+func (w *World) TurfMoved(turf *types.Datum, oldX, oldY, oldZ uint) {
+	old := tilePos{X: oldX, Y: oldY, Z: oldZ}
+	wasPlaced := false
+	if ref, found := w.grid.turfs[old]; found && ref.Dereference() == turf {
+		delete(w.grid.turfs, old)
+		wasPlaced = true
+	}
+	x, y, z := XYZ(turf)
+	isPlaced := x != 0 && y != 0 && z != 0
+	if isPlaced {
+		w.grid.turfs[tilePos{X: x, Y: y, Z: z}] = types.Reference(turf)
+	}
+	// anything casting light from this turf is now casting it from somewhere else. a turf being placed on the map has
+	// its coordinates set one at a time, so this only happens once the last of them is set.
+	if wasPlaced || isPlaced {
+		w.UpdateLighting(turf)
+	}
+}
+
+func (w *World) LocateXYZ(x, y, z uint) types.Value {
+	if ref, found := w.grid.turfs[tilePos{X: x, Y: y, Z: z}]; found {
+		return ref.Dereference()
+	}
+	return nil
+}
+
+// lists the turfs in a box, which may extend beyond the edges of the map, in order of increasing x, then y, then z
+func (w *World) Block(x1, y1, z1, x2, y2, z2 uint) (turfs []types.Value) {
+	// only the part of the box within the map is searched, so that huge boxes do not take forever
+	maxX, maxY, maxZ := w.MaxXYZ()
+	x1, y1, z1 = MaxUint(x1, 1), MaxUint(y1, 1), MaxUint(z1, 1)
+	x2, y2, z2 = MinUint(x2, maxX), MinUint(y2, maxY), MinUint(z2, maxZ)
+	if x1 > x2 || y1 > y2 || z1 > z2 {
+		return nil
+	}
+	for z := z1; z <= z2; z++ {
+		for y := y1; y <= y2; y++ {
+			for x := x1; x <= x2; x++ {
+				if turf := w.LocateXYZ(x, y, z); turf != nil {
+					turfs = append(turfs, turf)
+				}
+			}
+		}
+	}
+	return turfs
+}
+
+// lists the turfs within a distance of a position
+func (w *World) blockAround(x, y, z uint, distance uint) []types.Value {
+	lx, ly := uint(1), uint(1)
+	if x > distance {
+		lx = x - distance
+	}
+	if y > distance {
+		ly = y - distance
+	}
+	return w.Block(lx, ly, z, x+distance, y+distance, z)
+}
+
+func (w *World) turfsInRange(distance uint, center *types.Datum, z uint) []types.Value {
+	cx, cy := XY(center)
+	return w.blockAround(cx, cy, z, distance)
+}
+
+// everything within a distance of the center, regardless of whether it can be seen
+func (w *World) Range(distance uint, center *types.Datum) []types.Value {
+	location := turfOf(center)
+	if location == nil {
+		if loc := center.Var("loc"); loc != nil {
+			return append([]types.Value{loc}, datum.Elements(loc.Var("contents"))...)
+		}
+		return []types.Value{center}
+	}
+	return expandWithContents(w.turfsInRange(distance, center, types.Unuint(location.Var("z"))))
+}
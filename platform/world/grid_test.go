@@ -0,0 +1,100 @@
+package world_test
+
+import (
+	"fmt"
+	"github.com/celskeggs/mediator/platform/testworld"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/platform/world"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+// a 3x3 z-level with a crate in the middle, followed by a 2x2 z-level
+var gridMaps = []string{`"a" = (/turf,/area)
+"b" = (/obj{name = "crate"},/turf,/area)
+
+(1,1,1) = {"
+aaa
+aba
+aaa
+"}
+`, `"a" = (/turf,/area)
+
+(1,1,1) = {"
+aa
+aa
+"}
+`}
+
+// describes turfs by their positions and everything else by name
+func describe(atoms []types.Value) (out []string) {
+	for _, atom := range atoms {
+		if types.IsType(atom, "/turf") {
+			x, y, z := world.XYZ(atom)
+			out = append(out, fmt.Sprintf("%d,%d,%d", x, y, z))
+		} else {
+			out = append(out, types.Unstring(atom.Var("name")))
+		}
+	}
+	return out
+}
+
+func TestLocateXYZ(t *testing.T) {
+	w := testworld.NewWorld(t, gridMaps...)
+	assert.Equal(t, []string{"2,3,1"}, describe([]types.Value{w.LocateXYZ(2, 3, 1)}))
+	assert.Equal(t, []string{"2,2,2"}, describe([]types.Value{w.LocateXYZ(2, 2, 2)}))
+	assert.Nil(t, w.LocateXYZ(0, 1, 1))
+	assert.Nil(t, w.LocateXYZ(1, 1, 0))
+	assert.Nil(t, w.LocateXYZ(4, 1, 1))
+	assert.Nil(t, w.LocateXYZ(3, 3, 2))
+	assert.Nil(t, w.LocateXYZ(1, 1, 3))
+}
+
+func TestBlock(t *testing.T) {
+	w := testworld.NewWorld(t, gridMaps...)
+	assert.Equal(t, []string{"2,2,1", "3,2,1", "2,3,1", "3,3,1"}, describe(w.Block(2, 2, 1, 3, 3, 1)))
+	// parts of the box beyond the edges of the map are skipped
+	assert.Equal(t, []string{"1,1,1", "2,1,1", "1,1,2", "2,1,2"}, describe(w.Block(0, 0, 0, 2, 1, 5)))
+	assert.Empty(t, w.Block(4, 4, 1, 9, 9, 1))
+	// boxes are cut down to the size of the map before they are searched, so even the largest box finishes
+	assert.Len(t, w.Block(0, 0, 0, math.MaxUint, math.MaxUint, math.MaxUint), 13)
+	assert.Empty(t, w.Block(3, 1, 1, 2, 3, 1))
+	assert.Empty(t, w.Block(1, 1, 2, 3, 3, 1))
+}
+
+func TestRange(t *testing.T) {
+	w := testworld.NewWorld(t, gridMaps...)
+	corner := w.LocateXYZ(1, 1, 1).(*types.Datum)
+	// the range is cut off at the edges of the map, rather than wrapping around below x=1 or y=1
+	assert.Equal(t, []string{"1,1,1", "2,1,1", "1,2,1", "2,2,1", "crate"}, describe(w.Range(1, corner)))
+	assert.Len(t, w.Range(5, corner), 10)
+	crate := w.FindOneType("/obj").(*types.Datum)
+	assert.Len(t, w.Range(1, crate), 10)
+	assert.Equal(t, []string{"2,2,1", "crate"}, describe(w.Range(0, crate)))
+	// atoms that are not on the map only have their surroundings in range
+	box := w.Realm().New("/obj", nil)
+	box.SetVar("name", types.String("box"))
+	assert.Equal(t, []string{"box"}, describe(w.Range(3, box)))
+	box.SetVar("loc", crate)
+	assert.Equal(t, []string{"crate", "box"}, describe(w.Range(3, box)))
+}
+
+func TestTurfMoved(t *testing.T) {
+	w := testworld.NewWorld(t, gridMaps...)
+	old := w.LocateXYZ(3, 3, 1)
+	// turfs are only indexed once all three coordinates are set
+	turf := w.Realm().New("/turf", nil)
+	turf.SetVar("x", types.Int(3))
+	turf.SetVar("y", types.Int(3))
+	assert.Equal(t, old, w.LocateXYZ(3, 3, 1))
+	turf.SetVar("z", types.Int(1))
+	assert.Equal(t, turf, w.LocateXYZ(3, 3, 1))
+	// moving the replaced turf away must not unindex the turf that replaced it
+	old.SetVar("z", types.Int(0))
+	assert.Equal(t, turf, w.LocateXYZ(3, 3, 1))
+	assert.Nil(t, w.LocateXYZ(3, 3, 0))
+	turf.SetVar("x", types.Int(4))
+	assert.Nil(t, w.LocateXYZ(3, 3, 1))
+	assert.Equal(t, turf, w.LocateXYZ(4, 3, 1))
+}
@@ -0,0 +1,310 @@
+package world
+
+import (
+	"fmt"
+	"github.com/celskeggs/mediator/platform/atoms"
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/icon"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/webclient/sprite"
+	"math"
+)
+
+type tilePos struct {
+	X, Y, Z uint
+}
+
+// the amount of light reaching a tile, where each component is 1 at full brightness
+type lightLevel struct {
+	R, G, B float64
+}
+
+var fullLight = lightLevel{R: 1, G: 1, B: 1}
+
+func (l lightLevel) add(o lightLevel) lightLevel {
+	return lightLevel{R: l.R + o.R, G: l.G + o.G, B: l.B + o.B}
+}
+
+func (l lightLevel) scale(factor float64) lightLevel {
+	return lightLevel{R: l.R * factor, G: l.G * factor, B: l.B * factor}
+}
+
+func (l lightLevel) IsDark() bool {
+	return l.R <= 0 && l.G <= 0 && l.B <= 0
+}
+
+// formats the light level as a color, for multiplying into whatever is drawn on the tile
+func (l lightLevel) String() string {
+	component := func(c float64) int {
+		return int(math.Round(math.Max(0, math.Min(1, c)) * 255))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", component(l.R), component(l.G), component(l.B))
+}
+
+// describes the light cast by an atom: either BYOND-style luminosity, which fully lights every tile that it reaches,
+// or light_range, which fades out with distance
+type lightParams struct {
+	Range   uint
+	Color   lightLevel
+	Falloff bool
+}
+
+func lightParamsOf(atom *types.Datum) (lightParams, bool) {
+	if lightRange := nonNegative(atom.Var("light_range")); lightRange > 0 {
+		color := fullLight
+		if value := atom.Var("light_color"); value != nil {
+			c, err := icon.ParseColor(value)
+			if err != nil {
+				panic("invalid light_color: " + err.Error())
+			}
+			color = lightLevel{R: float64(c.R) / 255, G: float64(c.G) / 255, B: float64(c.B) / 255}
+		}
+		power := types.Unnumber(atom.Var("light_power"))
+		return lightParams{Range: lightRange, Color: color.scale(power), Falloff: true}, true
+	} else if lum := luminosity(atom); lum > 0 {
+		// luminosity 1 only lights the tile that the atom is on
+		return lightParams{Range: lum - 1, Color: fullLight}, true
+	}
+	return lightParams{}, false
+}
+
+// light is cast from the turf that an atom is on, even if the atom is inside of something else
+func turfPosOf(atom types.Value) (tilePos, bool) {
+	for atom != nil && !types.IsType(atom, "/turf") {
+		atom = atom.Var("loc")
+	}
+	if atom == nil {
+		return tilePos{}, false
+	}
+	x, y, z := XYZ(atom)
+	return tilePos{X: x, Y: y, Z: z}, true
+}
+
+type lightSource struct {
+	Params lightParams
+	Placed bool
+	Pos    tilePos
+	Lit    map[tilePos]lightLevel
+	// the atoms that the source is inside of, from its loc up to its turf
+	Carriers []uint64
+}
+
+// keeps track of the light that each light source casts on each tile, so that only the light sources affected by a
+// change need to be recomputed
+type lightingEngine struct {
+	// light sources are tracked by UID, so that it is possible to tell when they have been deleted
+	sources map[uint64]*lightSource
+	// the light that each source casts on each tile
+	tiles map[tilePos]map[uint64]lightLevel
+	// the number of light sources inside of each atom, so that only atoms carrying light have to search their contents
+	carried map[uint64]int
+	// atoms that might have started or stopped casting light, or changed the light that they cast
+	dirtyAtoms map[uint64]bool
+	// tiles that might have become opaque or transparent
+	dirtyTiles map[tilePos]bool
+}
+
+func newLightingEngine() *lightingEngine {
+	return &lightingEngine{
+		sources:    map[uint64]*lightSource{},
+		tiles:      map[tilePos]map[uint64]lightLevel{},
+		carried:    map[uint64]int{},
+		dirtyAtoms: map[uint64]bool{},
+		dirtyTiles: map[tilePos]bool{},
+	}
+}
+
+func (w *World) UpdateLighting(atom *types.Datum) {
+	le := w.lighting
+	if types.AsBool(atom.Var("opacity")) {
+		if pos, ok := turfPosOf(atom); ok {
+			le.dirtyTiles[pos] = true
+		}
+	}
+	// the light sources inside of an atom that is already dirty were marked along with it, and anything that has been
+	// put inside of it since then was marked when it moved
+	if !le.dirtyAtoms[atom.UID()] {
+		le.dirtyAtoms[atom.UID()] = true
+		le.markContainedSources(atom)
+	}
+}
+
+// light sources are carried along with whatever they are inside of, without being moved themselves, so any change to
+// where an atom is also changes where the light sources inside of it are
+func (le *lightingEngine) markContainedSources(atom *types.Datum) {
+	if le.carried[atom.UID()] == 0 {
+		return
+	}
+	for _, content := range datum.Elements(atom.Var("contents")) {
+		content := content.(*types.Datum)
+		if _, isSource := le.sources[content.UID()]; isSource {
+			le.dirtyAtoms[content.UID()] = true
+		}
+		le.markContainedSources(content)
+	}
+}
+
+var _ types.DeleteObserver = &World{}
+
+// deleted atoms are never moved out of their locations, so the light that they cast or block has to be recomputed here
+func (w *World) BeforeDelete(datum *types.Datum) {
+	if types.IsType(datum, "/atom") && !types.IsType(datum, "/area") {
+		w.UpdateLighting(datum)
+	}
+}
+
+// brings the light levels up to date with any changes since the last time that they were used
+func (w *World) updateLighting() {
+	le := w.lighting
+	for pos := range le.dirtyTiles {
+		for uid := range le.tiles[pos] {
+			le.dirtyAtoms[uid] = true
+		}
+	}
+	for uid := range le.dirtyAtoms {
+		le.removeSource(uid)
+		if atom, ok := w.realm.Lookup(uid).(*types.Datum); ok {
+			if params, ok := lightParamsOf(atom); ok {
+				le.addSource(uid, w.castLight(atom, params))
+			}
+		}
+	}
+	le.dirtyAtoms = map[uint64]bool{}
+	le.dirtyTiles = map[tilePos]bool{}
+}
+
+func (le *lightingEngine) removeSource(uid uint64) {
+	source, found := le.sources[uid]
+	if !found {
+		return
+	}
+	for pos := range source.Lit {
+		delete(le.tiles[pos], uid)
+		if len(le.tiles[pos]) == 0 {
+			delete(le.tiles, pos)
+		}
+	}
+	for _, carrier := range source.Carriers {
+		le.carried[carrier]--
+		if le.carried[carrier] == 0 {
+			delete(le.carried, carrier)
+		}
+	}
+	delete(le.sources, uid)
+}
+
+func (le *lightingEngine) addSource(uid uint64, source *lightSource) {
+	for pos, level := range source.Lit {
+		if le.tiles[pos] == nil {
+			le.tiles[pos] = map[uint64]lightLevel{}
+		}
+		le.tiles[pos][uid] = level
+	}
+	for _, carrier := range source.Carriers {
+		le.carried[carrier]++
+	}
+	le.sources[uid] = source
+}
+
+// determines which tiles a light source can reach, using the same line of sight rules as view()
+func (w *World) castLight(atom *types.Datum, params lightParams) *lightSource {
+	pos, placed := turfPosOf(atom)
+	source := &lightSource{
+		Params: params,
+		Placed: placed,
+		Pos:    pos,
+		Lit:    map[tilePos]lightLevel{},
+	}
+	// areas contain every turf, so they are never searched for light sources
+	for loc := atom.Var("loc"); loc != nil && !types.IsType(loc, "/area"); loc = loc.Var("loc") {
+		source.Carriers = append(source.Carriers, loc.(*types.Datum).UID())
+		if types.IsType(loc, "/turf") {
+			break
+		}
+	}
+	if !placed {
+		return source
+	}
+	turfs := w.blockAround(pos.X, pos.Y, pos.Z, params.Range)
+	vir := newViewInfoRegion(params.Range, pos.X, pos.Y, pos.X, pos.Y)
+	for _, turf := range turfs {
+		vir.AddTurf(turf.(*types.Datum), true)
+	}
+	vir.ComputeVisibility(0)
+	vir.forEach(func(info *viewInfo) {
+		if info.Vis == 0 {
+			return
+		}
+		distance := math.Hypot(float64(info.X)-float64(pos.X), float64(info.Y)-float64(pos.Y))
+		if distance > float64(params.Range) {
+			return
+		}
+		level := params.Color
+		if params.Falloff {
+			level = level.scale(1 - distance/float64(params.Range+1))
+		}
+		source.Lit[tilePos{X: uint(info.X), Y: uint(info.Y), Z: pos.Z}] = level
+	})
+	return source
+}
+
+// the total light cast on a tile by every light source, not counting whether its area is lit
+func (w *World) lightAt(pos tilePos) (level lightLevel) {
+	for _, contribution := range w.lighting.tiles[pos] {
+		level = level.add(contribution)
+	}
+	return level
+}
+
+// the light level of a turf as it is drawn: turfs in dynamically lit areas are only lit by light sources, and every
+// other turf is fully lit
+func (w *World) turfLight(turf *types.Datum) lightLevel {
+	area := atoms.ContainingArea(turf)
+	if area == nil || !types.AsBool(area.Var("dynamic_lighting")) {
+		return fullLight
+	}
+	pos, _ := turfPosOf(turf)
+	return w.lightAt(pos)
+}
+
+// whether a turf can be seen without any light of its own. turfs in dynamically lit areas need light to reach them,
+// other turfs are lit if their area is luminous, and turfs outside of any area are treated as lit.
+func (w *World) turfLit(turf *types.Datum) bool {
+	area := atoms.ContainingArea(turf)
+	if area == nil {
+		return true
+	} else if types.AsBool(area.Var("dynamic_lighting")) {
+		return !w.turfLight(turf).IsDark()
+	} else {
+		return luminosity(area) > 0
+	}
+}
+
+// the light levels of the tiles within viewDist of the center, or nil if they are all fully lit
+func (w *World) LightMap(center types.Value, viewDist uint) *sprite.LightMap {
+	w.updateLighting()
+	cx, cy, cz := XYZ(center)
+	size := viewDist*2 + 1
+	lights := &sprite.LightMap{
+		Width:  size,
+		Height: size,
+		Levels: make([]string, size*size),
+	}
+	full := fullLight.String()
+	for i := range lights.Levels {
+		lights.Levels[i] = full
+	}
+	shaded := false
+	for _, turf := range w.blockAround(cx, cy, cz, viewDist) {
+		x, y := XY(turf)
+		level := w.turfLight(turf.(*types.Datum)).String()
+		if level != full {
+			shaded = true
+		}
+		lights.Levels[(y+viewDist-cy)*size+(x+viewDist-cx)] = level
+	}
+	if !shaded {
+		return nil
+	}
+	return lights
+}
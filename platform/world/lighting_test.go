@@ -0,0 +1,132 @@
+package world_test
+
+import (
+	"github.com/celskeggs/mediator/platform/testworld"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/platform/world"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// a single row of five dynamically lit turfs
+const corridorMap = `"a" = (/turf,/area{dynamic_lighting = 1})
+
+(1,1,1) = {"
+aaaaa
+"}
+`
+
+func newObj(w *world.World, x uint, settings map[string]types.Value) *types.Datum {
+	obj := w.Realm().New("/obj", nil)
+	for name, value := range settings {
+		obj.SetVar(name, value)
+	}
+	obj.SetVar("loc", w.LocateXYZ(x, 1, 1))
+	return obj
+}
+
+// shows which turfs in the corridor are lit, as # for lit and . for dark
+func litTurfs(w *world.World) string {
+	lights := w.LightMap(w.LocateXYZ(3, 1, 1), 2)
+	if lights == nil {
+		return "#####"
+	}
+	var out strings.Builder
+	for x := uint(0); x < 5; x++ {
+		if lights.Levels[2*lights.Width+x] == "#000000" {
+			out.WriteByte('.')
+		} else {
+			out.WriteByte('#')
+		}
+	}
+	return out.String()
+}
+
+func TestLightSource(t *testing.T) {
+	w := testworld.NewWorld(t, corridorMap)
+	assert.Equal(t, ".....", litTurfs(w))
+	lamp := newObj(w, 1, map[string]types.Value{"luminosity": types.Int(2)})
+	assert.Equal(t, "##...", litTurfs(w))
+	lamp.SetVar("loc", w.LocateXYZ(4, 1, 1))
+	assert.Equal(t, "..###", litTurfs(w))
+	lamp.SetVar("luminosity", types.Int(1))
+	assert.Equal(t, "...#.", litTurfs(w))
+	types.Del(lamp)
+	assert.Equal(t, ".....", litTurfs(w))
+}
+
+func TestOpaqueAtomBlocksLight(t *testing.T) {
+	w := testworld.NewWorld(t, corridorMap)
+	newObj(w, 1, map[string]types.Value{"luminosity": types.Int(5)})
+	assert.Equal(t, "#####", litTurfs(w))
+	wall := newObj(w, 3, map[string]types.Value{"opacity": types.Int(1)})
+	assert.Equal(t, "###..", litTurfs(w))
+	wall.SetVar("loc", w.LocateXYZ(4, 1, 1))
+	assert.Equal(t, "####.", litTurfs(w))
+	types.Del(wall)
+	assert.Equal(t, "#####", litTurfs(w))
+}
+
+func TestLightCarriedInsideContainer(t *testing.T) {
+	w := testworld.NewWorld(t, corridorMap)
+	crate := newObj(w, 1, nil)
+	bag := newObj(w, 1, nil)
+	bag.SetVar("loc", crate)
+	lamp := newObj(w, 1, map[string]types.Value{"luminosity": types.Int(2)})
+	lamp.SetVar("loc", bag)
+	assert.Equal(t, "##...", litTurfs(w))
+	crate.SetVar("loc", w.LocateXYZ(4, 1, 1))
+	assert.Equal(t, "..###", litTurfs(w))
+	crate.SetVar("loc", nil)
+	assert.Equal(t, ".....", litTurfs(w))
+}
+
+func TestLightingUpdatesAreIncremental(t *testing.T) {
+	w := testworld.NewWorld(t, corridorMap)
+	litTurfs(w)
+	// a turf is only marked once all three of its coordinates are set
+	turf := w.Realm().New("/turf", nil)
+	turf.SetVar("x", types.Int(6))
+	turf.SetVar("y", types.Int(1))
+	assert.False(t, w.LightingDirty(turf))
+	turf.SetVar("z", types.Int(1))
+	assert.True(t, w.LightingDirty(turf))
+
+	// only the atoms that light sources are inside of are searched when they move
+	crate := newObj(w, 1, nil)
+	bag := newObj(w, 1, nil)
+	bag.SetVar("loc", crate)
+	lamp := newObj(w, 1, map[string]types.Value{"luminosity": types.Int(2)})
+	lamp.SetVar("loc", bag)
+	litTurfs(w)
+	assert.Equal(t, 1, w.CarriedLightSources(bag))
+	assert.Equal(t, 1, w.CarriedLightSources(crate))
+	assert.Equal(t, 1, w.CarriedLightSources(w.LocateXYZ(1, 1, 1).(*types.Datum)))
+	lamp.SetVar("loc", crate)
+	litTurfs(w)
+	assert.Equal(t, 0, w.CarriedLightSources(bag))
+	assert.Equal(t, 1, w.CarriedLightSources(crate))
+	bag.SetVar("loc", w.LocateXYZ(2, 1, 1))
+	assert.False(t, w.LightingDirty(lamp))
+	crate.SetVar("loc", w.LocateXYZ(3, 1, 1))
+	assert.True(t, w.LightingDirty(lamp))
+	assert.Equal(t, ".###.", litTurfs(w))
+}
+
+// a lamp loaded from a map with a fractional light_range and light_power
+const fractionalLampMap = `"a" = (/turf,/area{dynamic_lighting = 1})
+"b" = (/obj{light_range = 1.6; light_power = 0.5},/turf,/area{dynamic_lighting = 1})
+
+(1,1,1) = {"
+aabaa
+"}
+`
+
+func TestFractionalLightValues(t *testing.T) {
+	w := testworld.NewWorld(t, fractionalLampMap)
+	lights := w.LightMap(w.LocateXYZ(3, 1, 1), 2)
+	if assert.NotNil(t, lights) {
+		assert.Equal(t, []string{"#2b2b2b", "#555555", "#808080", "#555555", "#2b2b2b"}, lights.Levels[2*lights.Width:3*lights.Width])
+	}
+}
@@ -0,0 +1,186 @@
+package world
+
+import (
+	"fmt"
+	"github.com/celskeggs/mediator/platform/atoms"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/webclient"
+	"log"
+	"strings"
+)
+
+// the name of the map in DM's default skin, which is the only control that the webclient has
+const mapControl = "mapwindow.map"
+
+// the turf under a pixel of the client's map, using the same origin as Render
+func (w *World) turfAtScreen(client types.Value, screenX, screenY int) types.Value {
+	center := client.Var("virtual_eye")
+	if center == nil || screenX < 0 || screenY < 0 {
+		return nil
+	}
+	viewDist := int(types.Unuint(client.Var("view")))
+	cX, cY, cZ := XYZ(center)
+	x := int(cX) - viewDist + screenX/int(w.IconWidth)
+	y := int(cY) - viewDist + screenY/int(w.IconHeight)
+	if x < 1 || y < 1 {
+		return nil
+	}
+	return w.LocateXYZ(uint(x), uint(y), cZ)
+}
+
+// the atom with the UID, as long as it was drawn in the last view sent to the client; the client could otherwise name
+// any atom in the world
+func (w *World) mouseTarget(client types.Value, uid uint64) types.Value {
+	_, cd := ClientDataChunk(client)
+	if !cd.rendered[uid] {
+		return nil
+	}
+	target := w.realm.Lookup(uid)
+	if target == nil || !types.IsType(target, "/atom") {
+		return nil
+	}
+	return target
+}
+
+// the atom that a mouse event is about, if the client is allowed to name it
+func (w *World) eventTarget(client types.Value, event webclient.MouseEvent) types.Value {
+	_, cd := ClientDataChunk(client)
+	if event.Action == webclient.MouseExited {
+		// the atom may have just gone out of view, which is why the mouse is no longer over it, so it is checked
+		// against the atom that the mouse last entered instead of against the view
+		hovered, ok := cd.hovered.Dereference().(*types.Datum)
+		cd.hovered = nil
+		if !ok || hovered.UID() != event.Target {
+			return nil
+		}
+		return hovered
+	}
+	target := w.mouseTarget(client, event.Target)
+	if target != nil && event.Action == webclient.MouseEntered {
+		cd.hovered = types.Reference(target)
+	}
+	return target
+}
+
+// the location that DM passes to the mouse procs: the turf that an object is on, or the turf under the mouse for areas
+func mouseLocation(atom types.Value, under types.Value) types.Value {
+	if types.IsType(atom, "/turf") {
+		return atom
+	} else if types.IsType(atom, "/atom/movable") {
+		return atom.Var("loc")
+	} else {
+		return under
+	}
+}
+
+// the params string for an event, as DM formats it, like "icon-x=16;icon-y=5;left=1;shift=1;screen-loc=3:16,2:5"
+func (w *World) mouseParams(event webclient.MouseEvent) string {
+	var params []string
+	if event.IconX > 0 && event.IconY > 0 {
+		params = append(params, fmt.Sprintf("icon-x=%d", event.IconX), fmt.Sprintf("icon-y=%d", event.IconY))
+	}
+	switch event.Button {
+	case "left", "middle", "right":
+		params = append(params, event.Button+"=1")
+	}
+	if event.Ctrl {
+		params = append(params, "ctrl=1")
+	}
+	if event.Shift {
+		params = append(params, "shift=1")
+	}
+	if event.Alt {
+		params = append(params, "alt=1")
+	}
+	if event.ScreenX >= 0 && event.ScreenY >= 0 {
+		width, height := int(w.IconWidth), int(w.IconHeight)
+		params = append(params, fmt.Sprintf("screen-loc=%d:%d,%d:%d",
+			event.ScreenX/width+1, event.ScreenX%width+1, event.ScreenY/height+1, event.ScreenY%height+1))
+	}
+	return strings.Join(params, ";")
+}
+
+var mouseProcs = map[string]string{
+	webclient.MouseClick:    "Click",
+	webclient.MouseDblClick: "DblClick",
+	webclient.MouseDown:     "MouseDown",
+	webclient.MouseUp:       "MouseUp",
+	webclient.MouseEntered:  "MouseEntered",
+	webclient.MouseExited:   "MouseExited",
+}
+
+// passes something that the player did with the mouse to the /client proc for it
+func InvokeMouse(client types.Value, event webclient.MouseEvent) {
+	clientDatum, clientData := ClientDataChunk(client)
+	w := atoms.WorldOf(clientDatum).(*World)
+	var usr *types.Datum
+	if mob := clientData.GetMob(clientDatum); mob != nil {
+		usr = mob.(*types.Datum)
+	}
+	target := w.eventTarget(client, event)
+	if target == nil {
+		return
+	}
+	under := w.turfAtScreen(client, event.ScreenX, event.ScreenY)
+	control := types.String(mapControl)
+	params := types.String(w.mouseParams(event))
+	if event.Action == webclient.MouseDrop {
+		var over types.Value
+		if event.Over != 0 {
+			over = w.mouseTarget(client, event.Over)
+		}
+		overLocation := under
+		if over != nil {
+			overLocation = mouseLocation(over, under)
+		}
+		client.Invoke(usr, "MouseDrop", target, over, mouseLocation(target, nil), overLocation, control, control, params)
+	} else if proc, found := mouseProcs[event.Action]; found {
+		client.Invoke(usr, proc, target, mouseLocation(target, under), control, params)
+	} else {
+		log.Printf("unknown mouse action %q\n", event.Action)
+	}
+}
+
+func (d *ClientData) forwardMouse(usr *types.Datum, object types.Value, proc string, location, control, params types.Value) {
+	if object != nil {
+		object.Invoke(usr, proc, location, control, params)
+	}
+}
+
+// each of the client's mouse procs calls the proc of the same name on the object by default
+func (d *ClientData) ProcClick(src *types.Datum, usr *types.Datum, object types.Value, location types.Value, control types.Value, params types.Value) types.Value {
+	d.forwardMouse(usr, object, "Click", location, control, params)
+	return nil
+}
+
+func (d *ClientData) ProcDblClick(src *types.Datum, usr *types.Datum, object types.Value, location types.Value, control types.Value, params types.Value) types.Value {
+	d.forwardMouse(usr, object, "DblClick", location, control, params)
+	return nil
+}
+
+func (d *ClientData) ProcMouseDown(src *types.Datum, usr *types.Datum, object types.Value, location types.Value, control types.Value, params types.Value) types.Value {
+	d.forwardMouse(usr, object, "MouseDown", location, control, params)
+	return nil
+}
+
+func (d *ClientData) ProcMouseUp(src *types.Datum, usr *types.Datum, object types.Value, location types.Value, control types.Value, params types.Value) types.Value {
+	d.forwardMouse(usr, object, "MouseUp", location, control, params)
+	return nil
+}
+
+func (d *ClientData) ProcMouseEntered(src *types.Datum, usr *types.Datum, object types.Value, location types.Value, control types.Value, params types.Value) types.Value {
+	d.forwardMouse(usr, object, "MouseEntered", location, control, params)
+	return nil
+}
+
+func (d *ClientData) ProcMouseExited(src *types.Datum, usr *types.Datum, object types.Value, location types.Value, control types.Value, params types.Value) types.Value {
+	d.forwardMouse(usr, object, "MouseExited", location, control, params)
+	return nil
+}
+
+func (d *ClientData) ProcMouseDrop(src *types.Datum, usr *types.Datum, srcObject types.Value, overObject types.Value, srcLocation types.Value, overLocation types.Value, srcControl types.Value, overControl types.Value, params types.Value) types.Value {
+	if srcObject != nil {
+		srcObject.Invoke(usr, "MouseDrop", overObject, srcLocation, overLocation, srcControl, overControl, params)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package world
+
+import (
+	"github.com/celskeggs/mediator/webclient"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMouseParams(t *testing.T) {
+	w := &World{IconWidth: 32, IconHeight: 32}
+	assert.Equal(t, "icon-x=16;icon-y=5;left=1;shift=1;screen-loc=3:16,2:5", w.mouseParams(webclient.MouseEvent{
+		IconX:   16,
+		IconY:   5,
+		ScreenX: 79,
+		ScreenY: 36,
+		Button:  "left",
+		Shift:   true,
+	}))
+	assert.Equal(t, "middle=1;ctrl=1;alt=1;screen-loc=1:1,1:32", w.mouseParams(webclient.MouseEvent{
+		ScreenX: 0,
+		ScreenY: 31,
+		Button:  "middle",
+		Ctrl:    true,
+		Alt:     true,
+	}))
+	assert.Equal(t, "", w.mouseParams(webclient.MouseEvent{ScreenX: -1, ScreenY: -1}))
+}
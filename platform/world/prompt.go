@@ -0,0 +1,145 @@
+package world
+
+import (
+	"fmt"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/util"
+	"github.com/celskeggs/mediator/webclient"
+	"github.com/celskeggs/mediator/webclient/sprite"
+	"log"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// runs a proc that a player started, like a verb. if the proc waits for the player to answer input() or alert(), this
+// returns early, and the rest of the proc runs when the player answers.
+func (w *World) RunProc(body func()) {
+	w.resumeProc(util.NewCoroutine(body))
+}
+
+func (w *World) resumeProc(proc *util.Coroutine) {
+	outer := w.running
+	w.running = proc
+	defer func() {
+		w.running = outer
+	}()
+	proc.Resume()
+}
+
+type pendingPrompt struct {
+	Prompt  sprite.Prompt
+	Choices []types.Value
+	Proc    *util.Coroutine
+	Answer  types.Value
+}
+
+// asks the player a question and suspends the calling proc until they answer. for lists, the answer is the choice
+// that the player picked, and for alerts, it is the text of the button that they pressed. the answer is null if the
+// player cancels or leaves.
+func (w *World) Prompt(client types.Value, prompt sprite.Prompt, choices []types.Value) types.Value {
+	proc := w.running
+	if proc == nil {
+		panic("input() and alert() can only wait for players in procs that players started, like verbs")
+	}
+	_, cd := ClientDataChunk(client)
+	w.lastPromptID++
+	prompt.ID = w.lastPromptID
+	pending := &pendingPrompt{
+		Prompt:  prompt,
+		Choices: choices,
+		Proc:    proc,
+	}
+	if cd.pending == nil {
+		cd.pending = map[uint64]*pendingPrompt{}
+	}
+	cd.pending[prompt.ID] = pending
+	cd.prompts = append(cd.prompts, prompt)
+	proc.Suspend()
+	return pending.Answer
+}
+
+func (p *pendingPrompt) interpret(answer webclient.PromptAnswer) (types.Value, error) {
+	if answer.Cancel {
+		if !p.Prompt.Cancel {
+			return nil, fmt.Errorf("prompt %d cannot be cancelled", p.Prompt.ID)
+		}
+		return nil, nil
+	}
+	switch p.Prompt.Kind {
+	case sprite.PromptText, sprite.PromptMessage:
+		if utf8.RuneCountInString(answer.Text) > webclient.MaxAnswerLength {
+			return nil, fmt.Errorf("answer to prompt %d is longer than %d characters", p.Prompt.ID, webclient.MaxAnswerLength)
+		}
+		return types.String(answer.Text), nil
+	case sprite.PromptNum:
+		util.NiceToHave("support fractional numbers")
+		num, err := strconv.Atoi(answer.Text)
+		if err != nil {
+			return nil, fmt.Errorf("answer to prompt %d is not a number: %q", p.Prompt.ID, answer.Text)
+		}
+		return types.Int(num), nil
+	case sprite.PromptList, sprite.PromptAlert:
+		if answer.Choice < 0 || answer.Choice >= len(p.Choices) {
+			return nil, fmt.Errorf("answer to prompt %d is not one of the %d choices: %d", p.Prompt.ID, len(p.Choices), answer.Choice)
+		}
+		return p.Choices[answer.Choice], nil
+	default:
+		panic(fmt.Sprintf("unknown prompt kind %q", p.Prompt.Kind))
+	}
+}
+
+// resumes the proc that is waiting for the answer. if the answer does not make sense, the player is asked again.
+func (w *World) AnswerPrompt(client types.Value, answer webclient.PromptAnswer) {
+	_, cd := ClientDataChunk(client)
+	pending, found := cd.pending[answer.ID]
+	if !found {
+		// already answered, perhaps from another tab
+		return
+	}
+	value, err := pending.interpret(answer)
+	if err != nil {
+		// the webclient checks answers before sending them, so this should not happen
+		log.Printf("invalid answer from %v: %v\n", client, err)
+		cd.prompts = append(cd.prompts, pending.Prompt)
+		return
+	}
+	delete(cd.pending, answer.ID)
+	pending.Answer = value
+	w.resumeProc(pending.Proc)
+}
+
+// asks every unanswered question again, in the order that they were first asked, for when the player's session moves
+// to a new page. prompts that have not been sent yet are left alone.
+func (w *World) resendPrompts(client types.Value) {
+	_, cd := ClientDataChunk(client)
+	unsent := map[uint64]bool{}
+	for _, prompt := range cd.prompts {
+		unsent[prompt.ID] = true
+	}
+	for _, id := range sortedPromptIDs(cd) {
+		if !unsent[id] {
+			cd.prompts = append(cd.prompts, cd.pending[id].Prompt)
+		}
+	}
+}
+
+func sortedPromptIDs(cd *ClientData) []uint64 {
+	var ids []uint64
+	for id := range cd.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// lets every proc that is waiting on the player continue, as if they had cancelled
+func (w *World) cancelPrompts(cd *ClientData) {
+	for _, id := range sortedPromptIDs(cd) {
+		pending := cd.pending[id]
+		delete(cd.pending, id)
+		w.resumeProc(pending.Proc)
+	}
+}
@@ -0,0 +1,45 @@
+package world_test
+
+import (
+	"github.com/celskeggs/mediator/platform/testworld"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/webclient"
+	"github.com/celskeggs/mediator/webclient/sprite"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func promptIDs(update sprite.ViewUpdate) (ids []uint64) {
+	for _, prompt := range update.Prompts {
+		ids = append(ids, prompt.ID)
+	}
+	return ids
+}
+
+func TestPromptsResentOnResume(t *testing.T) {
+	w := testworld.NewWorld(t, gridMaps...)
+	player := w.ServerAPI().AddPlayer("tester")
+	client := w.FindOneType("/client")
+	var answers []types.Value
+	for i := 0; i < 3; i++ {
+		w.RunProc(func() {
+			answers = append(answers, w.Prompt(client, sprite.Prompt{Kind: sprite.PromptText}, nil))
+		})
+	}
+	first := promptIDs(player.PullRequests())
+	assert.Len(t, first, 3)
+
+	player.Command(webclient.Command{Answer: &webclient.PromptAnswer{ID: first[1], Text: "hello"}})
+	assert.Equal(t, []types.Value{types.String("hello")}, answers)
+	player.Resumed()
+	assert.Equal(t, []uint64{first[0], first[2]}, promptIDs(player.PullRequests()))
+
+	// answers that are too long are refused, and the player is asked again
+	tooLong := strings.Repeat("x", webclient.MaxAnswerLength+1)
+	player.Command(webclient.Command{Answer: &webclient.PromptAnswer{ID: first[0], Text: tooLong}})
+	assert.Len(t, answers, 1)
+	// prompts that have not been pulled yet are not sent twice
+	player.Resumed()
+	assert.Equal(t, []uint64{first[0], first[2]}, promptIDs(player.PullRequests()))
+}
@@ -5,12 +5,14 @@ import (
 	"github.com/celskeggs/mediator/common"
 	"github.com/celskeggs/mediator/platform/atoms"
 	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/resourcepack"
 	"github.com/celskeggs/mediator/util"
 	"github.com/celskeggs/mediator/webclient"
 	"github.com/celskeggs/mediator/webclient/sprite"
 	"github.com/celskeggs/mediator/websession"
 	"math/rand"
 	"sort"
+	"time"
 )
 
 type worldAPI struct {
@@ -35,6 +37,10 @@ func (w *worldAPI) AddPlayer(key string) websession.PlayerAPI {
 	}
 }
 
+func (w *worldAPI) DynamicResource(name string) (resourcepack.Resource, bool) {
+	return w.World.iconCache.DynamicResource(name)
+}
+
 func (w *worldAPI) Tick() {
 	// update stat panels
 	for _, player := range w.World.clients {
@@ -56,6 +62,7 @@ func (w *worldAPI) Tick() {
 	for _, movable := range w.World.FindAllType("/atom/movable") {
 		UpdateWalk(movable)
 	}
+	w.World.iconCache.EvictUnused(time.Now())
 	w.Update()
 }
 
@@ -84,64 +91,88 @@ func (p playerAPI) Remove() {
 	p.API.Update()
 }
 
+func (p playerAPI) Resumed() {
+	p.API.World.resendPrompts(p.Client)
+	p.API.Update()
+}
+
 func (p playerAPI) IsValid() bool {
 	return p.API.World.PlayerExists(p.Client)
 }
 
 func (p playerAPI) Command(cmd webclient.Command) {
 	if cmd.Verb != "" {
-		InvokeVerb(p.Client, cmd.Verb)
+		p.API.World.RunProc(func() {
+			InvokeVerb(p.Client, cmd.Verb)
+		})
+		p.API.Update()
+	}
+	if cmd.Topic != "" {
+		p.API.World.RunProc(func() {
+			InvokeTopic(p.Client, cmd.Topic)
+		})
+		p.API.Update()
+	}
+	if cmd.Answer != nil {
+		p.API.World.AnswerPrompt(p.Client, *cmd.Answer)
+		p.API.Update()
+	}
+	if cmd.Mouse != nil {
+		p.API.World.RunProc(func() {
+			InvokeMouse(p.Client, *cmd.Mouse)
+		})
 		p.API.Update()
 	}
 }
 
-const SpriteSize = 32
-
 func (p playerAPI) Render() sprite.SpriteView {
 	center, viewAtoms, stats, verbs, verbsOn := p.API.World.RenderClientView(p.Client)
 
-	util.FIXME("don't use hardcoded tile sizes here")
 	util.FIXME("add adjacent cell movement animations")
 
+	tileWidth, tileHeight := p.API.World.IconWidth, p.API.World.IconHeight
 	viewDist := types.Unuint(p.Client.Var("view"))
 	sizeInCells := (viewDist * 2) + 1
-	viewportSize := sizeInCells * SpriteSize
 
 	var view sprite.SpriteView
 	view.WindowTitle = p.API.World.Name
-	view.ViewPortWidth = viewportSize
-	view.ViewPortHeight = viewportSize
+	view.ViewPortWidth = sizeInCells * tileWidth
+	view.ViewPortHeight = sizeInCells * tileHeight
 	view.Stats = stats
 	view.Verbs = verbs
 
+	_, cd := ClientDataChunk(p.Client)
+	cd.rendered = map[uint64]bool{}
 	if center != nil {
 		cX, cY := XY(center)
-		shiftX, shiftY := (cX-viewDist)*SpriteSize, (cY-viewDist)*SpriteSize
+		shiftX, shiftY := int((cX-viewDist)*tileWidth), int((cY-viewDist)*tileHeight)
 
-		layers := map[int][]sprite.GameSprite{}
+		view.OriginX, view.OriginY = shiftX, shiftY
 		for _, visibleAtom := range viewAtoms {
 			x, y := XY(visibleAtom)
-			found, layer, s := visibleAtom.Var("appearance").(atoms.Appearance).ToSprite(x*SpriteSize-shiftX, y*SpriteSize-shiftY, visibleAtom.Var("dir").(common.Direction))
-			if found {
+			appearance := visibleAtom.Var("appearance").(atoms.Appearance)
+			sprites := appearance.ToSprites(int(x*tileWidth)-shiftX, int(y*tileHeight)-shiftY, visibleAtom.Var("dir").(common.Direction))
+			for i, ls := range sprites {
+				// clicking on an overlay is the same as clicking on the atom
+				s := ls.Sprite
 				s.Name = types.Unstring(visibleAtom.Var("name"))
 				s.Verbs = verbsOn[visibleAtom.(*types.Datum)]
 				s.UID = visibleAtom.(*types.Datum).UID()
-				layers[layer] = append(layers[layer], s)
+				cd.rendered[s.UID] = true
+				s.Layer = ls.Layer
+				s.Seq = uint(i)
+				view.Sprites = append(view.Sprites, s)
 			}
 		}
-		var layerOrder []int
-		for layer := range layers {
-			layerOrder = append(layerOrder, layer)
-		}
-		sort.Ints(layerOrder)
-		for _, layer := range layerOrder {
-			view.Sprites = append(view.Sprites, layers[layer]...)
-		}
+		sort.Slice(view.Sprites, func(i, j int) bool {
+			return view.Sprites[i].DrawsBefore(view.Sprites[j])
+		})
+		view.LightMap = p.API.World.LightMap(center, viewDist)
 	}
 
 	return view
 }
 
-func (p playerAPI) PullRequests() (lines []string, sounds []sprite.Sound, flicks []sprite.Flick) {
+func (p playerAPI) PullRequests() sprite.ViewUpdate {
 	return PullClientRequests(p.Client)
 }
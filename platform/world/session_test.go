@@ -0,0 +1,85 @@
+package world_test
+
+import (
+	"github.com/celskeggs/mediator/dmi"
+	"github.com/celskeggs/mediator/platform/icon"
+	"github.com/celskeggs/mediator/platform/testworld"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/resourcepack"
+	"github.com/celskeggs/mediator/webclient"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"testing"
+)
+
+// a blank icon, so that atoms are drawn
+func blankIcon(t *testing.T) *icon.Icon {
+	cache, err := icon.NewIconCache(&resourcepack.ResourcePack{Resources: map[string]resourcepack.Resource{}})
+	assert.NoError(t, err)
+	blank, err := cache.Register(&dmi.DMI{
+		Width:  32,
+		Height: 32,
+		States: []dmi.StateImages{
+			{Images: [][]image.Image{{image.NewNRGBA(image.Rect(0, 0, 32, 32))}}},
+		},
+	})
+	assert.NoError(t, err)
+	return blank
+}
+
+func TestMouseTargetsRenderedAtoms(t *testing.T) {
+	w := testworld.NewWorld(t, gridMaps...)
+	player := w.ServerAPI().AddPlayer("tester")
+	client := w.FindOneType("/client")
+	client.Var("mob").SetVar("loc", w.LocateXYZ(1, 1, 1))
+	crate := w.FindOneType("/obj").(*types.Datum)
+	crate.SetVar("icon", blankIcon(t))
+	assert.Nil(t, w.MouseTarget(client, crate.UID()))
+
+	player.Render()
+	assert.Equal(t, crate, w.MouseTarget(client, crate.UID()))
+	// the player can click on what they were shown until they are shown something else
+	crate.SetVar("loc", w.LocateXYZ(1, 1, 2))
+	assert.Equal(t, crate, w.MouseTarget(client, crate.UID()))
+	player.Render()
+	assert.Nil(t, w.MouseTarget(client, crate.UID()))
+
+	// a client whose eye has been deleted sees nothing
+	eye := w.Realm().New("/obj", nil)
+	eye.SetVar("icon", blankIcon(t))
+	eye.SetVar("loc", w.LocateXYZ(2, 2, 1))
+	client.SetVar("eye", eye)
+	player.Render()
+	assert.Equal(t, eye, w.MouseTarget(client, eye.UID()))
+	types.Del(eye)
+	assert.Empty(t, player.Render().Sprites)
+	assert.Nil(t, w.MouseTarget(client, crate.UID()))
+}
+
+func TestMouseExitedOnlyLeavesEnteredAtom(t *testing.T) {
+	w := testworld.NewWorld(t, gridMaps...)
+	player := w.ServerAPI().AddPlayer("tester")
+	client := w.FindOneType("/client")
+	client.Var("mob").SetVar("loc", w.LocateXYZ(1, 1, 1))
+	crate := w.FindOneType("/obj").(*types.Datum)
+	crate.SetVar("icon", blankIcon(t))
+	elsewhere := w.Realm().New("/obj", nil, w.LocateXYZ(1, 1, 2))
+	elsewhere.SetVar("icon", blankIcon(t))
+	player.Render()
+
+	exit := func(target *types.Datum) types.Value {
+		return w.EventTarget(client, webclient.MouseEvent{Action: webclient.MouseExited, Target: target.UID()})
+	}
+	// atoms that the mouse never entered cannot be exited, even if they are in view
+	assert.Nil(t, exit(elsewhere))
+	assert.Nil(t, exit(crate))
+	assert.Equal(t, crate, w.EventTarget(client, webclient.MouseEvent{Action: webclient.MouseEntered, Target: crate.UID()}))
+	assert.Nil(t, exit(elsewhere))
+	// the mouse can leave the crate even after it goes out of view, but only once
+	w.EventTarget(client, webclient.MouseEvent{Action: webclient.MouseEntered, Target: crate.UID()})
+	crate.SetVar("loc", w.LocateXYZ(1, 1, 2))
+	player.Render()
+	assert.Nil(t, w.MouseTarget(client, crate.UID()))
+	assert.Equal(t, crate, exit(crate))
+	assert.Nil(t, exit(crate))
+}
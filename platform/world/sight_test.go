@@ -0,0 +1,89 @@
+package world_test
+
+import (
+	"github.com/celskeggs/mediator/platform/atoms"
+	"github.com/celskeggs/mediator/platform/testworld"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/platform/world"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// a row of turfs with a wall between the viewer's tile and a crate and a guard
+const wallMap = `"a" = (/turf,/area)
+"w" = (/turf{opacity = 1},/area)
+"o" = (/obj{name = "crate"},/turf,/area)
+"m" = (/mob{name = "guard"},/turf,/area)
+
+(1,1,1) = {"
+awoma
+"}
+`
+
+// describes what the viewer can see, leaving out areas
+func seen(w *world.World, viewer *types.Datum, mode atoms.ViewMode) (out []string) {
+	for _, name := range describe(w.View(5, viewer, mode)) {
+		if name != "area" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+func newViewer(w *world.World) *types.Datum {
+	viewer := w.Realm().New("/mob", nil, w.LocateXYZ(1, 1, 1))
+	viewer.SetVar("name", types.String("viewer"))
+	return viewer
+}
+
+func TestViewInvisibility(t *testing.T) {
+	w := testworld.NewWorld(t, gridMaps...)
+	viewer := newViewer(w)
+	crate := w.FindOneType("/obj")
+	assert.Contains(t, seen(w, viewer, atoms.ViewExclusive), "crate")
+	crate.SetVar("invisibility", types.Int(30))
+	assert.NotContains(t, seen(w, viewer, atoms.ViewExclusive), "crate")
+	viewer.SetVar("see_invisible", types.Int(29))
+	assert.NotContains(t, seen(w, viewer, atoms.ViewExclusive), "crate")
+	viewer.SetVar("see_invisible", types.Int(30))
+	assert.Contains(t, seen(w, viewer, atoms.ViewExclusive), "crate")
+	// an invisibility of 101 hides the crate from every viewer
+	crate.SetVar("invisibility", types.Int(101))
+	viewer.SetVar("see_invisible", types.Int(100))
+	assert.NotContains(t, seen(w, viewer, atoms.ViewExclusive), "crate")
+
+	// a viewer that cannot see itself is still part of view(), but is not drawn unless it has SEE_SELF
+	viewer.SetVar("invisibility", types.Int(101))
+	assert.Contains(t, seen(w, viewer, atoms.ViewInclusive), "viewer")
+	assert.NotContains(t, seen(w, viewer, atoms.ViewVisual), "viewer")
+	viewer.SetVar("sight", types.Int(atoms.SightSeeSelf))
+	assert.Contains(t, seen(w, viewer, atoms.ViewVisual), "viewer")
+}
+
+func TestViewSightFlags(t *testing.T) {
+	w := testworld.NewWorld(t, wallMap)
+	viewer := newViewer(w)
+	assert.Equal(t, []string{"1,1,1", "2,1,1"}, seen(w, viewer, atoms.ViewExclusive))
+	viewer.SetVar("sight", types.Int(atoms.SightSeeObjs))
+	assert.Equal(t, []string{"1,1,1", "2,1,1", "crate"}, seen(w, viewer, atoms.ViewExclusive))
+	viewer.SetVar("sight", types.Int(atoms.SightSeeMobs))
+	assert.Equal(t, []string{"1,1,1", "2,1,1", "guard"}, seen(w, viewer, atoms.ViewExclusive))
+	viewer.SetVar("sight", types.Int(atoms.SightSeeTurfs))
+	assert.Equal(t, []string{"1,1,1", "2,1,1", "3,1,1", "4,1,1", "5,1,1"}, seen(w, viewer, atoms.ViewExclusive))
+	// x-ray vision still does not show atoms that are too invisible
+	w.FindOneType("/obj").SetVar("invisibility", types.Int(1))
+	viewer.SetVar("sight", types.Int(atoms.SightSeeObjs|atoms.SightSeeMobs))
+	assert.Equal(t, []string{"1,1,1", "2,1,1", "guard"}, seen(w, viewer, atoms.ViewExclusive))
+}
+
+func TestViewBlind(t *testing.T) {
+	w := testworld.NewWorld(t, wallMap)
+	viewer := newViewer(w)
+	key := w.Realm().New("/obj", nil, viewer)
+	key.SetVar("name", types.String("key"))
+	viewer.SetVar("sight", types.Int(atoms.SightBlind|atoms.SightSeeTurfs|atoms.SightSeeSelf))
+	// blind viewers can still feel themselves and what they are carrying, but see nothing
+	assert.Equal(t, []string{"viewer", "key"}, seen(w, viewer, atoms.ViewInclusive))
+	assert.Empty(t, seen(w, viewer, atoms.ViewExclusive))
+	assert.Empty(t, seen(w, viewer, atoms.ViewVisual))
+}
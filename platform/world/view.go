@@ -16,6 +16,14 @@ func MaxUint(a uint, b uint) uint {
 	}
 }
 
+func MinUint(a uint, b uint) uint {
+	if a < b {
+		return a
+	} else {
+		return b
+	}
+}
+
 func AbsDiff(a uint, b uint) uint {
 	if a > b {
 		return a - b
@@ -84,10 +92,15 @@ func (w *World) View(distance uint, centerD *types.Datum, mode atoms.ViewMode) [
 }
 
 func (w *World) ViewX(distance uint, center *types.Datum, perspective *types.Datum, mode atoms.ViewMode) []types.Value {
-	locations := w.ViewXLocations(distance, center, perspective)
+	sight := sightOf(perspective)
+	var contents []types.Value
+	if !sight.Blind() {
+		contents = expandWithContents(w.ViewXLocations(distance, center, perspective))
+		contents = w.addXRay(contents, distance, center, perspective, sight)
+		contents = sight.Filter(contents)
+	}
 	switch mode {
 	case atoms.ViewInclusive:
-		contents := expandWithContents(locations)
 		// make sure that 'perspective' and its contents are in the list of found objects
 		// but also that perspective is not in the output more than once
 		if perspective != nil {
@@ -98,15 +111,14 @@ func (w *World) ViewX(distance uint, center *types.Datum, perspective *types.Dat
 		}
 		return contents
 	case atoms.ViewVisual:
-		// include 'perspective' in the list of found objects, but not its contents
-		contents := expandWithContents(locations)
-		if !containsAtom(contents, perspective) {
+		// include 'perspective' in the list of found objects, but not its contents, as long as it can see itself
+		contents = atomsExcept(contents, perspective)
+		if perspective != nil && !sight.Blind() && (sight.CanSee(perspective) || sight.Flags&atoms.SightSeeSelf != 0) {
 			contents = append(contents, perspective)
 		}
 		return contents
 	case atoms.ViewExclusive:
 		// make sure 'perspective' does not get added to the list of contents
-		contents := expandWithContents(locations)
 		contents = atomsExcept(contents, perspective)
 		return contents
 	default:
@@ -114,6 +126,103 @@ func (w *World) ViewX(distance uint, center *types.Datum, perspective *types.Dat
 	}
 }
 
+// what a viewer is able to see, based on mob.sight and mob.see_invisible
+type sight struct {
+	Flags        uint
+	SeeInvisible int
+}
+
+func sightOf(perspective *types.Datum) sight {
+	if perspective != nil && types.IsType(perspective, "/mob") {
+		return sight{
+			Flags:        nonNegative(perspective.Var("sight")),
+			SeeInvisible: types.Unint(perspective.Var("see_invisible")),
+		}
+	}
+	return sight{}
+}
+
+func (s sight) Blind() bool {
+	return s.Flags&atoms.SightBlind != 0
+}
+
+func (s sight) CanSee(atom types.Value) bool {
+	return types.Unint(atom.Var("invisibility")) <= s.SeeInvisible
+}
+
+// removes anything that is too invisible to be seen
+func (s sight) Filter(atoms []types.Value) (out []types.Value) {
+	for _, atom := range atoms {
+		if s.CanSee(atom) {
+			out = append(out, atom)
+		}
+	}
+	return out
+}
+
+// adds the turfs, objs and mobs that the viewer can see regardless of walls and darkness
+func (w *World) addXRay(contents []types.Value, distance uint, center *types.Datum, perspective *types.Datum, s sight) []types.Value {
+	if s.Flags&(atoms.SightSeeTurfs|atoms.SightSeeObjs|atoms.SightSeeMobs) == 0 || center == nil || perspective == nil {
+		return contents
+	}
+	location := turfOf(perspective)
+	if location == nil {
+		return contents
+	}
+	seen := map[types.Value]bool{}
+	for _, atom := range contents {
+		seen[atom] = true
+	}
+	for _, turf := range w.turfsInRange(distance, center, types.Unuint(location.Var("z"))) {
+		for _, atom := range append([]types.Value{turf}, datum.Elements(turf.Var("contents"))...) {
+			if seen[atom] {
+				continue
+			}
+			if (s.Flags&atoms.SightSeeTurfs != 0 && types.IsType(atom, "/turf")) ||
+				(s.Flags&atoms.SightSeeObjs != 0 && types.IsType(atom, "/obj")) ||
+				(s.Flags&atoms.SightSeeMobs != 0 && types.IsType(atom, "/mob")) {
+				contents = append(contents, atom)
+				seen[atom] = true
+			}
+		}
+	}
+	return contents
+}
+
+// the see_in_dark used for anything other than a mob, which matches the default for mobs
+const DefaultSeeInDark = 2
+
+func seeInDark(perspective *types.Datum) uint {
+	if types.IsType(perspective, "/mob") {
+		return nonNegative(perspective.Var("see_in_dark"))
+	}
+	return DefaultSeeInDark
+}
+
+func nonNegative(value types.Value) uint {
+	n := types.Unint(value)
+	if n < 0 {
+		return 0
+	}
+	return uint(n)
+}
+
+func luminosity(atom types.Value) uint {
+	return nonNegative(atom.Var("luminosity"))
+}
+
+// the turf that an atom is standing on, or the atom itself if it is a turf
+func turfOf(atom *types.Datum) types.Value {
+	var location types.Value = atom
+	if !types.IsType(location, "/turf") {
+		location = atom.Var("loc")
+	}
+	if types.IsType(location, "/turf") {
+		return location
+	}
+	return nil
+}
+
 func (w *World) ViewXLocations(distance uint, center *types.Datum, perspective *types.Datum) []types.Value {
 	if center == nil || perspective == nil {
 		return nil
@@ -121,21 +230,10 @@ func (w *World) ViewXLocations(distance uint, center *types.Datum, perspective *
 
 	util.FIXME("include areas")
 
-	var location types.Value = perspective
-	if !types.IsType(location, "/turf") {
-		location = perspective.Var("loc")
-	}
-	if types.IsType(location, "/turf") {
-		tz := types.Unuint(location.Var("z"))
-		turfs := w.FindAll(func(turf *types.Datum) bool {
-			if types.IsType(turf, "/turf") {
-				t2z := types.Unuint(turf.Var("z"))
-				return t2z == tz && ManhattanDistance(turf, center) <= distance
-			}
-			return false
-		})
-		return limitViewers(distance, center, perspective, turfs)
-	} else if location != nil {
+	if location := turfOf(perspective); location != nil {
+		turfs := w.turfsInRange(distance, center, types.Unuint(location.Var("z")))
+		return w.limitViewers(distance, center, perspective, turfs)
+	} else if location := perspective.Var("loc"); location != nil {
 		return []types.Value{
 			location,
 		}
@@ -145,6 +243,7 @@ func (w *World) ViewXLocations(distance uint, center *types.Datum, perspective *
 }
 
 type viewInfo struct {
+	X, Y       int
 	Opaque     bool
 	Luminosity uint
 	Lit        bool
@@ -161,6 +260,7 @@ type viewInfoRegion struct {
 	CornerX, CornerY           int
 	PerspectiveX, PerspectiveY uint
 	Distance                   uint
+	MaxDepthMax, SumDepthMax   int
 }
 
 func newViewInfoRegion(distance uint, centerX, centerY, perspectiveX, perspectiveY uint) viewInfoRegion {
@@ -201,201 +301,204 @@ func (vir *viewInfoRegion) XYToOffset(xu, yu uint) (lx, ly uint) {
 	return uint(rx), uint(ry)
 }
 
-func (vir *viewInfoRegion) PopulateTurfs(input []types.Value) (maxDepthMax, sumDepthMax int) {
-	for _, turf := range input {
-		tx, ty := XY(turf)
-		ox, oy := vir.XYToOffset(tx, ty)
-		if vir.Info[ox][oy] != nil {
-			panic("duplicate turfs for position")
+// adds a single tile to the region; anything that is never added is treated as if it were dark and transparent
+func (vir *viewInfoRegion) AddTile(x, y uint, opaque bool, luminosity uint, lit bool) *viewInfo {
+	ox, oy := vir.XYToOffset(x, y)
+	if vir.Info[ox][oy] != nil {
+		panic("duplicate turfs for position")
+	}
+	dx, dy := AbsDiff(vir.PerspectiveX, x), AbsDiff(vir.PerspectiveY, y)
+	vi := &viewInfo{
+		X:          int(x),
+		Y:          int(y),
+		Opaque:     opaque,
+		Luminosity: luminosity,
+		Lit:        lit,
+		MaxXY:      int(MaxUint(dx, dy)),
+		SumXY:      int(dx + dy),
+	}
+	vir.Info[ox][oy] = vi
+	util.NiceToHave("infrared vision?")
+
+	if vi.MaxXY > vir.MaxDepthMax {
+		vir.MaxDepthMax = vi.MaxXY
+	}
+	if vi.SumXY > vir.SumDepthMax {
+		vir.SumDepthMax = vi.SumXY
+	}
+	return vi
+}
+
+// a tile is opaque if its turf or anything on it is opaque, and it is as luminous as the brightest of them
+func (vir *viewInfoRegion) AddTurf(turf *types.Datum, lit bool) {
+	opaque := types.AsBool(turf.Var("opacity"))
+	lum := luminosity(turf)
+	for _, atom := range datum.Elements(turf.Var("contents")) {
+		if types.AsBool(atom.Var("opacity")) {
+			opaque = true
 		}
-		dx, dy := AbsDiff(vir.PerspectiveX, tx), AbsDiff(vir.PerspectiveY, ty)
-		vi := &viewInfo{
-			Opaque:     types.AsBool(turf.Var("opacity")),
-			Luminosity: 0,
-			Lit:        true,
-			Turf:       turf.(*types.Datum),
-			MaxXY:      int(MaxUint(dx, dy)),
-			SumXY:      int(dx + dy),
-			Vis:        0,
-			Vis2:       0,
+		lum = MaxUint(lum, luminosity(atom))
+	}
+	tx, ty := XY(turf)
+	vir.AddTile(tx, ty, opaque, lum, lit).Turf = turf
+}
+
+func (vir *viewInfoRegion) forEach(f func(info *viewInfo)) {
+	for _, infos := range vir.Info {
+		for _, info := range infos {
+			if info != nil {
+				f(info)
+			}
 		}
-		vir.Info[ox][oy] = vi
-		util.NiceToHave("infrared vision?")
+	}
+}
 
-		if vi.MaxXY > maxDepthMax {
-			maxDepthMax = vi.MaxXY
+// whether a tile has been seen from any of the tiles next to it that are one step closer to the perspective
+func (vir *viewInfoRegion) seenFromNeighbor(info *viewInfo, depth int, vis func(*viewInfo) int) bool {
+	for _, neighborDir := range common.EightDirections {
+		dx, dy := neighborDir.XY()
+		neighbor := vir.InfoAt(info.X+dx, info.Y+dy)
+		if neighbor != nil && vis(neighbor) == depth {
+			return true
 		}
-		if vi.SumXY > sumDepthMax {
-			sumDepthMax = vi.SumXY
+	}
+	return false
+}
+
+func visible(info *viewInfo) bool {
+	return info != nil && info.Vis != 0
+}
+
+func opaque(info *viewInfo) bool {
+	return info != nil && info.Opaque
+}
+
+// an opaque tile that cannot itself be seen is still shown if it sits between two visible tiles, or if it is the
+// corner of a wall whose two visible sides meet at a visible transparent tile
+func (vir *viewInfoRegion) shouldNote(info *viewInfo) bool {
+	x, y := info.X, info.Y
+	east, west := vir.InfoAt(x+1, y), vir.InfoAt(x-1, y)
+	north, south := vir.InfoAt(x, y+1), vir.InfoAt(x, y-1)
+	if (visible(east) && visible(west)) || (visible(north) && visible(south)) {
+		return true
+	}
+	corners := []struct {
+		diagonal, a, b *viewInfo
+	}{
+		{vir.InfoAt(x+1, y+1), east, north},
+		{vir.InfoAt(x-1, y+1), west, north},
+		{vir.InfoAt(x+1, y-1), east, south},
+		{vir.InfoAt(x-1, y-1), west, south},
+	}
+	for _, corner := range corners {
+		if visible(corner.diagonal) && !opaque(corner.diagonal) &&
+			visible(corner.a) && opaque(corner.a) && visible(corner.b) && opaque(corner.b) {
+			return true
 		}
 	}
-	// we let anything else just not get populated, which means Opaque=false, Lit=false, Luminosity=0
-	return maxDepthMax, sumDepthMax
+	return false
 }
 
 // this is an approximate reimplementation of the BYOND algorithm, based on http://www.byond.com/forum/post/2130277#comment20659267
-func limitViewers(distance uint, center *types.Datum, perspective *types.Datum, base []types.Value) []types.Value {
-	centerX, centerY := XY(center)
-	perspectiveX, perspectiveY := XY(perspective)
-	vir := newViewInfoRegion(distance, centerX, centerY, perspectiveX, perspectiveY)
-	maxDepthMax, sumDepthMax := vir.PopulateTurfs(base)
-
+// afterwards, any tile with Vis != 0 can be seen from the perspective
+func (vir *viewInfoRegion) ComputeVisibility(seeInDark uint) {
 	util.NiceToHave("handle blindness")
 	util.NiceToHave("there's something here related to having everything be visible in some circumstances?")
 
 	// diagonal shadow loop
-	for d := 0; d < maxDepthMax; d++ {
-		for _, infos := range vir.Info {
-			for _, info := range infos {
-				if info == nil {
-					continue
-				}
-				tx, ty := XY(info.Turf)
-				if info.MaxXY == d+1 {
-					for _, neighborDir := range common.EightDirections {
-						dx, dy := neighborDir.XY()
-						neighbor := vir.InfoAt(int(tx)+dx, int(ty)+dy)
-						if neighbor != nil && neighbor.Vis2 == d {
-							if info.Opaque {
-								info.Vis2 = -1
-							} else {
-								info.Vis2 = d + 1
-							}
-							break
-						}
-					}
+	for d := 0; d < vir.MaxDepthMax; d++ {
+		vir.forEach(func(info *viewInfo) {
+			if info.MaxXY == d+1 && vir.seenFromNeighbor(info, d, func(n *viewInfo) int { return n.Vis2 }) {
+				if info.Opaque {
+					info.Vis2 = -1
+				} else {
+					info.Vis2 = d + 1
 				}
 			}
-		}
+		})
 	}
 
 	// straight shadow loop
-	for d := 0; d < sumDepthMax; d++ {
-		for _, infos := range vir.Info {
-			for _, info := range infos {
-				if info == nil {
-					continue
-				}
-				tx, ty := XY(info.Turf)
-				if info.SumXY == d+1 {
-					for _, neighborDir := range common.EightDirections {
-						dx, dy := neighborDir.XY()
-						neighbor := vir.InfoAt(int(tx)+dx, int(ty)+dy)
-						if neighbor != nil && neighbor.Vis == d {
-							if info.Opaque {
-								info.Vis = -1
-							} else if info.Vis2 != 0 {
-								info.Vis = d + 1
-							}
-							break
-						}
-					}
+	for d := 0; d < vir.SumDepthMax; d++ {
+		vir.forEach(func(info *viewInfo) {
+			if info.SumXY == d+1 && vir.seenFromNeighbor(info, d, func(n *viewInfo) int { return n.Vis }) {
+				if info.Opaque {
+					info.Vis = -1
+				} else if info.Vis2 != 0 {
+					info.Vis = d + 1
 				}
 			}
-		}
+		})
 	}
 
-	vir.InfoAt(int(vir.PerspectiveX), int(vir.PerspectiveY)).Vis = 1
+	if perspective := vir.InfoAt(int(vir.PerspectiveX), int(vir.PerspectiveY)); perspective != nil {
+		perspective.Vis = 1
+	}
 
+	// light spreads outward from luminous tiles, losing one step of luminosity per tile, and stops at opaque tiles
 	updatedLighting := true
 	for updatedLighting {
 		updatedLighting = false
-		for _, infos := range vir.Info {
-			for _, info := range infos {
-				if info == nil || info.Luminosity == 0 {
-					continue
-				}
-				tx, ty := XY(info.Turf)
-				for _, neighborDir := range common.EightDirections {
-					dx, dy := neighborDir.XY()
-					neighbor := vir.InfoAt(int(tx)+dx, int(ty)+dy)
-					if neighbor == nil {
-						// nothing
-					} else if neighbor.Opaque {
+		vir.forEach(func(info *viewInfo) {
+			if info.Luminosity == 0 {
+				return
+			}
+			for _, neighborDir := range common.EightDirections {
+				dx, dy := neighborDir.XY()
+				neighbor := vir.InfoAt(info.X+dx, info.Y+dy)
+				if neighbor == nil {
+					// nothing
+				} else if neighbor.Opaque {
+					if neighbor.Luminosity == 0 && info.Luminosity > 1 {
 						neighbor.Luminosity = 1
-					} else if neighbor.Luminosity < info.Luminosity-1 {
-						neighbor.Luminosity = info.Luminosity - 1
 						updatedLighting = true
 					}
+				} else if neighbor.Luminosity < info.Luminosity-1 {
+					neighbor.Luminosity = info.Luminosity - 1
+					updatedLighting = true
 				}
 			}
-		}
+		})
 	}
 
 	util.NiceToHave("infrared sight handling: step 7")
 
-	for _, infos := range vir.Info {
-		for _, info := range infos {
-			if info == nil {
-				continue
-			}
-			info.Vis2 = info.Vis
-			if info.Luminosity == 0 && !info.Lit {
-				info.Vis = 0
-			}
+	// dark tiles cannot be seen, unless they are close enough to see in the dark
+	vir.forEach(func(info *viewInfo) {
+		info.Vis2 = info.Vis
+		if info.Luminosity == 0 && !info.Lit && uint(info.MaxXY) >= seeInDark {
+			info.Vis = 0
 		}
-	}
+	})
 
-	for _, infos := range vir.Info {
-		for _, info := range infos {
-			if info == nil {
-				continue
-			}
-			if info.Vis == 0 && info.Opaque {
-				makeANote := false
-
-				txu, tyu := XY(info.Turf)
-				tx, ty := int(txu), int(tyu)
-				east, west := vir.InfoAt(tx+1, ty), vir.InfoAt(tx-1, ty)
-				north, south := vir.InfoAt(tx, ty+1), vir.InfoAt(tx, ty-1)
-				if (east != nil && west != nil && east.Vis != 0 && west.Vis != 0) ||
-					(north != nil && south != nil && north.Vis != 0 && south.Vis != 0) {
-					makeANote = true
-				}
-				ne, nw := vir.InfoAt(tx+1, ty+1), vir.InfoAt(tx-1, ty+1)
-				se, sw := vir.InfoAt(tx+1, ty-1), vir.InfoAt(tx-1, ty-1)
-				if ne != nil && ne.Vis != 0 && east.Vis != 0 && north.Vis != 0 && east.Opaque && north.Opaque && !ne.Opaque {
-					makeANote = true
-				}
-				if nw != nil && nw.Vis != 0 && west.Vis != 0 && north.Vis != 0 && west.Opaque && north.Opaque && !nw.Opaque {
-					makeANote = true
-				}
-				if se != nil && se.Vis != 0 && east.Vis != 0 && south.Vis != 0 && east.Opaque && south.Opaque && !se.Opaque {
-					makeANote = true
-				}
-				if sw != nil && sw.Vis != 0 && west.Vis != 0 && south.Vis != 0 && west.Opaque && south.Opaque && !sw.Opaque {
-					makeANote = true
-				}
-
-				info.Noted = makeANote
-			}
+	vir.forEach(func(info *viewInfo) {
+		if info.Vis == 0 && info.Opaque {
+			info.Noted = vir.shouldNote(info)
 		}
-	}
+	})
 
-	for _, infos := range vir.Info {
-		for _, info := range infos {
-			if info == nil {
-				continue
-			}
-			if info.Noted {
-				info.Vis = -1
-			}
+	vir.forEach(func(info *viewInfo) {
+		if info.Noted {
+			info.Vis = -1
 		}
-	}
+	})
+}
 
-	// at this point, if vis2 != 0, then we have line of sight visibility but not necessarily anything else
+func (w *World) limitViewers(distance uint, center *types.Datum, perspective *types.Datum, base []types.Value) []types.Value {
+	centerX, centerY := XY(center)
+	perspectiveX, perspectiveY := XY(perspective)
+	vir := newViewInfoRegion(distance, centerX, centerY, perspectiveX, perspectiveY)
+	w.updateLighting()
+	for _, turf := range base {
+		vir.AddTurf(turf.(*types.Datum), w.turfLit(turf.(*types.Datum)))
+	}
+	vir.ComputeVisibility(seeInDark(perspective))
 
 	var finalTurfs []types.Value
-
-	for _, infos := range vir.Info {
-		for _, info := range infos {
-			if info == nil {
-				continue
-			}
-			if info.Vis != 0 {
-				finalTurfs = append(finalTurfs, info.Turf)
-			}
+	vir.forEach(func(info *viewInfo) {
+		if info.Vis != 0 {
+			finalTurfs = append(finalTurfs, info.Turf)
 		}
-	}
+	})
 	return finalTurfs
 }
@@ -0,0 +1,100 @@
+package world
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// computes which tiles of a map can be seen from '@', which stands on an open tile. '.' is an open tile, '#' is an
+// opaque tile, ',' is a dark open tile, and '*' is a dark open tile with a luminosity of 3. tiles that cannot be seen
+// are replaced with ' ' in the result.
+func visibleMap(rows []string, seeInDark uint) []string {
+	var px, py uint
+	for y, row := range rows {
+		if x := strings.IndexByte(row, '@'); x >= 0 {
+			px, py = uint(x), uint(len(rows)-1-y)
+		}
+	}
+	distance := uint(len(rows) + len(rows[0]))
+	vir := newViewInfoRegion(distance, px, py, px, py)
+	for y, row := range rows {
+		for x, c := range row {
+			tile := vir.AddTile(uint(x), uint(len(rows)-1-y), c == '#', 0, c != ',' && c != '*')
+			if c == '*' {
+				tile.Luminosity = 3
+			}
+		}
+	}
+	vir.ComputeVisibility(seeInDark)
+	var result []string
+	for y, row := range rows {
+		out := []byte(row)
+		for x := range row {
+			if vir.InfoAt(x, len(rows)-1-y).Vis == 0 {
+				out[x] = ' '
+			}
+		}
+		result = append(result, string(out))
+	}
+	return result
+}
+
+func TestViewWallsBlockSight(t *testing.T) {
+	assert.Equal(t, []string{
+		"       ",
+		".     .",
+		"..###..",
+		"...@...",
+		".......",
+	}, visibleMap([]string{
+		".......",
+		".......",
+		"..###..",
+		"...@...",
+		".......",
+	}, 0))
+}
+
+func TestViewThroughDoorway(t *testing.T) {
+	assert.Equal(t, []string{
+		"###.###",
+		"#.....#",
+		"#..@..#",
+		"#.....#",
+		"###.###",
+		"  ...  ",
+	}, visibleMap([]string{
+		"###.###",
+		"#.....#",
+		"#..@..#",
+		"#.....#",
+		"###.###",
+		".......",
+	}, 0))
+}
+
+func TestViewDarkness(t *testing.T) {
+	dark := []string{
+		",,,,,,,,,",
+		",,,,,,,,,",
+		",,,,@,,,,",
+		",,,,,,,,,",
+		",*,,,,,,,",
+	}
+	// the lamp lights everything within two tiles of it
+	assert.Equal(t, []string{
+		"         ",
+		"         ",
+		",,,,@    ",
+		",,,,     ",
+		",*,,     ",
+	}, visibleMap(dark, 0))
+	assert.Equal(t, []string{
+		"         ",
+		"   ,,,   ",
+		",,,,@,   ",
+		",,,,,,   ",
+		",*,,     ",
+	}, visibleMap(dark, 2))
+}
@@ -14,6 +14,8 @@ type World struct {
 	Name     string
 	Mob      types.TypePath
 	ViewDist uint
+	// the size of each tile, in pixels; icons of other sizes are drawn from the bottom-left corner of their tile
+	IconWidth, IconHeight uint
 
 	defaultLazyEye uint
 
@@ -21,8 +23,16 @@ type World struct {
 
 	realm     *types.Realm
 	iconCache *icon.IconCache
+	lighting  *lightingEngine
+	grid      *turfGrid
 
 	clients map[*types.Datum]*types.Ref
+	// the farthest that the verbs of each type can be used from, by view(N) or oview(N)
+	verbReaches map[types.TypePath]uint
+
+	// the proc that is running right now, if it was started by RunProc
+	running      *util.Coroutine
+	lastPromptID uint64
 
 	// true if this instance has an API associated with it
 	// we never provide more than one API so that we avoid double-threading
@@ -38,11 +48,10 @@ func (w World) MaxXYZ() (uint, uint, uint) {
 	return w.MaxX, w.MaxY, w.MaxZ
 }
 
+// expands the world to be at least the specified size; the world never shrinks
 func (w *World) SetMaxXYZ(x, y, z uint) {
-	if w.MaxX != 0 || w.MaxY != 0 || w.MaxZ != 0 {
-		panic("world map's size was already populated")
-	}
-	w.MaxX, w.MaxY, w.MaxZ = x, y, z
+	util.NiceToHave("support shrinking the world, which deletes the turfs outside of the new bounds")
+	w.MaxX, w.MaxY, w.MaxZ = MaxUint(w.MaxX, x), MaxUint(w.MaxY, y), MaxUint(w.MaxZ, z)
 }
 
 func (w *World) Realm() *types.Realm {
@@ -50,27 +59,19 @@ func (w *World) Realm() *types.Realm {
 }
 
 func (w *World) FindAll(predicate func(*types.Datum) bool) []types.Value {
-	return w.Realm().FindAll(func(d *types.Datum) bool {
-		return types.IsType(d, "/atom") && (predicate == nil || predicate(d))
-	})
+	return w.Realm().FindAllType("/atom", predicate)
 }
 
 func (w *World) FindAllType(tp types.TypePath) []types.Value {
-	return w.Realm().FindAll(func(datum *types.Datum) bool {
-		return types.IsType(datum, tp)
-	})
+	return w.Realm().FindAllType(tp, nil)
 }
 
 func (w *World) FindOne(predicate func(*types.Datum) bool) types.Value {
-	return w.Realm().FindOne(func(datum *types.Datum) bool {
-		return types.IsType(datum, "/atom") && predicate(datum)
-	})
+	return w.Realm().FindOneType("/atom", predicate)
 }
 
 func (w *World) FindOneType(tp types.TypePath) types.Value {
-	return w.Realm().FindOne(func(datum *types.Datum) bool {
-		return types.IsType(datum, tp)
-	})
+	return w.Realm().FindOneType(tp, nil)
 }
 
 func (w *World) CreateNewPlayer(key string) *types.Datum {
@@ -81,7 +82,10 @@ func (w *World) CreateNewPlayer(key string) *types.Datum {
 		client.SetVar("view", types.Int(w.ViewDist))
 	}
 	w.clients[client] = types.Reference(client)
-	client.Invoke(nil, "New", w.findExistingMob(key))
+	// Login() may want to ask the player questions
+	w.RunProc(func() {
+		client.Invoke(nil, "New", w.findExistingMob(key))
+	})
 	return client
 }
 
@@ -106,19 +110,6 @@ func (w *World) ServerAPI() websession.WorldAPI {
 	}
 }
 
-func (w *World) LocateXYZ(x, y, z uint) types.Value {
-	util.FIXME("this can definitely be more efficient")
-	return w.FindOne(func(atom *types.Datum) bool {
-		if types.IsType(atom, "/turf") {
-			tx, ty, tz := XYZ(atom)
-			if tx == x && ty == y && tz == z {
-				return true
-			}
-		}
-		return false
-	})
-}
-
 func (w *World) UpdateDefaultViewDistance() {
 	// if the map is <= 21x21, adjust view to fit the whole thing
 	if w.MaxX > 0 && w.MaxY > 0 && w.MaxX <= 21 && w.MaxY <= 21 {
@@ -132,9 +123,17 @@ func (w *World) Icon(name string) *icon.Icon {
 	return w.iconCache.LoadOrPanic(name)
 }
 
+func (w *World) LoadIcon(name string) (*icon.Icon, bool) {
+	return w.iconCache.Load(name)
+}
+
+func (w *World) RegisterIcon(data []byte) (*icon.Icon, error) {
+	return w.iconCache.RegisterData(data)
+}
+
 func (w *World) Flick(icon *icon.Icon, iconState string, target types.Value) {
 	appearance := target.Var("appearance").(atoms.Appearance)
-	appearance.Icon = icon
+	appearance.Icon = icon.Resource()
 	appearance.IconState = iconState
 	ok, _, s := appearance.ToSprite(0, 0, target.Var("dir").(common.Direction))
 	if !ok {
@@ -143,6 +142,7 @@ func (w *World) Flick(icon *icon.Icon, iconState string, target types.Value) {
 	flick := sprite.Flick{
 		Icon:         s.Icon,
 		Frames:       s.Frames,
+		Animation:    s.Animation,
 		SourceWidth:  s.SourceWidth,
 		SourceHeight: s.SourceHeight,
 		UID:          target.(*types.Datum).UID(),
@@ -157,9 +157,14 @@ func NewWorld(realm *types.Realm, cache *icon.IconCache) *World {
 		Name:          "Untitled",
 		Mob:           "/mob",
 		ViewDist:      5,
+		IconWidth:     32,
+		IconHeight:    32,
 		realm:         realm,
 		iconCache:     cache,
+		lighting:      newLightingEngine(),
+		grid:          newTurfGrid(),
 		clients:       map[*types.Datum]*types.Ref{},
+		verbReaches:   map[types.TypePath]uint{},
 		claimed:       false,
 		setVirtualEye: false,
 	}
@@ -0,0 +1,144 @@
+package worldmap
+
+import (
+	"fmt"
+	"github.com/celskeggs/mediator/common"
+	"github.com/celskeggs/mediator/parsemap"
+	"github.com/celskeggs/mediator/platform/atoms"
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/icon"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/util"
+	"io/ioutil"
+)
+
+// vars that a map expresses through its layout, rather than through overrides
+var layoutVars = map[string]bool{
+	"loc":      true,
+	"contents": true,
+	"x":        true,
+	"y":        true,
+	"z":        true,
+}
+
+// the inverse of convertValue; values that cannot be written in a map, like datums and verbs, are not exportable
+func exportValue(value types.Value) (parsemap.Value, bool) {
+	switch v := value.(type) {
+	case nil:
+		return parsemap.Value{Kind: parsemap.ValueNull}, true
+	case types.Int:
+		return parsemap.Value{Kind: parsemap.ValueNumber, Number: float64(v)}, true
+	case types.Float:
+		return parsemap.Value{Kind: parsemap.ValueNumber, Number: float64(v)}, true
+	case common.Direction:
+		return parsemap.Value{Kind: parsemap.ValueNumber, Number: float64(v)}, true
+	case types.String:
+		return parsemap.Value{Kind: parsemap.ValueString, Str: string(v)}, true
+	case types.TypePath:
+		return parsemap.Value{Kind: parsemap.ValuePath, Str: string(v)}, true
+	case *icon.Icon:
+		if v == nil {
+			return parsemap.Value{Kind: parsemap.ValueNull}, true
+		}
+		return parsemap.Value{Kind: parsemap.ValueResource, Str: v.Name()}, true
+	case datum.List:
+		list := parsemap.Value{Kind: parsemap.ValueList, List: []parsemap.ListEntry{}}
+		assoc, isAssoc := v.ListProvider.(*datum.AssocList)
+		for _, element := range datum.Elements(v) {
+			exported, ok := exportValue(element)
+			if !ok {
+				return parsemap.Value{}, false
+			}
+			entry := parsemap.ListEntry{Value: exported}
+			if isAssoc {
+				if associated := assoc.Association(element); associated != nil {
+					entry.Value, ok = exportValue(associated)
+					if !ok {
+						return parsemap.Value{}, false
+					}
+					entry.HasKey, entry.Key = true, exported
+				}
+			}
+			list.List = append(list.List, entry)
+		}
+		return list, true
+	default:
+		return parsemap.Value{}, false
+	}
+}
+
+// keeps one freshly-created instance of each type during an export, for comparing the vars of atoms against
+type prototypes map[types.TypePath]*types.Datum
+
+func (p prototypes) of(atom *types.Datum) *types.Datum {
+	prototype, found := p[atom.Type()]
+	if !found {
+		prototype = atom.Realm().NewPlain(atom.Type())
+		p[atom.Type()] = prototype
+	}
+	return prototype
+}
+
+// describes an atom as its type plus overrides for each var that differs from a freshly-created instance
+func (p prototypes) exportInstance(atom *types.Datum) parsemap.Instance {
+	instance := parsemap.NewInstance(string(atom.Type()))
+	if types.IsType(atom, "/area") {
+		util.NiceToHave("export overrides on areas, which are singletons and so have no separate prototype")
+		return instance
+	}
+	prototype := p.of(atom)
+	for _, info := range types.UnpackDatum(atom).Vars() {
+		if info.ReadOnly || layoutVars[info.Name] {
+			continue
+		}
+		value, ok := exportValue(atom.Var(info.Name))
+		if !ok {
+			continue
+		}
+		initial, ok := exportValue(prototype.Var(info.Name))
+		if ok && initial.String() == value.String() {
+			continue
+		}
+		instance.Vars = append(instance.Vars, parsemap.VarOverride{Name: info.Name, Value: value})
+	}
+	return instance
+}
+
+// snapshots the turfs, areas and objs on a z-level of the world into a single-level map
+func ExportMap(world atoms.World, z uint, format parsemap.Format) (*parsemap.RawMap, error) {
+	maxX, maxY, maxZ := world.MaxXYZ()
+	if z < 1 || z > maxZ {
+		return nil, fmt.Errorf("no such z-level %d", z)
+	}
+	p := prototypes{}
+	cells := make([][][][]parsemap.Instance, maxX)
+	for x := uint(0); x < maxX; x++ {
+		cells[x] = make([][][]parsemap.Instance, maxY)
+		for y := uint(0); y < maxY; y++ {
+			turf := world.LocateXYZ(x+1, y+1, z)
+			if turf == nil {
+				return nil, fmt.Errorf("no turf at (%d,%d,%d)", x+1, y+1, z)
+			}
+			var instances []parsemap.Instance
+			for _, content := range datum.Elements(turf.Var("contents")) {
+				if types.IsType(content, "/obj") {
+					instances = append(instances, p.exportInstance(content.(*types.Datum)))
+				}
+			}
+			instances = append(instances, p.exportInstance(turf.(*types.Datum)))
+			if area, ok := turf.Var("loc").(*types.Datum); ok {
+				instances = append(instances, p.exportInstance(area))
+			}
+			cells[x][y] = [][]parsemap.Instance{instances}
+		}
+	}
+	return parsemap.NewRawMap(format, cells), nil
+}
+
+func SaveMapToFile(world atoms.World, z uint, format parsemap.Format, filename string) error {
+	raw, err := ExportMap(world, z, format)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, []byte(parsemap.WriteMap(raw)), 0644)
+}
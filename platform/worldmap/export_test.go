@@ -0,0 +1,58 @@
+package worldmap_test
+
+import (
+	"github.com/celskeggs/mediator/parsemap"
+	"github.com/celskeggs/mediator/platform/datum"
+	"github.com/celskeggs/mediator/platform/testworld"
+	"github.com/celskeggs/mediator/platform/types"
+	"github.com/celskeggs/mediator/platform/worldmap"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const storeroomMap = `"a" = (/turf,/area)
+"b" = (/obj{name = "crate"; density = 1; pixel_x = 2.5},/obj{name = "barrel"},/turf{name = "floor"},/area)
+
+(1,1,1) = {"
+ab
+"}
+`
+
+// describes the objs on a turf by their names and descriptions
+func describeObjs(turf types.Value) (out []string) {
+	for _, obj := range datum.Elements(turf.Var("contents")) {
+		out = append(out, types.Unstring(obj.Var("name"))+": "+types.Unstring(obj.Var("desc")))
+	}
+	return out
+}
+
+func TestExportMap(t *testing.T) {
+	w := testworld.NewWorld(t, storeroomMap)
+	crate := datum.Elements(w.LocateXYZ(2, 1, 1).Var("contents"))[0]
+	// a var changed back to its initial value is no longer an override
+	crate.SetVar("density", types.Int(0))
+	crate.SetVar("desc", types.String("dusty"))
+	w.LocateXYZ(1, 1, 1).SetVar("name", types.String("doorway"))
+
+	raw, err := worldmap.ExportMap(w, 1, parsemap.FormatDMM)
+	assert.NoError(t, err)
+	text := parsemap.WriteMap(raw)
+	assert.Equal(t, `"a" = (/turf{name = "doorway"},/area)
+"b" = (/obj{desc = "dusty"; name = "crate"; pixel_x = 3},/obj{name = "barrel"},/turf{name = "floor"},/area)
+
+(1,1,1) = {"
+ab
+"}
+`, text)
+
+	reloaded := testworld.NewWorld(t, text)
+	assert.Equal(t, types.String("doorway"), reloaded.LocateXYZ(1, 1, 1).Var("name"))
+	assert.Equal(t, types.String("floor"), reloaded.LocateXYZ(2, 1, 1).Var("name"))
+	assert.Equal(t, []string{"crate: dusty", "barrel: "}, describeObjs(reloaded.LocateXYZ(2, 1, 1)))
+	reloadedCrate := datum.Elements(reloaded.LocateXYZ(2, 1, 1).Var("contents"))[0]
+	assert.Equal(t, types.Int(0), reloadedCrate.Var("density"))
+	assert.Equal(t, types.Int(3), reloadedCrate.Var("pixel_x"))
+
+	_, err = worldmap.ExportMap(w, 2, parsemap.FormatDMM)
+	assert.Error(t, err)
+}
@@ -1,13 +1,16 @@
 package worldmap
 
 import (
-	"errors"
+	"fmt"
 	"github.com/celskeggs/mediator/parsemap"
 	"github.com/celskeggs/mediator/platform/atoms"
+	"github.com/celskeggs/mediator/platform/datum"
 	"github.com/celskeggs/mediator/platform/types"
 	"github.com/celskeggs/mediator/resourcepack"
 	"github.com/celskeggs/mediator/util"
+	"github.com/pkg/errors"
 	"io/ioutil"
+	"strings"
 )
 
 type mapCell struct {
@@ -56,6 +59,8 @@ type worldMap [][][]mapCell
 type loaderObserver struct {
 	world atoms.World
 	m     worldMap
+	// the number of z-levels already loaded below this map
+	zOffset uint
 }
 
 func (lo *loaderObserver) SetSize(l parsemap.Location) {
@@ -64,15 +69,85 @@ func (lo *loaderObserver) SetSize(l parsemap.Location) {
 		lo.m[x] = make([][]mapCell, l.Y)
 		for y := uint32(0); y < l.Y; y++ {
 			lo.m[x][y] = make([]mapCell, l.Z)
-			for z := uint32(0); z < l.Z; z++ {
-				lo.m[x][z][z] = mapCell{}
+		}
+	}
+}
+
+func (lo *loaderObserver) Size() (x, y, z uint) {
+	return uint(len(lo.m)), uint(len(lo.m[0])), uint(len(lo.m[0][0]))
+}
+
+func convertValue(world atoms.World, value parsemap.Value) (types.Value, error) {
+	switch value.Kind {
+	case parsemap.ValueNull:
+		return nil, nil
+	case parsemap.ValueNumber:
+		return types.Number(value.Number), nil
+	case parsemap.ValueString:
+		return types.String(value.Str), nil
+	case parsemap.ValuePath:
+		return types.TypePath(value.Str), nil
+	case parsemap.ValueResource:
+		if !strings.HasSuffix(value.Str, ".dmi") {
+			util.FIXME("support non-icon resources in map overrides")
+			return nil, fmt.Errorf("resource %q not supported: only icons can be referenced from maps", value.Str)
+		}
+		return world.Icon(value.Str), nil
+	case parsemap.ValueList:
+		elements := make([]types.Value, len(value.List))
+		keyed := false
+		for i, entry := range value.List {
+			element, err := convertValue(world, entry.Value)
+			if err != nil {
+				return nil, err
 			}
+			elements[i] = element
+			keyed = keyed || entry.HasKey
 		}
+		if !keyed {
+			return datum.NewList(elements...), nil
+		}
+		// in an associative list like list("a" = 1, "b"), the keys are the elements, and "b" has no associated value
+		list := datum.NewAssocList()
+		for i, entry := range value.List {
+			if !entry.HasKey {
+				list.Associate(elements[i], nil)
+				continue
+			}
+			key, err := convertValue(world, entry.Key)
+			if err != nil {
+				return nil, err
+			}
+			if _, isNumber := key.(types.Int); isNumber || key == nil {
+				return nil, fmt.Errorf("%v cannot be used as a key in an associative list", entry.Key)
+			}
+			list.Associate(key, elements[i])
+		}
+		return datum.List{ListProvider: list}, nil
+	default:
+		panic("unknown map value kind")
 	}
 }
 
-func (lo *loaderObserver) AddAtom(l parsemap.Location, path string) error {
-	atom := lo.world.Realm().New(types.TypePath(path), nil)
+// creates the atom, applying the map's overrides before New() runs, as DreamMaker does
+func (lo *loaderObserver) AddAtom(l parsemap.Location, instance parsemap.Instance) error {
+	atom := lo.world.Realm().NewPlain(types.TypePath(instance.Path))
+	for _, override := range instance.Vars {
+		value, err := convertValue(lo.world, override.Value)
+		if err != nil {
+			return errors.Wrapf(err, "while converting override of %s on %s", override.Name, instance.Path)
+		}
+		switch types.UnpackDatum(atom).SetVar(atom, override.Name, value) {
+		case types.SetResultOk:
+		case types.SetResultNonexistent:
+			return fmt.Errorf("no such variable %s on %s", override.Name, instance.Path)
+		case types.SetResultReadOnly:
+			return fmt.Errorf("variable %s on %s is read-only", override.Name, instance.Path)
+		default:
+			panic("invalid result type")
+		}
+	}
+	atom.Invoke(nil, "New")
 	return lo.m[l.X][l.Y][l.Z].Add(atom)
 }
 
@@ -82,23 +157,35 @@ func (lo *loaderObserver) StitchMap() {
 		for y := 0; y < len(lo.m[x]); y++ {
 			for z := 0; z < len(lo.m[x][y]); z++ {
 				cell := lo.m[x][y][z]
-				cell.Stitch(uint(x+1), uint(y+1), uint(z+1))
+				cell.Stitch(uint(x+1), uint(y+1), lo.zOffset+uint(z+1))
 			}
 		}
 	}
 }
 
+// loads a single map on top of any z-levels that have already been loaded
 func LoadMap(world atoms.World, text string) error {
-	l := loaderObserver{
-		world: world,
-	}
-	err := parsemap.ProduceMap(text, &l)
-	if err != nil {
-		return err
+	return LoadMaps(world, text)
+}
+
+// loads each map as a consecutive set of z-levels, stacked on top of any z-levels that have already been loaded
+func LoadMaps(world atoms.World, texts ...string) error {
+	_, _, zOffset := world.MaxXYZ()
+	for _, text := range texts {
+		l := loaderObserver{
+			world:   world,
+			zOffset: zOffset,
+		}
+		err := parsemap.ProduceMap(text, &l)
+		if err != nil {
+			return err
+		}
+		l.StitchMap()
+		util.NiceToHave("handle changing this both at compile time and at runtime")
+		maxX, maxY, maxZ := l.Size()
+		zOffset += maxZ
+		world.SetMaxXYZ(maxX, maxY, zOffset)
 	}
-	l.StitchMap()
-	util.NiceToHave("handle changing this both at compile time and at runtime")
-	world.SetMaxXYZ(uint(len(l.m)), uint(len(l.m[0])), uint(len(l.m[0][0])))
 	return nil
 }
 
@@ -111,9 +198,17 @@ func LoadMapFromFile(world atoms.World, filename string) error {
 }
 
 func LoadMapFromPack(world atoms.World, pack *resourcepack.ResourcePack, name string) error {
-	content, err := pack.Resource(name)
-	if err != nil {
-		return err
+	return LoadMapsFromPack(world, pack, name)
+}
+
+func LoadMapsFromPack(world atoms.World, pack *resourcepack.ResourcePack, names ...string) error {
+	texts := make([]string, len(names))
+	for i, name := range names {
+		content, err := pack.Resource(name)
+		if err != nil {
+			return err
+		}
+		texts[i] = string(content.Data)
 	}
-	return LoadMap(world, string(content.Data))
+	return LoadMaps(world, texts...)
 }
@@ -0,0 +1,127 @@
+"use strict";
+
+/* BrowserWindows shows the HTML sent by browse() in popup windows within the page, which can be dragged around and
+ * closed. A window with the same name as an open window replaces its contents.
+ * The public interface:
+ *  - addResource(name, url)
+ *  - open(name, html, width, height)
+ *  - close(name)
+ *  - closeAll()
+ * The events to be overridden by the user:
+ *  - ontopic(query)
+ */
+
+const DEFAULT_BROWSER_WIDTH = 400;
+const DEFAULT_BROWSER_HEIGHT = 300;
+// each new window is placed a little below and to the right of the last one, so that none are completely hidden
+const BROWSER_CASCADE = 24;
+
+function BrowserWindows(container) {
+    this.container = container;
+    this.windows = {};
+    this.resources = {};
+    this.cascade = 0;
+    this.topZ = 0;
+    this.ontopic = null;
+}
+
+BrowserWindows.prototype.addResource = function (name, url) {
+    this.resources[name] = url;
+};
+
+BrowserWindows.prototype.open = function (name, html, width, height) {
+    let popup = this.windows[name];
+    if (popup === undefined) {
+        popup = this.create(name, width || DEFAULT_BROWSER_WIDTH, height || DEFAULT_BROWSER_HEIGHT);
+        this.windows[name] = popup;
+    } else if (width && height) {
+        popup.div.style.width = width + "px";
+        popup.div.style.height = height + "px";
+    }
+    const browser = this;
+    popup.title.textContent = titleOfHTML(html) || name || "Browser";
+    popup.body.textContent = "";
+    popup.body.appendChild(sanitizeHTML(html, function (query) {
+        if (browser.ontopic !== null) {
+            browser.ontopic(query);
+        }
+    }, this.resources));
+    popup.body.scrollTop = 0;
+    this.raise(popup);
+};
+
+BrowserWindows.prototype.close = function (name) {
+    const popup = this.windows[name];
+    if (popup !== undefined) {
+        this.container.removeChild(popup.div);
+        delete this.windows[name];
+    }
+};
+
+BrowserWindows.prototype.closeAll = function () {
+    for (const name in this.windows) {
+        if (this.windows.hasOwnProperty(name)) {
+            this.close(name);
+        }
+    }
+};
+
+BrowserWindows.prototype.raise = function (popup) {
+    this.topZ++;
+    popup.div.style.zIndex = 10 + this.topZ;
+};
+
+BrowserWindows.prototype.create = function (name, width, height) {
+    const browser = this;
+    const popup = {
+        div: document.createElement("div"),
+        title: document.createElement("span"),
+        body: document.createElement("div"),
+    };
+    popup.div.classList.add("browser");
+    popup.div.style.width = width + "px";
+    popup.div.style.height = height + "px";
+    const offset = 40 + BROWSER_CASCADE * this.cascade;
+    this.cascade = (this.cascade + 1) % 8;
+    popup.div.style.left = offset + "px";
+    popup.div.style.top = offset + "px";
+
+    const titlebar = document.createElement("div");
+    titlebar.classList.add("browsertitle");
+    titlebar.appendChild(popup.title);
+    const closeButton = document.createElement("button");
+    closeButton.textContent = "×";
+    closeButton.title = "Close";
+    closeButton.addEventListener("click", function () {
+        browser.close(name);
+    });
+    titlebar.appendChild(closeButton);
+    popup.div.appendChild(titlebar);
+
+    popup.body.classList.add("browserbody");
+    popup.div.appendChild(popup.body);
+
+    popup.div.addEventListener("mousedown", function () {
+        browser.raise(popup);
+    });
+    titlebar.addEventListener("mousedown", function (ev) {
+        if (ev.target === closeButton) {
+            return;
+        }
+        ev.preventDefault();
+        const startX = ev.clientX - popup.div.offsetLeft, startY = ev.clientY - popup.div.offsetTop;
+        function drag(ev) {
+            popup.div.style.left = Math.max(0, ev.clientX - startX) + "px";
+            popup.div.style.top = Math.max(0, ev.clientY - startY) + "px";
+        }
+        function drop() {
+            document.removeEventListener("mousemove", drag);
+            document.removeEventListener("mouseup", drop);
+        }
+        document.addEventListener("mousemove", drag);
+        document.addEventListener("mouseup", drop);
+    });
+
+    this.container.appendChild(popup.div);
+    return popup;
+};
@@ -8,15 +8,40 @@ function Canvas(canvas, imageLoader) {
     this.width = this.height = 100;
     this.aspectShiftX = this.aspectShiftY = 0;
     this.scaleFactor = 1;
-    this.frameID = 1;
     this.gameSprites = [];
+    this.lightMap = null;
     this.animationInfo = {};
 }
 
-Canvas.prototype.updateSprites = function (sprites) {
+// sprites are drawn in order of layer, then grouped by atom, in the order of each atom's underlays, itself and overlays
+function drawsBefore(a, b) {
+    return (a.layer - b.layer) || (a.uid - b.uid) || ((a.seq || 0) - (b.seq || 0));
+}
+
+// each distinct look is only sent once, so it needs to be copied into every sprite that refers to it. likewise,
+// underlays and overlays are only sent with names and verbs when the atom that they are attached to has no sprite.
+Canvas.prototype.updateView = function (view) {
+    const sprites = [];
+    for (const key in view.sprites) {
+        const sent = view.sprites[key];
+        const sprite = Object.assign({}, view.looks[sent.look], sent);
+        sprite.x = sent.x - view.origin.x;
+        sprite.y = sent.y - view.origin.y;
+        const main = view.sprites[spriteKey(sent.uid, 0)];
+        if (sprite.part && main) {
+            sprite.name = main.name;
+            sprite.verbs = main.verbs;
+        }
+        sprites.push(sprite);
+    }
+    sprites.sort(drawsBefore);
     this.gameSprites = sprites;
 };
 
+Canvas.prototype.updateLights = function (lightMap) {
+    this.lightMap = lightMap;
+};
+
 Canvas.prototype.startRender = function (fill) {
     const rect = this.canvas.getBoundingClientRect();
     this.canvas.width = rect.width;
@@ -37,8 +62,8 @@ Canvas.prototype.renderLoading = function (message) {
     ctx.fillText(message, this.canvas.width / 2, this.canvas.height / 2);
 };
 
-Canvas.prototype.prepareRenderImage = function (sprite, animationInfo, frameID) {
-    const info = this.imageLoader.prepareImage(sprite, animationInfo, frameID);
+Canvas.prototype.prepareRenderImage = function (sprite, animationInfo, now) {
+    const info = this.imageLoader.prepareImage(sprite, animationInfo, now);
     if (!info) {
         return null;
     }
@@ -55,22 +80,79 @@ Canvas.prototype.prepareRenderImage = function (sprite, animationInfo, frameID)
     return info;
 };
 
+// the canvas operations that approximate each of DM's blend modes
+const BLEND_OPERATIONS = ["source-over", "source-over", "lighter", "difference", "multiply", "source-atop"];
+
+Canvas.prototype.drawSprite = function (ctx, sprite, info) {
+    ctx.save();
+    ctx.globalAlpha = (sprite.alpha === undefined ? 255 : sprite.alpha) / 255;
+    ctx.globalCompositeOperation = BLEND_OPERATIONS[sprite.blend || 0] || "source-over";
+    if (sprite.transform) {
+        // DM transforms are applied around the center of the icon, with y pointing up rather than down
+        const t = sprite.transform;
+        ctx.translate(info.dx + info.dw / 2, info.dy + info.dh / 2);
+        ctx.transform(t[0], -t[3], -t[1], t[4], t[2] * this.scaleFactor, -t[5] * this.scaleFactor);
+        ctx.drawImage(info.img,
+            info.sx, info.sy, info.sw, info.sh,
+            -info.dw / 2, -info.dh / 2, info.dw, info.dh);
+    } else {
+        ctx.drawImage(info.img,
+            info.sx, info.sy, info.sw, info.sh,
+            info.dx, info.dy, info.dw, info.dh);
+    }
+    ctx.restore();
+};
+
 Canvas.prototype.renderGame = function () {
     const ctx = this.startRender('rgb(0,0,0)');
     ctx.imageSmoothingEnabled = false;
+    // animations are timed by the clock, rather than by how often we happen to be drawn
+    const now = performance.now() / TICK_LENGTH;
+    // icons larger than a tile may extend past the edge of the viewport, so keep them out of the margins
+    ctx.save();
+    ctx.beginPath();
+    ctx.rect(this.aspectShiftX, this.canvas.height - this.aspectShiftY - this.viewHeight * this.scaleFactor,
+        this.viewWidth * this.scaleFactor, this.viewHeight * this.scaleFactor);
+    ctx.clip();
     for (let i = 0; i < this.gameSprites.length; i++) {
         const sprite = this.gameSprites[i];
         if (sprite.icon && sprite.x !== undefined && sprite.y !== undefined) {
-            const info = this.prepareRenderImage(sprite, this.animationInfo, this.frameID);
+            const info = this.prepareRenderImage(sprite, this.animationInfo, now);
             if (!info) {
                 continue;
             }
-            ctx.drawImage(info.img,
-                info.sx, info.sy, info.sw, info.sh,
-                info.dx, info.dy, info.dw, info.dh);
+            this.drawSprite(ctx, sprite, info);
         }
     }
-    this.frameID += 1;
+    this.drawLights(ctx);
+    ctx.restore();
+};
+
+// shades each tile by multiplying its light level into everything that was drawn there
+Canvas.prototype.drawLights = function (ctx) {
+    const lights = this.lightMap;
+    if (!lights) {
+        return;
+    }
+    const tileWidth = this.viewWidth / lights.width, tileHeight = this.viewHeight / lights.height;
+    ctx.save();
+    ctx.globalCompositeOperation = "multiply";
+    for (let y = 0; y < lights.height; y++) {
+        // rounded the same way as sprites, so that tiles line up with what was drawn on them
+        const ly = Math.round(this.canvas.height - this.aspectShiftY - (y + 1) * tileHeight * this.scaleFactor);
+        const hy = Math.round(this.canvas.height - this.aspectShiftY - y * tileHeight * this.scaleFactor);
+        for (let x = 0; x < lights.width; x++) {
+            const level = lights.levels[y * lights.width + x];
+            if (level === "#ffffff") {
+                continue;
+            }
+            const lx = Math.round(this.aspectShiftX + x * tileWidth * this.scaleFactor);
+            const hx = Math.round(this.aspectShiftX + (x + 1) * tileWidth * this.scaleFactor);
+            ctx.fillStyle = level;
+            ctx.fillRect(lx, ly, hx - lx, hy - ly);
+        }
+    }
+    ctx.restore();
 };
 
 Canvas.prototype.applyFlick = function (flick) {
@@ -80,18 +162,51 @@ Canvas.prototype.applyFlick = function (flick) {
 Canvas.prototype.findSprites = function (ev) {
     const pos = this.getMousePosition(ev);
     const sprites = [];
+    // overlays and underlays stand in for the atom that they are attached to, which is only listed once
+    const found = {};
     for (let i = 0; i < this.gameSprites.length; i++) {
         const sprite = this.gameSprites[i];
-        if (sprite.icon && sprite.x !== undefined && sprite.y !== undefined) {
+        // mouse_opacity 0 sprites cannot be clicked, and mouse_opacity 1 sprites can only be clicked where they are visible
+        const mouseOpacity = sprite.mouseopacity === undefined ? 1 : sprite.mouseopacity;
+        if (sprite.icon && sprite.x !== undefined && sprite.y !== undefined && mouseOpacity > 0) {
             const info = this.prepareRenderImage(sprite, null, null);
             if (info && pos.x >= info.dx && pos.y >= info.dy && pos.x < info.dx + info.dw && pos.y < info.dy + info.dh) {
-                sprites.push(sprite);
+                if (mouseOpacity === 2 || this.imageLoader.isOpaqueAt(sprite, info, pos.x - info.dx, pos.y - info.dy)) {
+                    if (found[sprite.uid] === undefined) {
+                        found[sprite.uid] = sprites.length;
+                        sprites.push(sprite);
+                    } else if (!sprite.part) {
+                        sprites[found[sprite.uid]] = sprite;
+                    }
+                }
             }
         }
     }
     return sprites;
 };
 
+// where the mouse is, as the server wants to hear about it: the pixel within the map, and the topmost atom under the
+// mouse along with the pixel within its icon. pixels count from the bottom left, as they do in DM. null if the mouse
+// is outside of the map.
+Canvas.prototype.findMouseTarget = function (ev) {
+    const pos = this.getMousePosition(ev);
+    const screenX = Math.floor((pos.x - this.aspectShiftX) / this.scaleFactor);
+    const screenY = Math.floor((this.canvas.height - this.aspectShiftY - pos.y) / this.scaleFactor);
+    if (screenX < 0 || screenY < 0 || screenX >= this.viewWidth || screenY >= this.viewHeight) {
+        return null;
+    }
+    const target = {"screenx": screenX, "screeny": screenY, "target": 0};
+    const sprites = this.findSprites(ev);
+    if (sprites.length > 0) {
+        const sprite = sprites[sprites.length - 1];
+        const info = this.prepareRenderImage(sprite, null, null);
+        target.target = sprite.uid;
+        target.iconx = Math.floor((pos.x - info.dx) / this.scaleFactor) + 1;
+        target.icony = Math.floor((info.dy + info.dh - pos.y) / this.scaleFactor) + 1;
+    }
+    return target;
+};
+
 Canvas.prototype.updateSizing = function () {
     const aspectRatio = this.canvas.width / this.canvas.height;
     if (this.viewHeight * aspectRatio > this.viewWidth) {
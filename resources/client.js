@@ -1,6 +1,6 @@
 "use strict";
 
-function prepareGame(canvas, inputsource, verbentry, paneltabs, panelbody, textoutput) {
+function prepareGame(canvas, inputsource, verbentry, paneltabs, panelbody, textoutput, loginform) {
     let gameActive = false;
     const imageLoader = new ImageLoader("resource");
     const statPanels = new StatPanel(paneltabs, panelbody, imageLoader);
@@ -9,19 +9,37 @@ function prepareGame(canvas, inputsource, verbentry, paneltabs, panelbody, texto
     const render = new Canvas(canvas, imageLoader);
     const soundPlayer = new SoundPlayer();
     const keyHandler = new KeyHandler(inputsource);
+    const login = new LoginForm(loginform);
+    const browser = new BrowserWindows(document.body);
+    const prompts = new PromptDialogs(document.body);
 
     function sendVerb(verb) {
         console.log("send verb", verb);
         session.sendMessage({"verb": verb})
     }
 
+    function sendTopic(query) {
+        console.log("send topic", query);
+        session.sendMessage({"topic": query});
+    }
+
     keyHandler.onmove = function (direction) {
         sendVerb("." + direction);
     };
 
+    // a lost connection is retried for about as long as the server waits for the player to come back
+    const RECONNECT_DELAY = 3000;
+    const RECONNECT_ATTEMPTS = 20;
+    let reconnectAttempts = 0;
+    let reconnecting = false;
+
     function getLoadingMessage() {
-        if (session.terminated) {
-            if (gameActive) {
+        if (reconnecting) {
+            return "Connection lost. Reconnecting...";
+        } else if (session.terminated) {
+            if (session.error !== null) {
+                return session.error;
+            } else if (gameActive) {
                 return "Disconnected.";
             } else {
                 return "Could not connect.";
@@ -34,7 +52,7 @@ function prepareGame(canvas, inputsource, verbentry, paneltabs, panelbody, texto
     }
 
     function draw() {
-        if (!gameActive || session.terminated) {
+        if (!gameActive || session.terminated || reconnecting) {
             render.renderLoading(getLoadingMessage());
         } else {
             keyHandler.tick();
@@ -45,7 +63,7 @@ function prepareGame(canvas, inputsource, verbentry, paneltabs, panelbody, texto
     function displayText(line) {
         var shouldScroll = textoutput.scrollHeight - textoutput.scrollTop === textoutput.clientHeight;
         var nextLine = document.createElement("p");
-        nextLine.textContent = line;
+        nextLine.appendChild(sanitizeHTML(line, sendTopic));
         textoutput.append(nextLine);
         if (shouldScroll) {
             textoutput.scrollTop = textoutput.scrollHeight - textoutput.clientHeight;
@@ -80,17 +98,34 @@ function prepareGame(canvas, inputsource, verbentry, paneltabs, panelbody, texto
         sendVerb(verb);
     };
 
+    browser.ontopic = sendTopic;
+
+    prompts.onanswer = function (answer) {
+        console.log("send answer", answer);
+        session.sendMessage({"answer": answer});
+        verbentry.focus();
+    };
+
     session.onmessage = function (message) {
         if (!gameActive) {
             gameActive = true;
         }
-        if (message.newstate) {
-            render.updateSprites(message.newstate.sprites || []);
-            render.updateSize(message.newstate.viewportwidth, message.newstate.viewportheight);
-            if (message.newstate.windowtitle) {
-                document.getElementsByTagName("title")[0].textContent = message.newstate.windowtitle;
+        reconnecting = false;
+        reconnectAttempts = 0;
+        if (message.delta) {
+            // the session has already applied the delta to its view
+            const delta = message.delta, view = session.view;
+            if (delta.keyframe || delta.sprites || delta.removed || delta.origin) {
+                render.updateView(view);
+            }
+            render.updateSize(view.viewportWidth, view.viewportHeight);
+            render.updateLights(view.lightMap);
+            if (view.windowTitle) {
+                document.getElementsByTagName("title")[0].textContent = view.windowTitle;
+            }
+            if (delta.keyframe || delta.verbs || delta.stats) {
+                statPanels.update(view.verbs, view.panels);
             }
-            statPanels.update(message.newstate.verbs || [], message.newstate.stats.panels || {});
         }
         if (message.textlines) {
             for (let i = 0; i < message.textlines.length; i++) {
@@ -109,10 +144,45 @@ function prepareGame(canvas, inputsource, verbentry, paneltabs, panelbody, texto
                 render.applyFlick(flick);
             }
         }
+        if (message.browse) {
+            for (let i = 0; i < message.browse.length; i++) {
+                const browse = message.browse[i];
+                if (browse.resource) {
+                    browser.addResource(browse.name, "resource/" + browse.resource);
+                } else if (browse.close) {
+                    browser.close(browse.window || "");
+                } else {
+                    browser.open(browse.window || "", browse.html || "", browse.width, browse.height);
+                }
+            }
+        }
+        if (message.prompts) {
+            for (let i = 0; i < message.prompts.length; i++) {
+                prompts.ask(message.prompts[i]);
+            }
+        }
     };
 
     session.onclose = function () {
         soundPlayer.cancelAllSounds();
+        if (session.loginRequired) {
+            reconnecting = false;
+            login.show(session.error);
+        } else if (session.canResume() && reconnectAttempts < RECONNECT_ATTEMPTS) {
+            reconnecting = true;
+            reconnectAttempts++;
+            setTimeout(function () {
+                session.connect();
+            }, RECONNECT_DELAY);
+        } else {
+            reconnecting = false;
+        }
+    };
+
+    login.onlogin = function (key) {
+        console.log("logged in as", key);
+        session.connect();
+        verbentry.focus();
     };
 
     inputsource.addEventListener("contextmenu", function (ev) {
@@ -146,6 +216,93 @@ function prepareGame(canvas, inputsource, verbentry, paneltabs, panelbody, texto
         }
     });
 
+    // presses and releases of the left and middle buttons over the same atom are clicks, and releases over anything
+    // else drop the atom that was pressed on. the right button is left for the context menu.
+    const MOUSE_BUTTONS = ["left", "middle"];
+    // for events that are not over the map
+    const NOWHERE = {"screenx": -1, "screeny": -1};
+    let mousePressed = null;
+    let mouseOver = 0;
+
+    function sendMouse(action, ev, at, button, extra) {
+        const mouse = Object.assign({
+            "action": action,
+            "button": button,
+            "shift": ev.shiftKey,
+            "ctrl": ev.ctrlKey,
+            "alt": ev.altKey,
+        }, at || NOWHERE, extra);
+        session.sendMessage({"mouse": mouse});
+    }
+
+    canvas.addEventListener("mousedown", function (ev) {
+        const button = MOUSE_BUTTONS[ev.button];
+        mousePressed = null;
+        if (!gameActive || button === undefined) {
+            return;
+        }
+        const at = render.findMouseTarget(ev);
+        if (at !== null && at.target) {
+            mousePressed = {"target": at.target, "button": button};
+            sendMouse("down", ev, at, button);
+        }
+    });
+
+    canvas.addEventListener("mouseup", function (ev) {
+        const button = MOUSE_BUTTONS[ev.button];
+        if (!gameActive || button === undefined) {
+            return;
+        }
+        const at = render.findMouseTarget(ev);
+        const over = at === null ? 0 : at.target;
+        if (over) {
+            sendMouse("up", ev, at, button);
+        }
+        const pressed = mousePressed;
+        mousePressed = null;
+        if (pressed === null || pressed.button !== button) {
+            return;
+        }
+        if (over === pressed.target) {
+            sendMouse("click", ev, at, button);
+        } else {
+            sendMouse("drop", ev, at, button, {"target": pressed.target, "over": over});
+        }
+    });
+
+    canvas.addEventListener("dblclick", function (ev) {
+        const at = render.findMouseTarget(ev);
+        if (gameActive && at !== null && at.target) {
+            sendMouse("dblclick", ev, at, MOUSE_BUTTONS[ev.button]);
+        }
+    });
+
+    function updateMouseOver(ev, at) {
+        const over = at === null ? 0 : at.target;
+        if (over === mouseOver) {
+            return;
+        }
+        if (mouseOver) {
+            sendMouse("exit", ev, at, undefined, {"target": mouseOver});
+        }
+        if (over) {
+            sendMouse("enter", ev, at, undefined);
+        }
+        mouseOver = over;
+    }
+
+    canvas.addEventListener("mousemove", function (ev) {
+        if (gameActive) {
+            updateMouseOver(ev, render.findMouseTarget(ev));
+        }
+    });
+
+    canvas.addEventListener("mouseleave", function (ev) {
+        if (gameActive) {
+            updateMouseOver(ev, null);
+        }
+    });
+
     verbentry.focus();
 
     verbentry.addEventListener("keypress", function (ev) {
@@ -175,7 +332,8 @@ window.addEventListener("load", function () {
     var panelTabs = document.getElementById("paneltabs");
     var panelBody = document.getElementById("panelbody");
     var textOutput = document.getElementById("textspace");
+    var loginForm = document.getElementById("login");
     if (canvas.getContext) {
-        prepareGame(canvas, document.body, verbEntry, panelTabs, panelBody, textOutput);
+        prepareGame(canvas, document.body, verbEntry, panelTabs, panelBody, textOutput, loginForm);
     }
 });
@@ -0,0 +1,114 @@
+"use strict";
+
+/* sanitizeHTML turns the HTML that the game outputs into DOM nodes, keeping only the tags and attributes that cannot
+ * run scripts or escape from where they are displayed.
+ * Links like byond://?src=[0x1];action=buy are topics: following one calls ontopic with its query string, rather
+ * than navigating anywhere.
+ * Images may only show the resources that were sent with browse_rsc(), which are given as a map from the names that
+ * the HTML uses to their URLs; images of anything else are dropped.
+ */
+
+// the attributes that each allowed tag may keep, in addition to the common ones
+const ALLOWED_TAGS = {
+    "a": ["href"],
+    "b": [], "i": [], "u": [], "s": [], "em": [], "strong": [], "small": [], "big": [], "tt": [], "code": [],
+    "span": [], "div": [], "p": [], "br": [], "hr": [], "pre": [], "center": [],
+    "h1": [], "h2": [], "h3": [], "ul": [], "ol": [], "li": [],
+    "table": [], "tr": [], "td": [], "th": [],
+    "font": ["color", "size", "face"],
+    "img": ["alt", "width", "height"],
+};
+const COMMON_ATTRIBUTES = ["class", "title"];
+// these are removed along with everything inside them, rather than just being unwrapped
+const DROPPED_TAGS = ["script", "style", "iframe", "object", "embed", "template", "title"];
+
+// the query string of a link that should be sent to the server as a topic, or null if the link is not a topic
+function topicQuery(href) {
+    href = href.trim();
+    if (href.startsWith("byond://?")) {
+        return href.substring("byond://?".length);
+    } else if (href.startsWith("?")) {
+        return href.substring(1);
+    }
+    return null;
+}
+
+function sanitizeLink(link, ontopic) {
+    const href = link.getAttribute("href");
+    if (href === null) {
+        return;
+    }
+    const query = topicQuery(href);
+    if (query !== null) {
+        link.addEventListener("click", function (ev) {
+            ev.preventDefault();
+            ontopic(query);
+        });
+    } else if (/^https?:\/\//i.test(href.trim())) {
+        link.target = "_blank";
+        link.rel = "noopener noreferrer";
+    } else {
+        // anything else, like javascript: links, could run code
+        link.removeAttribute("href");
+    }
+}
+
+function sanitizeImage(img, src, resources) {
+    if (src === null || resources === undefined || !resources.hasOwnProperty(src)) {
+        return false;
+    }
+    img.setAttribute("src", resources[src]);
+    return true;
+}
+
+function sanitizeChildren(from, into, ontopic, resources) {
+    for (let child = from.firstChild; child !== null; child = child.nextSibling) {
+        if (child.nodeType === Node.TEXT_NODE) {
+            into.appendChild(document.createTextNode(child.textContent));
+        } else if (child.nodeType === Node.ELEMENT_NODE) {
+            const tag = child.tagName.toLowerCase();
+            const allowed = ALLOWED_TAGS[tag];
+            if (DROPPED_TAGS.indexOf(tag) >= 0) {
+                continue;
+            } else if (allowed === undefined) {
+                // unknown tags are unwrapped, so that their text is still shown
+                sanitizeChildren(child, into, ontopic, resources);
+                continue;
+            }
+            const element = document.createElement(tag);
+            const attributes = allowed.concat(COMMON_ATTRIBUTES);
+            for (let i = 0; i < attributes.length; i++) {
+                if (child.hasAttribute(attributes[i])) {
+                    element.setAttribute(attributes[i], child.getAttribute(attributes[i]));
+                }
+            }
+            if (tag === "a") {
+                sanitizeLink(element, ontopic);
+            } else if (tag === "img" && !sanitizeImage(element, child.getAttribute("src"), resources)) {
+                continue;
+            }
+            sanitizeChildren(child, element, ontopic, resources);
+            into.appendChild(element);
+        }
+        // comments and everything else are dropped
+    }
+}
+
+// the contents of a template are parsed without running scripts or loading anything
+function parseHTML(html) {
+    const template = document.createElement("template");
+    template.innerHTML = html;
+    return template.content;
+}
+
+function sanitizeHTML(html, ontopic, resources) {
+    const fragment = document.createDocumentFragment();
+    sanitizeChildren(parseHTML(html), fragment, ontopic, resources);
+    return fragment;
+}
+
+// the text of the HTML's <title>, or null if it has none
+function titleOfHTML(html) {
+    const title = parseHTML(html).querySelector("title");
+    return title === null ? null : title.textContent.trim();
+}
@@ -5,6 +5,7 @@
  *  - load(iconlist)
  *  - isLoaded()
  *  - getImage(name)
+ * Icons created while the game runs are named dynamic/..., and are loaded the first time that they are used.
  * The events to be overridden by the user:
  *  - onload
  */
@@ -12,6 +13,11 @@
 function ImageLoader(basepath) {
     this.started = false;
     this.images = {};
+    this.dynamic = {};
+    // recolored copies of images, keyed by image name and color matrix
+    this.colored = {};
+    // pixel data of images, used to find the opaque parts of sprites
+    this.pixels = {};
     this.pending = 0;
     basepath = basepath || "";
     if (basepath && !basepath.endsWith("/")) {
@@ -41,6 +47,22 @@ ImageLoader.prototype.receive = function (filename, img) {
     }
 };
 
+ImageLoader.prototype.loadDynamic = function (filename) {
+    if (this.dynamic[filename]) {
+        return;
+    }
+    this.dynamic[filename] = true;
+    const loader = this;
+    const img = new Image();
+    img.addEventListener("load", function () {
+        loader.images[filename] = img;
+    }, false);
+    img.addEventListener("error", function () {
+        console.log("could not load dynamic icon:", filename);
+    }, false);
+    img.src = this.basepath + filename;
+};
+
 ImageLoader.prototype.isLoaded = function () {
     return this.started && this.pending === 0;
 };
@@ -66,6 +88,11 @@ ImageLoader.prototype.getImage = function (image) {
         return null;
     }
     const loaded = this.images[image];
+    if (!loaded && image.startsWith("dynamic/")) {
+        // drawn once it finishes loading
+        this.loadDynamic(image);
+        return null;
+    }
     if (!loaded) {
         console.log("attempt to use icon that was never loaded:", image);
         return null;
@@ -73,6 +100,75 @@ ImageLoader.prototype.getImage = function (image) {
     return loaded;
 };
 
+// converts a sprite's color or color matrix into a 20-entry color matrix, or null if its icon is drawn unchanged
+function spriteColorMatrix(sprite) {
+    if (sprite.colormatrix) {
+        return sprite.colormatrix;
+    }
+    if (sprite.color) {
+        const hex = sprite.color.substring(1);
+        const r = parseInt(hex.substring(0, 2), 16) / 255;
+        const g = parseInt(hex.substring(2, 4), 16) / 255;
+        const b = parseInt(hex.substring(4, 6), 16) / 255;
+        const a = hex.length >= 8 ? parseInt(hex.substring(6, 8), 16) / 255 : 1;
+        return [r, 0, 0, 0, 0, g, 0, 0, 0, 0, b, 0, 0, 0, 0, a, 0, 0, 0, 0];
+    }
+    return null;
+}
+
+ImageLoader.prototype.getPixels = function (name, image) {
+    let pixels = this.pixels[name];
+    if (!pixels) {
+        const canvas = document.createElement("canvas");
+        canvas.width = image.width;
+        canvas.height = image.height;
+        const ctx = canvas.getContext("2d");
+        ctx.drawImage(image, 0, 0);
+        this.pixels[name] = pixels = ctx.getImageData(0, 0, image.width, image.height);
+    }
+    return pixels;
+};
+
+// produces a copy of an image with a color matrix applied to each of its pixels
+ImageLoader.prototype.getColoredImage = function (name, image, matrix) {
+    const key = name + "|" + matrix.join(",");
+    let colored = this.colored[key];
+    if (!colored) {
+        const source = this.getPixels(name, image);
+        colored = document.createElement("canvas");
+        colored.width = image.width;
+        colored.height = image.height;
+        const ctx = colored.getContext("2d");
+        const output = ctx.createImageData(image.width, image.height);
+        const inp = source.data, out = output.data;
+        for (let i = 0; i < inp.length; i += 4) {
+            const r = inp[i] / 255, g = inp[i + 1] / 255, b = inp[i + 2] / 255, a = inp[i + 3] / 255;
+            for (let c = 0; c < 4; c++) {
+                const value = r * matrix[c] + g * matrix[4 + c] + b * matrix[8 + c] + a * matrix[12 + c] + matrix[16 + c];
+                out[i + c] = Math.max(0, Math.min(255, Math.round(value * 255)));
+            }
+        }
+        ctx.putImageData(output, 0, 0);
+        this.colored[key] = colored;
+    }
+    return colored;
+};
+
+// determines whether the pixel at a position within a sprite's icon is visible
+ImageLoader.prototype.isOpaqueAt = function (sprite, info, x, y) {
+    const image = this.getImage(sprite.icon);
+    if (!image) {
+        return false;
+    }
+    const px = Math.floor(info.sx + x * info.sw / info.dw);
+    const py = Math.floor(info.sy + y * info.sh / info.dh);
+    if (px < 0 || py < 0 || px >= image.width || py >= image.height) {
+        return false;
+    }
+    const pixels = this.getPixels(sprite.icon, image);
+    return pixels.data[(py * image.width + px) * 4 + 3] > 0;
+};
+
 function framesEq(a, b) {
     if (a.length !== b.length) {
         return false;
@@ -85,15 +181,69 @@ function framesEq(a, b) {
     return true;
 }
 
-ImageLoader.prototype.getAnimationInfo = function (uid, animationInfoMap, create) {
-    let animationInfo = animationInfoMap["#" + uid];
+// the duration of a tick, in milliseconds; frame delays are measured in ticks
+const TICK_LENGTH = 100;
+
+// expands an animation into the order in which its frames are displayed: frames play forwards, and then backwards
+// if the animation rewinds, so that the first frame follows the second frame once more
+function animationOrder(animation, frameCount) {
+    const order = [];
+    for (let i = 0; i < frameCount; i++) {
+        order.push(i);
+    }
+    if (animation.rewind) {
+        for (let i = frameCount - 2; i > 0; i--) {
+            order.push(i);
+        }
+    }
+    return order;
+}
+
+// delays may be fractional numbers of ticks; frames without a delay are displayed for one tick
+function frameDelay(animation, frame) {
+    const delay = animation.delays && animation.delays[frame];
+    return delay > 0 ? delay : 1;
+}
+
+// finds the frame to display once an animation has been playing for some number of ticks. loops is the number of
+// times that the animation plays before it stops, or zero if it plays forever.
+function selectFrame(animation, frameCount, loops, elapsed) {
+    const order = animationOrder(animation, frameCount);
+    let cycle = 0;
+    for (let i = 0; i < order.length; i++) {
+        cycle += frameDelay(animation, order[i]);
+    }
+    if (loops && elapsed >= loops * cycle) {
+        // a finished animation rests on the frame that it would have displayed next
+        return {"frame": animation.rewind ? 0 : frameCount - 1, "done": true};
+    }
+    let remaining = elapsed % cycle;
+    for (let i = 0; i < order.length; i++) {
+        const delay = frameDelay(animation, order[i]);
+        if (remaining < delay) {
+            return {"frame": order[i], "done": false};
+        }
+        remaining -= delay;
+    }
+    return {"frame": order[order.length - 1], "done": false};
+}
+
+// underlays and overlays are animated separately from the atom that they are attached to, and flicks only apply to
+// the atom itself, which is part zero
+function animationKey(uid, part) {
+    return part ? "#" + uid + "." + part : "#" + uid;
+}
+
+ImageLoader.prototype.getAnimationInfo = function (key, animationInfoMap, create) {
+    let animationInfo = animationInfoMap[key];
     if (!animationInfo) {
         if (!create) {
             return null;
         }
-        animationInfoMap["#" + uid] = animationInfo = {
+        animationInfoMap[key] = animationInfo = {
             "icon": null,
             "frames": [],  // sentinel value; no actual frames list will be empty
+            "flick": null,
             "start": 0,
             "flicking": false,
         };
@@ -104,45 +254,60 @@ ImageLoader.prototype.getAnimationInfo = function (uid, animationInfoMap, create
 // this doesn't really belong here, except that this is where the other animationInfoMap handling is kept
 // FIXME: put all of the animation-handling code somewhere more reasonable
 ImageLoader.prototype.applyFlick = function (flick, animationInfoMap) {
-    const animationInfo = this.getAnimationInfo(flick.uid, animationInfoMap, true);
+    const animationInfo = this.getAnimationInfo(animationKey(flick.uid, 0), animationInfoMap, true);
     animationInfo.flicking = true;
     animationInfo.icon = flick.icon;
     animationInfo.frames = flick.frames;
+    animationInfo.flick = flick;
     animationInfo.start = null;
 };
 
-ImageLoader.prototype.prepareImage = function (sprite, animationInfoMap, frameID) {
+// now is the current time in ticks, which may be fractional
+ImageLoader.prototype.prepareImage = function (sprite, animationInfoMap, now) {
     let icon = sprite.icon;
     let frames = sprite.frames;
     let frame = 0;
     if (animationInfoMap) {
         const needed = sprite.frames.length > 1;
-        const animationInfo = this.getAnimationInfo(sprite.uid, animationInfoMap, needed);
+        const animationInfo = this.getAnimationInfo(animationKey(sprite.uid, sprite.part), animationInfoMap, needed);
         if (animationInfo !== null && (needed || animationInfo.flicking)) {
             if (animationInfo.start === null) {
-                animationInfo.start = frameID;
-            }
-            if (animationInfo.flicking && frameID >= animationInfo.start + animationInfo.frames.length) {
-                animationInfo.flicking = false;
-                // reset to sentinel values so we'll always reload the frame state
-                animationInfo.icon = null;
-                animationInfo.frames = [];
+                animationInfo.start = now;
             }
             if (animationInfo.flicking) {
-                icon = animationInfo.icon;
-                frames = animationInfo.frames;
-            } else if (animationInfo.icon !== sprite.icon || !framesEq(animationInfo.frames, sprite.frames)) {
-                animationInfo.icon = sprite.icon;
-                animationInfo.frames = sprite.frames;
-                animationInfo.start = frameID;
+                // flicks always play exactly once
+                const selected = selectFrame(animationInfo.flick, animationInfo.frames.length, 1,
+                    now - animationInfo.start);
+                if (selected.done) {
+                    animationInfo.flicking = false;
+                    animationInfo.flick = null;
+                    // reset to sentinel values so we'll always reload the frame state
+                    animationInfo.icon = null;
+                    animationInfo.frames = [];
+                } else {
+                    icon = animationInfo.icon;
+                    frames = animationInfo.frames;
+                    frame = selected.frame;
+                }
+            }
+            if (!animationInfo.flicking) {
+                if (animationInfo.icon !== sprite.icon || !framesEq(animationInfo.frames, sprite.frames)) {
+                    animationInfo.icon = sprite.icon;
+                    animationInfo.frames = sprite.frames;
+                    animationInfo.start = now;
+                }
+                frame = selectFrame(sprite, frames.length, sprite.loop, now - animationInfo.start).frame;
             }
-            frame = (frameID - animationInfo.start) % animationInfo.frames.length;
         }
     }
-    const image = this.getImage(icon);
+    let image = this.getImage(icon);
     if (!image) {
         return null;
     }
+    const matrix = spriteColorMatrix(sprite);
+    if (matrix) {
+        image = this.getColoredImage(icon, image, matrix);
+    }
     const sx = frames[frame].x;
     const sy = frames[frame].y;
     const sw = sprite.sw || image.width;
@@ -0,0 +1,66 @@
+"use strict";
+
+/* LoginForm asks the player for their username and password, when the server requires them to log in.
+ * The public interface:
+ *  - show(message)
+ *  - hide()
+ * The events to be overridden by the user:
+ *  - onlogin(key)
+ */
+
+function LoginForm(form) {
+    const login = this;
+    this.form = form;
+    this.message = form.querySelector(".loginmessage");
+    this.onlogin = null;
+    this.busy = false;
+    form.addEventListener("submit", function (ev) {
+        ev.preventDefault();
+        // pressing enter submits using the first button, which logs in
+        const register = ev.submitter !== undefined && ev.submitter !== null && ev.submitter.value === "register";
+        login.submit(register);
+    });
+}
+
+LoginForm.prototype.show = function (message) {
+    this.message.textContent = message || "";
+    this.form.classList.remove("hidden");
+    this.form.elements["username"].focus();
+};
+
+LoginForm.prototype.hide = function () {
+    this.form.classList.add("hidden");
+};
+
+LoginForm.prototype.submit = function (register) {
+    if (this.busy) {
+        return;
+    }
+    const login = this;
+    const body = new URLSearchParams();
+    body.set("username", this.form.elements["username"].value);
+    body.set("password", this.form.elements["password"].value);
+    if (register) {
+        body.set("register", "1");
+    }
+    this.busy = true;
+    this.message.textContent = register ? "Creating account..." : "Logging in...";
+    fetch("/login", {"method": "POST", "body": body, "credentials": "same-origin"}).then(function (response) {
+        return response.json().then(function (result) {
+            login.busy = false;
+            if (response.ok) {
+                login.form.elements["password"].value = "";
+                login.hide();
+                if (login.onlogin !== null) {
+                    login.onlogin(result.key);
+                }
+            } else {
+                login.message.textContent = result.error || "Could not log in.";
+            }
+        });
+    }).catch(function (err) {
+        console.log("login error", err);
+        login.busy = false;
+        login.message.textContent = "Could not reach the server.";
+    });
+};
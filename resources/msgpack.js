@@ -0,0 +1,132 @@
+"use strict";
+
+/* decodeMsgpack decodes the subset of MessagePack that the server sends: nil, booleans, integers, 64-bit floats,
+ * strings, arrays and maps with string keys.
+ */
+
+function decodeMsgpack(buffer) {
+    const view = new DataView(buffer);
+    const textDecoder = new TextDecoder("utf-8");
+    let offset = 0;
+
+    function readString(length) {
+        const text = textDecoder.decode(new Uint8Array(buffer, offset, length));
+        offset += length;
+        return text;
+    }
+
+    function readArray(length) {
+        const array = [];
+        for (let i = 0; i < length; i++) {
+            array.push(readValue());
+        }
+        return array;
+    }
+
+    function readMap(length) {
+        const map = {};
+        for (let i = 0; i < length; i++) {
+            const key = readValue();
+            map[key] = readValue();
+        }
+        return map;
+    }
+
+    function readValue() {
+        const type = view.getUint8(offset);
+        offset += 1;
+        let value;
+        if (type <= 0x7f) {
+            return type;
+        } else if (type >= 0xe0) {
+            return type - 0x100;
+        } else if ((type & 0xe0) === 0xa0) {
+            return readString(type & 0x1f);
+        } else if ((type & 0xf0) === 0x90) {
+            return readArray(type & 0x0f);
+        } else if ((type & 0xf0) === 0x80) {
+            return readMap(type & 0x0f);
+        }
+        switch (type) {
+            case 0xc0:
+                return null;
+            case 0xc2:
+                return false;
+            case 0xc3:
+                return true;
+            case 0xcb:
+                value = view.getFloat64(offset);
+                offset += 8;
+                return value;
+            case 0xcc:
+                value = view.getUint8(offset);
+                offset += 1;
+                return value;
+            case 0xcd:
+                value = view.getUint16(offset);
+                offset += 2;
+                return value;
+            case 0xce:
+                value = view.getUint32(offset);
+                offset += 4;
+                return value;
+            case 0xcf:
+                // FIXME: values this large cannot be accurately represented by javascript's numbers
+                value = view.getUint32(offset) * 0x100000000 + view.getUint32(offset + 4);
+                offset += 8;
+                return value;
+            case 0xd0:
+                value = view.getInt8(offset);
+                offset += 1;
+                return value;
+            case 0xd1:
+                value = view.getInt16(offset);
+                offset += 2;
+                return value;
+            case 0xd2:
+                value = view.getInt32(offset);
+                offset += 4;
+                return value;
+            case 0xd3:
+                value = view.getInt32(offset) * 0x100000000 + view.getUint32(offset + 4);
+                offset += 8;
+                return value;
+            case 0xd9:
+                value = view.getUint8(offset);
+                offset += 1;
+                return readString(value);
+            case 0xda:
+                value = view.getUint16(offset);
+                offset += 2;
+                return readString(value);
+            case 0xdb:
+                value = view.getUint32(offset);
+                offset += 4;
+                return readString(value);
+            case 0xdc:
+                value = view.getUint16(offset);
+                offset += 2;
+                return readArray(value);
+            case 0xdd:
+                value = view.getUint32(offset);
+                offset += 4;
+                return readArray(value);
+            case 0xde:
+                value = view.getUint16(offset);
+                offset += 2;
+                return readMap(value);
+            case 0xdf:
+                value = view.getUint32(offset);
+                offset += 4;
+                return readMap(value);
+            default:
+                throw new Error("unsupported msgpack type " + type);
+        }
+    }
+
+    const result = readValue();
+    if (offset !== buffer.byteLength) {
+        throw new Error("extra data after msgpack value");
+    }
+    return result;
+}
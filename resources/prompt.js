@@ -0,0 +1,201 @@
+"use strict";
+
+/* PromptDialogs asks the player the questions from input() and alert(), one at a time, in the order that they arrive.
+ * Answers are checked before they are sent, so that a num prompt can only be answered with a number.
+ * The public interface:
+ *  - ask(prompt)
+ * The events to be overridden by the user:
+ *  - onanswer(answer)
+ */
+
+const MAX_LIST_ROWS = 10;
+// the server refuses longer answers; see webclient.MaxAnswerLength
+const MAX_ANSWER_LENGTH = 4096;
+
+function PromptDialogs(container) {
+    this.container = container;
+    this.queue = [];
+    this.current = null;
+    this.onanswer = null;
+}
+
+PromptDialogs.prototype.ask = function (prompt) {
+    for (let i = 0; i < this.queue.length; i++) {
+        if (this.queue[i].id === prompt.id) {
+            // the server is asking again, because the last answer was invalid
+            return;
+        }
+    }
+    this.queue.push(prompt);
+    if (this.current === null) {
+        this.showNext();
+    }
+};
+
+PromptDialogs.prototype.showNext = function () {
+    if (this.queue.length === 0) {
+        this.current = null;
+        return;
+    }
+    this.current = new PromptDialog(this.queue[0], this);
+    this.container.appendChild(this.current.form);
+    this.current.focus();
+};
+
+PromptDialogs.prototype.answer = function (answer) {
+    this.container.removeChild(this.current.form);
+    this.queue.shift();
+    if (this.onanswer !== null) {
+        this.onanswer(answer);
+    }
+    this.showNext();
+};
+
+function PromptDialog(prompt, dialogs) {
+    const dialog = this;
+    this.prompt = prompt;
+    this.form = document.createElement("form");
+    this.form.classList.add("prompt");
+    if (prompt.title) {
+        const title = document.createElement("div");
+        title.classList.add("prompttitle");
+        title.textContent = prompt.title;
+        this.form.appendChild(title);
+    }
+    if (prompt.message) {
+        const message = document.createElement("p");
+        message.textContent = prompt.message;
+        this.form.appendChild(message);
+    }
+    this.error = document.createElement("p");
+    this.error.classList.add("prompterror");
+    this.entry = this.buildEntry();
+    if (this.entry !== null) {
+        this.form.appendChild(this.entry);
+    }
+    this.form.appendChild(this.error);
+
+    const buttons = document.createElement("div");
+    buttons.classList.add("promptbuttons");
+    if (prompt.kind === "alert") {
+        for (let i = 0; i < prompt.choices.length; i++) {
+            buttons.appendChild(this.buildButton(prompt.choices[i], function () {
+                dialogs.answer({"id": prompt.id, "choice": i});
+            }));
+        }
+    } else {
+        buttons.appendChild(this.buildButton("OK", function () {
+            const answer = dialog.check();
+            if (answer !== null) {
+                dialogs.answer(answer);
+            }
+        }));
+    }
+    if (prompt.cancel) {
+        buttons.appendChild(this.buildButton("Cancel", function () {
+            dialogs.answer({"id": prompt.id, "cancel": true});
+        }));
+    }
+    this.form.appendChild(buttons);
+
+    this.form.addEventListener("submit", function (ev) {
+        ev.preventDefault();
+        // pressing enter picks the first button
+        buttons.firstChild.click();
+    });
+    this.form.addEventListener("keydown", function (ev) {
+        // keep the game from seeing keys that are typed into the prompt
+        ev.stopPropagation();
+    });
+}
+
+PromptDialog.prototype.buildButton = function (label, onclick) {
+    const button = document.createElement("button");
+    button.type = "button";
+    button.textContent = label;
+    button.addEventListener("click", onclick);
+    return button;
+};
+
+PromptDialog.prototype.buildEntry = function () {
+    const prompt = this.prompt;
+    let entry;
+    switch (prompt.kind) {
+        case "text":
+        case "num":
+            entry = document.createElement("input");
+            entry.type = "text";
+            entry.maxLength = MAX_ANSWER_LENGTH;
+            if (prompt.kind === "num") {
+                entry.inputMode = "numeric";
+            }
+            entry.value = prompt.default || "";
+            return entry;
+        case "message":
+            entry = document.createElement("textarea");
+            entry.rows = 5;
+            entry.maxLength = MAX_ANSWER_LENGTH;
+            entry.value = prompt.default || "";
+            return entry;
+        case "list":
+            entry = document.createElement("select");
+            entry.size = Math.max(2, Math.min(MAX_LIST_ROWS, prompt.choices.length));
+            for (let i = 0; i < prompt.choices.length; i++) {
+                const option = document.createElement("option");
+                option.value = i.toString();
+                option.textContent = prompt.choices[i];
+                if (prompt.choices[i] === prompt.default && entry.selectedIndex < 0) {
+                    option.selected = true;
+                }
+                entry.appendChild(option);
+            }
+            if (entry.selectedIndex < 0 && prompt.choices.length > 0) {
+                entry.selectedIndex = 0;
+            }
+            const form = this.form;
+            entry.addEventListener("dblclick", function () {
+                form.requestSubmit();
+            });
+            return entry;
+        default:
+            return null;
+    }
+};
+
+PromptDialog.prototype.focus = function () {
+    if (this.entry !== null) {
+        this.entry.focus();
+    } else {
+        this.form.querySelector("button").focus();
+    }
+};
+
+// the answer to send, or null if what the player entered is not a valid answer
+PromptDialog.prototype.check = function () {
+    const prompt = this.prompt;
+    switch (prompt.kind) {
+        case "num":
+            const text = this.entry.value.trim();
+            if (!/^-?\d+$/.test(text)) {
+                this.error.textContent = "Please enter a whole number.";
+                return null;
+            }
+            return {"id": prompt.id, "text": text};
+        case "list":
+            if (this.entry.selectedIndex < 0) {
+                this.error.textContent = "Please pick one.";
+                return null;
+            }
+            return {"id": prompt.id, "choice": this.entry.selectedIndex};
+        case "text":
+        case "message":
+            // the default answer can be longer than the player could have typed
+            if (this.entry.value.length > MAX_ANSWER_LENGTH) {
+                this.error.textContent = "Please enter at most " + MAX_ANSWER_LENGTH + " characters.";
+                return null;
+            }
+            return {"id": prompt.id, "text": this.entry.value};
+        default:
+            return {"id": prompt.id, "text": this.entry.value};
+    }
+};
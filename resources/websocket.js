@@ -1,25 +1,131 @@
 "use strict";
 
+/* ViewState reconstructs the server's view of the game from the deltas that it sends.
+ * The public interface:
+ *  - apply(delta)
+ *  - windowTitle, viewportWidth, viewportHeight, origin, looks, sprites, verbs, panels, lightMap
+ */
+
+function ViewState() {
+    this.reset();
+}
+
+function spriteKey(uid, part) {
+    return uid + "." + (part || 0);
+}
+
+ViewState.prototype.reset = function () {
+    this.windowTitle = "";
+    this.viewportWidth = this.viewportHeight = 0;
+    this.origin = {x: 0, y: 0};
+    this.looks = [];
+    // sprites refer to their looks by index, and are positioned relative to the map, rather than the viewport
+    this.sprites = {};
+    this.verbs = [];
+    this.panels = {};
+    this.lightMap = null;
+};
+
+ViewState.prototype.apply = function (delta) {
+    if (delta.keyframe) {
+        this.reset();
+    }
+    if (delta.windowtitle !== undefined) {
+        this.windowTitle = delta.windowtitle;
+    }
+    if (delta.viewportwidth || delta.viewportheight) {
+        this.viewportWidth = delta.viewportwidth;
+        this.viewportHeight = delta.viewportheight;
+    }
+    if (delta.origin) {
+        this.origin = delta.origin;
+    }
+    if (delta.looks) {
+        for (let i = 0; i < delta.looks.length; i++) {
+            this.looks.push(delta.looks[i]);
+        }
+    }
+    if (delta.removed) {
+        for (let i = 0; i < delta.removed.length; i++) {
+            delete this.sprites[spriteKey(delta.removed[i].uid, delta.removed[i].part)];
+        }
+    }
+    if (delta.sprites) {
+        for (let i = 0; i < delta.sprites.length; i++) {
+            const sprite = delta.sprites[i];
+            this.sprites[spriteKey(sprite.uid, sprite.part)] = sprite;
+        }
+    }
+    if (delta.verbs) {
+        this.verbs = delta.verbs;
+    }
+    if (delta.stats) {
+        for (const name in delta.stats) {
+            if (delta.stats[name] === null) {
+                delete this.panels[name];
+            } else {
+                this.panels[name] = delta.stats[name];
+            }
+        }
+    }
+    if (delta.lightmap) {
+        // an empty light map means that everything is fully lit
+        this.lightMap = delta.lightmap.width ? delta.lightmap : null;
+    }
+};
+
 /* Session handles an open websocket connection to the server.
  * The public interface:
  *  - sendMessage(jsonobject)
  *  - connect()
+ *  - view, the ViewState that the server's deltas have been applied to
+ *  - error, the reason that the server gave for refusing the connection, if it did
+ *  - loginRequired, whether the connection was refused because the player needs to log in first
+ *  - canResume(), whether the connection was lost unexpectedly, so that connecting again would resume the session
  * The events to be overridden by the user:
  *  - onmessage
  *  - onclose
  */
 
+// the protocol versions and message encodings that this client understands, in order of preference
+const PROTOCOL_VERSIONS = [1];
+const MESSAGE_ENCODINGS = ["msgpack", "json"];
+
 function Session() {
     this.socket = null;
     this.onmessage = null;
     this.onclose = null;
     this.socketOpen = false;
     this.terminated = false;
+    this.error = null;
+    this.loginRequired = false;
+    this.encoding = null;
+    // the server's token for this session, which lets a connection that drops be resumed without logging out
+    this.token = null;
+    this.dropped = false;
+    // the serial of the last update received, and of the last one that the server has been told about
+    this.received = 0;
+    this.acknowledged = 0;
+    this.view = new ViewState();
 }
 
+// the server closes connections on purpose, such as when the same player connects from elsewhere, but lost
+// connections are closed abnormally
+const CLOSE_ABNORMAL = 1006;
+
+// the server keeps every update that has not been acknowledged, in case the connection drops, so they are acknowledged
+// at least this often, even if the player is not sending any commands
+const ACKNOWLEDGE_INTERVAL = 20;
+
+Session.prototype.canResume = function () {
+    return this.token !== null && this.dropped && this.error === null;
+};
+
 Session.prototype.sendMessage = function (jsonobject) {
-    if (this.socketOpen) {
-        this.socket.send(JSON.stringify(jsonobject));
+    if (this.socketOpen && this.encoding !== null) {
+        const message = Object.assign({"received": this.received}, jsonobject);
+        this.socket.send(JSON.stringify(message));
+        this.acknowledged = this.received;
     }
 };
 
@@ -33,35 +139,109 @@ Session.prototype.reportClose = function () {
 };
 
 Session.prototype.openSocket = function () {
-    if (this.socket !== null) {
-        throw new Error("socket already created for session");
+    if (this.socket !== null && !this.terminated) {
+        throw new Error("socket already open for session");
     }
     const url = new URL("/websocket", window.location.href);
     url.protocol = (url.protocol === "http:") ? "ws:" : "wss:";
     console.log("connecting to", url.href);
     this.socket = new WebSocket(url.href);
+    this.socket.binaryType = "arraybuffer";
+};
+
+// the first message from the server answers the handshake, and is always JSON
+Session.prototype.receiveHandshake = function (reply) {
+    if (reply.error) {
+        console.log("server refused connection:", reply.error);
+        this.error = reply.error;
+        this.loginRequired = !!reply.login;
+        this.reportClose();
+    } else {
+        console.log("using protocol version", reply.version, "with encoding", reply.encoding);
+        this.encoding = reply.encoding;
+        if ((reply.session || null) !== this.token) {
+            // a new session numbers its updates from the beginning
+            this.received = this.acknowledged = 0;
+        }
+        this.token = reply.session || null;
+    }
+};
+
+Session.prototype.decode = function (data) {
+    if (data instanceof ArrayBuffer) {
+        return decodeMsgpack(data);
+    } else {
+        return JSON.parse(data);
+    }
 };
 
+// may be called again once the session has been terminated, such as after logging in
 Session.prototype.connect = function () {
     const session = this;
     this.openSocket();
+    this.view.reset();
     this.socketOpen = false;
     this.terminated = false;
-    this.socket.addEventListener("open", function () {
+    this.error = null;
+    this.loginRequired = false;
+    this.dropped = false;
+    this.encoding = null;
+    const socket = this.socket;
+    // events from a socket that has since been replaced must not affect the new connection
+    function current() {
+        return session.socket === socket;
+    }
+    socket.addEventListener("open", function () {
+        if (!current()) {
+            return;
+        }
         console.log("connection opened");
         session.socketOpen = true;
+        const handshake = {"versions": PROTOCOL_VERSIONS, "encodings": MESSAGE_ENCODINGS};
+        if (session.token !== null) {
+            handshake.resume = session.token;
+            handshake.received = session.received;
+        }
+        socket.send(JSON.stringify(handshake));
     });
-    this.socket.addEventListener('error', function () {
+    socket.addEventListener('error', function () {
+        if (!current()) {
+            return;
+        }
         console.log("connection error");
+        session.dropped = true;
         session.reportClose();
     });
-    this.socket.addEventListener('message', function (ev) {
+    socket.addEventListener('message', function (ev) {
+        if (!current()) {
+            return;
+        }
+        const message = session.decode(ev.data);
+        if (session.encoding === null) {
+            session.receiveHandshake(message);
+            return;
+        }
+        if (message.serial) {
+            session.received = message.serial;
+        }
+        if (message.delta) {
+            session.view.apply(message.delta);
+        }
         if (session.onmessage !== null) {
-            session.onmessage(JSON.parse(ev.data));
+            session.onmessage(message);
+        }
+        if (session.received - session.acknowledged >= ACKNOWLEDGE_INTERVAL) {
+            session.sendMessage({});
         }
     });
-    this.socket.addEventListener('close', function () {
-        console.log("connection terminated");
+    socket.addEventListener('close', function (ev) {
+        if (!current()) {
+            return;
+        }
+        console.log("connection terminated with code", ev.code);
+        if (ev.code === CLOSE_ABNORMAL) {
+            session.dropped = true;
+        }
         session.reportClose();
     });
 };
@@ -0,0 +1,63 @@
+package util
+
+// a Coroutine runs on its own goroutine, but only while whoever resumed it waits for it to finish or suspend, so that
+// code which is only ever run from one thread at a time stays that way
+type Coroutine struct {
+	body     func()
+	started  bool
+	finished bool
+	resume   chan struct{}
+	yield    chan coroutineYield
+}
+
+type coroutineYield struct {
+	Finished bool
+	Panic    interface{}
+}
+
+func NewCoroutine(body func()) *Coroutine {
+	return &Coroutine{
+		body:   body,
+		resume: make(chan struct{}),
+		yield:  make(chan coroutineYield),
+	}
+}
+
+func (co *Coroutine) run() {
+	defer func() {
+		co.yield <- coroutineYield{
+			Finished: true,
+			Panic:    recover(),
+		}
+	}()
+	co.body()
+}
+
+// runs the coroutine until it finishes or suspends. if it panics, the panic is passed on to the caller.
+func (co *Coroutine) Resume() {
+	if co.finished {
+		panic("attempt to resume a coroutine that has already finished")
+	}
+	if co.started {
+		co.resume <- struct{}{}
+	} else {
+		co.started = true
+		go co.run()
+	}
+	result := <-co.yield
+	co.finished = result.Finished
+	if result.Panic != nil {
+		panic(result.Panic)
+	}
+}
+
+// MUST be called from within the coroutine. hands control back to whoever resumed the coroutine, until it is resumed
+// again.
+func (co *Coroutine) Suspend() {
+	co.yield <- coroutineYield{}
+	<-co.resume
+}
+
+func (co *Coroutine) Finished() bool {
+	return co.finished
+}
@@ -0,0 +1,38 @@
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCoroutine(t *testing.T) {
+	var steps []string
+	var co *Coroutine
+	co = NewCoroutine(func() {
+		steps = append(steps, "start")
+		co.Suspend()
+		steps = append(steps, "resumed")
+		co.Suspend()
+		steps = append(steps, "end")
+	})
+	co.Resume()
+	assert.Equal(t, []string{"start"}, steps)
+	assert.False(t, co.Finished())
+	co.Resume()
+	assert.Equal(t, []string{"start", "resumed"}, steps)
+	co.Resume()
+	assert.Equal(t, []string{"start", "resumed", "end"}, steps)
+	assert.True(t, co.Finished())
+	assert.Panics(t, co.Resume)
+}
+
+func TestCoroutinePanic(t *testing.T) {
+	var co *Coroutine
+	co = NewCoroutine(func() {
+		co.Suspend()
+		panic("failed")
+	})
+	co.Resume()
+	assert.PanicsWithValue(t, "failed", co.Resume)
+	assert.True(t, co.Finished())
+}
@@ -10,9 +10,19 @@ type ServerSession interface {
 	OnMessage(Command)
 	// send nil to the view send callback to close connection
 	BeginSend(func(update *sprite.ViewUpdate) error)
+	// a secret that lets a client that loses its connection resume this session, rather than starting a new one
+	Token() string
 }
 
 type ServerAPI interface {
-	Connect() ServerSession
+	// the key identifies the player, and is empty for guests. if resume is the token of a session that was recently
+	// disconnected, that session is continued, and the updates after the received serial are sent again.
+	Connect(key string, resume string, received uint64) ServerSession
+	// whether players need to log in, rather than connecting as guests
+	LoginRequired() bool
+	// checks a player's username and password, or creates a new account if register is set, and returns their key
+	Login(username, password string, register bool) (key string, err error)
 	ResourcePack() *resourcepack.ResourcePack
+	// resources created while the server is running, like modified icons, which are not in the resource pack
+	DynamicResource(name string) (resourcepack.Resource, bool)
 }
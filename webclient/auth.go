@@ -0,0 +1,141 @@
+package webclient
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/celskeggs/mediator/util"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const sessionCookieName = "mediator_session"
+const sessionLifetime = 30 * 24 * time.Hour
+
+// signs the cookies that remember which key each browser logged in as, so that they cannot be forged
+type cookieSigner struct {
+	secret []byte
+}
+
+func newCookieSigner() *cookieSigner {
+	util.NiceToHave("keep session cookies valid across server restarts")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic("cannot generate session secret: " + err.Error())
+	}
+	return &cookieSigner{secret: secret}
+}
+
+func (cs *cookieSigner) mac(payload string) []byte {
+	mac := hmac.New(sha256.New, cs.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// cookies are of the form key.expiry.signature, where the key and the signature are base64-encoded
+func (cs *cookieSigner) Sign(key string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(key)) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(cs.mac(payload))
+}
+
+func (cs *cookieSigner) Verify(value string, now time.Time) (key string, ok bool) {
+	split := strings.LastIndexByte(value, '.')
+	if split < 0 {
+		return "", false
+	}
+	payload := value[:split]
+	signature, err := base64.RawURLEncoding.DecodeString(value[split+1:])
+	if err != nil || !hmac.Equal(signature, cs.mac(payload)) {
+		return "", false
+	}
+	parts := strings.Split(payload, ".")
+	if len(parts) != 2 {
+		return "", false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return "", false
+	}
+	keyBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", false
+	}
+	return string(keyBytes), true
+}
+
+// the key that the request's session cookie was signed for, if it has a valid one
+func (cs *cookieSigner) KeyFor(request *http.Request) (string, bool) {
+	cookie, err := request.Cookie(sessionCookieName)
+	if err != nil {
+		return "", false
+	}
+	return cs.Verify(cookie.Value, time.Now())
+}
+
+func writeJSONResponse(writer http.ResponseWriter, code int, response interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
+	if err := json.NewEncoder(writer).Encode(response); err != nil {
+		log.Printf("error writing response: %v", err)
+	}
+}
+
+type loginResponse struct {
+	Key   string `json:"key,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// handles POSTs of the login form, which has username and password fields, and a register field that is set when
+// creating a new account. on success, the browser is given a session cookie for the websocket to check.
+func loginHandler(api ServerAPI, signer *cookieSigner) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			http.Error(writer, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !api.LoginRequired() {
+			writeJSONResponse(writer, http.StatusNotFound, loginResponse{Error: "this server does not have accounts"})
+			return
+		}
+		username, password := request.PostFormValue("username"), request.PostFormValue("password")
+		register := request.PostFormValue("register") != ""
+		key, err := api.Login(username, password, register)
+		if err != nil {
+			writeJSONResponse(writer, http.StatusForbidden, loginResponse{Error: err.Error()})
+			return
+		}
+		expires := time.Now().Add(sessionLifetime)
+		http.SetCookie(writer, &http.Cookie{
+			Name:     sessionCookieName,
+			Value:    signer.Sign(key, expires),
+			Path:     "/",
+			Expires:  expires,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+		writeJSONResponse(writer, http.StatusOK, loginResponse{Key: key})
+	})
+}
+
+func logoutHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			http.Error(writer, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		http.SetCookie(writer, &http.Cookie{
+			Name:     sessionCookieName,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+		writeJSONResponse(writer, http.StatusOK, loginResponse{})
+	})
+}
@@ -0,0 +1,26 @@
+package webclient
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCookieSigner(t *testing.T) {
+	signer := newCookieSigner()
+	now := time.Now()
+	cookie := signer.Sign("Some.Player", now.Add(time.Hour))
+
+	key, ok := signer.Verify(cookie, now)
+	assert.True(t, ok)
+	assert.Equal(t, "Some.Player", key)
+
+	_, ok = signer.Verify(cookie, now.Add(2*time.Hour))
+	assert.False(t, ok)
+	_, ok = newCookieSigner().Verify(cookie, now)
+	assert.False(t, ok)
+	_, ok = signer.Verify("U29tZQ"+cookie[len("U29tZS5QbGF5ZXI"):], now)
+	assert.False(t, ok)
+	_, ok = signer.Verify("garbage", now)
+	assert.False(t, ok)
+}
@@ -1,5 +1,55 @@
 package webclient
 
+// a command with nothing set other than Received only acknowledges updates
 type Command struct {
-	Verb string `json:"verb"`
+	// the serial of the last update that the client has received, so that it need not be sent again on resume
+	Received uint64 `json:"received,omitempty"`
+	Verb     string `json:"verb"`
+	// the query string of a link that the player followed, like src=[0x1];action=buy
+	Topic  string        `json:"topic,omitempty"`
+	Answer *PromptAnswer `json:"answer,omitempty"`
+	Mouse  *MouseEvent   `json:"mouse,omitempty"`
+}
+
+// the longest text answer to a prompt that the server accepts, in characters
+const MaxAnswerLength = 4096
+
+// the player's answer to a sprite.Prompt
+type PromptAnswer struct {
+	ID     uint64 `json:"id"`
+	Cancel bool   `json:"cancel,omitempty"`
+	// what the player entered, for text, message and num prompts
+	Text string `json:"text,omitempty"`
+	// the index of what the player picked, for list and alert prompts
+	Choice int `json:"choice,omitempty"`
+}
+
+const (
+	MouseClick    = "click"
+	MouseDblClick = "dblclick"
+	MouseDown     = "down"
+	MouseUp       = "up"
+	MouseDrop     = "drop"
+	MouseEntered  = "enter"
+	MouseExited   = "exit"
+)
+
+// something that the player did with the mouse on the map
+type MouseEvent struct {
+	Action string `json:"action"`
+	// the UID of the atom under the mouse, or of the atom that was dragged, for drops
+	Target uint64 `json:"target,omitempty"`
+	// for drops, the UID of the atom that the dragged atom was dropped onto, if any
+	Over uint64 `json:"over,omitempty"`
+	// the pixel within the icon of the atom under the mouse, counting from 1 at the bottom left, as DM does
+	IconX int `json:"iconx,omitempty"`
+	IconY int `json:"icony,omitempty"`
+	// the pixel within the map, counting from 0 at the bottom left
+	ScreenX int `json:"screenx"`
+	ScreenY int `json:"screeny"`
+	// left, middle or right
+	Button string `json:"button,omitempty"`
+	Shift  bool   `json:"shift,omitempty"`
+	Ctrl   bool   `json:"ctrl,omitempty"`
+	Alt    bool   `json:"alt,omitempty"`
 }
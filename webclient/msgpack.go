@@ -0,0 +1,233 @@
+package webclient
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// encodes values as MessagePack, laid out the same way as encoding/json would lay them out: structs become maps keyed
+// by their json field names, with omitempty and embedded structs handled the same way.
+func marshalMsgpack(v interface{}) ([]byte, error) {
+	e := &msgpackEncoder{}
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.out, nil
+}
+
+type msgpackEncoder struct {
+	out []byte
+}
+
+type msgpackField struct {
+	Name      string
+	Index     []int
+	OmitEmpty bool
+}
+
+var msgpackFieldCache sync.Map
+
+func msgpackFields(t reflect.Type) []msgpackField {
+	if fields, ok := msgpackFieldCache.Load(t); ok {
+		return fields.([]msgpackField)
+	}
+	var fields []msgpackField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if comma := strings.IndexByte(tag, ','); comma >= 0 {
+			name, options = tag[:comma], tag[comma+1:]
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			// the fields of embedded structs are promoted into the struct that embeds them
+			for _, inner := range msgpackFields(field.Type) {
+				inner.Index = append([]int{i}, inner.Index...)
+				fields = append(fields, inner)
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, msgpackField{
+			Name:      name,
+			Index:     []int{i},
+			OmitEmpty: strings.Contains(","+options+",", ",omitempty,"),
+		})
+	}
+	msgpackFieldCache.Store(t, fields)
+	return fields
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// writes a type byte for short lengths, or one of the three type bytes for 8, 16 and 32-bit lengths. a zero for the
+// 8-bit type byte means that there is no 8-bit form.
+func (e *msgpackEncoder) header(fixed byte, fixedMax int, t8, t16, t32 byte, length int) {
+	switch {
+	case length <= fixedMax:
+		e.out = append(e.out, fixed|byte(length))
+	case t8 != 0 && length <= math.MaxUint8:
+		e.out = append(e.out, t8, byte(length))
+	case length <= math.MaxUint16:
+		e.out = append(e.out, t16)
+		e.out = appendBigEndian(e.out, 16, uint64(length))
+	default:
+		e.out = append(e.out, t32)
+		e.out = appendBigEndian(e.out, 32, uint64(length))
+	}
+}
+
+// appends the low bits of the value, most significant byte first
+func appendBigEndian(out []byte, bits uint, value uint64) []byte {
+	for shift := int(bits) - 8; shift >= 0; shift -= 8 {
+		out = append(out, byte(value>>uint(shift)))
+	}
+	return out
+}
+
+func (e *msgpackEncoder) encodeString(s string) {
+	e.header(0xa0, 31, 0xd9, 0xda, 0xdb, len(s))
+	e.out = append(e.out, s...)
+}
+
+func (e *msgpackEncoder) encodeUint(u uint64) {
+	switch {
+	case u <= 0x7f:
+		e.out = append(e.out, byte(u))
+	case u <= math.MaxUint8:
+		e.out = append(e.out, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		e.out = append(e.out, 0xcd)
+		e.out = appendBigEndian(e.out, 16, u)
+	case u <= math.MaxUint32:
+		e.out = append(e.out, 0xce)
+		e.out = appendBigEndian(e.out, 32, u)
+	default:
+		e.out = append(e.out, 0xcf)
+		e.out = appendBigEndian(e.out, 64, u)
+	}
+}
+
+func (e *msgpackEncoder) encodeInt(i int64) {
+	switch {
+	case i >= 0:
+		e.encodeUint(uint64(i))
+	case i >= -32:
+		e.out = append(e.out, byte(i))
+	case i >= math.MinInt8:
+		e.out = append(e.out, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		e.out = append(e.out, 0xd1)
+		e.out = appendBigEndian(e.out, 16, uint64(i))
+	case i >= math.MinInt32:
+		e.out = append(e.out, 0xd2)
+		e.out = appendBigEndian(e.out, 32, uint64(i))
+	default:
+		e.out = append(e.out, 0xd3)
+		e.out = appendBigEndian(e.out, 64, uint64(i))
+	}
+}
+
+func (e *msgpackEncoder) encode(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Invalid:
+		e.out = append(e.out, 0xc0)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.out = append(e.out, 0xc0)
+		} else {
+			return e.encode(v.Elem())
+		}
+	case reflect.Bool:
+		if v.Bool() {
+			e.out = append(e.out, 0xc3)
+		} else {
+			e.out = append(e.out, 0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.out = append(e.out, 0xcb)
+		e.out = appendBigEndian(e.out, 64, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.encodeString(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			e.out = append(e.out, 0xc0)
+			return nil
+		}
+		e.header(0x90, 15, 0, 0xdc, 0xdd, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			e.out = append(e.out, 0xc0)
+			return nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("cannot encode map with %v keys as msgpack", v.Type().Key())
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		e.header(0x80, 15, 0, 0xde, 0xdf, len(keys))
+		for _, key := range keys {
+			e.encodeString(key.String())
+			if err := e.encode(v.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		fields := msgpackFields(v.Type())
+		var present []msgpackField
+		for _, field := range fields {
+			if !field.OmitEmpty || !isEmptyValue(v.FieldByIndex(field.Index)) {
+				present = append(present, field)
+			}
+		}
+		e.header(0x80, 15, 0, 0xde, 0xdf, len(present))
+		for _, field := range present {
+			e.encodeString(field.Name)
+			if err := e.encode(v.FieldByIndex(field.Index)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode %v as msgpack", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package webclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+)
+
+// the version of the protocol spoken over the websocket, which changes whenever an older client would misunderstand
+// what the server sends
+const ProtocolVersion = 1
+
+// the first message that a client sends, which lists the protocol versions and message encodings that it understands,
+// in order of preference
+type Handshake struct {
+	Versions  []int    `json:"versions"`
+	Encodings []string `json:"encodings"`
+	// the token of the session to resume, if the client was disconnected
+	Resume string `json:"resume,omitempty"`
+	// the serial of the last update received before the client was disconnected
+	Received uint64 `json:"received,omitempty"`
+}
+
+// the server's answer to a Handshake, which either chooses a version and an encoding, or explains why the client
+// cannot connect
+type HandshakeReply struct {
+	Version  int    `json:"version,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// set when the client was refused because it needs to log in first
+	Login bool `json:"login,omitempty"`
+	// the token to send back in the Handshake when reconnecting
+	Session string `json:"session,omitempty"`
+}
+
+// how messages from the server are encoded; messages from clients are always JSON
+type messageEncoding struct {
+	Name        string
+	MessageType int
+	Marshal     func(v interface{}) ([]byte, error)
+}
+
+var messageEncodings = []messageEncoding{
+	{Name: "msgpack", MessageType: websocket.BinaryMessage, Marshal: marshalMsgpack},
+	{Name: "json", MessageType: websocket.TextMessage, Marshal: json.Marshal},
+}
+
+// chooses the encoding that the client prefers most out of the ones that the server supports
+func negotiate(handshake Handshake) (messageEncoding, error) {
+	versionOk := false
+	for _, version := range handshake.Versions {
+		if version == ProtocolVersion {
+			versionOk = true
+		}
+	}
+	if !versionOk {
+		return messageEncoding{}, fmt.Errorf("this client is out of date; the server only supports protocol version %d, so please reload the page", ProtocolVersion)
+	}
+	for _, name := range handshake.Encodings {
+		for _, encoding := range messageEncodings {
+			if encoding.Name == name {
+				return encoding, nil
+			}
+		}
+	}
+	return messageEncoding{}, fmt.Errorf("this client does not support any message encoding that the server supports")
+}
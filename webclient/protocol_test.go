@@ -0,0 +1,57 @@
+package webclient
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	encoding, err := negotiate(Handshake{Versions: []int{ProtocolVersion}, Encodings: []string{"cbor", "json", "msgpack"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "json", encoding.Name)
+
+	_, err = negotiate(Handshake{Versions: []int{ProtocolVersion + 1}, Encodings: []string{"json"}})
+	assert.Error(t, err)
+
+	// clients from before the handshake existed send a command instead, which has neither field
+	_, err = negotiate(Handshake{})
+	assert.Error(t, err)
+
+	_, err = negotiate(Handshake{Versions: []int{ProtocolVersion}, Encodings: []string{"cbor"}})
+	assert.Error(t, err)
+}
+
+type testEmbedded struct {
+	B int `json:"b"`
+}
+
+type testMessage struct {
+	testEmbedded
+	A       string            `json:"a"`
+	Skipped []int             `json:"skipped,omitempty"`
+	List    []int             `json:"list"`
+	Map     map[string]bool   `json:"map"`
+	Ptr     *testEmbedded     `json:"ptr"`
+	Nested  map[string]string `json:"nested,omitempty"`
+}
+
+func TestMsgpack(t *testing.T) {
+	data, err := marshalMsgpack(testMessage{
+		testEmbedded: testEmbedded{B: -1},
+		A:            "hi",
+		List:         []int{1, 300},
+		Map:          map[string]bool{"y": true, "x": false},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x85,
+		0xa1, 'b', 0xff,
+		0xa1, 'a', 0xa2, 'h', 'i',
+		0xa4, 'l', 'i', 's', 't', 0x92, 0x01, 0xcd, 0x01, 0x2c,
+		0xa3, 'm', 'a', 'p', 0x82, 0xa1, 'x', 0xc2, 0xa1, 'y', 0xc3,
+		0xa3, 'p', 't', 'r', 0xc0,
+	}, data)
+
+	_, err = marshalMsgpack(map[int]int{1: 2})
+	assert.Error(t, err)
+}
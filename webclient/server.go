@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 	"net/http"
 	"path"
+	"strings"
 	"time"
 )
 
@@ -49,6 +50,18 @@ func AttachResources(mux *http.ServeMux, path string, resources []string) error
 	return nil
 }
 
+// serves the resources that are created while the server runs; these are all named within the dynamic directory
+func AttachDynamicResources(mux *http.ServeMux, basepath string, api ServerAPI) {
+	mux.HandleFunc(path.Join(basepath, "dynamic")+"/", func(writer http.ResponseWriter, request *http.Request) {
+		resource, found := api.DynamicResource(strings.TrimPrefix(request.URL.Path, basepath))
+		if !found {
+			http.Error(writer, "Not Found", 404)
+			return
+		}
+		http.ServeContent(writer, request, resource.Name, resource.Modified, bytes.NewReader(resource.Data))
+	})
+}
+
 func CreateMux(api ServerAPI) (*http.ServeMux, error) {
 	mux := http.NewServeMux()
 	pack := api.ResourcePack()
@@ -76,7 +89,11 @@ func CreateMux(api ServerAPI) (*http.ServeMux, error) {
 	if err != nil {
 		return nil, err
 	}
-	wss := NewWebSocketServer(api)
+	AttachDynamicResources(mux, "/resource/", api)
+	signer := newCookieSigner()
+	mux.Handle("/login", loginHandler(api, signer))
+	mux.Handle("/logout", logoutHandler())
+	wss := NewWebSocketServer(api, signer)
 	mux.Handle("/websocket", wss)
 	return mux, nil
 }
@@ -0,0 +1,41 @@
+package sprite
+
+import (
+	"fmt"
+	"github.com/celskeggs/mediator/platform/types"
+)
+
+// what browse() and browse_rsc() send to a player: either HTML to show in a popup window, a request to close a
+// window, or a resource that HTML in popup windows can refer to by name
+type Browse struct {
+	// windows with the same name replace each other
+	Window string `json:"window,omitempty"`
+	HTML   string `json:"html,omitempty"`
+	Close  bool   `json:"close,omitempty"`
+	Width  uint   `json:"width,omitempty"`
+	Height uint   `json:"height,omitempty"`
+	// for browse_rsc(), the name that HTML uses for the resource, and the resource in the resource pack
+	Name     string `json:"name,omitempty"`
+	Resource string `json:"resource,omitempty"`
+}
+
+var _ types.Value = Browse{}
+
+func (b Browse) Var(name string) types.Value {
+	panic("no such field " + name + " on browse()")
+}
+
+func (b Browse) SetVar(name string, value types.Value) {
+	panic("no such field " + name + " on browse()")
+}
+
+func (b Browse) Invoke(usr *types.Datum, name string, parameters ...types.Value) types.Value {
+	panic("no such proc " + name + " on browse()")
+}
+
+func (b Browse) String() string {
+	if b.Resource != "" {
+		return fmt.Sprintf("[browse_rsc: %q as %q]", b.Resource, b.Name)
+	}
+	return fmt.Sprintf("[browse: %q]", b.Window)
+}
@@ -0,0 +1,214 @@
+package sprite
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// identifies a sprite from one view to the next
+type SpriteKey struct {
+	UID  uint64 `json:"uid"`
+	Part uint   `json:"part,omitempty"`
+}
+
+func (s GameSprite) Key() SpriteKey {
+	return SpriteKey{UID: s.UID, Part: s.Part}
+}
+
+// a sprite as it is sent to clients, which refers to its look by index. sprites are positioned relative to the map,
+// rather than the viewport, so that they do not need to be sent again when the viewport moves.
+type SentSprite struct {
+	Look  int      `json:"look"`
+	X     int      `json:"x"`
+	Y     int      `json:"y"`
+	Name  string   `json:"name,omitempty"`
+	Verbs []string `json:"verbs,omitempty"`
+	UID   uint64   `json:"uid"`
+	Part  uint     `json:"part,omitempty"`
+	Layer int      `json:"layer"`
+	Seq   uint     `json:"seq,omitempty"`
+}
+
+func (s SentSprite) Equal(o SentSprite) bool {
+	return s.Look == o.Look && s.X == o.X && s.Y == o.Y && s.Name == o.Name && s.UID == o.UID && s.Part == o.Part &&
+		s.Layer == o.Layer && s.Seq == o.Seq && stringsEqual(s.Verbs, o.Verbs)
+}
+
+type Origin struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// the changes to a client's view since the last delta. a keyframe starts over from an empty view, rather than
+// building on the previous one, and every field that is left out has not changed.
+type ViewDelta struct {
+	Keyframe       bool    `json:"keyframe,omitempty"`
+	WindowTitle    *string `json:"windowtitle,omitempty"`
+	ViewPortWidth  uint    `json:"viewportwidth,omitempty"`
+	ViewPortHeight uint    `json:"viewportheight,omitempty"`
+	Origin         *Origin `json:"origin,omitempty"`
+	// looks are numbered in the order that they are sent, starting from zero at each keyframe
+	Looks []SpriteLook `json:"looks,omitempty"`
+	// sprites that were added or changed
+	Sprites []SentSprite `json:"sprites,omitempty"`
+	Removed []SpriteKey  `json:"removed,omitempty"`
+	// the stat panels that were added or changed, or nil for the panels that were removed
+	Stats map[string]*StatPanel `json:"stats,omitempty"`
+	Verbs *[]string             `json:"verbs,omitempty"`
+	// a light map with no tiles means that every tile is now fully lit
+	LightMap *LightMap `json:"lightmap,omitempty"`
+}
+
+type sentRecord struct {
+	Sprite SentSprite
+	Look   SpriteLook
+}
+
+// keeps track of what one client has been sent, so that only the changes need to be sent next time
+type ViewEncoder struct {
+	keyframeInterval int
+	sinceKeyframe    int
+	started          bool
+	last             SpriteView
+	sprites          map[SpriteKey]sentRecord
+	lookIndexes      map[string]int
+}
+
+// a keyframe is sent after every keyframeInterval deltas, which keeps the table of looks from growing forever
+func NewViewEncoder(keyframeInterval int) *ViewEncoder {
+	if keyframeInterval < 1 {
+		panic("keyframe interval must be positive")
+	}
+	return &ViewEncoder{
+		keyframeInterval: keyframeInterval,
+	}
+}
+
+func (e *ViewEncoder) lookIndex(look SpriteLook, previous sentRecord, hasPrevious bool, delta *ViewDelta) int {
+	if hasPrevious && previous.Look.Equal(look) {
+		return previous.Sprite.Look
+	}
+	key, err := json.Marshal(look)
+	if err != nil {
+		panic("cannot encode sprite look: " + err.Error())
+	}
+	index, found := e.lookIndexes[string(key)]
+	if !found {
+		index = len(e.lookIndexes)
+		e.lookIndexes[string(key)] = index
+		delta.Looks = append(delta.Looks, look)
+	}
+	return index
+}
+
+func (e *ViewEncoder) encodeSprites(view SpriteView, delta *ViewDelta) {
+	// underlays and overlays have the same name and verbs as the atom that they are attached to, so those are only
+	// sent with the atom's own sprite
+	hasMainSprite := map[uint64]bool{}
+	for _, s := range view.Sprites {
+		if s.Part == 0 {
+			hasMainSprite[s.UID] = true
+		}
+	}
+	seen := map[SpriteKey]bool{}
+	for _, s := range view.Sprites {
+		key := s.Key()
+		if seen[key] {
+			panic("duplicate sprite in view")
+		}
+		seen[key] = true
+		previous, hasPrevious := e.sprites[key]
+		sent := SentSprite{
+			Look:  e.lookIndex(s.SpriteLook, previous, hasPrevious, delta),
+			X:     s.X + view.OriginX,
+			Y:     s.Y + view.OriginY,
+			Name:  s.Name,
+			Verbs: s.Verbs,
+			UID:   s.UID,
+			Part:  s.Part,
+			Layer: s.Layer,
+			Seq:   s.Seq,
+		}
+		if s.Part != 0 && hasMainSprite[s.UID] {
+			sent.Name, sent.Verbs = "", nil
+		}
+		if !hasPrevious || !previous.Sprite.Equal(sent) {
+			delta.Sprites = append(delta.Sprites, sent)
+		}
+		e.sprites[key] = sentRecord{Sprite: sent, Look: s.SpriteLook}
+	}
+	for key := range e.sprites {
+		if !seen[key] {
+			delta.Removed = append(delta.Removed, key)
+			delete(e.sprites, key)
+		}
+	}
+	sort.Slice(delta.Removed, func(i, j int) bool {
+		a, b := delta.Removed[i], delta.Removed[j]
+		return a.UID < b.UID || (a.UID == b.UID && a.Part < b.Part)
+	})
+}
+
+func (e *ViewEncoder) encodeStats(view SpriteView, delta *ViewDelta) {
+	for name, panel := range view.Stats.Panels {
+		if old, found := e.last.Stats.Panels[name]; !found || !old.Equal(panel) {
+			if delta.Stats == nil {
+				delta.Stats = map[string]*StatPanel{}
+			}
+			p := panel
+			delta.Stats[name] = &p
+		}
+	}
+	for name := range e.last.Stats.Panels {
+		if _, found := view.Stats.Panels[name]; !found {
+			if delta.Stats == nil {
+				delta.Stats = map[string]*StatPanel{}
+			}
+			delta.Stats[name] = nil
+		}
+	}
+}
+
+// produces the delta that brings the client up to date with the view, or nil if nothing has changed
+func (e *ViewEncoder) Encode(view SpriteView) *ViewDelta {
+	delta := &ViewDelta{}
+	if !e.started || e.sinceKeyframe >= e.keyframeInterval {
+		// the client starts over from an empty view, so everything is compared against one
+		e.started = true
+		e.sinceKeyframe = 0
+		e.last = SpriteView{}
+		e.sprites = map[SpriteKey]sentRecord{}
+		e.lookIndexes = map[string]int{}
+		delta.Keyframe = true
+	}
+	if view.WindowTitle != e.last.WindowTitle {
+		title := view.WindowTitle
+		delta.WindowTitle = &title
+	}
+	if view.ViewPortWidth != e.last.ViewPortWidth || view.ViewPortHeight != e.last.ViewPortHeight {
+		delta.ViewPortWidth, delta.ViewPortHeight = view.ViewPortWidth, view.ViewPortHeight
+	}
+	if view.OriginX != e.last.OriginX || view.OriginY != e.last.OriginY {
+		delta.Origin = &Origin{X: view.OriginX, Y: view.OriginY}
+	}
+	e.encodeSprites(view, delta)
+	e.encodeStats(view, delta)
+	if !stringsEqual(view.Verbs, e.last.Verbs) {
+		verbs := append([]string{}, view.Verbs...)
+		delta.Verbs = &verbs
+	}
+	if !view.LightMap.Equal(e.last.LightMap) {
+		delta.LightMap = view.LightMap
+		if delta.LightMap == nil {
+			delta.LightMap = &LightMap{}
+		}
+	}
+	e.last = view
+	if !delta.Keyframe && delta.WindowTitle == nil && delta.ViewPortWidth == 0 && delta.ViewPortHeight == 0 &&
+		delta.Origin == nil && delta.Looks == nil && delta.Sprites == nil && delta.Removed == nil &&
+		delta.Stats == nil && delta.Verbs == nil && delta.LightMap == nil {
+		return nil
+	}
+	e.sinceKeyframe += 1
+	return delta
+}
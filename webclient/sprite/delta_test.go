@@ -0,0 +1,102 @@
+package sprite
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func testSprite(uid uint64, part uint, x, y int, icon string) GameSprite {
+	return GameSprite{
+		SpriteLook: SpriteLook{Icon: icon, Width: 32, Height: 32, Alpha: 255},
+		X:          x,
+		Y:          y,
+		Name:       "thing",
+		UID:        uid,
+		Part:       part,
+	}
+}
+
+func TestDeltaKeyframe(t *testing.T) {
+	encoder := NewViewEncoder(10)
+	delta := encoder.Encode(SpriteView{
+		WindowTitle: "test",
+		Sprites: []GameSprite{
+			testSprite(1, 0, 0, 0, "floor.dmi"),
+			testSprite(2, 0, 32, 0, "floor.dmi"),
+			testSprite(2, 1, 32, 0, "hat.dmi"),
+		},
+		Verbs: []string{"say"},
+	})
+	assert.True(t, delta.Keyframe)
+	assert.Equal(t, "test", *delta.WindowTitle)
+	assert.Equal(t, []string{"say"}, *delta.Verbs)
+	// the two floors share a look, and the overlay takes its name from the atom that it is attached to
+	assert.Len(t, delta.Looks, 2)
+	assert.Len(t, delta.Sprites, 3)
+	assert.Equal(t, delta.Sprites[0].Look, delta.Sprites[1].Look)
+	assert.Equal(t, "", delta.Sprites[2].Name)
+	assert.Nil(t, delta.Removed)
+}
+
+func TestDeltaUnchanged(t *testing.T) {
+	encoder := NewViewEncoder(10)
+	view := SpriteView{Sprites: []GameSprite{testSprite(1, 0, 0, 0, "floor.dmi")}}
+	assert.NotNil(t, encoder.Encode(view))
+	assert.Nil(t, encoder.Encode(view))
+}
+
+func TestDeltaChanges(t *testing.T) {
+	encoder := NewViewEncoder(10)
+	encoder.Encode(SpriteView{
+		Sprites: []GameSprite{
+			testSprite(1, 0, 0, 0, "floor.dmi"),
+			testSprite(2, 0, 32, 0, "mob.dmi"),
+			testSprite(3, 0, 64, 0, "mob.dmi"),
+		},
+		Stats: StatDisplay{Panels: map[string]StatPanel{
+			"Stats":     {Entries: []StatEntry{{Label: "Health", Name: "10"}}},
+			"Inventory": {},
+		}},
+	})
+	// the viewport moves one tile to the right along with the mob, so only the mob itself needs to be sent again
+	delta := encoder.Encode(SpriteView{
+		OriginX: 32,
+		Sprites: []GameSprite{
+			testSprite(1, 0, -32, 0, "floor.dmi"),
+			testSprite(2, 0, 32, 0, "mob.dmi"),
+		},
+		Stats: StatDisplay{Panels: map[string]StatPanel{
+			"Stats": {Entries: []StatEntry{{Label: "Health", Name: "9"}}},
+		}},
+	})
+	assert.False(t, delta.Keyframe)
+	assert.Equal(t, &Origin{X: 32}, delta.Origin)
+	assert.Nil(t, delta.Looks)
+	if assert.Len(t, delta.Sprites, 1) {
+		assert.Equal(t, uint64(2), delta.Sprites[0].UID)
+		assert.Equal(t, 64, delta.Sprites[0].X)
+	}
+	assert.Equal(t, []SpriteKey{{UID: 3}}, delta.Removed)
+	assert.Len(t, delta.Stats, 2)
+	assert.Equal(t, "9", delta.Stats["Stats"].Entries[0].Name)
+	assert.Nil(t, delta.Stats["Inventory"])
+	assert.Nil(t, delta.Verbs)
+}
+
+func TestDeltaLightMap(t *testing.T) {
+	encoder := NewViewEncoder(10)
+	lights := &LightMap{Width: 1, Height: 1, Levels: []string{"#000000"}}
+	assert.Nil(t, encoder.Encode(SpriteView{}).LightMap)
+	assert.Equal(t, lights, encoder.Encode(SpriteView{LightMap: lights}).LightMap)
+	// going back to being fully lit is sent as an empty light map
+	assert.Equal(t, &LightMap{}, encoder.Encode(SpriteView{}).LightMap)
+}
+
+func TestDeltaPeriodicKeyframes(t *testing.T) {
+	encoder := NewViewEncoder(2)
+	assert.True(t, encoder.Encode(SpriteView{WindowTitle: "a"}).Keyframe)
+	assert.False(t, encoder.Encode(SpriteView{WindowTitle: "b"}).Keyframe)
+	delta := encoder.Encode(SpriteView{WindowTitle: "b"})
+	assert.True(t, delta.Keyframe)
+	assert.Equal(t, "b", *delta.WindowTitle)
+}
@@ -0,0 +1,24 @@
+package sprite
+
+type PromptKind string
+
+const (
+	PromptText    PromptKind = "text"
+	PromptMessage PromptKind = "message"
+	PromptNum     PromptKind = "num"
+	PromptList    PromptKind = "list"
+	PromptAlert   PromptKind = "alert"
+)
+
+// a question from input() or alert(), which the player answers with a webclient.PromptAnswer with the same ID
+type Prompt struct {
+	ID      uint64     `json:"id"`
+	Kind    PromptKind `json:"kind"`
+	Title   string     `json:"title,omitempty"`
+	Message string     `json:"message,omitempty"`
+	Default string     `json:"default,omitempty"`
+	// the entries of a list, or the buttons of an alert
+	Choices []string `json:"choices,omitempty"`
+	// whether the player may cancel instead of answering
+	Cancel bool `json:"cancel,omitempty"`
+}
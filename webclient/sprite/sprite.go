@@ -1,53 +1,117 @@
 package sprite
 
-import "github.com/celskeggs/mediator/platform/icon"
+import (
+	"github.com/celskeggs/mediator/platform/icon"
+)
 
-type GameSprite struct {
+// the parts of a sprite that describe what it looks like. many sprites look the same, such as the overlays that many
+// atoms share, so each distinct look is only sent once between keyframes.
+type SpriteLook struct {
 	Icon         string          `json:"icon"`
 	Frames       []icon.SourceXY `json:"frames"`
 	SourceWidth  uint            `json:"sw"`
 	SourceHeight uint            `json:"sh"`
-	X            uint            `json:"x"`
-	Y            uint            `json:"y"`
 	Width        uint            `json:"w"`
 	Height       uint            `json:"h"`
-	Name         string          `json:"name"`
-	Verbs        []string        `json:"verbs"`
-	UID          uint64          `json:"uid"`
+	// the timing of the frames, which is sent to clients as part of the sprite itself
+	icon.Animation
+	// a color to multiply the icon by, as #rrggbb or #rrggbbaa
+	Color string `json:"color,omitempty"`
+	// a 20-entry color matrix; see atoms.ParseColorMatrix
+	ColorMatrix []float64 `json:"colormatrix,omitempty"`
+	Alpha       int       `json:"alpha"`
+	// the six entries a-f of a transformation matrix, which is applied around the center of the icon
+	Transform    []float64 `json:"transform,omitempty"`
+	BlendMode    int       `json:"blend,omitempty"`
+	MouseOpacity int       `json:"mouseopacity"`
 }
 
-func (s GameSprite) Equal(o GameSprite) bool {
-	if !(s.Icon == o.Icon &&
-		s.SourceWidth == o.SourceWidth && s.SourceHeight == o.SourceHeight &&
-		s.X == o.X && s.Y == o.Y && s.Width == o.Width && s.Height == o.Height &&
-		s.Name == o.Name && s.UID == o.UID) {
+func floatsEqual(a []float64, b []float64) bool {
+	if len(a) != len(b) {
 		return false
 	}
-	if len(s.Frames) != len(o.Frames) {
+	for i, v := range a {
+		if b[i] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (l SpriteLook) Equal(o SpriteLook) bool {
+	if !(l.Icon == o.Icon &&
+		l.SourceWidth == o.SourceWidth && l.SourceHeight == o.SourceHeight &&
+		l.Width == o.Width && l.Height == o.Height && l.Animation.Equal(o.Animation) &&
+		l.Color == o.Color && floatsEqual(l.ColorMatrix, o.ColorMatrix) && l.Alpha == o.Alpha &&
+		floatsEqual(l.Transform, o.Transform) && l.BlendMode == o.BlendMode && l.MouseOpacity == o.MouseOpacity) {
 		return false
 	}
-	for i, v := range s.Frames {
+	if len(l.Frames) != len(o.Frames) {
+		return false
+	}
+	for i, v := range l.Frames {
 		if o.Frames[i] != v {
 			return false
 		}
 	}
-	if len(s.Verbs) != len(o.Verbs) {
+	return true
+}
+
+type GameSprite struct {
+	SpriteLook
+	X     int      `json:"x"`
+	Y     int      `json:"y"`
+	Name  string   `json:"name"`
+	Verbs []string `json:"verbs"`
+	UID   uint64   `json:"uid"`
+	// zero for an atom itself, and distinct for each of the underlays and overlays that are drawn along with it
+	Part uint `json:"part,omitempty"`
+	// the layer that the sprite is drawn on, and its position among the sprites of its atom, which are drawn in the
+	// order of the atom's underlays, the atom itself, and then its overlays
+	Layer int  `json:"layer"`
+	Seq   uint `json:"seq,omitempty"`
+}
+
+func stringsEqual(a []string, b []string) bool {
+	if len(a) != len(b) {
 		return false
 	}
-	for i, v := range s.Verbs {
-		if o.Verbs[i] != v {
+	for i, v := range a {
+		if b[i] != v {
 			return false
 		}
 	}
 	return true
 }
 
+func (s GameSprite) Equal(o GameSprite) bool {
+	if !(s.X == o.X && s.Y == o.Y && s.Name == o.Name && s.UID == o.UID && s.Part == o.Part &&
+		s.Layer == o.Layer && s.Seq == o.Seq) {
+		return false
+	}
+	return s.SpriteLook.Equal(o.SpriteLook) && stringsEqual(s.Verbs, o.Verbs)
+}
+
+// sprites are drawn in order of layer, and then grouped by the atom that they belong to, so that the order does not
+// depend on anything else that happens to be in view
+func (s GameSprite) DrawsBefore(o GameSprite) bool {
+	if s.Layer != o.Layer {
+		return s.Layer < o.Layer
+	}
+	if s.UID != o.UID {
+		return s.UID < o.UID
+	}
+	return s.Seq < o.Seq
+}
+
 type Flick struct {
 	Icon         string          `json:"icon"`
 	Frames       []icon.SourceXY `json:"frames"`
 	SourceWidth  uint            `json:"sw"`
 	SourceHeight uint            `json:"sh"`
 	UID          uint64          `json:"uid"`
+	// a flick plays exactly once, so its Loop is ignored
+	icon.Animation
 }
 
 type StatEntry struct {
@@ -144,50 +208,40 @@ func (d StatDisplay) Equal(o StatDisplay) bool {
 	return true
 }
 
-type SpriteView struct {
-	WindowTitle    string       `json:"windowtitle"`
-	ViewPortWidth  uint         `json:"viewportwidth"`
-	ViewPortHeight uint         `json:"viewportheight"`
-	Sprites        []GameSprite `json:"sprites"`
-	Stats          StatDisplay  `json:"stats"`
-	Verbs          []string     `json:"verbs"`
+// the light level of each tile in the viewport, which is multiplied into everything drawn on that tile
+type LightMap struct {
+	Width  uint `json:"width"`
+	Height uint `json:"height"`
+	// colors like "#rrggbb", in rows from the bottom of the viewport up, where "#ffffff" is fully lit
+	Levels []string `json:"levels"`
 }
 
-func (a SpriteView) Equal(b SpriteView) bool {
-	if a.ViewPortWidth != b.ViewPortWidth || a.ViewPortHeight != b.ViewPortHeight {
-		return false
+func (a *LightMap) Equal(b *LightMap) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
-	if !a.Stats.Equal(b.Stats) {
+	if a.Width != b.Width || a.Height != b.Height || len(a.Levels) != len(b.Levels) {
 		return false
 	}
-	if len(a.Verbs) != len(b.Verbs) {
-		return false
-	}
-	for i, verb := range a.Verbs {
-		if b.Verbs[i] != verb {
+	for i, level := range a.Levels {
+		if b.Levels[i] != level {
 			return false
 		}
 	}
-	if len(a.Sprites) != len(b.Sprites) {
-		return false
-	}
-	used := make([]bool, len(a.Sprites))
-	for _, sprite := range b.Sprites {
-		found := false
-		for i, cmp := range a.Sprites {
-			if !used[i] && sprite.Equal(cmp) {
-				used[i] = true
-				found = true
-				break
-			}
-		}
-		if !found {
-			return false
-		}
-	}
-	// at this point, we know that:
-	//  - A and B have the same number of elements
-	//  - every element in B is also in A
-	// so we can conclude that A and B have the same elements
 	return true
 }
+
+type SpriteView struct {
+	WindowTitle    string `json:"windowtitle"`
+	ViewPortWidth  uint   `json:"viewportwidth"`
+	ViewPortHeight uint   `json:"viewportheight"`
+	// the position of the bottom left corner of the viewport on the map, in pixels. sprites are positioned relative to
+	// the viewport.
+	OriginX int          `json:"originx"`
+	OriginY int          `json:"originy"`
+	Sprites []GameSprite `json:"sprites"`
+	Stats   StatDisplay  `json:"stats"`
+	Verbs   []string     `json:"verbs"`
+	// nil if every tile in the viewport is fully lit
+	LightMap *LightMap `json:"lightmap,omitempty"`
+}
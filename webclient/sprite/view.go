@@ -1,8 +1,17 @@
 package sprite
 
 type ViewUpdate struct {
-	NewState  *SpriteView `json:"newstate"`
-	TextLines []string    `json:"textlines"`
-	Sounds    []Sound     `json:"sounds"`
-	Flicks    []Flick     `json:"flicks"`
+	// numbers the updates sent to a session, starting from 1, so that the client can say which ones it has received
+	Serial    uint64     `json:"serial,omitempty"`
+	Delta     *ViewDelta `json:"delta,omitempty"`
+	TextLines []string   `json:"textlines"`
+	Sounds    []Sound    `json:"sounds"`
+	Flicks    []Flick    `json:"flicks"`
+	Browse    []Browse   `json:"browse,omitempty"`
+	Prompts   []Prompt   `json:"prompts,omitempty"`
+}
+
+// true if there is nothing in the update worth sending
+func (v *ViewUpdate) IsEmpty() bool {
+	return v.Delta == nil && v.TextLines == nil && v.Sounds == nil && v.Flicks == nil && v.Browse == nil && v.Prompts == nil
 }
@@ -17,14 +17,24 @@ func (e ErrorStop) Error() string {
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	// permessage-deflate, which is used if the browser supports it
+	EnableCompression: true,
 }
 
+// the largest message that a client may send. commands are small, other than text answers to prompts, where each
+// character takes at most six bytes once it has been encoded as JSON.
+const maxCommandSize = 1024 + 6*MaxAnswerLength
+
+// messages smaller than this are not worth compressing
+const compressionThreshold = 256
+
 type WebSocketClient struct {
 	api ServerSession
 }
 
 type WebSocketServer struct {
-	api ServerAPI
+	api    ServerAPI
+	signer *cookieSigner
 }
 
 func setConnectionTimeout(conn *websocket.Conn, timeout time.Duration) error {
@@ -47,12 +57,6 @@ func (wss *WebSocketServer) handleSessionTransmit(session ServerSession, conn *w
 			log.Printf("error during session close: %v", err)
 		}
 	}()
-	conn.SetReadLimit(1024)
-	err := setConnectionTimeout(conn, time.Minute)
-	if err != nil {
-		log.Printf("error during session setup: %v", err)
-		return
-	}
 	for {
 		var command Command
 		err := conn.ReadJSON(&command)
@@ -66,7 +70,7 @@ func (wss *WebSocketServer) handleSessionTransmit(session ServerSession, conn *w
 	}
 }
 
-func (wss *WebSocketServer) handleSessionReceive(session ServerSession, conn *websocket.Conn) {
+func (wss *WebSocketServer) handleSessionReceive(session ServerSession, conn *websocket.Conn, encoding messageEncoding) {
 	ticker := time.NewTicker(40 * time.Second)
 	sendChannel := make(chan *sprite.ViewUpdate)
 	terminated := false
@@ -109,7 +113,13 @@ func (wss *WebSocketServer) handleSessionReceive(session ServerSession, conn *we
 				return
 			}
 
-			err = conn.WriteJSON(message)
+			data, err := encoding.Marshal(message)
+			if err != nil {
+				log.Printf("error encoding message: %v", err)
+				return
+			}
+			conn.EnableWriteCompression(len(data) >= compressionThreshold)
+			err = conn.WriteMessage(encoding.MessageType, data)
 			if err != nil {
 				return
 			}
@@ -121,20 +131,78 @@ func (wss *WebSocketServer) handleSessionReceive(session ServerSession, conn *we
 	}
 }
 
+// tells the client why it cannot be served, and then closes the websocket
+func refuse(conn *websocket.Conn, reply HandshakeReply) {
+	log.Printf("refusing client: %v", reply.Error)
+	if err := conn.WriteJSON(reply); err != nil {
+		log.Printf("error sending handshake reply: %v", err)
+		return
+	}
+	message := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "connection refused")
+	if err := conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second)); err != nil {
+		log.Printf("error sending close message: %v", err)
+	}
+}
+
+// waits for the client's Handshake, connects its session, and answers it. if the client cannot be served, it is told
+// why and the connection is closed.
+func (wss *WebSocketServer) handshake(conn *websocket.Conn, key string, loggedIn bool) (ServerSession, messageEncoding, bool) {
+	var handshake Handshake
+	if err := conn.ReadJSON(&handshake); err != nil {
+		log.Printf("error reading handshake: %v", err)
+		return nil, messageEncoding{}, false
+	}
+	encoding, err := negotiate(handshake)
+	if err != nil {
+		refuse(conn, HandshakeReply{Error: err.Error()})
+		return nil, messageEncoding{}, false
+	}
+	if !loggedIn {
+		refuse(conn, HandshakeReply{Error: "you need to log in to play", Login: true})
+		return nil, messageEncoding{}, false
+	}
+	session := wss.api.Connect(key, handshake.Resume, handshake.Received)
+	reply := HandshakeReply{Version: ProtocolVersion, Encoding: encoding.Name, Session: session.Token()}
+	if err := conn.WriteJSON(reply); err != nil {
+		log.Printf("error sending handshake reply: %v", err)
+		session.Close()
+		return nil, messageEncoding{}, false
+	}
+	return session, encoding, true
+}
+
 func (wss *WebSocketServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	key, loggedIn := "", true
+	if wss.api.LoginRequired() {
+		key, loggedIn = wss.signer.KeyFor(request)
+	}
 	conn, err := upgrader.Upgrade(writer, request, nil)
 	if err != nil {
 		log.Printf("websocket establishment error: %v", err)
 		http.Error(writer, "Failed", 400)
 		return
 	}
-	session := wss.api.Connect()
+	conn.SetReadLimit(maxCommandSize)
+	err = setConnectionTimeout(conn, time.Minute)
+	if err != nil {
+		log.Printf("error during session setup: %v", err)
+		_ = conn.Close()
+		return
+	}
+	session, encoding, ok := wss.handshake(conn, key, loggedIn)
+	if !ok {
+		if err := conn.Close(); err != nil {
+			log.Printf("error during session close: %v", err)
+		}
+		return
+	}
 	go wss.handleSessionTransmit(session, conn)
-	go wss.handleSessionReceive(session, conn)
+	go wss.handleSessionReceive(session, conn, encoding)
 }
 
-func NewWebSocketServer(api ServerAPI) *WebSocketServer {
+func NewWebSocketServer(api ServerAPI, signer *cookieSigner) *WebSocketServer {
 	return &WebSocketServer{
-		api: api,
+		api:    api,
+		signer: signer,
 	}
 }
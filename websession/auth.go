@@ -0,0 +1,209 @@
+package websession
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/celskeggs/mediator/common"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// decides who a player is. the key that is returned becomes the player's /client.key.
+type Authenticator interface {
+	Login(username, password string) (key string, err error)
+	// authenticators that cannot create accounts should always return an error
+	Register(username, password string) (key string, err error)
+}
+
+const (
+	passwordIterations = 100000
+	passwordSaltSize   = 16
+	minPasswordLength  = 8
+	maxKeyLength       = 30
+)
+
+type localAccount struct {
+	Key        string
+	Iterations int
+	Salt       []byte
+	Hash       []byte
+}
+
+// an Authenticator for accounts stored in a local file, with one account per line in the form
+// key:iterations:salt:hash. passwords are hashed with PBKDF2-HMAC-SHA256.
+type LocalAccounts struct {
+	path     string
+	lock     sync.Mutex
+	accounts map[string]localAccount // by ckey
+}
+
+var _ Authenticator = &LocalAccounts{}
+
+// loads the accounts from the file, which does not need to exist yet
+func LoadLocalAccounts(filename string) (*LocalAccounts, error) {
+	la := &LocalAccounts{
+		path:     filename,
+		accounts: map[string]localAccount{},
+	}
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return la, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		account, err := parseLocalAccount(scanner.Text())
+		if err != nil {
+			return nil, errors.Wrapf(err, "in accounts file %s at line %d", filename, line)
+		}
+		la.accounts[common.Ckey(account.Key)] = account
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return la, nil
+}
+
+func parseLocalAccount(line string) (localAccount, error) {
+	parts := strings.Split(line, ":")
+	if len(parts) != 4 {
+		return localAccount{}, fmt.Errorf("expected four fields, not %d", len(parts))
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return localAccount{}, fmt.Errorf("invalid iteration count %q", parts[1])
+	}
+	salt, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return localAccount{}, errors.Wrap(err, "invalid salt")
+	}
+	hash, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return localAccount{}, errors.Wrap(err, "invalid hash")
+	}
+	return localAccount{Key: parts[0], Iterations: iterations, Salt: salt, Hash: hash}, nil
+}
+
+func (a localAccount) String() string {
+	return fmt.Sprintf("%s:%d:%s:%s", a.Key, a.Iterations, hex.EncodeToString(a.Salt), hex.EncodeToString(a.Hash))
+}
+
+// PBKDF2 with HMAC-SHA256, producing a single block of output
+func hashPassword(password string, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, []byte(password))
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+	result := append([]byte{}, u...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+func (la *LocalAccounts) Login(username, password string) (string, error) {
+	la.lock.Lock()
+	account, found := la.accounts[common.Ckey(username)]
+	la.lock.Unlock()
+	if !found {
+		// take as long as checking a real password would, so that nobody can tell which usernames exist
+		hashPassword(password, make([]byte, passwordSaltSize), passwordIterations)
+		return "", errors.New("incorrect username or password")
+	}
+	hash := hashPassword(password, account.Salt, account.Iterations)
+	if subtle.ConstantTimeCompare(hash, account.Hash) != 1 {
+		return "", errors.New("incorrect username or password")
+	}
+	return account.Key, nil
+}
+
+func validateKey(key string) error {
+	if len(key) > maxKeyLength {
+		return fmt.Errorf("username must be at most %d characters long", maxKeyLength)
+	}
+	if len(common.Ckey(key)) < 3 {
+		return errors.New("username must contain at least three letters or digits")
+	}
+	for _, r := range key {
+		if !(r == ' ' || r == '-' || r == '_' || r == '@' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			return fmt.Errorf("username cannot contain %q", r)
+		}
+	}
+	if strings.TrimSpace(key) != key {
+		return errors.New("username cannot start or end with a space")
+	}
+	return nil
+}
+
+func (la *LocalAccounts) Register(username, password string) (string, error) {
+	if err := validateKey(username); err != nil {
+		return "", err
+	}
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters long", minPasswordLength)
+	}
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	account := localAccount{
+		Key:        username,
+		Iterations: passwordIterations,
+		Salt:       salt,
+		Hash:       hashPassword(password, salt, passwordIterations),
+	}
+	la.lock.Lock()
+	defer la.lock.Unlock()
+	ckey := common.Ckey(username)
+	if _, found := la.accounts[ckey]; found {
+		return "", errors.New("that username is already taken")
+	}
+	la.accounts[ckey] = account
+	if err := la.save(); err != nil {
+		delete(la.accounts, ckey)
+		return "", errors.Wrap(err, "cannot save accounts")
+	}
+	return account.Key, nil
+}
+
+// MUST be called with the lock held. the file is replaced all at once, so that it is never left half-written.
+func (la *LocalAccounts) save() error {
+	var out strings.Builder
+	for _, account := range la.accounts {
+		out.WriteString(account.String())
+		out.WriteString("\n")
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(la.path), ".accounts")
+	if err != nil {
+		return err
+	}
+	if _, err := temp.WriteString(out.String()); err != nil {
+		_ = temp.Close()
+		_ = os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		_ = os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), la.path)
+}
@@ -0,0 +1,43 @@
+package websession
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalAccounts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	filename := filepath.Join(dir, "accounts.txt")
+
+	accounts, err := LoadLocalAccounts(filename)
+	assert.NoError(t, err)
+	key, err := accounts.Register("Some Player", "correct horse")
+	assert.NoError(t, err)
+	assert.Equal(t, "Some Player", key)
+	_, err = accounts.Register("someplayer", "another password")
+	assert.Error(t, err)
+	_, err = accounts.Register("Other Player", "short")
+	assert.Error(t, err)
+	_, err = accounts.Register("a:b", "a long password")
+	assert.Error(t, err)
+
+	// accounts persist, and are looked up by ckey
+	accounts, err = LoadLocalAccounts(filename)
+	assert.NoError(t, err)
+	key, err = accounts.Login("someplayer", "correct horse")
+	assert.NoError(t, err)
+	assert.Equal(t, "Some Player", key)
+	_, err = accounts.Login("Some Player", "wrong password")
+	assert.Error(t, err)
+	_, err = accounts.Login("Nobody", "correct horse")
+	assert.Error(t, err)
+}
@@ -6,13 +6,18 @@ import (
 )
 
 var resourcePack = flag.String("pack", "resource_pack.tgz", "the path to the game's resource pack")
+var accounts = flag.String("accounts", "", "the path to a file of player accounts; if set, players must log in")
 var parsed = false
 
-func FindResourcePack() string {
+func parseFlags() {
 	if !parsed {
 		flag.Parse()
 		parsed = true
 	}
+}
+
+func FindResourcePack() string {
+	parseFlags()
 	return *resourcePack
 }
 
@@ -20,13 +25,26 @@ func LoadResourcePack() (*resourcepack.ResourcePack, error) {
 	return resourcepack.Load(FindResourcePack())
 }
 
+// returns nil if players should connect as guests
+func AuthenticatorFromFlags() Authenticator {
+	parseFlags()
+	if *accounts == "" {
+		return nil
+	}
+	auth, err := LoadLocalAccounts(*accounts)
+	if err != nil {
+		panic("error loading accounts: " + err.Error())
+	}
+	return auth
+}
+
 // does not return
 func LaunchServerFromFlags(api WorldAPI) {
 	pack, err := LoadResourcePack()
 	if err != nil {
 		panic("error loading resource pack: " + err.Error())
 	}
-	err = LaunchServer(api, pack)
+	err = LaunchServer(api, pack, AuthenticatorFromFlags())
 	if err != nil {
 		panic("error in server: " + err.Error())
 	}
@@ -1,11 +1,14 @@
 package websession
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"github.com/celskeggs/mediator/resourcepack"
 	"github.com/celskeggs/mediator/util"
 	"github.com/celskeggs/mediator/webclient"
 	"github.com/celskeggs/mediator/webclient/sprite"
+	"github.com/pkg/errors"
 	"time"
 )
 
@@ -14,23 +17,125 @@ type worldServer struct {
 	SingleThread       *util.SingleThread
 	Subscribers        map[chan struct{}]struct{}
 	LoadedResourcePack *resourcepack.ResourcePack
+	// if nil, players connect as guests
+	Auth Authenticator
+	// the latest session for each player who is connected or might still reconnect, by token
+	Sessions map[string]*worldSession
 }
 
 func (ws worldServer) ResourcePack() *resourcepack.ResourcePack {
 	return ws.LoadedResourcePack
 }
 
-func (ws worldServer) Connect() webclient.ServerSession {
+func (ws worldServer) DynamicResource(name string) (resource resourcepack.Resource, found bool) {
+	ws.SingleThread.Run("DynamicResource()", func() {
+		resource, found = ws.World.DynamicResource(name)
+	})
+	return resource, found
+}
+
+func (ws worldServer) LoginRequired() bool {
+	return ws.Auth != nil
+}
+
+func (ws worldServer) Login(username, password string, register bool) (string, error) {
+	if ws.Auth == nil {
+		return "", errors.New("this server does not have accounts")
+	}
+	if register {
+		return ws.Auth.Register(username, password)
+	}
+	return ws.Auth.Login(username, password)
+}
+
+// how long a disconnected player has to reconnect before they are logged out
+const ReconnectGracePeriod = time.Minute
+
+func newSessionToken() string {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		panic("cannot generate session token: " + err.Error())
+	}
+	return hex.EncodeToString(token)
+}
+
+// MUST be called from SingleThread context
+func (ws worldServer) resumable(key string, resume string) *worldSession {
+	if previous, found := ws.Sessions[resume]; found && previous.Key == key && previous.Player.IsValid() {
+		return previous
+	}
+	if key != "" {
+		// the same player connecting again from somewhere else takes over their existing session
+		for _, previous := range ws.Sessions {
+			if previous.Key == key && previous.Player.IsValid() {
+				return previous
+			}
+		}
+	}
+	return nil
+}
+
+// the most updates that a session keeps for replaying; a client that falls further behind than this loses the oldest
+const ReplayLimit = 1000
+
+// the updates sent to a session that the client has not yet acknowledged, kept so that they can be sent again if the
+// client reconnects. deltas are not kept, since a new connection starts from a keyframe.
+type replayBuffer struct {
+	lastSerial uint64
+	unacked    []sprite.ViewUpdate
+}
+
+// MUST be called from SingleThread context. numbers the update and keeps whatever in it would be lost on reconnect.
+func (rb *replayBuffer) record(update *sprite.ViewUpdate) {
+	rb.lastSerial += 1
+	update.Serial = rb.lastSerial
+	kept := *update
+	kept.Delta = nil
+	// flicks are only worth seeing as they happen
+	kept.Flicks = nil
+	if !kept.IsEmpty() {
+		if len(rb.unacked) >= ReplayLimit {
+			rb.unacked = rb.unacked[1:]
+		}
+		rb.unacked = append(rb.unacked, kept)
+	}
+}
+
+// MUST be called from SingleThread context
+func (rb *replayBuffer) acknowledge(serial uint64) {
+	i := 0
+	for i < len(rb.unacked) && rb.unacked[i].Serial <= serial {
+		i++
+	}
+	rb.unacked = rb.unacked[i:]
+}
+
+func (ws worldServer) Connect(key string, resume string, received uint64) webclient.ServerSession {
 	subscription := make(chan struct{}, 1)
 	session := &worldSession{
 		WS:           ws,
+		Key:          key,
 		Active:       true,
 		Subscription: subscription,
 	}
-	ws.SingleThread.Run("AddPlayer()", func() {
-		util.FIXME("actually populate the key, rather than always using a Guest")
-		session.Player = ws.World.AddPlayer("")
+	ws.SingleThread.Run("Connect()", func() {
+		if previous := ws.resumable(key, resume); previous != nil {
+			// the previous connection might not have noticed that it was dropped yet
+			previous.removeSubscription()
+			session.Player, session.SessionToken = previous.Player, previous.SessionToken
+			session.Player.Resumed()
+			session.Replay = previous.Replay
+			session.Replay.acknowledge(received)
+			session.Resend = append([]sprite.ViewUpdate(nil), session.Replay.unacked...)
+		} else {
+			session.Player = ws.World.AddPlayer(key)
+			session.SessionToken = newSessionToken()
+			session.Replay = &replayBuffer{}
+		}
+		ws.Sessions[session.SessionToken] = session
 		ws.Subscribers[subscription] = struct{}{}
+		// make sure that the new connection is sent a view even if nothing changes in the world
+		subscription <- struct{}{}
 	})
 	return session
 }
@@ -38,8 +143,23 @@ func (ws worldServer) Connect() webclient.ServerSession {
 type worldSession struct {
 	WS           worldServer
 	Player       PlayerAPI
+	Key          string
+	SessionToken string
 	Active       bool
 	Subscription chan struct{}
+	// shared by every connection to the same session
+	Replay *replayBuffer
+	// the updates to send again before anything new, when the session is resumed
+	Resend []sprite.ViewUpdate
+}
+
+func (ws *worldSession) Token() string {
+	return ws.SessionToken
+}
+
+// MUST be called from SingleThread context. false if another connection has taken over this session.
+func (ws *worldSession) isCurrent() bool {
+	return ws.WS.Sessions[ws.SessionToken] == ws
 }
 
 // MUST be called from SingleThread context
@@ -58,10 +178,25 @@ func (ws *worldSession) Close() {
 	ws.Active = false
 	ws.WS.SingleThread.Run("Close()", func() {
 		ws.removeSubscription()
-		ws.Player.Remove()
+		if ws.isCurrent() {
+			// the player's client stays in the world for a while, collecting text and sounds, in case they reconnect
+			time.AfterFunc(ReconnectGracePeriod, func() {
+				ws.WS.SingleThread.Run("Expire()", ws.expire)
+			})
+		}
 	})
 }
 
+// MUST be called from SingleThread context
+func (ws *worldSession) expire() {
+	if ws.isCurrent() {
+		delete(ws.WS.Sessions, ws.SessionToken)
+		if ws.Player.IsValid() {
+			ws.Player.Remove()
+		}
+	}
+}
+
 var totalTimeSpent time.Duration = 0
 var countTimeSpent = 0
 
@@ -70,7 +205,8 @@ func (e *worldSession) OnMessage(cmd webclient.Command) {
 		e.WS.SingleThread.Run("OnMessage()", func() {
 			if !e.Player.IsValid() {
 				e.removeSubscription()
-			} else {
+			} else if e.isCurrent() {
+				e.Replay.acknowledge(cmd.Received)
 				start := time.Now()
 				e.Player.Command(cmd)
 				total := time.Now().Sub(start)
@@ -82,40 +218,39 @@ func (e *worldSession) OnMessage(cmd webclient.Command) {
 	}
 }
 
+// the number of deltas sent between keyframes
+const KeyframeInterval = 600
+
 func (e *worldSession) BeginSend(send func(update *sprite.ViewUpdate) error) {
 	go func() {
 		defer func() {
 			_ = send(nil)
 		}()
-		var sv sprite.SpriteView
-		var lines []string
-		var sounds []sprite.Sound
-		var flicks []sprite.Flick
-		first := true
+		for i := range e.Resend {
+			if send(&e.Resend[i]) != nil {
+				return
+			}
+		}
+		encoder := sprite.NewViewEncoder(KeyframeInterval)
 		for range e.Subscription {
-			diff := false
+			var vup sprite.ViewUpdate
 			e.WS.SingleThread.Run("Render()", func() {
-				sv2 := e.Player.Render()
-				if !sv.Equal(sv2) {
-					diff = true
-					sv = sv2
+				// a connection that has been taken over must not take updates meant for the one that replaced it
+				if !e.isCurrent() {
+					return
+				}
+				delta := encoder.Encode(e.Player.Render())
+				vup = e.Player.PullRequests()
+				vup.Delta = delta
+				if !vup.IsEmpty() {
+					e.Replay.record(&vup)
 				}
-				lines, sounds, flicks = e.Player.PullRequests()
 			})
-			vup := sprite.ViewUpdate{
-				TextLines: lines,
-				Sounds:    sounds,
-				Flicks:    flicks,
-			}
-			if diff || first {
-				vup.NewState = &sv
-			}
-			if vup.TextLines != nil || vup.NewState != nil {
+			if !vup.IsEmpty() {
 				if send(&vup) != nil {
 					break
 				}
 			}
-			first = false
 		}
 	}()
 }
@@ -149,13 +284,16 @@ func (ws *worldServer) Ticker(fps int) {
 	}()
 }
 
-func LaunchServer(world WorldAPI, pack *resourcepack.ResourcePack) error {
+// if auth is nil, anyone can connect as a guest
+func LaunchServer(world WorldAPI, pack *resourcepack.ResourcePack, auth Authenticator) error {
 	// TODO: teardown for SingleThread and our subscriber?
 	ws := worldServer{
 		World:              world,
 		SingleThread:       util.NewSingleThread(),
 		Subscribers:        make(map[chan struct{}]struct{}),
+		Sessions:           make(map[string]*worldSession),
 		LoadedResourcePack: pack,
+		Auth:               auth,
 	}
 	updates := world.SubscribeToUpdates()
 	if updates == nil {
@@ -0,0 +1,185 @@
+package websession
+
+import (
+	"github.com/celskeggs/mediator/resourcepack"
+	"github.com/celskeggs/mediator/util"
+	"github.com/celskeggs/mediator/webclient"
+	"github.com/celskeggs/mediator/webclient/sprite"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type testPlayer struct {
+	Key     string
+	Removed bool
+	// text waiting to be pulled
+	Text    []string
+	Resumes int
+}
+
+func (p *testPlayer) Remove() {
+	p.Removed = true
+}
+
+func (p *testPlayer) IsValid() bool {
+	return !p.Removed
+}
+
+func (p *testPlayer) Command(cmd webclient.Command) {
+}
+
+func (p *testPlayer) Resumed() {
+	p.Resumes++
+}
+
+func (p *testPlayer) Render() sprite.SpriteView {
+	return sprite.SpriteView{}
+}
+
+func (p *testPlayer) PullRequests() sprite.ViewUpdate {
+	update := sprite.ViewUpdate{TextLines: p.Text}
+	p.Text = nil
+	return update
+}
+
+type testWorld struct {
+	Players []*testPlayer
+}
+
+func (w *testWorld) AddPlayer(key string) PlayerAPI {
+	player := &testPlayer{Key: key}
+	w.Players = append(w.Players, player)
+	return player
+}
+
+func (w *testWorld) Tick() {
+}
+
+func (w *testWorld) SubscribeToUpdates() <-chan struct{} {
+	return nil
+}
+
+func (w *testWorld) DynamicResource(name string) (resourcepack.Resource, bool) {
+	return resourcepack.Resource{}, false
+}
+
+func newTestServer() (worldServer, *testWorld) {
+	world := &testWorld{}
+	return worldServer{
+		World:        world,
+		SingleThread: util.NewSingleThread(),
+		Subscribers:  map[chan struct{}]struct{}{},
+		Sessions:     map[string]*worldSession{},
+	}, world
+}
+
+func TestResumeSession(t *testing.T) {
+	ws, world := newTestServer()
+	first := ws.Connect("", "", 0).(*worldSession)
+	first.Close()
+	assert.False(t, world.Players[0].Removed)
+
+	second := ws.Connect("", first.Token(), 0).(*worldSession)
+	assert.Equal(t, first.Token(), second.Token())
+	assert.Len(t, world.Players, 1)
+	assert.Equal(t, first.Player, second.Player)
+	assert.Equal(t, 1, world.Players[0].Resumes)
+
+	// the first session's grace period ending has no effect once the session has been resumed
+	ws.SingleThread.Run("expire", first.expire)
+	assert.False(t, world.Players[0].Removed)
+
+	second.Close()
+	ws.SingleThread.Run("expire", second.expire)
+	assert.True(t, world.Players[0].Removed)
+
+	third := ws.Connect("", second.Token(), 0)
+	assert.NotEqual(t, second.Token(), third.Token())
+	assert.Len(t, world.Players, 2)
+}
+
+func TestTakeOverSession(t *testing.T) {
+	ws, world := newTestServer()
+	first := ws.Connect("Player", "", 0).(*worldSession)
+	// a different player cannot use someone else's token
+	other := ws.Connect("Other", first.Token(), 0).(*worldSession)
+	assert.NotEqual(t, first.Player, other.Player)
+
+	// the same player connecting from elsewhere takes over their session, even while it is still connected
+	second := ws.Connect("Player", "", 0).(*worldSession)
+	assert.Equal(t, first.Player, second.Player)
+	assert.Equal(t, 1, world.Players[0].Resumes)
+	assert.Len(t, world.Players, 2)
+	_, subscribed := ws.Subscribers[first.Subscription]
+	assert.False(t, subscribed)
+
+	first.Close()
+	ws.SingleThread.Run("expire", first.expire)
+	assert.False(t, world.Players[0].Removed)
+}
+
+// starts sending a session's updates, and returns the channel that they arrive on
+func receive(session *worldSession) <-chan sprite.ViewUpdate {
+	updates := make(chan sprite.ViewUpdate, 10)
+	session.BeginSend(func(update *sprite.ViewUpdate) error {
+		if update != nil {
+			updates <- *update
+		}
+		return nil
+	})
+	return updates
+}
+
+func say(ws worldServer, session *worldSession, text string) {
+	ws.SingleThread.Run("say", func() {
+		player := session.Player.(*testPlayer)
+		player.Text = append(player.Text, text)
+		session.Subscription <- struct{}{}
+	})
+}
+
+func TestReplayUnacknowledged(t *testing.T) {
+	ws, _ := newTestServer()
+	first := ws.Connect("", "", 0).(*worldSession)
+	firstUpdates := receive(first)
+	update := <-firstUpdates
+	assert.Equal(t, uint64(1), update.Serial)
+	say(ws, first, "hello")
+	update = <-firstUpdates
+	assert.Equal(t, uint64(2), update.Serial)
+	assert.Equal(t, []string{"hello"}, update.TextLines)
+	say(ws, first, "world")
+	assert.Equal(t, []string{"world"}, (<-firstUpdates).TextLines)
+	first.OnMessage(webclient.Command{Received: 2})
+	first.Close()
+
+	// the client only acknowledged "hello", so "world" is sent again before anything new
+	second := ws.Connect("", first.Token(), 2).(*worldSession)
+	secondUpdates := receive(second)
+	update = <-secondUpdates
+	assert.Equal(t, uint64(3), update.Serial)
+	assert.Equal(t, []string{"world"}, update.TextLines)
+	assert.Nil(t, update.Delta)
+	update = <-secondUpdates
+	assert.Equal(t, uint64(4), update.Serial)
+	assert.NotNil(t, update.Delta)
+	assert.Nil(t, update.TextLines)
+	second.Close()
+
+	third := ws.Connect("", first.Token(), 4).(*worldSession)
+	assert.Empty(t, third.Resend)
+}
+
+func TestReplayLimit(t *testing.T) {
+	rb := &replayBuffer{}
+	for i := 0; i < ReplayLimit+5; i++ {
+		rb.record(&sprite.ViewUpdate{TextLines: []string{"line"}})
+	}
+	// updates with nothing worth replaying are numbered, but not kept
+	rb.record(&sprite.ViewUpdate{Delta: &sprite.ViewDelta{}})
+	assert.Len(t, rb.unacked, ReplayLimit)
+	assert.Equal(t, uint64(6), rb.unacked[0].Serial)
+	assert.Equal(t, uint64(ReplayLimit+6), rb.lastSerial)
+	rb.acknowledge(ReplayLimit)
+	assert.Len(t, rb.unacked, 5)
+}
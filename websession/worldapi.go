@@ -1,6 +1,7 @@
 package websession
 
 import (
+	"github.com/celskeggs/mediator/resourcepack"
 	"github.com/celskeggs/mediator/webclient"
 	"github.com/celskeggs/mediator/webclient/sprite"
 )
@@ -12,7 +13,11 @@ type PlayerAPI interface {
 	IsValid() bool
 	Command(cmd webclient.Command)
 	Render() sprite.SpriteView
-	PullRequests() (lines []string, sounds []sprite.Sound, flicks []sprite.Flick)
+	// everything other than the view that has been sent to the player since the last call
+	PullRequests() sprite.ViewUpdate
+	// called when a new connection takes over the player's session, which might be a page that has never seen what the
+	// player was in the middle of, like their unanswered prompts
+	Resumed()
 }
 
 type WorldAPI interface {
@@ -20,4 +25,5 @@ type WorldAPI interface {
 	Tick()
 	// only a single call to SubscribeToUpdates needs to be supported by the WorldAPI
 	SubscribeToUpdates() <-chan struct{}
+	DynamicResource(name string) (resourcepack.Resource, bool)
 }